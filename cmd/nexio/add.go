@@ -40,6 +40,9 @@ var addCmd = &cobra.Command{
 		}
 
 		Debug("Processing %d files", len(filePaths))
+		if RefuseDuringBisect() {
+			return
+		}
 
 		results := make([]AddResult, 0, len(filePaths))
 		for _, filePath := range filePaths {
@@ -52,6 +55,19 @@ var addCmd = &cobra.Command{
 
 func runAddCommand(filePath string, force bool) AddResult {
 	result := AddResult{FilePath: filePath}
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		result.ReturnCode = 005
+		return result
+	}
+	defer release()
+
+	if IsBisecting() {
+		result.ReturnCode = 006
+		return result
+	}
+
 	returnCode := runAddCommandInternal(filePath, force, &result)
 	result.ReturnCode = returnCode
 	return result
@@ -72,10 +88,32 @@ func runAddCommandInternal(filePath string, force bool, _ *AddResult) int {
 		}
 	}
 
-	_, fileName := ParsePath(filePath)
 	generatedId := GenRandHex(20)
 	Debug("Generated ID for file: %s", generatedId)
 
+	// Adding a conflicting file marks it resolved, it is then staged like any other change.
+	if conflicted, id, _ := LogEntryLookup("CON", filePath); conflicted {
+		Debug("Resolving conflict: %s", filePath)
+		if err := RemoveConflict(id); err != nil {
+			Debug("Error removing conflict: %s", err.Error())
+			MustSucceed(err, "operation failed")
+		}
+	}
+
+	// The old path of a staged move only needs staging if a file was created there again.
+	if renamed, _ := RenameSourceLookup(filePath); renamed && !IsFileStaged(filePath) {
+		if !FileExists(filePath) {
+			Debug("File was moved, the move is staged: %s", filePath)
+			return 114
+		}
+		Debug("File was moved and created again, staging as added")
+		if err := StageAndLog(generatedId, filePath, "added"); err != nil {
+			Debug("Error staging file: %s", err.Error())
+			MustSucceed(err, "operation failed")
+		}
+		return 112
+	}
+
 	fileStaged := IsFileStaged(filePath)
 	if fileStaged {
 		Debug("File is already staged: %s", filePath)
@@ -90,7 +128,7 @@ func runAddCommandInternal(filePath string, force bool, _ *AddResult) int {
 				}
 				return 101
 			}
-			modified, err := IsModified(filePath, dirs.StagingAdded+id+"/"+fileName)
+			modified, err := IsModifiedFromStaged(filePath, "added", id)
 			if err != nil {
 				Debug("Error checking if file is modified: %s", err.Error())
 				MustSucceed(err, "operation failed")
@@ -117,7 +155,7 @@ func runAddCommandInternal(filePath string, force bool, _ *AddResult) int {
 				LogOperation(generatedId, "REM", filePath)
 				return 104
 			}
-			modified, err := IsModified(filePath, dirs.StagingModified+id+"/"+fileName)
+			modified, err := IsModifiedFromStaged(filePath, "modified", id)
 			if err != nil {
 				Debug("Error checking if file is modified: %s", err.Error())
 				MustSucceed(err, "operation failed")
@@ -142,7 +180,7 @@ func runAddCommandInternal(filePath string, force bool, _ *AddResult) int {
 					MustSucceed(err, "operation failed")
 				}
 				_, commitId, fileId := GetFileMetadata(filePath)
-				modified, err := IsModified(filePath, dirs.Commits+commitId+"/"+fileId+"/"+fileName)
+				modified, err := IsModifiedFromObject(filePath, commitId, fileId)
 				if err != nil {
 					Debug("Error checking if file is modified: %s", err.Error())
 					MustSucceed(err, "operation failed")
@@ -162,13 +200,45 @@ func runAddCommandInternal(filePath string, force bool, _ *AddResult) int {
 				return 108
 			}
 		}
+		renamed, rename := RenameLookup(filePath)
+		if renamed {
+			if !exists {
+				Debug("File was moved but no longer exists, staging the removal of its old path")
+				if err := RemoveFileAndLog(rename.Id, "renamed"); err != nil {
+					Debug("Error removing file from staging: %s", err.Error())
+					MustSucceed(err, "operation failed")
+				}
+				_, commitId, fileId := GetFileMetadata(rename.From)
+				if err := StageRemoval(generatedId, rename.From, commitId, fileId); err != nil {
+					Debug("Error adding file to staging: %s", err.Error())
+					MustSucceed(err, "operation failed")
+				}
+				LogOperation(generatedId, "REM", rename.From)
+				return 117
+			}
+			modified, err := IsModifiedFromStaged(filePath, "renamed", rename.Id)
+			if err != nil {
+				Debug("Error checking if file is modified: %s", err.Error())
+				MustSucceed(err, "operation failed")
+			}
+			if modified {
+				Debug("File was moved and changed, updating staging")
+				if err := AddToStaging(rename.Id, filePath, "renamed"); err != nil {
+					Debug("Error adding file to staging: %s", err.Error())
+					MustSucceed(err, "operation failed")
+				}
+				return 115
+			}
+			Debug("File was moved but not changed")
+			return 116
+		}
 	} else {
 		Debug("File is not staged, checking commit status")
 		isCommitted, commitId, fileId := GetFileMetadata(filePath)
 		isDeleted := IsFileDeleted(filePath)
 		if isDeleted {
 			Debug("File was committed but deleted, staging for removal")
-			if err := AddToStaging(generatedId, dirs.Commits+commitId+"/"+fileId+"/"+fileName, "removed"); err != nil {
+			if err := StageRemoval(generatedId, filePath, commitId, fileId); err != nil {
 				Debug("Error adding file to staging: %s", err.Error())
 				MustSucceed(err, "operation failed")
 			}
@@ -177,7 +247,7 @@ func runAddCommandInternal(filePath string, force bool, _ *AddResult) int {
 		}
 
 		if isCommitted {
-			modified, err := IsModified(filePath, dirs.Commits+commitId+"/"+fileId+"/"+fileName)
+			modified, err := IsModifiedFromObject(filePath, commitId, fileId)
 			if err != nil {
 				Debug("Error checking if file is modified: %s", err.Error())
 				MustSucceed(err, "operation failed")
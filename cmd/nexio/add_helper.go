@@ -10,17 +10,29 @@ func AddToStaging(id string, path string, op string) error {
 	Debug("Adding file to staging: id=%s, path=%s, op=%s", id, path, op)
 	_, file := ParsePath(path)
 
-	if err := os.MkdirAll(dirs.Staging+op+"/"+id, 0755); err != nil {
-		Debug("Failed to create staging directory")
-		MustSucceed(err, "operation failed")
+	data, mode, err := ReadFileWithMode(path)
+	if err != nil {
+		return err
 	}
-	if err := CopyFile(path, dirs.Staging+op+"/"+id+"/"+file); err != nil {
+	if err := store.WriteStagedFile(op, id, file, data, mode); err != nil {
 		return err
 	}
 	Debug("File added to staging successfully")
 	return nil
 }
 
+// StageRemoval stages the committed version of a file deleted from the working directory.
+func StageRemoval(id string, path string, commitId string, fileId string) error {
+	Debug("Staging removal: id=%s, path=%s, commit=%s", id, path, commitId)
+	_, file := ParsePath(path)
+
+	data, mode, err := store.ReadObject(commitId, fileId, file)
+	if err != nil {
+		return err
+	}
+	return store.WriteStagedFile("removed", id, file, data, mode)
+}
+
 func DisplayAddResults(results []AddResult) {
 	if len(results) == 0 {
 		Debug("Results length is 0.")
@@ -36,11 +48,11 @@ func DisplayAddResults(results []AddResult) {
 			added = append(added, r.FilePath)
 		case 109: // File deleted from filesystem (committed file)
 			removed = append(removed, r.FilePath)
-		case 102, 105, 107: // Staged file updated
+		case 102, 105, 107, 115: // Staged file updated
 			updated = append(updated, r.FilePath)
-		case 101, 104: // File deleted from filesystem (was staged)
+		case 101, 104, 117: // File deleted from filesystem (was staged)
 			removed = append(removed, r.FilePath)
-		case 103, 106, 108, 113: // File already staged or restored to original state
+		case 103, 106, 108, 113, 114, 116: // File already staged or restored to original state
 			alreadyStaged = append(alreadyStaged, r.FilePath)
 		case 111: // File not modified
 			notModified = append(notModified, r.FilePath)
@@ -92,7 +104,12 @@ func DisplayAddResults(results []AddResult) {
 
 func RemoveFileAndLog(id string, op string) error {
 	Debug("Removing file and log entry: id=%s, op=%s", id, op)
-	RemoveFile(dirs.Staging + op + "/" + id)
+	if op == StagingOps["CON"] {
+		return RemoveConflict(id)
+	}
+	if err := store.RemoveStagedFile(op, id); err != nil {
+		return err
+	}
 	RemoveLogEntry(id)
 	return nil
 }
@@ -140,7 +157,7 @@ func ExpandFilePaths(args []string) ([]string, error) {
 
 			stagedFiles := GetStagingLogsContent()
 			for _, entry := range *stagedFiles {
-				if entry.Op == "ADD" || entry.Op == "MOD" {
+				if entry.Op == "ADD" || entry.Op == "MOD" || entry.Op == "REN" {
 					if !FileExists(entry.Path) {
 						Debug("Found staged file that no longer exists: %s", entry.Path)
 						filePaths = append(filePaths, entry.Path)
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(amCmd)
+}
+
+var amCmd = &cobra.Command{
+	Use:     "am",
+	Short:   "Apply patches from mails and commit them with their author",
+	Example: "nexio am 0001-fix-the-parser.patch\nnexio am outgoing/*.patch",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting am command: patches=%v", args)
+		runAmCommand(args)
+	},
+}
+
+func runAmCommand(paths []string) (returnCode int, commits []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006, nil
+	}
+
+	// The staged changes would end up in the first commit.
+	if !IsStagingLogsEmpty() {
+		Debug("%s", AM_RETURN_CODES[2502])
+		Fail(AM_RETURN_CODES[2502])
+		return 2502, nil
+	}
+
+	patches := []Patch{}
+	for _, path := range paths {
+		read, returnCode := readPatchesOrFail(path)
+		if returnCode == 2504 {
+			Fail(AM_RETURN_CODES[2504] + " " + path)
+			return 2504, nil
+		}
+		if returnCode == 2506 {
+			Fail(AM_RETURN_CODES[2506] + " " + path)
+			return 2506, nil
+		}
+		patches = append(patches, read...)
+	}
+	if len(patches) == 0 {
+		Warning(AM_RETURN_CODES[2505])
+		return 2505, nil
+	}
+
+	BreakLine()
+	for _, patch := range patches {
+		subject := FirstLine(patch.Message)
+		files := NewPatchedFiles()
+		results := []FileApplyResult{}
+		clean := true
+		for _, filePatch := range patch.Files {
+			result := files.Apply(filePatch)
+			results = append(results, result)
+			clean = clean && result.Clean()
+		}
+		if !clean {
+			Fail(AM_RETURN_CODES[2503] + " " + subject)
+			Tree(describePatchResults(results, false), false)
+			Text("Fix the patch or apply it with "+Code("nexio apply")+" to see the rejected hunks", "")
+			BreakLine()
+			return 2503, commits
+		}
+		if err := files.Write(); err != nil {
+			Debug("Failed to write patched files")
+			MustSucceed(err, "operation failed")
+		}
+		stageFiles(files.Paths())
+		if IsStagingLogsEmpty() {
+			Warning("Patch changes nothing, skipped: " + subject)
+			continue
+		}
+
+		author, message := patch.Author, patch.Message
+		if author.Name == "" && author.Email == "" {
+			author = GetConfigAuthor()
+		}
+		if message == "" {
+			message = "Apply patch"
+		}
+		_, commitId := commitStagedChanges(CommitMetadata{Author: author, Message: message})
+		commits = append(commits, commitId)
+		Text(StyledCommit(commitId)+" "+subject, "  ")
+	}
+	Success(AM_RETURN_CODES[2501] + " " + FormatFileCount(len(commits)))
+	BreakLine()
+	return 2501, commits
+}
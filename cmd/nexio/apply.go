@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	applyCmd.Flags().BoolVar(&ApplyCheck, "check", false, "Only check whether the patch applies, change nothing")
+	applyCmd.Flags().BoolVarP(&ApplyStage, "stage", "s", false, "Stage the patched files")
+
+	rootCmd.AddCommand(applyCmd)
+}
+
+var (
+	ApplyCheck bool
+	ApplyStage bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:     "apply",
+	Short:   "Apply a patch to the working directory",
+	Example: "nexio apply fix.patch\nnexio apply --check 0001-fix-the-parser.patch\nnexio apply --stage changes.diff",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting apply command: patch=%s, check=%t, stage=%t", args[0], ApplyCheck, ApplyStage)
+		runApplyCommand(args[0], ApplyCheck, ApplyStage)
+	},
+}
+
+func runApplyCommand(path string, check bool, stage bool) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	mode := ExclusiveLock
+	if check {
+		mode = SharedLock
+	}
+	release, err := AcquireRepoLock(mode)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if !check && RefuseDuringBisect() {
+		return 006
+	}
+
+	patches, returnCode := readPatchesOrFail(path)
+	if returnCode != 0 {
+		if returnCode == 2504 {
+			Fail(APPLY_RETURN_CODES[2405] + " " + path)
+			return 2405
+		}
+		if returnCode == 2506 {
+			Fail(APPLY_RETURN_CODES[2407] + " " + path)
+			return 2407
+		}
+		Warning(APPLY_RETURN_CODES[2406])
+		return 2406
+	}
+
+	files := NewPatchedFiles()
+	results := []FileApplyResult{}
+	for _, patch := range patches {
+		for _, filePatch := range patch.Files {
+			results = append(results, files.Apply(filePatch))
+		}
+	}
+	clean := true
+	for _, result := range results {
+		clean = clean && result.Clean()
+	}
+
+	BreakLine()
+	if check {
+		if clean {
+			Success(APPLY_RETURN_CODES[2402])
+			returnCode = 2402
+		} else {
+			Fail(APPLY_RETURN_CODES[2403])
+			returnCode = 2403
+		}
+		Tree(describePatchResults(results, false), false)
+		BreakLine()
+		return returnCode
+	}
+
+	if err := files.Write(); err != nil {
+		Debug("Failed to write patched files")
+		MustSucceed(err, "operation failed")
+	}
+	for _, result := range results {
+		if rejected := result.Rejected(); result.Error == "" && len(rejected) > 0 {
+			if err := os.WriteFile(result.Patch.Path()+".rej", []byte(FormatRejects(result)), 0644); err != nil {
+				Debug("Failed to write rejects")
+				MustSucceed(err, "operation failed")
+			}
+		}
+	}
+	if stage {
+		stageFiles(files.Paths())
+	}
+
+	if clean {
+		Success(APPLY_RETURN_CODES[2401])
+		returnCode = 2401
+	} else {
+		Warning(APPLY_RETURN_CODES[2404])
+		returnCode = 2404
+	}
+	Tree(describePatchResults(results, true), false)
+	BreakLine()
+	return returnCode
+}
+
+// readPatchesOrFail reads a patch file, returning 2504 if it cannot be read, 2505 if it holds no changes
+// and 2506 if it changes a path outside of the working tree.
+func readPatchesOrFail(path string) ([]Patch, int) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		Debug("Failed to read patch: %v", err)
+		return nil, 2504
+	}
+	patches, err := ParsePatches(data)
+	if err != nil {
+		Debug("Failed to parse patch: %v", err)
+		return nil, 2504
+	}
+	if len(patches) == 0 {
+		Debug("No changes found in patch: %s", path)
+		return nil, 2505
+	}
+	if err := ValidatePatchPaths(patches); err != nil {
+		Debug("Invalid path in patch: %v", err)
+		return nil, 2506
+	}
+	return patches, 0
+}
+
+// stageFiles stages the files as `nexio add` does. The caller holds the repository lock.
+func stageFiles(paths []string) {
+	for _, path := range paths {
+		// A new file deleted again leaves nothing to stage.
+		if !FileExists(path) && !IsFileStaged(path) {
+			if committed, _, _ := GetFileMetadata(path); !committed {
+				Debug("Nothing to stage for %s", path)
+				continue
+			}
+		}
+		returnCode := runAddCommandInternal(path, true, nil)
+		Debug("Staged file %s: %d", path, returnCode)
+	}
+}
+
+func describePatchResults(results []FileApplyResult, written bool) []string {
+	lines := []string{}
+	for _, result := range results {
+		path := result.Patch.Path()
+		if result.Error != "" {
+			lines = append(lines, path+"  "+result.Error)
+			continue
+		}
+		rejected := len(result.Rejected())
+		details := []string{}
+		for i, hunk := range result.Hunks {
+			if hunk.Applied && (hunk.Offset != 0 || hunk.Fuzz != 0) {
+				details = append(details, fmt.Sprintf("hunk %d at offset %d with fuzz %d", i+1, hunk.Offset, hunk.Fuzz))
+			}
+		}
+		switch {
+		case rejected > 0 && written:
+			details = append(details, fmt.Sprintf("%d of %d hunks rejected, see %s.rej", rejected, len(result.Hunks), path))
+		case rejected > 0:
+			details = append(details, fmt.Sprintf("%d of %d hunks do not apply", rejected, len(result.Hunks)))
+		case len(details) == 0:
+			details = append(details, "ok")
+		}
+		lines = append(lines, path+"  "+strings.Join(details, ", "))
+	}
+	return lines
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	archiveCmd.Flags().StringVarP(&ArchiveFormat, "format", "f", "", "Archive format: tar, tar.gz or zip (default from the output file name, else tar)")
+	archiveCmd.Flags().StringVarP(&ArchiveOutput, "output", "o", "", "File to write, the archive is written to stdout if omitted")
+	archiveCmd.Flags().StringVarP(&ArchivePrefix, "prefix", "p", "", "Directory to put in front of every path, e.g. project-1.0/")
+
+	rootCmd.AddCommand(archiveCmd)
+}
+
+var (
+	ArchiveFormat string
+	ArchiveOutput string
+	ArchivePrefix string
+)
+
+var archiveCmd = &cobra.Command{
+	Use:     "archive [flags] <rev> [path...]",
+	Short:   "Export the files of a commit as a tar or zip archive",
+	Example: "nexio archive HEAD -o release.tar.gz --prefix project-1.0/\nnexio archive main --format zip -o docs.zip docs/\nnexio archive <commit-id> > snapshot.tar",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting archive command: revision=%s, format=%s, output=%s, prefix=%s, paths=%v", args[0], ArchiveFormat, ArchiveOutput, ArchivePrefix, args[1:])
+		runArchiveCommand(args[0], ArchiveFormat, ArchiveOutput, ArchivePrefix, args[1:])
+	},
+}
+
+func runArchiveCommand(revision string, format string, output string, prefix string, paths []string) int {
+	// The archive goes to stdout without an output file, messages would end up inside it.
+	quiet := output == "" || output == "-"
+	fail := func(message string) {
+		if quiet {
+			fmt.Fprintln(os.Stderr, message)
+		} else {
+			Fail(message)
+		}
+	}
+
+	if initialized := IsInitialized(); !initialized {
+		fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if format == "" {
+		format = ArchiveFormatFromPath(output)
+	}
+	if !slices.Contains(ArchiveFormats, format) {
+		Debug("Unsupported archive format: %s", format)
+		fail(ARCHIVE_RETURN_CODES[2003])
+		return 2003
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	commit, err := ResolveRevision(store, revision)
+	if err != nil {
+		Debug("Failed to resolve revision: %v", err)
+		fail(ARCHIVE_RETURN_CODES[2002] + " " + err.Error())
+		return 2002
+	}
+	files, err := ArchiveFiles(store, commit.Id, paths)
+	if err != nil {
+		Debug("Failed to read file list of commit: %s", commit.Id)
+		MustSucceed(err, "operation failed")
+	}
+	if len(files) == 0 {
+		Debug("%s", ARCHIVE_RETURN_CODES[2004])
+		fail(ARCHIVE_RETURN_CODES[2004])
+		return 2004
+	}
+
+	var w io.Writer = os.Stdout
+	if !quiet {
+		file, err := os.Create(output)
+		if err != nil {
+			Debug("Failed to create output file: %v", err)
+			fail(ARCHIVE_RETURN_CODES[2005] + " " + err.Error())
+			return 2005
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := WriteArchive(w, store, format, files, prefix, ArchiveTime(commit)); err != nil {
+		Debug("Failed to write archive: %v", err)
+		if !quiet {
+			os.Remove(output)
+		}
+		fail(ARCHIVE_RETURN_CODES[2005] + " " + err.Error())
+		return 2005
+	}
+	if quiet {
+		return 2001
+	}
+
+	BreakLine()
+	Success(ARCHIVE_RETURN_CODES[2001])
+	Text("File: "+Code(output), "  ")
+	Text("Commit: "+StyledCommit(commit.Id), "  ")
+	Text(fmt.Sprintf("Files: %d", len(files)), "  ")
+	BreakLine()
+	return 2001
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ArchiveFormats = []string{"tar", "tar.gz", "zip"}
+
+// ArchiveFormatFromPath guesses the format from the extension of the output file, tar if it has none of ours.
+func ArchiveFormatFromPath(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(path, ".zip"):
+		return "zip"
+	default:
+		return "tar"
+	}
+}
+
+// ArchiveFiles returns the file list entries of a commit matching the filters, sorted by path.
+// A filter matches a file if it is the file, one of its parent directories or a glob matching it.
+func ArchiveFiles(s Storage, commitId string, filters []string) ([]FileListEntry, error) {
+	fileList, err := s.ReadFileList(commitId)
+	if err != nil {
+		return nil, err
+	}
+	files := []FileListEntry{}
+	for _, file := range fileList {
+		if len(filters) == 0 || matchesPathFilter(file.Path, filters) {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func matchesPathFilter(path string, filters []string) bool {
+	for _, filter := range filters {
+		filter = strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(filter), "./"), "/")
+		if path == filter || strings.HasPrefix(path, filter+"/") {
+			return true
+		}
+		if matched, _ := filepath.Match(filter, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveTime returns the modification time written for every entry, the commit timestamp so that
+// archiving the same commit twice gives the same bytes.
+func ArchiveTime(commit Commit) time.Time {
+	if t, err := time.Parse(time.RFC3339, commit.Timestamp); err == nil {
+		return t.UTC()
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// WriteArchive streams the files of a commit from the storage to w.
+func WriteArchive(w io.Writer, s Storage, format string, files []FileListEntry, prefix string, modified time.Time) error {
+	type archiveWriter interface {
+		add(name string, mode os.FileMode, data []byte) error
+		Close() error
+	}
+	var archive archiveWriter
+	var compressed *gzip.Writer
+	switch format {
+	case "tar.gz":
+		compressed = gzip.NewWriter(w)
+		compressed.ModTime = modified
+		archive = &tarArchive{Writer: tar.NewWriter(compressed), modified: modified}
+	case "tar":
+		archive = &tarArchive{Writer: tar.NewWriter(w), modified: modified}
+	case "zip":
+		archive = &zipArchive{Writer: zip.NewWriter(w), modified: modified}
+	}
+
+	for _, file := range files {
+		_, fileName := ParsePath(file.Path)
+		data, mode, err := s.ReadObject(file.CommitId, file.Id, fileName)
+		if err != nil {
+			return err
+		}
+		if err := archive.add(prefix+file.Path, mode, data); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if compressed != nil {
+		return compressed.Close()
+	}
+	return nil
+}
+
+type tarArchive struct {
+	*tar.Writer
+	modified time.Time
+}
+
+func (a *tarArchive) add(name string, mode os.FileMode, data []byte) error {
+	if mode.IsDir() {
+		name += "/"
+	}
+	header := tarHeader(name, mode, data)
+	header.ModTime = a.modified
+	if err := a.WriteHeader(header); err != nil {
+		return err
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+	_, err := a.Write(data)
+	return err
+}
+
+// tarHeader describes a file of the given mode. A symlink has no content of its own, data is
+// its target.
+func tarHeader(name string, mode os.FileMode, data []byte) *tar.Header {
+	header := &tar.Header{Name: name, Mode: int64(mode.Perm()), Size: int64(len(data)), Typeflag: tar.TypeReg}
+	switch {
+	case mode&os.ModeSymlink != 0:
+		header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, string(data), 0
+	case mode.IsDir():
+		header.Typeflag, header.Size = tar.TypeDir, 0
+	}
+	return header
+}
+
+type zipArchive struct {
+	*zip.Writer
+	modified time.Time
+}
+
+func (a *zipArchive) add(name string, mode os.FileMode, data []byte) error {
+	if mode.IsDir() {
+		name += "/"
+	}
+	// Zip keeps the target of a symlink as its content.
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.modified}
+	header.SetMode(mode.Type() | mode.Perm())
+	writer, err := a.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_Archive_Tar(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	os.WriteFile(namespace+"run.sh", []byte("#!/bin/sh"), 0755)
+	runAddCommand(namespace+"run.sh", false)
+	commitTestFiles(t, 2)
+
+	output := t.TempDir() + "/release.tar"
+	if returnCode := runArchiveCommand(HeadRevision, "", output, "project-1.0", nil); returnCode != 2001 {
+		t.Fatalf("Expected 2001, got %d", returnCode)
+	}
+	file, _ := os.Open(output)
+	defer file.Close()
+	archive := tar.NewReader(file)
+	headers := map[string]*tar.Header{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		headers[header.Name] = header
+	}
+	if len(headers) != 3 {
+		t.Errorf("Expected 3 files, got %d", len(headers))
+	}
+	script, exists := headers["project-1.0/"+namespace+"run.sh"]
+	if !exists {
+		t.Fatalf("Expected prefixed script in archive, got %v", headers)
+	}
+	if script.Mode != 0755 {
+		t.Errorf("Expected mode 0755, got %o", script.Mode)
+	}
+	commitTime, _ := time.Parse(time.RFC3339, GetLastCommit().Timestamp)
+	if !script.ModTime.Equal(commitTime) {
+		t.Errorf("Expected modification time %v, got %v", commitTime, script.ModTime)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Archive_Reproducible(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 2)
+
+	first, second := t.TempDir()+"/first.tar.gz", t.TempDir()+"/second.tar.gz"
+	runArchiveCommand(HeadRevision, "", first, "", nil)
+	time.Sleep(1100 * time.Millisecond)
+	runArchiveCommand(HeadRevision, "", second, "", nil)
+	a, _ := os.ReadFile(first)
+	b, _ := os.ReadFile(second)
+	if len(a) == 0 || !bytes.Equal(a, b) {
+		t.Errorf("Expected archiving the same commit twice to give the same bytes")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Archive_ZipWithFilter(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 3)
+
+	output := t.TempDir() + "/files.zip"
+	if returnCode := runArchiveCommand(HeadRevision+"~1", "", output, "", []string{namespace + "file1.txt", namespace + "file2*"}); returnCode != 2001 {
+		t.Fatalf("Expected 2001, got %d", returnCode)
+	}
+	archive, err := zip.OpenReader(output)
+	if err != nil {
+		t.Fatalf("Failed to open zip: %v", err)
+	}
+	defer archive.Close()
+	names := []string{}
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	if len(names) != 2 || names[0] != namespace+"file1.txt" || names[1] != namespace+"file2.txt" {
+		t.Errorf("Expected file1 and file2 from HEAD~1, got %v", names)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Archive_Errors(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	output := t.TempDir() + "/out"
+	if returnCode := runArchiveCommand(HeadRevision, "rar", output, "", nil); returnCode != 2003 {
+		t.Errorf("Expected 2003, got %d", returnCode)
+	}
+	if returnCode := runArchiveCommand("unknown", "tar", output, "", nil); returnCode != 2002 {
+		t.Errorf("Expected 2002, got %d", returnCode)
+	}
+	if returnCode := runArchiveCommand(HeadRevision, "tar", output, "", []string{"missing/"}); returnCode != 2004 {
+		t.Errorf("Expected 2004, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
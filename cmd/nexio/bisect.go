@@ -0,0 +1,449 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(bisectCmd)
+
+	bisectCmd.AddCommand(bisectStartCmd)
+	bisectCmd.AddCommand(bisectGoodCmd)
+	bisectCmd.AddCommand(bisectBadCmd)
+	bisectCmd.AddCommand(bisectSkipCmd)
+	bisectCmd.AddCommand(bisectResetCmd)
+	bisectCmd.AddCommand(bisectLogCmd)
+	bisectCmd.AddCommand(bisectRunCmd)
+
+	// The flags after the command are its own, e.g. `nexio bisect run sh -c 'make test'`.
+	bisectRunCmd.Flags().SetInterspersed(false)
+}
+
+var bisectCmd = &cobra.Command{
+	Use:     "bisect",
+	Short:   "Find the commit that introduced a regression by binary search",
+	Example: "nexio bisect start HEAD <good-commit-id>\nnexio bisect good\nnexio bisect bad\nnexio bisect run make test\nnexio bisect reset",
+	Args:    cobra.NoArgs,
+}
+
+var bisectStartCmd = &cobra.Command{
+	Use:     "start",
+	Short:   "Start a bisect, optionally with the bad and good commits",
+	Example: "nexio bisect start\nnexio bisect start HEAD main~20",
+	Args:    cobra.ArbitraryArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bisect start command: revisions=%v", args)
+		bad, good := "", []string{}
+		if len(args) > 0 {
+			bad, good = args[0], args[1:]
+		}
+		runBisectStartCommand(bad, good)
+	},
+}
+
+var bisectGoodCmd = &cobra.Command{
+	Use:     "good",
+	Short:   "Mark commits as good, the checked out one by default",
+	Example: "nexio bisect good\nnexio bisect good <commit-id>",
+	Args:    cobra.ArbitraryArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bisect good command: revisions=%v", args)
+		runBisectMarkCommand("good", args)
+	},
+}
+
+var bisectBadCmd = &cobra.Command{
+	Use:     "bad",
+	Short:   "Mark a commit as bad, the checked out one by default",
+	Example: "nexio bisect bad\nnexio bisect bad <commit-id>",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bisect bad command: revisions=%v", args)
+		runBisectMarkCommand("bad", args)
+	},
+}
+
+var bisectSkipCmd = &cobra.Command{
+	Use:     "skip",
+	Short:   "Skip commits that cannot be tested, the checked out one by default",
+	Example: "nexio bisect skip\nnexio bisect skip <commit-id>",
+	Args:    cobra.ArbitraryArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bisect skip command: revisions=%v", args)
+		runBisectMarkCommand("skip", args)
+	},
+}
+
+var bisectResetCmd = &cobra.Command{
+	Use:     "reset",
+	Short:   "End the bisect and check out the original commit again",
+	Example: "nexio bisect reset",
+	Args:    cobra.NoArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bisect reset command")
+		runBisectResetCommand()
+	},
+}
+
+var bisectLogCmd = &cobra.Command{
+	Use:     "log",
+	Short:   "Show the commands of the bisect so far",
+	Example: "nexio bisect log",
+	Args:    cobra.NoArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bisect log command")
+		runBisectLogCommand()
+	},
+}
+
+var bisectRunCmd = &cobra.Command{
+	Use:     "run",
+	Short:   "Bisect automatically, marking commits by the exit code of a command",
+	Long:    "Runs the command on every commit to test: exit code 0 marks it good, 125 skips it, 1 to 127 mark it bad and anything else stops the bisect.",
+	Example: "nexio bisect run make test\nnexio bisect run ./check.sh\nnexio bisect run sh -c 'go test ./... -run Parser'",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bisect run command: command=%v", args)
+		runBisectRunCommand(args)
+	},
+}
+
+func runBisectStartCommand(bad string, good []string) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if _, err := store.ReadBisectState(); err == nil {
+		Debug("%s", BISECT_RETURN_CODES[2702])
+		Fail(BISECT_RETURN_CODES[2702])
+		Text("End it with "+Code("nexio bisect reset"), "")
+		return 2702
+	}
+	if HasUncommittedChanges() {
+		Debug("%s", BISECT_RETURN_CODES[2704])
+		Fail(BISECT_RETURN_CODES[2704])
+		return 2704
+	}
+	branch := GetCurrentBranchName()
+	commits, err := GetBranchCommits(store, branch)
+	if err != nil {
+		Debug("Failed to read branch commits")
+		MustSucceed(err, "operation failed")
+	}
+	if len(commits) == 0 {
+		Debug("%s", BISECT_RETURN_CODES[2713])
+		Fail(BISECT_RETURN_CODES[2713])
+		return 2713
+	}
+
+	head := HeadOf(commits)
+	state := BisectState{Branch: branch, Original: head, Current: head, Good: []string{}, Skipped: []string{}, Log: []string{"start"}}
+	if bad != "" {
+		if state, err = markBisectCommits(state, commits, "bad", []string{bad}); err != nil {
+			Fail(BISECT_RETURN_CODES[2705] + " " + err.Error())
+			return 2705
+		}
+	}
+	if len(good) > 0 {
+		if state, err = markBisectCommits(state, commits, "good", good); err != nil {
+			Fail(BISECT_RETURN_CODES[2705] + " " + err.Error())
+			return 2705
+		}
+	}
+
+	BreakLine()
+	Success(BISECT_RETURN_CODES[2701] + " Branch: " + StyledBranch(branch))
+	returnCode, _ := advanceBisect(state, commits)
+	if returnCode == 2711 {
+		// Leave no half started bisect behind.
+		store.RemoveBisectState()
+	}
+	return returnCode
+}
+
+func runBisectMarkCommand(mark string, revisions []string) (returnCode int, step BisectStep) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, step
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, step
+	}
+	defer release()
+
+	state, commits, returnCode := readBisectState()
+	if returnCode != 0 {
+		return returnCode, step
+	}
+	if state, err = markBisectCommits(state, commits, mark, revisions); err != nil {
+		Fail(BISECT_RETURN_CODES[2705] + " " + err.Error())
+		return 2705, step
+	}
+	BreakLine()
+	return advanceBisect(state, commits)
+}
+
+func runBisectResetCommand() int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	state, _, returnCode := readBisectState()
+	if returnCode != 0 {
+		return returnCode
+	}
+	if state.Current != state.Original {
+		CheckoutFiles(state.Current, state.Original)
+	}
+	if err := store.RemoveBisectState(); err != nil {
+		Debug("Failed to remove bisect state")
+		MustSucceed(err, "operation failed")
+	}
+	Success(BISECT_RETURN_CODES[2710] + " Back at " + StyledCommit(state.Original))
+	return 2710
+}
+
+func runBisectLogCommand() (returnCode int, log []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	state, _, returnCode := readBisectState()
+	if returnCode != 0 {
+		return returnCode, nil
+	}
+	for _, entry := range state.Log {
+		fmt.Println("nexio bisect " + entry)
+	}
+	return 2714, state.Log
+}
+
+func runBisectRunCommand(command []string) (returnCode int, firstBad string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, ""
+	}
+
+	// The lock is only held between the runs, the command may call nexio itself.
+	returnCode, step := lockedBisectStep(func(state BisectState, commits []Commit) (int, BisectStep) {
+		step, err := NextBisectStep(state, commits)
+		if err != nil || step.Next == "" {
+			// Nothing left to run: the search is over, has not been narrowed down yet or is invalid.
+			BreakLine()
+			return advanceBisect(state, commits)
+		}
+		return 2707, BisectStep{Next: state.Current}
+	})
+	if returnCode != 2707 {
+		return returnCode, step.FirstBad
+	}
+
+	BreakLine()
+	for {
+		Info("Running " + Code(strings.Join(command, " ")) + " on " + StyledCommit(step.Next))
+		run := exec.Command(command[0], command[1:]...)
+		run.Stdout, run.Stderr = os.Stdout, os.Stderr
+		exitCode := 0
+		if err := run.Run(); err != nil {
+			var exitError *exec.ExitError
+			if !errors.As(err, &exitError) {
+				Debug("Failed to run command: %v", err)
+				Fail(BISECT_RETURN_CODES[2712] + " " + err.Error())
+				return 2712, ""
+			}
+			exitCode = exitError.ExitCode()
+		}
+
+		var mark string
+		switch {
+		case exitCode == 0:
+			mark = "good"
+		case exitCode == 125:
+			mark = "skip"
+		case exitCode > 0 && exitCode < 128:
+			mark = "bad"
+		default:
+			Fail(BISECT_RETURN_CODES[2712] + fmt.Sprintf(" The command exited with %d.", exitCode))
+			return 2712, ""
+		}
+		returnCode, step = lockedBisectStep(func(state BisectState, commits []Commit) (int, BisectStep) {
+			state, err := markBisectCommits(state, commits, mark, nil)
+			if err != nil {
+				MustSucceed(err, "operation failed")
+			}
+			return advanceBisect(state, commits)
+		})
+		if returnCode != 2707 {
+			return returnCode, step.FirstBad
+		}
+	}
+}
+
+// lockedBisectStep reads the bisect state and runs next on it while holding the repository lock.
+func lockedBisectStep(next func(state BisectState, commits []Commit) (int, BisectStep)) (int, BisectStep) {
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, BisectStep{}
+	}
+	defer release()
+
+	state, commits, returnCode := readBisectState()
+	if returnCode != 0 {
+		return returnCode, BisectStep{}
+	}
+	return next(state, commits)
+}
+
+// readBisectState returns the state of the bisect in progress with the commits of its branch,
+// or a return code if there is none.
+func readBisectState() (BisectState, []Commit, int) {
+	state, err := store.ReadBisectState()
+	if os.IsNotExist(err) {
+		Debug("%s", BISECT_RETURN_CODES[2703])
+		Fail(BISECT_RETURN_CODES[2703])
+		Text("Start one with "+Code("nexio bisect start"), "")
+		return state, nil, 2703
+	}
+	if err != nil {
+		Debug("Failed to read bisect state")
+		MustSucceed(err, "operation failed")
+	}
+	commits, err := GetBranchCommits(store, state.Branch)
+	if err != nil {
+		Debug("Failed to read branch commits")
+		MustSucceed(err, "operation failed")
+	}
+	return state, commits, 0
+}
+
+// markBisectCommits marks the revisions, the checked out commit if there are none, and logs the mark.
+func markBisectCommits(state BisectState, commits []Commit, mark string, revisions []string) (BisectState, error) {
+	ids := []string{}
+	for _, revision := range revisions {
+		commit, err := ResolveRevision(store, revision)
+		if err != nil {
+			return state, err
+		}
+		if !slices.ContainsFunc(commits, func(c Commit) bool { return c.Id == commit.Id }) {
+			return state, fmt.Errorf("commit %s is not on branch %s", commit.Id, state.Branch)
+		}
+		ids = append(ids, commit.Id)
+	}
+	if len(ids) == 0 {
+		ids = []string{state.Current}
+	}
+	for _, id := range ids {
+		switch mark {
+		case "good":
+			state.Good = append(state.Good, id)
+		case "bad":
+			state.Bad = id
+		case "skip":
+			state.Skipped = append(state.Skipped, id)
+		}
+		state.Log = append(state.Log, mark+" "+id)
+	}
+	return state, nil
+}
+
+// advanceBisect checks out the next commit to test, or reports the first bad commit, and saves the state.
+func advanceBisect(state BisectState, commits []Commit) (int, BisectStep) {
+	step, err := NextBisectStep(state, commits)
+	if err != nil {
+		Debug("Invalid bisect: %v", err)
+		Fail(BISECT_RETURN_CODES[2711] + " " + err.Error())
+		return 2711, step
+	}
+
+	returnCode := 2708
+	switch {
+	case step.Next != "":
+		CheckoutFiles(state.Current, step.Next)
+		state.Current = step.Next
+		returnCode = 2707
+	case step.FirstBad != "":
+		returnCode = 2706
+	case len(step.Candidates) > 0:
+		returnCode = 2709
+	}
+	if err := store.WriteBisectState(state); err != nil {
+		Debug("Failed to write bisect state")
+		MustSucceed(err, "operation failed")
+	}
+
+	switch returnCode {
+	case 2707:
+		Info(fmt.Sprintf("Bisecting: %d commits left to test", step.Remaining))
+		Text("Checked out "+StyledCommit(step.Next)+" "+bisectSubject(step.Next), "  ")
+		Text("Test it, then run "+Code("nexio bisect good")+" or "+Code("nexio bisect bad"), "  ")
+	case 2706:
+		metadata, _ := store.ReadCommitMetadata(step.FirstBad)
+		Success(BISECT_RETURN_CODES[2706])
+		Box(Bold(StyledCommit(" "+step.FirstBad[:10])), fmt.Sprintf("Author:  %s <%s>\nMessage: %s", metadata.Author.Name, metadata.Author.Email, metadata.Message))
+		Text("Run "+Code("nexio bisect reset")+" to go back to "+StyledBranch(state.Branch), "")
+	case 2709:
+		Warning(BISECT_RETURN_CODES[2709])
+		lines := []string{}
+		for _, id := range step.Candidates {
+			lines = append(lines, StyledCommit(id)+" "+bisectSubject(id))
+		}
+		Tree(lines, false)
+	default:
+		missing := "good"
+		if state.Bad == "" {
+			missing = "bad"
+		}
+		Info(BISECT_RETURN_CODES[2708])
+		Text("Mark a "+missing+" commit with "+Code("nexio bisect "+missing+" <commit-id>"), "  ")
+	}
+	BreakLine()
+	return returnCode, step
+}
+
+func bisectSubject(commitId string) string {
+	metadata, err := store.ReadCommitMetadata(commitId)
+	if err != nil {
+		return ""
+	}
+	return FirstLine(metadata.Message)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// BisectState is the search for the first bad commit of a branch. Original is the commit checked out
+// when the bisect started, Current the one checked out now.
+type BisectState struct {
+	Branch   string   `json:"branch"`
+	Original string   `json:"original"`
+	Current  string   `json:"current"`
+	Bad      string   `json:"bad,omitempty"`
+	Good     []string `json:"good"`
+	Skipped  []string `json:"skipped"`
+	// Log records every command changing the state, as `nexio bisect log` shows it.
+	Log []string `json:"log"`
+}
+
+// BisectStep is the outcome of marking a commit: the next commit to test, the first bad commit once
+// it is found, or the candidates left when only skipped commits remain between good and bad.
+type BisectStep struct {
+	Next       string
+	Remaining  int
+	FirstBad   string
+	Candidates []string
+}
+
+// NextBisectStep narrows the commits between the newest good commit and the bad one, commits is the
+// branch oldest first. It returns an empty step while good or bad commits are missing.
+func NextBisectStep(state BisectState, commits []Commit) (BisectStep, error) {
+	if state.Bad == "" || len(state.Good) == 0 {
+		return BisectStep{}, nil
+	}
+	position := func(id string) int {
+		return slices.IndexFunc(commits, func(commit Commit) bool { return commit.Id == id })
+	}
+	bad := position(state.Bad)
+	good := -1
+	for _, id := range state.Good {
+		good = max(good, position(id))
+	}
+	if bad == -1 || good == -1 {
+		return BisectStep{}, fmt.Errorf("marked commits are no longer on branch %s", state.Branch)
+	}
+	if good >= bad {
+		return BisectStep{}, fmt.Errorf("good commit %s is not older than bad commit %s", commits[good].Id, state.Bad)
+	}
+
+	candidates := []int{}
+	for i := good + 1; i < bad; i++ {
+		if !slices.Contains(state.Skipped, commits[i].Id) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		if bad-good == 1 {
+			return BisectStep{FirstBad: state.Bad}, nil
+		}
+		// Any of the skipped commits may have introduced the change, as may the bad one.
+		step := BisectStep{}
+		for i := good + 1; i <= bad; i++ {
+			step.Candidates = append(step.Candidates, commits[i].Id)
+		}
+		return step, nil
+	}
+
+	// Test the candidate closest to the middle, halving what is left.
+	middle := (good + bad) / 2
+	next := candidates[0]
+	for _, candidate := range candidates {
+		if abs(candidate-middle) < abs(next-middle) {
+			next = candidate
+		}
+	}
+	return BisectStep{Next: commits[next].Id, Remaining: len(candidates)}, nil
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// IsBisecting tells whether a bisect is in progress.
+func IsBisecting() bool {
+	_, err := store.ReadBisectState()
+	return err == nil
+}
+
+// RefuseDuringBisect fails with 006 if a bisect is in progress: the checked out commit is not the head
+// of the branch, so the commands that change the repository wait for `nexio bisect reset`.
+func RefuseDuringBisect() bool {
+	if !IsBisecting() {
+		return false
+	}
+	Debug("%s", COMMON_RETURN_CODES[006])
+	Fail(COMMON_RETURN_CODES[006])
+	Text("End it with "+Code("nexio bisect reset"), "")
+	return true
+}
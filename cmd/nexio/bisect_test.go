@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// commitBisectHistory commits 8 versions of file1.txt, the fifth introduces the bug.
+func commitBisectHistory(t *testing.T) []string {
+	t.Helper()
+	ids := []string{}
+	for i := 1; i <= 8; i++ {
+		content := "version " + strconv.Itoa(i)
+		if i >= 5 {
+			content += " bug"
+		}
+		ids = append(ids, commitTestChange(t, content))
+	}
+	return ids
+}
+
+func Test_Bisect_Manual(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	ids := commitBisectHistory(t)
+	file := namespace + "file1.txt"
+
+	if returnCode := runBisectStartCommand("", nil); returnCode != 2708 {
+		t.Fatalf("Expected 2708 without good and bad commits, got %d", returnCode)
+	}
+	if returnCode, _ := runBisectMarkCommand("bad", nil); returnCode != 2708 {
+		t.Errorf("Expected 2708 without a good commit, got %d", returnCode)
+	}
+	returnCode, step := runBisectMarkCommand("good", []string{ids[0]})
+	if returnCode != 2707 || step.Next != ids[3] || step.Remaining != 6 {
+		t.Fatalf("Expected the middle commit to be checked out, got %d %v", returnCode, step)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "version 4" {
+		t.Errorf("Expected the working file of the middle commit, got %q", content)
+	}
+
+	for {
+		content, _ := os.ReadFile(file)
+		mark := "good"
+		if slices.Contains([]string{"version 5 bug", "version 6 bug", "version 7 bug"}, string(content)) {
+			mark = "bad"
+		}
+		if returnCode, step = runBisectMarkCommand(mark, nil); returnCode != 2707 {
+			break
+		}
+	}
+	if returnCode != 2706 || step.FirstBad != ids[4] {
+		t.Errorf("Expected the fifth commit to be the first bad one, got %d %v", returnCode, step)
+	}
+	if returnCode, log := runBisectLogCommand(); returnCode != 2714 || log[0] != "start" || log[1] != "bad "+ids[7] {
+		t.Errorf("Expected the bisect log, got %d %v", returnCode, log)
+	}
+
+	if returnCode := runBisectResetCommand(); returnCode != 2710 {
+		t.Errorf("Expected 2710, got %d", returnCode)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "version 8 bug" {
+		t.Errorf("Expected the original working file after reset, got %q", content)
+	}
+	if returnCode := runBisectResetCommand(); returnCode != 2703 {
+		t.Errorf("Expected 2703 after reset, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Bisect_Run(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	ids := commitBisectHistory(t)
+
+	if returnCode := runBisectStartCommand(HeadRevision, []string{ids[0]}); returnCode != 2707 {
+		t.Fatalf("Expected 2707, got %d", returnCode)
+	}
+	// The sixth commit cannot be tested and is skipped.
+	script := "grep -q 'version 6' " + namespace + "file1.txt && exit 125; ! grep -q bug " + namespace + "file1.txt"
+	returnCode, firstBad := runBisectRunCommand([]string{"sh", "-c", script})
+	if returnCode != 2706 || firstBad != ids[4] {
+		t.Errorf("Expected the fifth commit to be the first bad one, got %d %s", returnCode, firstBad)
+	}
+	state, _ := store.ReadBisectState()
+	if !slices.Equal(state.Skipped, []string{ids[5]}) {
+		t.Errorf("Expected the sixth commit to be skipped, got %v", state.Skipped)
+	}
+	runBisectResetCommand()
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Bisect_RunCommandWithFlags(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	ids := commitBisectHistory(t)
+
+	if returnCode := runBisectStartCommand(HeadRevision, []string{ids[0]}); returnCode != 2707 {
+		t.Fatalf("Expected 2707, got %d", returnCode)
+	}
+	// `-c` and `-q` belong to the command, they must not be parsed as flags of bisect run.
+	rootCmd.SetArgs([]string{"bisect", "run", "sh", "-c", "! grep -q bug " + namespace + "file1.txt"})
+	defer rootCmd.SetArgs(nil)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected the command and its flags to be run, got %v", err)
+	}
+	if state, _ := store.ReadBisectState(); state.Bad != ids[4] {
+		t.Errorf("Expected the fifth commit to be the first bad one, got %s", state.Bad)
+	}
+	runBisectResetCommand()
+
+	os.RemoveAll(namespace)
+}
+
+// Test_Bisect_RunHelper is the command of Test_Bisect_RunCommandCanUseRepository: like a nexio command,
+// it takes the repository lock, then fails on commits with the bug.
+func Test_Bisect_RunHelper(t *testing.T) {
+	if os.Getenv("NEXIO_BISECT_HELPER") == "" {
+		t.Skip("Only run by bisect run")
+	}
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		os.Exit(200)
+	}
+	defer release()
+	if content, _ := os.ReadFile(namespace + "file1.txt"); strings.Contains(string(content), "bug") {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func Test_Bisect_RunCommandCanUseRepository(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	ids := commitBisectHistory(t)
+
+	if returnCode := runBisectStartCommand(HeadRevision, []string{ids[0]}); returnCode != 2707 {
+		t.Fatalf("Expected 2707, got %d", returnCode)
+	}
+	t.Setenv("NEXIO_BISECT_HELPER", "1")
+	returnCode, firstBad := runBisectRunCommand([]string{os.Args[0], "-test.run=^Test_Bisect_RunHelper$"})
+	if returnCode != 2706 || firstBad != ids[4] {
+		t.Errorf("Expected the command to take the lock and find the fifth commit, got %d %s", returnCode, firstBad)
+	}
+	runBisectResetCommand()
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Bisect_RefusesChanges(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	ids := commitBisectHistory(t)
+	file := namespace + "file1.txt"
+
+	if returnCode := runBisectStartCommand(HeadRevision, []string{ids[0]}); returnCode != 2707 {
+		t.Fatalf("Expected 2707, got %d", returnCode)
+	}
+	state, _ := store.ReadBisectState()
+	if modified, deleted := GetModifiedOrDeletedFilesSince(state.Current); len(modified)+len(deleted) > 0 {
+		t.Errorf("Expected the checked out commit to be unchanged, got %v %v", modified, deleted)
+	}
+	if result := runAddCommand(file, false); result.ReturnCode != 006 {
+		t.Errorf("Expected add to fail with 006, got %d", result.ReturnCode)
+	}
+	if returnCode, _ := runCommitCommand("Revert"); returnCode != 006 {
+		t.Errorf("Expected commit to fail with 006, got %d", returnCode)
+	}
+	if returnCode := runNewCommand("feature", "", ""); returnCode != 006 {
+		t.Errorf("Expected a new branch to fail with 006, got %d", returnCode)
+	}
+	if returnCode := runSwitchCommand(InitBranch); returnCode != 006 {
+		t.Errorf("Expected switch to fail with 006, got %d", returnCode)
+	}
+	if returnCode, _ := runCherryPickCommand([]string{ids[1]}); returnCode != 006 {
+		t.Errorf("Expected cherry-pick to fail with 006, got %d", returnCode)
+	}
+	if returnCode, _ := runRebaseCommand(ids[0], false); returnCode != 006 {
+		t.Errorf("Expected rebase to fail with 006, got %d", returnCode)
+	}
+
+	runBisectResetCommand()
+	if commits := mustBranchCommits(t, store); HeadOf(commits) != ids[7] {
+		t.Errorf("Expected the branch to be left alone, got head %s", HeadOf(commits))
+	}
+	if result := runAddCommand(file, false); result.ReturnCode == 006 {
+		t.Errorf("Expected add to work again after the reset")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Bisect_Errors(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	if returnCode := runBisectStartCommand("", nil); returnCode != 2713 {
+		t.Errorf("Expected 2713 without commits, got %d", returnCode)
+	}
+	if returnCode, _ := runBisectMarkCommand("good", nil); returnCode != 2703 {
+		t.Errorf("Expected 2703 without a bisect, got %d", returnCode)
+	}
+	ids := commitBisectHistory(t)
+	if returnCode := runBisectStartCommand(ids[0], []string{ids[3]}); returnCode != 2711 {
+		t.Errorf("Expected 2711 with a good commit newer than the bad one, got %d", returnCode)
+	}
+	if returnCode := runBisectStartCommand("unknown", nil); returnCode != 2705 {
+		t.Errorf("Expected 2705 for an unknown revision, got %d", returnCode)
+	}
+	runBisectStartCommand("", nil)
+	if returnCode := runBisectStartCommand("", nil); returnCode != 2702 {
+		t.Errorf("Expected 2702 with a bisect in progress, got %d", returnCode)
+	}
+	if returnCode, _ := runBisectMarkCommand("skip", []string{"unknown"}); returnCode != 2705 {
+		t.Errorf("Expected 2705 for an unknown revision, got %d", returnCode)
+	}
+	runBisectResetCommand()
+	os.WriteFile(namespace+"file1.txt", []byte("changed"), 0644)
+	if returnCode := runBisectStartCommand("", nil); returnCode != 2704 {
+		t.Errorf("Expected 2704 with uncommitted changes, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
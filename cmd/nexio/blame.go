@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	blameCmd.Flags().StringVarP(&BlameLines, "lines", "L", "", "Only annotate the lines start,end")
+	blameCmd.Flags().BoolVar(&BlamePorcelain, "porcelain", false, "Machine-readable output, in the format of git blame --porcelain")
+
+	rootCmd.AddCommand(blameCmd)
+}
+
+var (
+	BlameLines     string
+	BlamePorcelain bool
+)
+
+var blameCmd = &cobra.Command{
+	Use:     "blame",
+	Short:   "Show which commit last changed each line of a file",
+	Example: "nexio blame main.go\nnexio blame -L 10,20 main.go\nnexio blame --porcelain main.go",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting blame command: path=%s, lines=%s, porcelain=%t", args[0], BlameLines, BlamePorcelain)
+		runBlameCommand(args[0], BlameLines, BlamePorcelain)
+	},
+}
+
+func runBlameCommand(path string, lineRange string, porcelain bool) (returnCode int, lines []BlameLine) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	commits, err := GetBranchCommits(store, GetCurrentBranchName())
+	if err != nil {
+		Debug("Failed to read branch commits")
+		MustSucceed(err, "operation failed")
+	}
+	lines, err = BlameFile(store, commits, path)
+	if os.IsNotExist(err) {
+		Debug("File is not tracked: %s", path)
+		Fail(BLAME_RETURN_CODES[2602] + " " + path)
+		return 2602, nil
+	}
+	if errors.Is(err, errBinaryFile) {
+		Debug("Binary file: %s", path)
+		Fail(BLAME_RETURN_CODES[2604] + " " + path)
+		return 2604, nil
+	}
+	if err != nil {
+		Debug("Failed to blame file: %v", err)
+		MustSucceed(err, "operation failed")
+	}
+	if len(lines) == 0 {
+		return 2601, lines
+	}
+	start, end, err := ParseLineRange(lineRange, len(lines))
+	if err != nil {
+		Debug("Invalid line range: %s", lineRange)
+		Fail(BLAME_RETURN_CODES[2603] + " " + err.Error())
+		return 2603, nil
+	}
+	lines = lines[start-1 : end]
+
+	metadata := map[string]CommitMetadata{}
+	for _, line := range lines {
+		if _, read := metadata[line.Commit.Id]; !read {
+			if metadata[line.Commit.Id], err = store.ReadCommitMetadata(line.Commit.Id); err != nil {
+				Debug("Failed to read commit metadata: %s", line.Commit.Id)
+				MustSucceed(err, "operation failed")
+			}
+		}
+	}
+	if porcelain {
+		printBlamePorcelain(lines, metadata, path)
+	} else {
+		printBlame(lines, metadata)
+	}
+	return 2601, lines
+}
+
+func printBlame(lines []BlameLine, metadata map[string]CommitMetadata) {
+	authorWidth, dateWidth := 0, 0
+	for _, line := range lines {
+		authorWidth = max(authorWidth, len(blameAuthor(metadata[line.Commit.Id].Author)))
+		dateWidth = max(dateWidth, len(TimeAgo(line.Commit.Timestamp)))
+	}
+	numberWidth := len(fmt.Sprint(lines[len(lines)-1].Number))
+	for _, line := range lines {
+		fmt.Printf("%s (%-*s %-*s %*d) %s\n",
+			StyledCommit(line.Commit.Id[:8]),
+			authorWidth, blameAuthor(metadata[line.Commit.Id].Author),
+			dateWidth, TimeAgo(line.Commit.Timestamp),
+			numberWidth, line.Number,
+			line.Content,
+		)
+	}
+}
+
+func blameAuthor(author Author) string {
+	if author.Name == "" {
+		return "Unknown"
+	}
+	return author.Name
+}
+
+// printBlamePorcelain describes every commit the first time one of its lines appears, as git blame --porcelain does.
+func printBlamePorcelain(lines []BlameLine, metadata map[string]CommitMetadata, path string) {
+	described := map[string]bool{}
+	for _, line := range lines {
+		fmt.Printf("%s %d %d\n", line.Commit.Id, line.OriginalLine, line.Number)
+		if !described[line.Commit.Id] {
+			described[line.Commit.Id] = true
+			commit := metadata[line.Commit.Id]
+			timestamp, err := time.Parse(time.RFC3339, line.Commit.Timestamp)
+			if err != nil {
+				timestamp = time.Unix(0, 0).UTC()
+			}
+			fmt.Printf("author %s\nauthor-mail <%s>\nauthor-time %d\nauthor-tz %s\n", commit.Author.Name, commit.Author.Email, timestamp.Unix(), timestamp.Format("-0700"))
+			fmt.Printf("summary %s\nfilename %s\n", FirstLine(commit.Message), strings.TrimPrefix(path, "./"))
+		}
+		fmt.Printf("\t%s\n", line.Content)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BlameLine attributes a line of a file to the commit that last changed it. OriginalLine is
+// its line number in that commit.
+type BlameLine struct {
+	Commit       Commit
+	Number       int
+	OriginalLine int
+	Content      string
+}
+
+var errBinaryFile = errors.New("binary file")
+
+// BlameFile attributes every line of the file as of the last of the commits, walking the commits
+// oldest first and diffing each version of the file with the one before. Lines of a file that was
+// renamed or copied are followed to the file they come from. Binary files have no lines to blame.
+func BlameFile(s Storage, commits []Commit, path string) ([]BlameLine, error) {
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+	paths, err := blamePaths(s, commits, path)
+	if err != nil {
+		return nil, err
+	}
+	blame := []BlameLine{}
+	var lines []string
+	var data []byte
+	versionId := ""
+	for i, commit := range commits {
+		files, err := commitFileMap(s, commit.Id)
+		if err != nil {
+			return nil, err
+		}
+		file, exists := files[paths[i]]
+		if !exists {
+			// Removed files start over if they are added again.
+			blame, lines, versionId = []BlameLine{}, nil, ""
+			continue
+		}
+		if file.Id == versionId {
+			continue
+		}
+		_, fileName := ParsePath(file.Path)
+		if data, _, err = s.ReadObject(file.CommitId, file.Id, fileName); err != nil {
+			return nil, err
+		}
+		newLines := SplitLines(data)
+		blame = blameVersion(blame, lines, newLines, commit)
+		lines, versionId = newLines, file.Id
+	}
+	if versionId == "" {
+		return nil, os.ErrNotExist
+	}
+	if IsBinaryFile(path, data) {
+		return nil, errBinaryFile
+	}
+	return blame, nil
+}
+
+// blamePaths returns the path of the file in every commit, going back from the last one and
+// switching to the source of a rename or copy at the commit that created the file.
+func blamePaths(s Storage, commits []Commit, path string) ([]string, error) {
+	paths := make([]string, len(commits))
+	for i := len(commits) - 1; i >= 0; i-- {
+		paths[i] = path
+		if i == 0 {
+			break
+		}
+		files, err := commitFileMap(s, commits[i].Id)
+		if err != nil {
+			return nil, err
+		}
+		parentFiles, err := commitFileMap(s, commits[i-1].Id)
+		if err != nil {
+			return nil, err
+		}
+		_, exists := files[path]
+		_, existed := parentFiles[path]
+		if !exists || existed {
+			continue
+		}
+		renames, err := DetectCommitRenames(s, commits[i-1].Id, commits[i].Id)
+		if err != nil {
+			return nil, err
+		}
+		if index := slices.IndexFunc(renames, func(rename Rename) bool { return rename.To == path }); index != -1 {
+			path = renames[index].From
+		}
+	}
+	return paths, nil
+}
+
+// blameVersion carries the attribution of unchanged lines over to the new version and
+// attributes the added ones to the commit.
+func blameVersion(blame []BlameLine, oldLines []string, newLines []string, commit Commit) []BlameLine {
+	result := make([]BlameLine, 0, len(newLines))
+	oldIndex := 0
+	for _, op := range diffLines(oldLines, newLines) {
+		switch op.kind {
+		case ' ':
+			line := blame[oldIndex]
+			line.Number = len(result) + 1
+			result = append(result, line)
+			oldIndex++
+		case '-':
+			oldIndex++
+		case '+':
+			number := len(result) + 1
+			result = append(result, BlameLine{Commit: commit, Number: number, OriginalLine: number, Content: strings.TrimSuffix(op.line, "\n")})
+		}
+	}
+	return result
+}
+
+// ParseLineRange parses `-L start,end` against a file of total lines. Either end may be left out.
+func ParseLineRange(spec string, total int) (start int, end int, err error) {
+	if spec == "" {
+		return 1, total, nil
+	}
+	from, to, _ := strings.Cut(spec, ",")
+	start, end = 1, total
+	if from != "" {
+		if start, err = strconv.Atoi(from); err != nil {
+			return 0, 0, fmt.Errorf("invalid line range %s", spec)
+		}
+	}
+	if to != "" {
+		if end, err = strconv.Atoi(to); err != nil {
+			return 0, 0, fmt.Errorf("invalid line range %s", spec)
+		}
+	}
+	if start < 1 || end < start || start > total {
+		return 0, 0, fmt.Errorf("line range %s is outside the %d lines of the file", spec, total)
+	}
+	return start, min(end, total), nil
+}
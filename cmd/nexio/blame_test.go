@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_Blame_AttributesLines(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	file := namespace + "blame.txt"
+	commit := func(content string, message string) string {
+		os.WriteFile(file, []byte(content), 0644)
+		runAddCommand(file, false)
+		returnCode, commitId := runCommitCommand(message)
+		if returnCode != 702 {
+			t.Fatalf("Expected 702, got %d", returnCode)
+		}
+		return commitId
+	}
+	first := commit("one\ntwo\nthree\n", "first")
+	second := commit("one\nTWO\nthree\nfour\n", "second")
+	commitTestFiles(t, 1)
+
+	returnCode, lines := runBlameCommand(file, "", false)
+	if returnCode != 2601 || len(lines) != 4 {
+		t.Fatalf("Expected 2601 with 4 lines, got %d %v", returnCode, lines)
+	}
+	expected := []string{first, second, first, second}
+	for i, line := range lines {
+		if line.Commit.Id != expected[i] || line.Number != i+1 {
+			t.Errorf("Expected line %d to be from %s, got %v", i+1, expected[i], line)
+		}
+	}
+	if lines[1].Content != "TWO" || lines[3].OriginalLine != 4 {
+		t.Errorf("Expected the content and original line of the changed lines, got %v", lines)
+	}
+
+	if returnCode, lines := runBlameCommand(file, "2,3", true); returnCode != 2601 || len(lines) != 2 || lines[0].Number != 2 {
+		t.Errorf("Expected lines 2 and 3, got %d %v", returnCode, lines)
+	}
+	if returnCode, _ := runBlameCommand(file, "3,2", false); returnCode != 2603 {
+		t.Errorf("Expected 2603, got %d", returnCode)
+	}
+	if returnCode, _ := runBlameCommand(namespace+"missing.txt", "", false); returnCode != 2602 {
+		t.Errorf("Expected 2602, got %d", returnCode)
+	}
+
+	// A removed file that is added again starts over.
+	os.Remove(file)
+	runAddCommand(file, false)
+	runCommitCommand("remove")
+	readded := commit("one\n", "add again")
+	if _, lines := runBlameCommand(file, "", false); len(lines) != 1 || lines[0].Commit.Id != readded {
+		t.Errorf("Expected the line to be from the commit adding the file again, got %v", lines)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Blame_BinaryFile(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	commitTestFile(t, "image.png", "\x89PNG\x00\x01", "add image")
+	if returnCode, lines := runBlameCommand(namespace+"image.png", "", false); returnCode != 2604 || lines != nil {
+		t.Errorf("Expected 2604, got %d %v", returnCode, lines)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Blame_ImportedHistory(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	runImportGitCommand("testdata/git/packed.git", "", "")
+	commits := mustBranchCommits(t, store)
+
+	_, lines := runBlameCommand(namespace+"docs/guide.md", "80,", false)
+	if len(lines) != 2 || lines[0].Commit.Id != commits[0].Id || lines[1].Commit.Id != commits[1].Id {
+		t.Errorf("Expected the last line of the guide to come from the second commit, got %v", lines)
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"slices"
 
 	"github.com/fatih/color"
@@ -12,6 +11,7 @@ import (
 func init() {
 	newCmd.Flags().StringVarP(&FromCommit, "from-commit", "c", "", "Commit to create branch from")
 	newCmd.Flags().StringVarP(&FromBranch, "from-branch", "b", "", "Branch to create branch from")
+	branchCmd.Flags().StringVarP(&SetUpstreamTo, "set-upstream-to", "u", "", "Remote branch to track, e.g. origin/main")
 
 	rootCmd.AddCommand(branchCmd)
 
@@ -23,16 +23,30 @@ func init() {
 }
 
 var (
-	FromCommit string
-	FromBranch string
+	FromCommit    string
+	FromBranch    string
+	SetUpstreamTo string
 )
 
 var branchCmd = &cobra.Command{
 	Use:     "branch",
 	Short:   "Branch management",
-	Example: "nexio branch",
-	Args:    cobra.NoArgs,
-	Run: func(_ *cobra.Command, args []string) {
+	Example: "nexio branch\nnexio branch --set-upstream-to origin/main\nnexio branch --set-upstream-to origin/feature feature",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if SetUpstreamTo != "" {
+			branch := ""
+			if len(args) == 1 {
+				branch = args[0]
+			}
+			Debug("Starting set upstream command: upstream=%s, branch=%s", SetUpstreamTo, branch)
+			runSetUpstreamCommand(SetUpstreamTo, branch)
+			return
+		}
+		if len(args) > 0 {
+			cmd.Help()
+			return
+		}
 		Debug("Starting branch command")
 		runBranchCommand()
 	},
@@ -102,7 +116,15 @@ func runBranchCommand() {
 		return
 	}
 
-	branches, err := os.ReadDir(dirs.Branches)
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		color.Red(COMMON_RETURN_CODES[005])
+		return
+	}
+	defer release()
+
+	branches, err := store.ListBranches()
 	if err != nil {
 		Debug("%s", BRANCH_RETURN_CODES[217])
 		Fail(BRANCH_RETURN_CODES[217])
@@ -114,24 +136,72 @@ func runBranchCommand() {
 	Debug("Current branch: %s, Default branch: %s", currentBranchName, defaultBranchName)
 
 	for _, branch := range branches {
-		if branch.IsDir() {
-			branchName := branch.Name()
-			if branchName == defaultBranchName {
-				branchName = "* " + branchName
-			} else {
-				branchName = "  " + branchName
-			}
+		branchName := branch
+		if branchName == defaultBranchName {
+			branchName = "* " + branchName
+		} else {
+			branchName = "  " + branchName
+		}
 
-			if branch.Name() == currentBranchName {
-				color.Green(branchName)
-			} else {
-				fmt.Println(branchName)
-			}
+		if tracking := DescribeTracking(branch); tracking != "" {
+			branchName += " [" + tracking + "]"
+		}
+
+		if branch == currentBranchName {
+			color.Green(branchName)
+		} else {
+			fmt.Println(branchName)
 		}
 	}
 	Debug("Branch command completed successfully")
 }
 
+func runSetUpstreamCommand(upstreamName string, branch string) int {
+	initialized := IsInitialized()
+	if !initialized {
+		color.Red(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		color.Red(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if branch == "" {
+		branch = GetCurrentBranchName()
+	}
+	if !slices.Contains(ListBranches(), branch) {
+		Debug("Branch does not exist: %s", branch)
+		color.Red(BRANCH_RETURN_CODES[219])
+		return 219
+	}
+	upstream, valid := ParseUpstream(upstreamName)
+	if !valid {
+		Debug("Invalid upstream: %s", upstreamName)
+		color.Red(BRANCH_RETURN_CODES[218])
+		return 218
+	}
+	if _, exists := FindRemote(upstream.Remote); !exists {
+		Debug("Remote does not exist: %s", upstream.Remote)
+		color.Red(REMOTE_RETURN_CODES[1304])
+		return 1304
+	}
+	if _, err := store.ReadRemoteRef(upstream.Remote, upstream.Branch); err != nil {
+		Debug("Remote-tracking ref does not exist: %s", upstream)
+		color.Red(BRANCH_RETURN_CODES[220])
+		return 220
+	}
+
+	SetUpstream(branch, upstream)
+	Debug("Upstream of %s set to %s", branch, upstream)
+	color.Green(BRANCH_RETURN_CODES[221] + " " + branch + " -> " + upstream.String())
+	return 221
+}
+
 func runCurrentCommand() {
 	initialized := IsInitialized()
 	if !initialized {
@@ -163,6 +233,18 @@ func runNewCommand(branchName string, fromCommit string, fromBranch string) int
 		return 001
 	}
 
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		color.Red(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006
+	}
+
 	if !IsValidBranchName(branchName) {
 		Debug("Invalid branch name: %s", branchName)
 		color.Red(BRANCH_RETURN_CODES[201])
@@ -198,17 +280,20 @@ func runNewCommand(branchName string, fromCommit string, fromBranch string) int
 		}
 	} else {
 		Debug("Creating branch from branch: %s", srcBranch)
-		if err := os.Mkdir(dirs.Branches+branchName, 0755); err != nil {
+		commits, err := store.ReadBranchCommits(srcBranch)
+		if err != nil {
+			Debug("Failed to read commits of branch: %s", srcBranch)
+			MustSucceed(err, "operation failed")
+		}
+		if err := store.CreateBranch(branchName, commits); err != nil {
 			Debug("Branch already exists: %s", branchName)
 			color.Red(BRANCH_RETURN_CODES[205])
 			return 205
 		}
-
-		CopyFile(dirs.Branches+srcBranch+"/commits.json", dirs.Branches+branchName+"/commits.json")
 	}
 	Debug("Branch created successfully: %s", branchName)
 	color.Green(BRANCH_RETURN_CODES[206])
-	runSwitchCommand(branchName)
+	switchBranch(branchName)
 	return 206
 }
 
@@ -219,6 +304,18 @@ func runDropCommand(branchName string) int {
 		return 001
 	}
 
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		color.Red(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006
+	}
+
 	branches := ListBranches()
 	if !slices.Contains(branches, branchName) {
 		Debug("Branch does not exist: %s", branchName)
@@ -238,11 +335,20 @@ func runDropCommand(branchName string) int {
 		return 209
 	}
 
-	if err := os.RemoveAll(dirs.Branches + branchName); err != nil {
+	commits, err := GetBranchCommits(store, branchName)
+	if err != nil {
+		Debug("Failed to read commits of branch: %s", branchName)
+		MustSucceed(err, "operation failed")
+	}
+	if err := store.RemoveBranch(branchName); err != nil {
 		Debug("Failed to delete branch: %s", branchName)
 		color.Red(BRANCH_RETURN_CODES[207])
 		return 207
 	}
+	if err := RecordDroppedCommits(store, branchName, commits, nil); err != nil {
+		Debug("Failed to record dropped commits")
+		MustSucceed(err, "operation failed")
+	}
 	Debug("Branch deleted successfully: %s", branchName)
 	color.Green(BRANCH_RETURN_CODES[210])
 	return 210
@@ -255,6 +361,23 @@ func runSwitchCommand(branchName string) int {
 		return 001
 	}
 
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		color.Red(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006
+	}
+
+	return switchBranch(branchName)
+}
+
+// switchBranch switches to the branch, the caller must hold the repository lock.
+func switchBranch(branchName string) int {
 	currentBranch := GetCurrentBranchName()
 	if currentBranch == branchName {
 		Debug("Already on branch: %s", branchName)
@@ -302,21 +425,9 @@ func runSwitchCommand(branchName string) int {
 
 	Debug("Remove tracked files for current branch %s before switching.", currentBranch)
 	oldBranchCommitId := GetLastCommitByBranch(currentBranch).Id
-	if oldBranchCommitId != "" {
-		fileList := GetFileListContent(oldBranchCommitId)
-		for _, file := range *fileList {
-			RemoveFile("./" + file.Path)
-		}
-	}
 	newBrnachCommitId := GetLastCommitByBranch(branchName).Id
 	Debug("Switching to commit: %s", newBrnachCommitId)
-	if newBrnachCommitId != "" {
-		fileList := GetFileListContent(newBrnachCommitId)
-		for _, file := range *fileList {
-			_, fileName := ParsePath(file.Path)
-			CopyFile(dirs.Commits+file.CommitId+"/"+file.Id+"/"+fileName, "./"+file.Path)
-		}
-	}
+	CheckoutFiles(oldBranchCommitId, newBrnachCommitId)
 	SetBranch(branchName, "current")
 	Debug("Switched to branch: %s", branchName)
 	color.Cyan(BRANCH_RETURN_CODES[213] + branchName)
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"os"
 	"slices"
@@ -38,29 +37,27 @@ func CreateBranchesMetadata() {
 		Default: InitBranch,
 		Current: InitBranch,
 	}
-	WriteJson(dirs.BranchesMetadata, payload)
+	if err := store.WriteBranchesMetadata(payload); err != nil {
+		Debug("Failed to write branches metadata")
+		MustSucceed(err, "operation failed")
+	}
 	Debug("Branches metadata created with initial branch: %s", InitBranch)
 }
 
 func GetBranchesMetadata() (m *BranchMetadata) {
 	Debug("Reading branches metadata")
-	content, err := os.ReadFile(dirs.BranchesMetadata)
+	metadata, err := store.ReadBranchesMetadata()
 	if err != nil {
 		Debug("Failed to read branches metadata")
 		MustSucceed(err, "operation failed")
 	}
-	var metadata BranchMetadata
-	if err = json.Unmarshal(content, &metadata); err != nil {
-		Debug("Failed to unmarshal branches metadata")
-		MustSucceed(err, "operation failed")
-	}
 	Debug("Branches metadata retrieved successfully")
 	return &metadata
 }
 
 func SetBranch(branch string, configParam string) error {
 	Debug("Setting branch: branch=%s, config=%s", branch, configParam)
-	err := WithLock(dirs.BranchesMetadata, DefaultLockTimeout, func() error {
+	err := store.WithLock(BranchesMetadataLock, func() error {
 		metadata := GetBranchesMetadata()
 
 		if (configParam == DefaultBranch && metadata.Default == branch) || (configParam == CurrentBranch && metadata.Current == branch) {
@@ -82,12 +79,7 @@ func SetBranch(branch string, configParam string) error {
 			return errors.New(BRANCH_RETURN_CODES[216])
 		}
 
-		jsonData, err := json.Marshal(metadata)
-		if err != nil {
-			Debug("Failed to marshal branch metadata")
-			MustSucceed(err, "operation failed")
-		}
-		if err = os.WriteFile(dirs.BranchesMetadata, jsonData, 0644); err != nil {
+		if err := store.WriteBranchesMetadata(*metadata); err != nil {
 			Debug("Failed to write branch metadata")
 			MustSucceed(err, "operation failed")
 		}
@@ -107,17 +99,44 @@ func SetBranch(branch string, configParam string) error {
 
 func ListBranches() []string {
 	Debug("Listing all branches")
-	entries, err := os.ReadDir(dirs.Branches)
+	branches, err := store.ListBranches()
 	if err != nil {
-		Debug("Failed to read branches directory")
+		Debug("Failed to list branches")
 		MustSucceed(err, "operation failed")
 	}
-	branches := []string{}
-	for _, e := range entries {
-		if e.IsDir() {
-			branches = append(branches, e.Name())
-		}
-	}
 	Debug("Found %d branches: %v", len(branches), branches)
 	return branches
 }
+
+// CheckoutFiles replaces the tracked files of the old commit in the working directory with the files of the new commit.
+// Either commit may be empty, e.g. when leaving or entering a branch without commits.
+func CheckoutFiles(oldCommitId string, newCommitId string) {
+	Debug("Checking out files: old=%s, new=%s", oldCommitId, newCommitId)
+	if oldCommitId != "" {
+		fileList := GetFileListContent(oldCommitId)
+		for _, file := range *fileList {
+			if err := ValidateTrackedPath(file.Path); err != nil {
+				Debug("Skipping file: %v", err)
+				continue
+			}
+			if err := CheckSymlinkParents(".", file.Path); err != nil {
+				Debug("Skipping file: %v", err)
+				continue
+			}
+			if file.IsDir() {
+				// Only left empty, the files put in it since are not the commit's to remove.
+				os.Remove("./" + file.Path)
+				continue
+			}
+			RemoveFile("./" + file.Path)
+		}
+	}
+	if newCommitId != "" {
+		fileList := GetFileListContent(newCommitId)
+		for _, file := range *fileList {
+			if err := RestoreObject(file); err != nil {
+				Debug("Failed to restore file: %s", file.Path)
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+
+	bundleCmd.AddCommand(bundleCreateCmd)
+	bundleCmd.AddCommand(bundleVerifyCmd)
+	bundleCmd.AddCommand(bundleUnbundleCmd)
+}
+
+// A bundle is a single file holding commits, their files and a branch, with a checksum for every entry.
+// Besides `unbundle`, a bundle file can be used as a remote that clone, fetch and pull read from.
+var bundleCmd = &cobra.Command{
+	Use:     "bundle",
+	Short:   "Move commits between repositories through a file",
+	Example: "nexio bundle create work.bundle main\nnexio bundle verify work.bundle\nnexio bundle unbundle work.bundle",
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:     "create",
+	Short:   "Write a branch, or the commits of a branch after a commit, to a bundle file",
+	Example: "nexio bundle create work.bundle main\nnexio bundle create work.bundle <commit-id>..main",
+	Args:    cobra.ExactArgs(2),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bundle create command: file=%s, range=%s", args[0], args[1])
+		runBundleCreateCommand(args[0], args[1])
+	},
+}
+
+var bundleVerifyCmd = &cobra.Command{
+	Use:     "verify",
+	Short:   "Check the checksums of a bundle and whether this repository can import it",
+	Example: "nexio bundle verify work.bundle",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bundle verify command: file=%s", args[0])
+		runBundleVerifyCommand(args[0])
+	},
+}
+
+var bundleUnbundleCmd = &cobra.Command{
+	Use:     "unbundle",
+	Short:   "Import the commits of a bundle and fast-forward its branches",
+	Example: "nexio bundle unbundle work.bundle",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting bundle unbundle command: file=%s", args[0])
+		runBundleUnbundleCommand(args[0])
+	},
+}
+
+func runBundleCreateCommand(path string, spec string) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	branch, commits, include, err := ResolveBundleRange(store, spec)
+	if err != nil {
+		Debug("Failed to resolve range: %v", err)
+		Fail(BUNDLE_RETURN_CODES[1906] + " " + err.Error())
+		return 1906
+	}
+	if len(include) == 0 {
+		Debug("%s", BUNDLE_RETURN_CODES[1909])
+		Warning(BUNDLE_RETURN_CODES[1909])
+		return 1909
+	}
+
+	manifest, err := CreateBundle(store, path, branch, commits, include)
+	if err != nil {
+		Debug("Failed to create bundle: %v", err)
+		os.Remove(path)
+		Fail(BUNDLE_RETURN_CODES[1908] + " " + err.Error())
+		return 1908
+	}
+
+	BreakLine()
+	Success(BUNDLE_RETURN_CODES[1901])
+	Text("File: "+Code(path), "  ")
+	Text("Branch: "+StyledBranch(branch), "  ")
+	Text(fmt.Sprintf("Commits: %d", len(manifest.Commits)), "  ")
+	if len(manifest.Prerequisites) > 0 {
+		Text(fmt.Sprintf("Requires %d commits in the importing repository", len(manifest.Prerequisites)), "  ")
+	}
+	BreakLine()
+	return 1901
+}
+
+func runBundleVerifyCommand(path string) int {
+	bundle, returnCode := readBundleOrFail(path)
+	if bundle == nil {
+		return returnCode
+	}
+
+	if IsInitialized() {
+		if missing := bundle.MissingPrerequisites(store); len(missing) > 0 {
+			Debug("Missing prerequisites: %v", missing)
+			Fail(BUNDLE_RETURN_CODES[1904])
+			Tree(missing, false)
+			return 1904
+		}
+	}
+
+	BreakLine()
+	Success(BUNDLE_RETURN_CODES[1902])
+	Tree(describeBundle(bundle), false)
+	BreakLine()
+	return 1902
+}
+
+func runBundleUnbundleCommand(path string) (returnCode int, imported []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006, nil
+	}
+
+	bundle, returnCode := readBundleOrFail(path)
+	if bundle == nil {
+		return returnCode, nil
+	}
+	if missing := bundle.MissingPrerequisites(store); len(missing) > 0 {
+		Debug("Missing prerequisites: %v", missing)
+		Fail(BUNDLE_RETURN_CODES[1904])
+		Tree(missing, false)
+		return 1904, nil
+	}
+
+	imported = []string{}
+	for _, id := range bundle.Manifest.Commits {
+		if store.HasCommit(id) {
+			continue
+		}
+		if err := WriteCommitBundle(store, bundle.Commits[id]); err != nil {
+			Debug("Failed to import commit %s", id)
+			MustSucceed(err, "operation failed")
+		}
+		imported = append(imported, id)
+	}
+
+	names := []string{}
+	for name := range bundle.Manifest.Branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{}
+	for _, name := range names {
+		lines = append(lines, StyledBranch(name)+": "+importBundleBranch(name, bundle.Manifest.Branches[name]))
+	}
+
+	BreakLine()
+	Success(fmt.Sprintf("%s %d new commits", BUNDLE_RETURN_CODES[1905], len(imported)))
+	Tree(lines, false)
+	BreakLine()
+	return 1905, imported
+}
+
+// importBundleBranch creates or fast-forwards a local branch to the commits of a bundled branch, the caller
+// must hold the exclusive repository lock. Branches that diverged are left alone.
+func importBundleBranch(branch string, commits []Commit) string {
+	if !slices.Contains(ListBranches(), branch) {
+		if err := store.CreateBranch(branch, commits); err != nil {
+			Debug("Failed to create branch %s", branch)
+			MustSucceed(err, "operation failed")
+		}
+		return "new branch"
+	}
+	localCommits, err := GetBranchCommits(store, branch)
+	if err != nil {
+		Debug("Failed to read branch commits")
+		MustSucceed(err, "operation failed")
+	}
+	switch {
+	case IsFastForward(commits, localCommits):
+		return "up to date"
+	case !IsFastForward(localCommits, commits):
+		return "diverged, not updated"
+	}
+
+	isCurrent := branch == GetCurrentBranchName()
+	if isCurrent && HasUncommittedChanges() {
+		return "has uncommitted changes, not updated"
+	}
+	if err := UpdateRef(store, branch, HeadOf(localCommits), commits, false); err != nil {
+		Debug("Failed to update branch: %v", err)
+		MustSucceed(err, "operation failed")
+	}
+	if isCurrent {
+		CheckoutFiles(HeadOf(localCommits), HeadOf(commits))
+	}
+	return fmt.Sprintf("fast-forwarded by %d commits", len(commits)-len(localCommits))
+}
+
+func readBundleOrFail(path string) (*Bundle, int) {
+	bundle, err := ReadBundle(path)
+	if err != nil {
+		Debug("Failed to read bundle: %v", err)
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			Fail(BUNDLE_RETURN_CODES[1907] + " " + err.Error())
+			return nil, 1907
+		}
+		Fail(BUNDLE_RETURN_CODES[1903] + " " + strings.TrimPrefix(err.Error(), "corrupted bundle: "))
+		return nil, 1903
+	}
+	return bundle, 0
+}
+
+func describeBundle(bundle *Bundle) []string {
+	lines := []string{}
+	for name, commits := range bundle.Manifest.Branches {
+		lines = append(lines, "Branch: "+StyledBranch(name)+" at "+StyledCommit(HeadOf(commits)))
+	}
+	sort.Strings(lines)
+	lines = append(lines, fmt.Sprintf("Commits: %d", len(bundle.Manifest.Commits)))
+	if len(bundle.Manifest.Prerequisites) > 0 {
+		lines = append(lines, fmt.Sprintf("Requires: %d commits", len(bundle.Manifest.Prerequisites)))
+	}
+	return lines
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	BundleFormatVersion = 1
+	bundleManifestPath  = "manifest.json"
+)
+
+// BundleManifest is the first entry of a bundle file. The other entries mirror `.nexio/commits/`:
+// `commits/<commit-id>/{metadata,logs,fileList}.json` and `commits/<commit-id>/<file-id>/<file-name>`.
+type BundleManifest struct {
+	Version int    `json:"version"`
+	Created string `json:"created"`
+	Default string `json:"default"`
+	// Branches holds the full commit list of each bundled branch, oldest first.
+	Branches map[string][]Commit `json:"branches"`
+	Commits  []string            `json:"commits"`
+	// Prerequisites are commits the bundle refers to without containing them, the importing repository must have them.
+	Prerequisites []string `json:"prerequisites"`
+	// Checksums maps every other entry to its SHA-256.
+	Checksums map[string]string `json:"checksums"`
+}
+
+// Bundle is a bundle file read into memory.
+type Bundle struct {
+	Manifest BundleManifest
+	Commits  map[string]CommitBundle
+}
+
+var errBundleReadOnly = errors.New("bundles are read-only, create a new one with `nexio bundle create`")
+
+// ResolveBundleRange returns the branch and the commits to bundle for `<branch>` or `<commit-id>..<branch>`,
+// the latter bundling the commits after commit-id.
+func ResolveBundleRange(s Storage, spec string) (branch string, commits []Commit, include []Commit, err error) {
+	base, branch, isRange := strings.Cut(spec, "..")
+	if !isRange {
+		branch, base = spec, ""
+	}
+	commits, err = GetBranchCommits(s, branch)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("branch %s does not exist", branch)
+	}
+	if base == "" {
+		return branch, commits, commits, nil
+	}
+	index := slices.IndexFunc(commits, func(commit Commit) bool { return commit.Id == base })
+	if index == -1 {
+		return "", nil, nil, fmt.Errorf("commit %s is not on branch %s", base, branch)
+	}
+	return branch, commits, commits[index+1:], nil
+}
+
+// CreateBundle reads the commits to include from the storage and writes them with the branch to path.
+func CreateBundle(s Storage, path string, branch string, commits []Commit, include []Commit) (BundleManifest, error) {
+	manifest := BundleManifest{
+		Version:       BundleFormatVersion,
+		Created:       GetTimestamp(),
+		Default:       branch,
+		Branches:      map[string][]Commit{branch: commits},
+		Commits:       CommitIds(include),
+		Prerequisites: []string{},
+		Checksums:     map[string]string{},
+	}
+	entries := []bundleEntry{}
+	for _, commit := range include {
+		bundle, err := ReadCommitBundle(s, commit.Id)
+		if err != nil {
+			return manifest, err
+		}
+		commitEntries, err := commitBundleEntries(bundle)
+		if err != nil {
+			return manifest, err
+		}
+		entries = append(entries, commitEntries...)
+		for _, entry := range bundle.FileList {
+			if !slices.Contains(manifest.Commits, entry.CommitId) && !slices.Contains(manifest.Prerequisites, entry.CommitId) {
+				manifest.Prerequisites = append(manifest.Prerequisites, entry.CommitId)
+			}
+		}
+	}
+	for _, commit := range commits {
+		if !slices.Contains(manifest.Commits, commit.Id) && !slices.Contains(manifest.Prerequisites, commit.Id) {
+			manifest.Prerequisites = append(manifest.Prerequisites, commit.Id)
+		}
+	}
+	sort.Strings(manifest.Prerequisites)
+	for _, entry := range entries {
+		manifest.Checksums[entry.path] = checksum(entry.data)
+	}
+	return manifest, writeBundleFile(path, manifest, entries)
+}
+
+type bundleEntry struct {
+	path string
+	mode os.FileMode
+	data []byte
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func commitBundleEntries(bundle CommitBundle) ([]bundleEntry, error) {
+	dir := "commits/" + bundle.Id + "/"
+	entries := []bundleEntry{}
+	for name, document := range map[string]any{"metadata.json": bundle.Metadata, "logs.json": bundle.Logs, "fileList.json": bundle.FileList} {
+		data, err := json.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, bundleEntry{path: dir + name, mode: 0644, data: data})
+	}
+	for _, object := range bundle.Objects {
+		entries = append(entries, bundleEntry{path: dir + object.FileId + "/" + object.FileName, mode: object.Mode, data: object.Data})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	return entries, nil
+}
+
+func writeBundleFile(path string, manifest BundleManifest, entries []bundleEntry) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	compressed := gzip.NewWriter(file)
+	archive := tar.NewWriter(compressed)
+	for _, entry := range append([]bundleEntry{{path: bundleManifestPath, mode: 0644, data: data}}, entries...) {
+		header := tarHeader(entry.path, entry.mode, entry.data)
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, err := archive.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// ReadBundle reads a bundle file and verifies the checksum of every entry.
+func ReadBundle(path string) (*Bundle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("not a bundle file: %w", err)
+	}
+	archive := tar.NewReader(compressed)
+
+	entries := map[string]bundleEntry{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corrupted bundle: %w", err)
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("corrupted bundle: %w", err)
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			mode, data = os.ModeSymlink|mode, []byte(header.Linkname)
+		case tar.TypeDir:
+			mode = os.ModeDir | mode
+		}
+		entries[header.Name] = bundleEntry{path: header.Name, mode: mode, data: data}
+	}
+
+	bundle := &Bundle{Commits: map[string]CommitBundle{}}
+	manifestEntry, exists := entries[bundleManifestPath]
+	if !exists {
+		return nil, errors.New("corrupted bundle: missing manifest")
+	}
+	if err := json.Unmarshal(manifestEntry.data, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("corrupted bundle: %w", err)
+	}
+	if bundle.Manifest.Version > BundleFormatVersion {
+		return nil, fmt.Errorf("bundle format version %d is newer than the supported version %d", bundle.Manifest.Version, BundleFormatVersion)
+	}
+	for path := range entries {
+		if _, listed := bundle.Manifest.Checksums[path]; !listed && path != bundleManifestPath {
+			return nil, fmt.Errorf("corrupted bundle: unexpected entry %s", path)
+		}
+	}
+	for path, sum := range bundle.Manifest.Checksums {
+		entry, exists := entries[path]
+		if !exists {
+			return nil, fmt.Errorf("corrupted bundle: missing entry %s", path)
+		}
+		if checksum(entry.data) != sum {
+			return nil, fmt.Errorf("corrupted bundle: checksum mismatch for %s", path)
+		}
+	}
+
+	if err := ValidateRemoteRefs(RemoteRefs{Default: bundle.Manifest.Default, Branches: bundle.Manifest.Branches}); err != nil {
+		return nil, fmt.Errorf("corrupted bundle: %w", err)
+	}
+	for _, id := range bundle.Manifest.Commits {
+		if !IsValidObjectId(id) {
+			return nil, fmt.Errorf("corrupted bundle: invalid commit id %q", id)
+		}
+		commit := CommitBundle{Id: id, Objects: []BundleObject{}}
+		dir := "commits/" + id + "/"
+		for name, document := range map[string]any{"metadata.json": &commit.Metadata, "logs.json": &commit.Logs, "fileList.json": &commit.FileList} {
+			entry, exists := entries[dir+name]
+			if !exists {
+				return nil, fmt.Errorf("corrupted bundle: missing entry %s", dir+name)
+			}
+			if err := json.Unmarshal(entry.data, document); err != nil {
+				return nil, fmt.Errorf("corrupted bundle: %s: %w", dir+name, err)
+			}
+		}
+		if err := ValidateCommitBundle(commit); err != nil {
+			return nil, fmt.Errorf("corrupted bundle: %w", err)
+		}
+		for _, file := range commit.FileList {
+			if file.CommitId != id {
+				continue
+			}
+			_, fileName := ParsePath(file.Path)
+			entry, exists := entries[dir+file.Id+"/"+fileName]
+			if !exists {
+				return nil, fmt.Errorf("corrupted bundle: missing object %s", dir+file.Id+"/"+fileName)
+			}
+			commit.Objects = append(commit.Objects, BundleObject{FileId: file.Id, FileName: fileName, Mode: entry.mode, Data: entry.data})
+		}
+		bundle.Commits[id] = commit
+	}
+	return bundle, nil
+}
+
+// MissingPrerequisites returns the prerequisites of the bundle the storage does not have.
+func (b *Bundle) MissingPrerequisites(s Storage) []string {
+	missing := []string{}
+	for _, id := range b.Manifest.Prerequisites {
+		if !s.HasCommit(id) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// IsBundleFile reports whether a remote URL points to a bundle file rather than a repository.
+func IsBundleFile(url string) bool {
+	info, err := os.Stat(RemotePath(url))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// BundleTransport reads a bundle file like a remote, it cannot be pushed to.
+type BundleTransport struct {
+	bundle *Bundle
+}
+
+func NewBundleTransport(path string) (*BundleTransport, error) {
+	bundle, err := ReadBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BundleTransport{bundle: bundle}, nil
+}
+
+func (t *BundleTransport) ListRefs() (RemoteRefs, error) {
+	return RemoteRefs{Default: t.bundle.Manifest.Default, Branches: t.bundle.Manifest.Branches}, nil
+}
+
+func (t *BundleTransport) MissingCommits(ids []string) ([]string, error) {
+	return nil, errBundleReadOnly
+}
+
+func (t *BundleTransport) FetchCommits(ids []string) ([]CommitBundle, error) {
+	bundles := []CommitBundle{}
+	for _, id := range ids {
+		bundle, exists := t.bundle.Commits[id]
+		if !exists {
+			return nil, fmt.Errorf("bundle does not contain commit %s", id)
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+func (t *BundleTransport) PushCommits(bundles []CommitBundle) error {
+	return errBundleReadOnly
+}
+
+func (t *BundleTransport) UpdateRef(branch string, expectedHead string, commits []Commit, force bool) error {
+	return errBundleReadOnly
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_Bundle_CreateAndUnbundle(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 2)
+
+	full := t.TempDir() + "/full.bundle"
+	if returnCode := runBundleCreateCommand(full, InitBranch); returnCode != 1901 {
+		t.Fatalf("Expected 1901, got %d", returnCode)
+	}
+	base := HeadOf(mustBranchCommits(t, store))
+	head := commitTestChange(t, "third")
+	incremental := t.TempDir() + "/incremental.bundle"
+	if returnCode := runBundleCreateCommand(incremental, base+".."+InitBranch); returnCode != 1901 {
+		t.Fatalf("Expected 1901, got %d", returnCode)
+	}
+	if returnCode := runBundleVerifyCommand(incremental); returnCode != 1902 {
+		t.Errorf("Expected 1902 in the source repository, got %d", returnCode)
+	}
+
+	// Import into a fresh repository.
+	os.RemoveAll(namespace)
+	runInitCommand()
+	if returnCode := runBundleVerifyCommand(incremental); returnCode != 1904 {
+		t.Errorf("Expected 1904 without the base commits, got %d", returnCode)
+	}
+	if returnCode, _ := runBundleUnbundleCommand(incremental); returnCode != 1904 {
+		t.Errorf("Expected 1904 without the base commits, got %d", returnCode)
+	}
+	if returnCode, imported := runBundleUnbundleCommand(full); returnCode != 1905 || len(imported) != 2 {
+		t.Errorf("Expected 1905 with 2 commits, got %d %v", returnCode, imported)
+	}
+	if content, _ := os.ReadFile(namespace + "file2.txt"); string(content) != "content 2" {
+		t.Errorf("Expected unbundled working file, got %q", content)
+	}
+	if returnCode, imported := runBundleUnbundleCommand(incremental); returnCode != 1905 || len(imported) != 1 {
+		t.Errorf("Expected 1905 with 1 commit, got %d %v", returnCode, imported)
+	}
+	if HeadOf(mustBranchCommits(t, store)) != head {
+		t.Errorf("Expected branch to be fast-forwarded to %s", head)
+	}
+	if content, _ := os.ReadFile(namespace + "file1.txt"); string(content) != "third" {
+		t.Errorf("Expected fast-forwarded working file, got %q", content)
+	}
+	if returnCode, imported := runBundleUnbundleCommand(incremental); returnCode != 1905 || len(imported) != 0 {
+		t.Errorf("Expected unbundling twice to import nothing, got %d %v", returnCode, imported)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Bundle_InvalidRange(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	path := t.TempDir() + "/work.bundle"
+	if returnCode := runBundleCreateCommand(path, "missing"); returnCode != 1906 {
+		t.Errorf("Expected 1906, got %d", returnCode)
+	}
+	if returnCode := runBundleCreateCommand(path, "unknown.."+InitBranch); returnCode != 1906 {
+		t.Errorf("Expected 1906, got %d", returnCode)
+	}
+	head := HeadOf(mustBranchCommits(t, store))
+	if returnCode := runBundleCreateCommand(path, head+".."+InitBranch); returnCode != 1909 {
+		t.Errorf("Expected 1909, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Bundle_Corrupted(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	path := t.TempDir() + "/work.bundle"
+	runBundleCreateCommand(path, InitBranch)
+	bundle, err := ReadBundle(path)
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+
+	// Rewrite the bundle with a tampered object but the original checksums.
+	entries := []bundleEntry{}
+	for _, commit := range bundle.Commits {
+		commit.Objects[0].Data = []byte("tampered")
+		commitEntries, _ := commitBundleEntries(commit)
+		entries = append(entries, commitEntries...)
+	}
+	writeBundleFile(path, bundle.Manifest, entries)
+	if returnCode := runBundleVerifyCommand(path); returnCode != 1903 {
+		t.Errorf("Expected 1903 for a tampered bundle, got %d", returnCode)
+	}
+
+	os.WriteFile(path, []byte("not a bundle"), 0644)
+	if returnCode := runBundleVerifyCommand(path); returnCode != 1903 {
+		t.Errorf("Expected 1903 for a file that is not a bundle, got %d", returnCode)
+	}
+	if returnCode := runBundleVerifyCommand(path + ".missing"); returnCode != 1907 {
+		t.Errorf("Expected 1907 for a missing file, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Bundle_AsRemote(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 2)
+
+	path := t.TempDir() + "/work.bundle"
+	runBundleCreateCommand(path, InitBranch)
+	clone := t.TempDir() + "/clone"
+	if returnCode := runCloneCommand(path, clone, false); returnCode != 1401 {
+		t.Fatalf("Expected 1401, got %d", returnCode)
+	}
+	cloned := NewFileStorage(NewDirs(clone + "/"))
+	if HeadOf(mustBranchCommits(t, cloned)) != HeadOf(mustBranchCommits(t, store)) {
+		t.Errorf("Expected clone of the bundle to have the bundled head")
+	}
+
+	runRemoteAddCommand("usb", path)
+	if returnCode, _ := runFetchCommand("usb"); returnCode != 1501 {
+		t.Errorf("Expected 1501, got %d", returnCode)
+	}
+	commitTestChange(t, "third")
+	if returnCode := runPushCommand("usb", "", false, false); returnCode != 1605 {
+		t.Errorf("Expected pushing to a bundle to fail with 1605, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Bundle_InvalidPaths(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	path := t.TempDir() + "/work.bundle"
+	runBundleCreateCommand(path, InitBranch)
+	bundle, err := ReadBundle(path)
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+
+	// Rewrite the bundle with a file outside of the working tree and matching checksums.
+	manifest := bundle.Manifest
+	manifest.Checksums = map[string]string{}
+	entries := []bundleEntry{}
+	for _, commit := range bundle.Commits {
+		commit.FileList[0].Path = "../escaped.txt"
+		commit.Objects[0].FileName = "escaped.txt"
+		commitEntries, _ := commitBundleEntries(commit)
+		entries = append(entries, commitEntries...)
+	}
+	for _, entry := range entries {
+		manifest.Checksums[entry.path] = checksum(entry.data)
+	}
+	writeBundleFile(path, manifest, entries)
+	if _, err := ReadBundle(path); err == nil {
+		t.Error("Expected a bundle with a path outside of the working tree to be rejected")
+	}
+	if returnCode := runBundleVerifyCommand(path); returnCode != 1903 {
+		t.Errorf("Expected 1903 for a bundle with an invalid path, got %d", returnCode)
+	}
+
+	if err := RestoreObject(FileListEntry{Id: GenRandHex(20), CommitId: GenRandHex(20), Path: "../escaped.txt"}); err == nil {
+		t.Error("Expected restoring a file outside of the working tree to fail")
+	}
+
+	os.RemoveAll(namespace)
+}
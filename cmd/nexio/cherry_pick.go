@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cherryPickCmd.Flags().BoolVar(&CherryPickContinue, "continue", false, "Commit the resolved conflicts and pick the remaining commits")
+	cherryPickCmd.Flags().BoolVar(&CherryPickAbort, "abort", false, "Give up and restore the branch as it was before the cherry-pick")
+	cherryPickCmd.MarkFlagsMutuallyExclusive("continue", "abort")
+
+	rootCmd.AddCommand(cherryPickCmd)
+}
+
+var (
+	CherryPickContinue bool
+	CherryPickAbort    bool
+)
+
+var cherryPickCmd = &cobra.Command{
+	Use:     "cherry-pick",
+	Short:   "Copy commits from other branches onto the current branch",
+	Example: "nexio cherry-pick <commit-id>\nnexio cherry-pick feature~2..feature\nnexio cherry-pick --continue\nnexio cherry-pick --abort",
+	Args: func(_ *cobra.Command, args []string) error {
+		if CherryPickContinue || CherryPickAbort {
+			return cobra.NoArgs(nil, args)
+		}
+		if len(args) == 0 {
+			return errors.New("requires at least one revision")
+		}
+		return nil
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting cherry-pick command: revisions=%v, continue=%t, abort=%t", args, CherryPickContinue, CherryPickAbort)
+		switch {
+		case CherryPickContinue:
+			runCherryPickContinueCommand()
+		case CherryPickAbort:
+			runCherryPickAbortCommand()
+		default:
+			runCherryPickCommand(args)
+		}
+	},
+}
+
+func runCherryPickCommand(revisions []string) (returnCode int, commits []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006, nil
+	}
+
+	if _, err := store.ReadCherryPickState(); err == nil {
+		Debug("%s", CHERRY_PICK_RETURN_CODES[2803])
+		Fail(CHERRY_PICK_RETURN_CODES[2803])
+		Text("Finish it with "+Code("nexio cherry-pick --continue")+" or "+Code("nexio cherry-pick --abort"), "")
+		return 2803, nil
+	}
+	if HasUncommittedChanges() {
+		Debug("%s", CHERRY_PICK_RETURN_CODES[2805])
+		Fail(CHERRY_PICK_RETURN_CODES[2805])
+		return 2805, nil
+	}
+
+	picks := []CherryPick{}
+	for _, revision := range revisions {
+		parent, rangeCommits, err := ResolveRange(store, revision)
+		if err != nil {
+			Debug("Failed to resolve revision %s: %v", revision, err)
+			Fail(CHERRY_PICK_RETURN_CODES[2806] + " " + err.Error())
+			return 2806, nil
+		}
+		for _, commit := range rangeCommits {
+			picks = append(picks, CherryPick{Id: commit.Id, Parent: parent})
+			parent = commit.Id
+		}
+	}
+
+	state := CherryPickState{Branch: GetCurrentBranchName(), Original: GetLastCommit().Id, Remaining: picks}
+	BreakLine()
+	return pickCommits(state, nil)
+}
+
+func runCherryPickContinueCommand() (returnCode int, commits []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	state, returnCode := readCherryPickState()
+	if returnCode != 0 {
+		return returnCode, nil
+	}
+	if unresolved := UnresolvedConflicts(); len(unresolved) > 0 {
+		Debug("Unresolved conflicts: %v", unresolved)
+		Fail(CHERRY_PICK_RETURN_CODES[2807])
+		Tree(unresolved, true)
+		return 2807, nil
+	}
+
+	BreakLine()
+	subject := FirstLine(state.Metadata.Message)
+	stageFiles(state.Paths)
+	if IsStagingLogsEmpty() {
+		Warning("Commit changes nothing, skipped: " + subject)
+	} else {
+		_, commitId := commitStagedChanges(state.Metadata)
+		commits = append(commits, commitId)
+		Text(StyledCommit(commitId)+" "+subject, "  ")
+	}
+	state.Current, state.Metadata, state.Paths, state.Conflicts = CherryPick{}, CommitMetadata{}, nil, nil
+	return pickCommits(state, commits)
+}
+
+func runCherryPickAbortCommand() int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	state, returnCode := readCherryPickState()
+	if returnCode != 0 {
+		return returnCode
+	}
+
+	// Drop the commits picked so far, then put back the files of the original head.
+	var head string
+	err = store.WithLock(BranchCommitsLock(state.Branch), func() error {
+		commits, err := GetBranchCommits(store, state.Branch)
+		if err != nil {
+			return err
+		}
+		head = HeadOf(commits)
+		index := slices.IndexFunc(commits, func(commit Commit) bool { return commit.Id == state.Original })
+		commits = commits[:index+1]
+		if len(commits) > 0 {
+			commits[len(commits)-1].Next = ""
+		}
+		return store.WriteBranchCommits(state.Branch, commits)
+	})
+	if err != nil {
+		Debug("Failed to reset branch %s", state.Branch)
+		MustSucceed(err, "operation failed")
+	}
+	TruncateLogs()
+	if err := store.ClearStagedFiles(); err != nil {
+		Debug("Failed to clear staged files")
+		MustSucceed(err, "operation failed")
+	}
+	for _, path := range state.Paths {
+		RemoveFile(path)
+	}
+	CheckoutFiles(head, state.Original)
+	if err := store.RemoveCherryPickState(); err != nil {
+		Debug("Failed to remove cherry-pick state")
+		MustSucceed(err, "operation failed")
+	}
+	Success(CHERRY_PICK_RETURN_CODES[2808] + " Back at " + StyledCommit(state.Original))
+	return 2808
+}
+
+// pickCommits commits the remaining picks one by one and stops at the first one with conflicts,
+// saving the state for --continue. commits are the commits created so far.
+func pickCommits(state CherryPickState, commits []string) (int, []string) {
+	for len(state.Remaining) > 0 {
+		pick := state.Remaining[0]
+		state.Remaining = state.Remaining[1:]
+		metadata, err := store.ReadCommitMetadata(pick.Id)
+		if err != nil {
+			Debug("Failed to read commit metadata of %s", pick.Id)
+			MustSucceed(err, "operation failed")
+		}
+		metadata.Source = pick.Id
+		subject := FirstLine(metadata.Message)
+
+		result, err := PickCommit(store, pick)
+		if err != nil {
+			Debug("Failed to pick commit %s", pick.Id)
+			MustSucceed(err, "operation failed")
+		}
+		if len(result.Conflicts) > 0 {
+			StagePickedPaths(result)
+			state.Current, state.Metadata, state.Paths, state.Conflicts = pick, metadata, result.Paths, result.Conflicts
+			if err := store.WriteCherryPickState(state); err != nil {
+				Debug("Failed to write cherry-pick state")
+				MustSucceed(err, "operation failed")
+			}
+			Fail(CHERRY_PICK_RETURN_CODES[2802] + " " + StyledCommit(pick.Id) + " " + subject)
+			Tree(result.Conflicts, true)
+			Text("Resolve them and run "+Code("nexio cherry-pick --continue")+", or "+Code("nexio cherry-pick --abort"), "")
+			BreakLine()
+			return 2802, commits
+		}
+
+		stageFiles(result.Paths)
+		if IsStagingLogsEmpty() {
+			Warning("Commit changes nothing, skipped: " + subject)
+			continue
+		}
+		_, commitId := commitStagedChanges(metadata)
+		commits = append(commits, commitId)
+		Text(StyledCommit(commitId)+" "+subject, "  ")
+	}
+
+	if err := store.RemoveCherryPickState(); err != nil {
+		Debug("Failed to remove cherry-pick state")
+		MustSucceed(err, "operation failed")
+	}
+	Success(CHERRY_PICK_RETURN_CODES[2801] + " " + FormatFileCount(len(commits)))
+	BreakLine()
+	return 2801, commits
+}
+
+// readCherryPickState returns the stopped cherry-pick, or a return code if there is none.
+func readCherryPickState() (CherryPickState, int) {
+	state, err := store.ReadCherryPickState()
+	if os.IsNotExist(err) {
+		Debug("%s", CHERRY_PICK_RETURN_CODES[2804])
+		Fail(CHERRY_PICK_RETURN_CODES[2804])
+		return state, 2804
+	}
+	if err != nil {
+		Debug("Failed to read cherry-pick state")
+		MustSucceed(err, "operation failed")
+	}
+	return state, 0
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+)
+
+// CherryPick is a commit to copy and the commit before it, the base of the three-way merge.
+type CherryPick struct {
+	Id     string `json:"id"`
+	Parent string `json:"parent"`
+}
+
+// CherryPickState is a cherry-pick stopped by conflicts. Original is the head of the branch before the
+// first commit was picked, Current the commit whose changes are in the working directory.
+type CherryPickState struct {
+	Branch   string         `json:"branch"`
+	Original string         `json:"original"`
+	Current  CherryPick     `json:"current"`
+	Metadata CommitMetadata `json:"metadata"`
+	// Paths are the files the current commit changed, Conflicts those of them still to resolve.
+	Paths     []string     `json:"paths"`
+	Conflicts []string     `json:"conflicts"`
+	Remaining []CherryPick `json:"remaining"`
+}
+
+// CherryPickResult lists the files written or removed when replaying a commit and the conflicting ones.
+type CherryPickResult struct {
+	Paths     []string
+	Conflicts []string
+}
+
+// PickCommit replays the changes of a commit on the files in the working directory. Files changed
+// on both sides are merged with MergeFile, conflicts are left in the files with markers and their
+// versions are staged with StageConflict.
+func PickCommit(s Storage, pick CherryPick) (CherryPickResult, error) {
+	result := CherryPickResult{Paths: []string{}, Conflicts: []string{}}
+	logs, err := s.ReadCommitLogs(pick.Id)
+	if err != nil {
+		return result, err
+	}
+	metadata, err := s.ReadCommitMetadata(pick.Id)
+	if err != nil {
+		return result, err
+	}
+	baseFiles, err := commitFileMap(s, pick.Parent)
+	if err != nil {
+		return result, err
+	}
+	theirsFiles, err := commitFileMap(s, pick.Id)
+	if err != nil {
+		return result, err
+	}
+	theirsLabel := shortCommitId(pick.Id) + " " + FirstLine(metadata.Message)
+
+	paths := []string{}
+	for _, entry := range logs {
+		paths = append(paths, entry.Path)
+		if entry.From != "" {
+			paths = append(paths, entry.From)
+		}
+	}
+	slices.Sort(paths)
+	for _, path := range slices.Compact(paths) {
+		base, baseMode, hasBase, err := readCommitFile(s, baseFiles, path)
+		if err != nil {
+			return result, err
+		}
+		theirs, theirsMode, hasTheirs, err := readCommitFile(s, theirsFiles, path)
+		if err != nil {
+			return result, err
+		}
+		ours, oursMode, err := ReadFileWithMode(path)
+		if err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+		hasOurs := err == nil
+		// The versions kept in the staging area when the path conflicts.
+		versions := map[string]ConflictFile{}
+		if hasBase {
+			versions["base"] = ConflictFile{Data: base, Mode: baseMode}
+		}
+		if hasOurs {
+			versions["ours"] = ConflictFile{Data: ours, Mode: oursMode}
+		}
+		if hasTheirs {
+			versions["theirs"] = ConflictFile{Data: theirs, Mode: theirsMode}
+		}
+
+		switch {
+		case !hasTheirs && !hasOurs:
+			continue
+		case !hasTheirs:
+			result.Paths = append(result.Paths, path)
+			if hasBase && bytes.Equal(ours, base) {
+				RemoveFile(path)
+				continue
+			}
+			// Changed here but removed by the picked commit, the file is kept.
+			if err := StageConflict(path, versions); err != nil {
+				return result, err
+			}
+			result.Conflicts = append(result.Conflicts, path)
+		case !hasOurs && hasBase:
+			// Removed here but changed by the picked commit, the file is brought back.
+			if err := WriteFileWithMode(path, theirs, theirsMode); err != nil {
+				return result, err
+			}
+			if err := StageConflict(path, versions); err != nil {
+				return result, err
+			}
+			result.Paths = append(result.Paths, path)
+			result.Conflicts = append(result.Conflicts, path)
+		default:
+			merged, conflicts := MergeFile(path, base, ours, theirs, HeadRevision, theirsLabel)
+			mode := theirsMode
+			if hasOurs && (!hasBase || theirsMode == baseMode) {
+				mode = oursMode
+			}
+			if hasOurs && bytes.Equal(merged, ours) && mode == oursMode {
+				continue
+			}
+			if err := WriteFileWithMode(path, merged, mode); err != nil {
+				return result, err
+			}
+			result.Paths = append(result.Paths, path)
+			if conflicts > 0 {
+				if err := StageConflict(path, versions); err != nil {
+					return result, err
+				}
+				result.Conflicts = append(result.Conflicts, path)
+			}
+		}
+	}
+	return result, nil
+}
+
+// StagePickedPaths stages the files of a pick stopped on conflicts that merged cleanly, so that they are
+// not left untracked while the conflicts are resolved. Files staged already, by the commits squashed
+// together in a rebase, keep their staged version until the pick is continued. It returns the staged paths.
+func StagePickedPaths(result CherryPickResult) []string {
+	staged := []string{}
+	for _, path := range result.Paths {
+		if slices.Contains(result.Conflicts, path) || IsFileStaged(path) {
+			continue
+		}
+		stageFiles([]string{path})
+		staged = append(staged, path)
+	}
+	return staged
+}
+
+// commitFileMap returns the files of a commit by path, none for an empty commit id.
+func commitFileMap(s Storage, commitId string) (map[string]FileListEntry, error) {
+	files := map[string]FileListEntry{}
+	if commitId == "" {
+		return files, nil
+	}
+	fileList, err := s.ReadFileList(commitId)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range fileList {
+		files[file.Path] = file
+	}
+	return files, nil
+}
+
+func readCommitFile(s Storage, files map[string]FileListEntry, path string) ([]byte, os.FileMode, bool, error) {
+	file, exists := files[path]
+	if !exists {
+		return nil, 0, false, nil
+	}
+	_, fileName := ParsePath(file.Path)
+	data, mode, err := s.ReadObject(file.CommitId, file.Id, fileName)
+	return data, mode, err == nil, err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+// commitTestFile writes a file of the test namespace and commits it.
+func commitTestFile(t *testing.T, name string, content string, message string) string {
+	t.Helper()
+	file := namespace + name
+	os.WriteFile(file, []byte(content), 0644)
+	runAddCommand(file, false)
+	returnCode, commitId := runCommitCommand(message)
+	if returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+	return commitId
+}
+
+func readTestFile(name string) string {
+	content, _ := os.ReadFile(namespace + name)
+	return string(content)
+}
+
+func Test_CherryPick(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\ntwo\nthree\n", "Add file1")
+	runNewCommand("feature", "", "")
+	setConfig("name", "Ada Lovelace")
+	fix := commitTestFile(t, "file1.txt", "one\ntwo\nTHREE\n", "Fix three")
+	added := commitTestFile(t, "file2.txt", "new\n", "Add file2")
+	setConfig("name", "Someone Else")
+	runSwitchCommand(InitBranch)
+	commitTestFile(t, "file1.txt", "ONE\ntwo\nthree\n", "Change one")
+
+	returnCode, commits := runCherryPickCommand([]string{fix, added})
+	if returnCode != 2801 || len(commits) != 2 {
+		t.Fatalf("Expected both commits to be picked, got %d %v", returnCode, commits)
+	}
+	if content := readTestFile("file1.txt"); content != "ONE\ntwo\nTHREE\n" {
+		t.Errorf("Expected the changes of both branches in file1.txt, got %q", content)
+	}
+	if content := readTestFile("file2.txt"); content != "new\n" {
+		t.Errorf("Expected file2.txt to be added, got %q", content)
+	}
+	metadata, _ := store.ReadCommitMetadata(commits[0])
+	if metadata.Author.Name != "Ada Lovelace" || metadata.Message != "Fix three" || metadata.Source != fix {
+		t.Errorf("Expected the original author, message and source, got %v", metadata)
+	}
+	if branchCommits := mustBranchCommits(t, store); HeadOf(branchCommits) != commits[1] || len(branchCommits) != 4 {
+		t.Errorf("Expected the picked commits on the current branch, got %v", branchCommits)
+	}
+	if HasUncommittedChanges() {
+		t.Errorf("Expected no uncommitted changes after the cherry-pick")
+	}
+
+	// Picking the same changes again changes nothing.
+	if returnCode, commits := runCherryPickCommand([]string{fix}); returnCode != 2801 || len(commits) != 0 {
+		t.Errorf("Expected the commit to be skipped, got %d %v", returnCode, commits)
+	}
+	if returnCode, _ := runCherryPickCommand([]string{"unknown"}); returnCode != 2806 {
+		t.Errorf("Expected 2806 for an unknown revision, got %d", returnCode)
+	}
+	if returnCode := runCherryPickAbortCommand(); returnCode != 2804 {
+		t.Errorf("Expected 2804 without a cherry-pick in progress, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_CherryPick_Conflicts(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\ntwo\nthree\n", "Add file1")
+	runNewCommand("feature", "", "")
+	conflicting := commitTestFile(t, "file1.txt", "one\nzwei\nthree\n", "Translate two")
+	clean := commitTestFile(t, "file2.txt", "new\n", "Add file2")
+	runSwitchCommand(InitBranch)
+	original := commitTestFile(t, "file1.txt", "one\nTWO\nthree\n", "Shout two")
+
+	returnCode, _ := runCherryPickCommand([]string{"feature~2..feature"})
+	if returnCode != 2802 {
+		t.Fatalf("Expected the cherry-pick to stop on conflicts, got %d", returnCode)
+	}
+	if !HasConflictMarkers([]byte(readTestFile("file1.txt"))) {
+		t.Errorf("Expected conflict markers in file1.txt, got %q", readTestFile("file1.txt"))
+	}
+	if returnCode, _ := runCherryPickCommand([]string{clean}); returnCode != 2803 {
+		t.Errorf("Expected 2803 while a cherry-pick is stopped, got %d", returnCode)
+	}
+	if returnCode, _ := runCherryPickContinueCommand(); returnCode != 2807 {
+		t.Errorf("Expected 2807 with unresolved conflicts, got %d", returnCode)
+	}
+
+	// Abort restores the branch, then the conflict is resolved on a second attempt.
+	if returnCode := runCherryPickAbortCommand(); returnCode != 2808 {
+		t.Fatalf("Expected 2808, got %d", returnCode)
+	}
+	if content := readTestFile("file1.txt"); content != "one\nTWO\nthree\n" {
+		t.Errorf("Expected file1.txt of the original head after abort, got %q", content)
+	}
+	if HeadOf(mustBranchCommits(t, store)) != original || HasUncommittedChanges() {
+		t.Errorf("Expected the branch and working directory as before the cherry-pick")
+	}
+
+	if returnCode, _ := runCherryPickCommand([]string{conflicting, clean}); returnCode != 2802 {
+		t.Fatalf("Expected the cherry-pick to stop on conflicts, got %d", returnCode)
+	}
+	os.WriteFile(namespace+"file1.txt", []byte("one\nTWO zwei\nthree\n"), 0644)
+	returnCode, commits := runCherryPickContinueCommand()
+	if returnCode != 2801 || len(commits) != 2 {
+		t.Fatalf("Expected both commits after continuing, got %d %v", returnCode, commits)
+	}
+	if metadata, _ := store.ReadCommitMetadata(commits[0]); metadata.Source != conflicting || metadata.Message != "Translate two" {
+		t.Errorf("Expected the resolved commit to keep its source, got %v", metadata)
+	}
+	if readTestFile("file1.txt") != "one\nTWO zwei\nthree\n" || readTestFile("file2.txt") != "new\n" {
+		t.Errorf("Expected the resolution and the remaining commit in the working directory")
+	}
+	if _, err := store.ReadCherryPickState(); !os.IsNotExist(err) {
+		t.Errorf("Expected the cherry-pick state to be removed, got %v", err)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+// commitConflictingChange commits file1.txt together with a new g.txt, the new file merges cleanly.
+func commitConflictingChange(t *testing.T, content string) string {
+	t.Helper()
+	os.WriteFile(namespace+"file1.txt", []byte(content), 0644)
+	os.WriteFile(namespace+"g.txt", []byte("g\n"), 0644)
+	runAddCommand(namespace+"file1.txt", false)
+	runAddCommand(namespace+"g.txt", false)
+	returnCode, commitId := runCommitCommand("Change file1 and add g")
+	if returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+	return commitId
+}
+
+func Test_CherryPick_StagesCleanChanges(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\ntwo\nthree\n", "Add file1")
+	runNewCommand("feature", "", "")
+	picked := commitConflictingChange(t, "one\nzwei\nthree\n")
+	runSwitchCommand(InitBranch)
+	commitTestFile(t, "file1.txt", "one\nTWO\nthree\n", "Shout two")
+
+	if returnCode, _ := runCherryPickCommand([]string{picked}); returnCode != 2802 {
+		t.Fatalf("Expected the cherry-pick to stop on conflicts, got %d", returnCode)
+	}
+	g := namespace + "g.txt"
+	if !IsFileStaged(g) || slices.Contains(GetUntrackedFiles(), g) {
+		t.Errorf("Expected g.txt to be staged while the conflicts are resolved")
+	}
+	if _, removed := runCleanCommand([]string{namespace}, false, true, false, false, false); slices.Contains(removed, g) {
+		t.Errorf("Expected clean to leave the staged g.txt alone, removed %v", removed)
+	}
+
+	// Dropping the new file before continuing commits the resolution alone.
+	os.Remove(g)
+	os.WriteFile(namespace+"file1.txt", []byte("one\nTWO zwei\nthree\n"), 0644)
+	returnCode, commits := runCherryPickContinueCommand()
+	if returnCode != 2801 || len(commits) != 1 {
+		t.Fatalf("Expected the resolution to be committed, got %d %v", returnCode, commits)
+	}
+	if fileList := GetFileListContent(commits[0]); slices.ContainsFunc(*fileList, func(file FileListEntry) bool { return file.Path == g }) {
+		t.Errorf("Expected the deleted g.txt not to be committed")
+	}
+
+	os.RemoveAll(namespace)
+}
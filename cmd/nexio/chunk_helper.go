@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// DefaultLargeFileThreshold is the size from which committed files are stored in chunks when
+// large-file-threshold is not configured.
+const DefaultLargeFileThreshold = 64 << 20
+
+// Chunks are cut where the rolling hash of the content matches chunkMask, about every 1 MB, so an
+// edit only changes the chunks around it. They are kept between chunkMinSize and chunkMaxSize.
+const (
+	chunkMinSize = 256 << 10
+	chunkMaxSize = 4 << 20
+	chunkMask    = 1<<20 - 1
+)
+
+// ChunkManifestSuffix is appended to the name of a committed file stored in chunks, the manifest
+// takes the place of its content.
+const ChunkManifestSuffix = ".chunks"
+
+// ChunkManifest lists the chunks of a file by their SHA-256, in order.
+type ChunkManifest struct {
+	Size   int64    `json:"size"`
+	Chunks []string `json:"chunks"`
+}
+
+// gearTable maps every byte to a pseudo-random number mixed into the rolling hash. It must never
+// change, the chunks already stored would no longer be found.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		table[i] = z ^ z>>31
+	}
+	return table
+}()
+
+// LargeFileThreshold returns the configured size from which files are stored in chunks.
+func LargeFileThreshold(config Config) int64 {
+	if config.LargeFileThreshold == "" {
+		return DefaultLargeFileThreshold
+	}
+	threshold, err := ParseSize(config.LargeFileThreshold)
+	if err != nil {
+		Debug("Invalid large file threshold, using the default: %v", err)
+		return DefaultLargeFileThreshold
+	}
+	return threshold
+}
+
+// SplitChunks cuts data into content-defined chunks.
+func SplitChunks(data []byte) [][]byte {
+	chunks := [][]byte{}
+	for len(data) > 0 {
+		length := chunkLength(data)
+		chunks = append(chunks, data[:length])
+		data = data[length:]
+	}
+	return chunks
+}
+
+func chunkLength(data []byte) int {
+	if len(data) <= chunkMinSize {
+		return len(data)
+	}
+	var hash uint64
+	end := min(len(data), chunkMaxSize)
+	for i := chunkMinSize; i < end; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if hash&chunkMask == 0 {
+			return i + 1
+		}
+	}
+	return end
+}
+
+// ChunkId is the name a chunk is stored under.
+func ChunkId(chunk []byte) string {
+	sum := sha256.Sum256(chunk)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeChunked stores data as the chunks missing from chunksDir and writes the manifest to path.
+func writeChunked(chunksDir string, path string, data []byte, mode os.FileMode) error {
+	manifest := ChunkManifest{Size: int64(len(data)), Chunks: []string{}}
+	for _, chunk := range SplitChunks(data) {
+		id := ChunkId(chunk)
+		manifest.Chunks = append(manifest.Chunks, id)
+		// Identical chunks are stored once, whichever file or commit they come from.
+		if FileExists(chunksDir + id) {
+			continue
+		}
+		if err := WriteFileWithMode(chunksDir+id, chunk, 0644); err != nil {
+			return err
+		}
+	}
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return WriteFileWithMode(path+ChunkManifestSuffix, content, mode)
+}
+
+// readChunked puts the content of a file stored in chunks back together, checking every chunk.
+func readChunked(chunksDir string, path string) ([]byte, os.FileMode, error) {
+	manifest, mode, err := readChunkManifest(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var data bytes.Buffer
+	data.Grow(int(manifest.Size))
+	for _, id := range manifest.Chunks {
+		chunk, err := os.ReadFile(chunksDir + id)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ChunkId(chunk) != id {
+			return nil, 0, errors.New("corrupted chunk " + id)
+		}
+		data.Write(chunk)
+	}
+	if int64(data.Len()) != manifest.Size {
+		return nil, 0, errors.New("corrupted chunk manifest " + path + ChunkManifestSuffix)
+	}
+	return data.Bytes(), mode, nil
+}
+
+func readChunkManifest(path string) (ChunkManifest, os.FileMode, error) {
+	var manifest ChunkManifest
+	content, mode, err := ReadFileWithMode(path + ChunkManifestSuffix)
+	if err != nil {
+		return manifest, 0, err
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, 0, err
+	}
+	return manifest, mode, nil
+}
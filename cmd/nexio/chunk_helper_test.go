@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func Test_SplitChunks(t *testing.T) {
+	data := make([]byte, 16<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	chunks := SplitChunks(data)
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fatalf("Expected the chunks to make up the data")
+	}
+	for i, chunk := range chunks {
+		if len(chunk) > chunkMaxSize || len(chunk) < chunkMinSize && i < len(chunks)-1 {
+			t.Errorf("Expected chunk %d to be within the size limits, got %d bytes", i, len(chunk))
+		}
+	}
+
+	// Inserting bytes only changes the chunks around the edit.
+	edited := append(append(append([]byte{}, data[:8<<20]...), "inserted"...), data[8<<20:]...)
+	ids := map[string]bool{}
+	for _, chunk := range chunks {
+		ids[ChunkId(chunk)] = true
+	}
+	changed := 0
+	for _, chunk := range SplitChunks(edited) {
+		if !ids[ChunkId(chunk)] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 2 {
+		t.Errorf("Expected 1 or 2 new chunks after the edit, got %d of %d", changed, len(chunks))
+	}
+	if chunks := SplitChunks(nil); len(chunks) != 0 {
+		t.Errorf("Expected no chunks for empty data, got %d", len(chunks))
+	}
+}
+
+func Test_ParseSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+	}{
+		{"100", 100},
+		{"100B", 100},
+		{"512KB", 512 << 10},
+		{"100 MB", 100 << 20},
+		{"2gb", 2 << 30},
+	}
+	for _, test := range tests {
+		if size, err := ParseSize(test.value); err != nil || size != test.expected {
+			t.Errorf("ParseSize(%q): expected %d, got %d %v", test.value, test.expected, size, err)
+		}
+	}
+	for _, value := range []string{"", "MB", "-1MB", "1.5MB", "100XB"} {
+		if _, err := ParseSize(value); err == nil {
+			t.Errorf("ParseSize(%q): expected an error", value)
+		}
+	}
+}
+
+func Test_LargeFiles_StoredInChunks(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	if returnCode := setConfig("large-file-threshold", "1MB"); returnCode != 603 {
+		t.Fatalf("Expected 603, got %d", returnCode)
+	}
+	if returnCode := setConfig("large-file-threshold", "big"); returnCode != 611 {
+		t.Errorf("Expected 611, got %d", returnCode)
+	}
+
+	data := make([]byte, 8<<20)
+	rand.New(rand.NewSource(2)).Read(data)
+	commitTestFile(t, "asset.bin", string(data), "add asset")
+	commitTestFile(t, "small.txt", "small", "add small file")
+	first := (*GetFileListContent(GetLastCommit().Id))
+	chunks, _ := os.ReadDir(dirs.Chunks)
+	if len(chunks) == 0 {
+		t.Fatalf("Expected the large file to be stored in chunks")
+	}
+
+	edited := append(append([]byte{}, data...), "appended"...)
+	copy(edited[4<<20:], "edited")
+	commitTestFile(t, "asset.bin", string(edited), "edit asset")
+	after, _ := os.ReadDir(dirs.Chunks)
+	if added := len(after) - len(chunks); added == 0 || added > 3 {
+		t.Errorf("Expected a few new chunks after a small edit, got %d more than %d", added, len(chunks))
+	}
+
+	files, err := commitFileMap(store, GetLastCommit().Id)
+	if err != nil {
+		t.Fatalf("Failed to read the file list: %v", err)
+	}
+	for _, test := range []struct {
+		path     string
+		expected []byte
+	}{{"asset.bin", edited}, {"small.txt", []byte("small")}} {
+		file := files[namespace+test.path]
+		if content, mode, err := store.ReadObject(file.CommitId, file.Id, test.path); err != nil || !bytes.Equal(content, test.expected) || mode.Perm() != 0644 {
+			t.Errorf("Expected %s to be read back, got %d bytes %v %v", test.path, len(content), mode, err)
+		}
+	}
+	if FileExists(dirs.Commits + files[namespace+"small.txt"].CommitId + "/" + files[namespace+"small.txt"].Id + "/small.txt" + ChunkManifestSuffix) {
+		t.Errorf("Expected small files to be stored as they are")
+	}
+	if returnCode, problems := runFsckCommand(store, false); returnCode != 1001 {
+		t.Errorf("Expected a clean repository, got %d %v", returnCode, problems)
+	}
+
+	// A lost chunk breaks every version of the file using it.
+	for _, entry := range first {
+		if entry.Path == namespace+"asset.bin" {
+			RemoveFile(dirs.Chunks + mustReadChunkManifest(t, entry).Chunks[0])
+		}
+	}
+	if returnCode, problems := runFsckCommand(store, false); !hasProblem(problems, SeverityError, "missing chunk of "+namespace+"asset.bin") {
+		t.Errorf("Expected the missing chunk to be reported, got %d %v", returnCode, problems)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func mustReadChunkManifest(t *testing.T, entry FileListEntry) ChunkManifest {
+	t.Helper()
+	_, fileName := ParsePath(entry.Path)
+	manifest, _, err := readChunkManifest(dirs.Commits + entry.CommitId + "/" + entry.Id + "/" + fileName)
+	if err != nil {
+		t.Fatalf("Failed to read chunk manifest of %s: %v", entry.Path, err)
+	}
+	return manifest
+}
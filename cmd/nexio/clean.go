@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cleanCmd.Flags().BoolVarP(&CleanDryRun, "dry-run", "n", false, "Only list what would be removed")
+	cleanCmd.Flags().BoolVarP(&CleanForce, "force", "f", false, "Remove the untracked files")
+	cleanCmd.Flags().BoolVarP(&CleanDirectories, "directories", "d", false, "Also remove the directories left empty")
+	cleanCmd.Flags().BoolVarP(&CleanIgnored, "ignored", "x", false, "Also remove the files ignored by the rules")
+	cleanCmd.Flags().BoolVarP(&CleanInteractive, "interactive", "i", false, "Select the files to remove")
+
+	rootCmd.AddCommand(cleanCmd)
+}
+
+var (
+	CleanDryRun      bool
+	CleanForce       bool
+	CleanDirectories bool
+	CleanIgnored     bool
+	CleanInteractive bool
+)
+
+var cleanCmd = &cobra.Command{
+	Use:     "clean",
+	Short:   "Remove untracked files from the working directory",
+	Example: "nexio clean -n\nnexio clean -f\nnexio clean -fdx <path>...\nnexio clean -i",
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting clean command: paths=%v, dry-run=%t, force=%t, directories=%t, ignored=%t, interactive=%t", args, CleanDryRun, CleanForce, CleanDirectories, CleanIgnored, CleanInteractive)
+		runCleanCommand(args, CleanDryRun, CleanForce, CleanDirectories, CleanIgnored, CleanInteractive)
+	},
+}
+
+func runCleanCommand(paths []string, dryRun bool, force bool, directories bool, ignored bool, interactive bool) (returnCode int, removed []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+	if !dryRun && !force && !interactive {
+		Debug("%s", CLEAN_RETURN_CODES[3304])
+		Fail(CLEAN_RETURN_CODES[3304])
+		return 3304, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	for _, path := range paths {
+		if ValidatePath(path) != nil {
+			Debug("%s", COMMON_RETURN_CODES[004])
+			Fail(COMMON_RETURN_CODES[004])
+			return 004, nil
+		}
+	}
+	candidates := CleanCandidates(paths, directories, ignored)
+	if len(candidates) == 0 {
+		Debug("%s", CLEAN_RETURN_CODES[3303])
+		Info(CLEAN_RETURN_CODES[3303])
+		return 3303, nil
+	}
+	if dryRun {
+		Info(CLEAN_RETURN_CODES[3302])
+		Tree(candidates, false)
+		return 3302, candidates
+	}
+	if interactive && os.Getenv("NEXIO_ENV") != "test" {
+		selected, err := pterm.DefaultInteractiveMultiselect.
+			WithOptions(candidates).
+			WithMaxHeight(15).
+			Show(pterm.Yellow("?") + pterm.Cyan(" Select the files to remove"))
+		if err != nil {
+			Debug("Failed to get user input for clean command.")
+			MustSucceed(err, "operation failed")
+		}
+		candidates = selected
+	}
+	if len(candidates) == 0 {
+		Debug("%s", CLEAN_RETURN_CODES[3305])
+		Info(CLEAN_RETURN_CODES[3305])
+		return 3305, nil
+	}
+
+	removed = []string{}
+	for _, candidate := range candidates {
+		if dir, isDir := strings.CutSuffix(candidate, "/"); isDir {
+			// Directories come after their files and only go if nothing was left in them.
+			if err := os.Remove(dir); err != nil {
+				Debug("Directory not removed: %s: %v", dir, err)
+				continue
+			}
+		} else {
+			RemoveFile(candidate)
+		}
+		removed = append(removed, candidate)
+	}
+
+	Success(CLEAN_RETURN_CODES[3301])
+	Tree(removed, false)
+	return 3301, removed
+}
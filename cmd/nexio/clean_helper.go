@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CleanCandidates returns what clean removes under paths, the whole working directory if none are
+// given: untracked files, ignored ones too if ignored is set. With directories, the directories left
+// without files once those are removed follow, with a trailing slash and deepest first.
+func CleanCandidates(paths []string, directories bool, ignored bool) []string {
+	scope := []string{"."}
+	if len(paths) > 0 {
+		scope = []string{}
+		for _, path := range paths {
+			scope = append(scope, filepath.Clean(path))
+		}
+	}
+	under := func(file string) bool {
+		return slices.ContainsFunc(scope, func(path string) bool {
+			return path == "." || file == path || strings.HasPrefix(file, path+"/")
+		})
+	}
+
+	files := GetUntrackedFiles()
+	if ignored {
+		files = append(files, GetIgnoredFiles()...)
+	}
+	files = slices.DeleteFunc(files, func(file string) bool { return !under(file) })
+	slices.Sort(files)
+	files = slices.Compact(files)
+	if !directories {
+		return files
+	}
+
+	// A directory can go once nothing is kept in it: no tracked or ignored file, no tracked directory
+	// and no repository.
+	removed := map[string]bool{}
+	for _, file := range files {
+		removed[file] = true
+	}
+	kept := map[string]bool{}
+	candidates := []string{}
+	filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.Contains(path, ".nexio") || !info.IsDir() && !removed[path] {
+			for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+				kept[dir] = true
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Tracked empty directories stay.
+		if committed, _, _ := GetFileMetadata(path); committed || IsFileStaged(path) {
+			for dir := path; dir != "."; dir = filepath.Dir(dir) {
+				kept[dir] = true
+			}
+		}
+		if info.IsDir() && path != "." && under(path) {
+			candidates = append(candidates, path)
+		}
+		return nil
+	})
+	empty := slices.DeleteFunc(candidates, func(dir string) bool { return kept[dir] })
+	slices.SortStableFunc(empty, func(a, b string) int { return strings.Count(b, "/") - strings.Count(a, "/") })
+	for _, dir := range empty {
+		files = append(files, dir+"/")
+	}
+	return files
+}
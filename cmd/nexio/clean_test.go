@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func Test_Clean(t *testing.T) {
+	// Clean walks the whole namespace, which is the working directory without NEXIO_ENV=test.
+	t.Chdir(t.TempDir())
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\n", "Add file1")
+	if err := os.WriteFile(".nexio.rules.yml", []byte("ignore:\n  - \"*.log\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test rules file: %v", err)
+	}
+	defer os.Remove(".nexio.rules.yml")
+	os.MkdirAll(namespace+"build/out", 0755)
+	os.MkdirAll(namespace+"empty", 0755)
+	os.WriteFile(namespace+"build/out/app", []byte("binary\n"), 0644)
+	os.WriteFile(namespace+"notes.txt", []byte("notes\n"), 0644)
+	os.WriteFile(namespace+"debug.log", []byte("log\n"), 0644)
+	os.WriteFile(namespace+"staged.txt", []byte("staged\n"), 0644)
+	runAddCommand(namespace+"staged.txt", false)
+
+	if returnCode, _ := runCleanCommand([]string{namespace}, false, false, false, false, false); returnCode != 3304 {
+		t.Errorf("Expected 3304 without -f, -n or -i, got %d", returnCode)
+	}
+
+	returnCode, listed := runCleanCommand([]string{namespace}, true, false, true, true, false)
+	expected := []string{namespace + "build/out/app", namespace + "debug.log", namespace + "notes.txt", namespace + "build/out/", namespace + "build/", namespace + "empty/"}
+	if returnCode != 3302 || !slices.Equal(listed, expected) {
+		t.Fatalf("Expected 3302 and %v, got %d %v", expected, returnCode, listed)
+	}
+	if !FileExists(namespace + "notes.txt") {
+		t.Fatalf("Expected a dry run to keep the files")
+	}
+
+	// Ignored files and directories are kept by default.
+	returnCode, removed := runCleanCommand([]string{namespace}, false, true, false, false, false)
+	if returnCode != 3301 || !slices.Equal(removed, []string{namespace + "build/out/app", namespace + "notes.txt"}) {
+		t.Fatalf("Expected 3301 and the untracked files, got %d %v", returnCode, removed)
+	}
+	if !FileExists(namespace+"debug.log") || !FileExists(namespace+"staged.txt") || !FileExists(namespace+"file1.txt") {
+		t.Errorf("Expected the ignored, staged and committed files to be kept")
+	}
+	if _, err := os.Stat(namespace + "build/out"); err != nil {
+		t.Errorf("Expected the directories to be kept without -d")
+	}
+
+	if returnCode, removed := runCleanCommand([]string{namespace}, false, true, true, true, false); returnCode != 3301 || len(removed) != 4 {
+		t.Fatalf("Expected 3301 and the ignored file with the directories, got %d %v", returnCode, removed)
+	}
+	for _, path := range []string{"debug.log", "build", "empty"} {
+		if _, err := os.Stat(namespace + path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	if returnCode, _ := runCleanCommand([]string{namespace}, false, true, true, true, false); returnCode != 3303 {
+		t.Errorf("Expected 3303, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cloneCmd.Flags().BoolVar(&CloneBare, "bare", false, "Create a repository without a working tree, to be pushed to")
+
+	rootCmd.AddCommand(cloneCmd)
+}
+
+var CloneBare bool
+
+var cloneCmd = &cobra.Command{
+	Use:     "clone",
+	Short:   "Copy a remote repository into a new directory",
+	Example: "nexio clone /mnt/share/project\nnexio clone file:///mnt/share/project my-project\nnexio clone --bare . /mnt/share/project",
+	Args:    cobra.RangeArgs(1, 2),
+	Run: func(_ *cobra.Command, args []string) {
+		directory := ""
+		if len(args) == 2 {
+			directory = args[1]
+		}
+		Debug("Starting clone command: url=%s, directory=%s, bare=%v", args[0], directory, CloneBare)
+		runCloneCommand(args[0], directory, CloneBare)
+	},
+}
+
+func runCloneCommand(url string, directory string, bare bool) int {
+	if directory == "" {
+		directory = CloneDirectory(url)
+	}
+	directory = strings.TrimSuffix(directory, "/")
+	if entries, err := os.ReadDir(directory); (err == nil && len(entries) > 0) || (err != nil && FileExists(directory)) {
+		Debug("Destination is not empty: %s", directory)
+		Fail(CLONE_RETURN_CODES[1402])
+		return 1402
+	}
+
+	transport, err := NewTransport(url)
+	if err != nil {
+		Debug("Failed to open remote: %v", err)
+		Fail(CLONE_RETURN_CODES[1403])
+		return 1403
+	}
+
+	// Relative paths are stored as absolute ones, they would not resolve from the new directory.
+	if !strings.Contains(url, "://") {
+		if absolute, err := filepath.Abs(url); err == nil {
+			url = absolute
+		}
+	}
+
+	created := !FileExists(directory)
+	stop := Spinner([]string{"Cloning repository..."}, false)
+	branch, err := cloneInto(transport, url, directory, bare)
+	stop()
+	if err != nil {
+		Debug("Clone failed: %v", err)
+		if created {
+			os.RemoveAll(directory)
+		} else {
+			os.RemoveAll(directory + "/.nexio")
+		}
+		if errors.Is(err, errNotARepository) {
+			Fail(CLONE_RETURN_CODES[1403])
+			return 1403
+		}
+		Fail(CLONE_RETURN_CODES[1404] + " " + err.Error())
+		return 1404
+	}
+
+	BreakLine()
+	Success(CLONE_RETURN_CODES[1401])
+	BreakLine()
+	Text("Directory: "+Code(directory), "  ")
+	Text("Branch: "+StyledBranch(branch), "  ")
+	Text("Remote: "+DefaultRemote+" "+url, "  ")
+	BreakLine()
+	return 1401
+}
+
+// cloneInto creates a repository in directory holding all branches of the remote and checks out its default branch,
+// unless the repository is bare.
+func cloneInto(transport Transport, url string, directory string, bare bool) (branch string, err error) {
+	refs, err := transport.ListRefs()
+	if err != nil {
+		return "", err
+	}
+
+	target := NewFileStorage(NewDirs(directory + "/"))
+	if err := target.Initialize(); err != nil {
+		return "", err
+	}
+	ids := []string{}
+	for _, commits := range refs.Branches {
+		ids = append(ids, CommitIds(commits)...)
+	}
+	fetched, err := FetchMissingCommits(target, transport, ids)
+	if err != nil {
+		return "", err
+	}
+	Debug("Fetched %d commits", len(fetched))
+
+	for name, commits := range refs.Branches {
+		if err := target.WriteBranchCommits(name, commits); err != nil {
+			return "", err
+		}
+	}
+	if _, exists := refs.Branches[InitBranch]; !exists {
+		if err := target.RemoveBranch(InitBranch); err != nil {
+			return "", err
+		}
+	}
+	if err := target.WriteBranchesMetadata(BranchMetadata{Default: refs.Default, Current: refs.Default}); err != nil {
+		return "", err
+	}
+	if err := UpdateRemoteRefs(target, DefaultRemote, refs); err != nil {
+		return "", err
+	}
+	config := Config{Bare: bare, Remotes: []Remote{{Name: DefaultRemote, Url: url}}}
+	if _, exists := refs.Branches[refs.Default]; exists {
+		config.Upstreams = map[string]Upstream{refs.Default: {Remote: DefaultRemote, Branch: refs.Default}}
+	}
+	if err := target.WriteConfig(config); err != nil {
+		return "", err
+	}
+	if err := target.WriteFormatVersion(CurrentFormatVersion()); err != nil {
+		return "", err
+	}
+
+	head := HeadOf(refs.Branches[refs.Default])
+	if head == "" || bare {
+		return refs.Default, nil
+	}
+	fileList, err := target.ReadFileList(head)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range fileList {
+		if err := ValidateTrackedPath(file.Path); err != nil {
+			return "", err
+		}
+		if err := CheckSymlinkParents(directory, file.Path); err != nil {
+			return "", err
+		}
+		_, fileName := ParsePath(file.Path)
+		data, mode, err := target.ReadObject(file.CommitId, file.Id, fileName)
+		if err != nil {
+			return "", err
+		}
+		if err := WriteFileWithMode(directory+"/"+file.Path, data, mode); err != nil {
+			return "", err
+		}
+	}
+	return refs.Default, nil
+}
@@ -32,9 +32,32 @@ func runCommitCommand(message string) (returnCode int, commitId string) {
 		return 001, ""
 	}
 
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		color.Red(COMMON_RETURN_CODES[005])
+		return 005, ""
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006, ""
+	}
+
+	return commitStagedChanges(CommitMetadata{Author: GetConfigAuthor(), Message: message})
+}
+
+// commitStagedChanges commits the staging area to the current branch. The caller holds the repository lock.
+func commitStagedChanges(metadata CommitMetadata) (returnCode int, commitId string) {
 	// Clean up any orphaned staging entries from previous failed operations
 	CleanOrphanedStagingEntries()
 
+	if conflicts := GetConflicts(); len(conflicts) > 0 {
+		Debug("Found %d unmerged paths", len(conflicts))
+		color.Red(COMMIT_RETURN_CODES[703])
+		return 703, ""
+	}
+
 	empty := IsStagingLogsEmpty()
 	if empty {
 		Debug("No changes staged for commit")
@@ -49,26 +72,18 @@ func runCommitCommand(message string) (returnCode int, commitId string) {
 	ProcessFileList(latestCommitId, newCommitId)
 	Debug("Processed file list for commit")
 
-	WriteCommitMetadata(newCommitId, message)
+	WriteCommitMetadata(newCommitId, metadata)
 	Debug("Wrote commit metadata")
 
-	if err := CopyFile(dirs.StagingLogs, dirs.Commits+newCommitId+"/logs.json"); err != nil {
+	if err := store.WriteCommitLogs(newCommitId, *GetStagingLogsContent()); err != nil {
 		color.Red("Error copying staging logs: " + err.Error())
 		return 001, ""
 	}
 	Debug("Copied staging logs to commit")
 
 	TruncateLogs()
-	if err := EmptyDir(dirs.StagingAdded); err != nil {
-		color.Red("Error emptying staging added directory: " + err.Error())
-		return 001, ""
-	}
-	if err := EmptyDir(dirs.StagingModified); err != nil {
-		color.Red("Error emptying staging modified directory: " + err.Error())
-		return 001, ""
-	}
-	if err := EmptyDir(dirs.StagingRemoved); err != nil {
-		color.Red("Error emptying staging removed directory: " + err.Error())
+	if err := store.ClearStagedFiles(); err != nil {
+		color.Red("Error emptying staging directories: " + err.Error())
 		return 001, ""
 	}
 	Debug("Cleaned up staging area")
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"os"
 	"slices"
@@ -21,6 +20,8 @@ type Author struct {
 type CommitMetadata struct {
 	Author  Author `json:"author"`
 	Message string `json:"message"`
+	// Source is the commit this one was copied from by cherry-pick.
+	Source string `json:"source,omitempty"`
 }
 
 func GetLastCommit() Commit {
@@ -33,16 +34,11 @@ func GetLastCommit() Commit {
 
 func GetLastCommitByBranch(branch string) Commit {
 	Debug("Getting last commit for branch: %s", branch)
-	commits, err := os.ReadFile(dirs.Branches + branch + "/commits.json")
+	content, err := store.ReadBranchCommits(branch)
 	if err != nil {
 		Debug("Failed to read commits file")
 		MustSucceed(err, "operation failed")
 	}
-	var content []Commit
-	if err = json.Unmarshal(commits, &content); err != nil {
-		Debug("Failed to unmarshal commits")
-		MustSucceed(err, "operation failed")
-	}
 	if len(content) == 0 {
 		Debug("No commits found for branch")
 		return Commit{}
@@ -62,16 +58,11 @@ func GetLastCommitByBranch(branch string) Commit {
 func CountCommits() int {
 	Debug("Counting all commits")
 	currentBranchName := GetCurrentBranchName()
-	commits, err := os.ReadFile(dirs.Branches + currentBranchName + "/commits.json")
+	content, err := store.ReadBranchCommits(currentBranchName)
 	if err != nil {
 		Debug("Failed to read commits file")
 		MustSucceed(err, "operation failed")
 	}
-	var content []Commit
-	if err = json.Unmarshal(commits, &content); err != nil {
-		Debug("Failed to unmarshal commits")
-		MustSucceed(err, "operation failed")
-	}
 	Debug("Counted %d commits", len(content))
 
 	return len(content)
@@ -80,16 +71,11 @@ func CountCommits() int {
 func GetCommits() *[]Commit {
 	Debug("Getting all commits")
 	currentBranchName := GetCurrentBranchName()
-	commits, err := os.ReadFile(dirs.Branches + currentBranchName + "/commits.json")
+	content, err := store.ReadBranchCommits(currentBranchName)
 	if err != nil {
 		Debug("Failed to read commits file")
 		MustSucceed(err, "operation failed")
 	}
-	var content []Commit
-	if err = json.Unmarshal(commits, &content); err != nil {
-		Debug("Failed to unmarshal commits")
-		MustSucceed(err, "operation failed")
-	}
 	Debug("Retrieved %d commits", len(content))
 
 	// Sort commits by following the linked list
@@ -162,22 +148,11 @@ func sortCommitsByLinkedList(commits []Commit) []Commit {
 
 func GetFileListContent(commitId string) (result *[]FileListEntry) {
 	Debug("Getting file list for commit: %s", commitId)
-	fileList, err := os.ReadFile(dirs.Commits + commitId + "/fileList.json")
+	content, err := store.ReadFileList(commitId)
 	if err != nil {
 		Debug("Failed to read file list")
 		MustSucceed(err, "operation failed")
 	}
-	var content []FileListEntry
-	if len(fileList) > 0 {
-		if err = json.Unmarshal(fileList, &content); err != nil {
-			Debug("Failed to unmarshal file list")
-			MustSucceed(err, "operation failed")
-		}
-	} else {
-		content = []FileListEntry{}
-		Debug("Empty file list")
-		return &content
-	}
 	Debug("Retrieved %d files from commit", len(content))
 	return &content
 }
@@ -218,37 +193,87 @@ func ProcessFileList(latestCommitId string, newCommitId string) {
 			}
 		case "ADD":
 			Debug("Adding new file to list: %s", logEntry.Path)
-			*fileList = append(*fileList, FileListEntry{Id: logEntry.Id, CommitId: newCommitId, Path: logEntry.Path})
-			_, fileName := ParsePath(logEntry.Path)
-			CopyFile(logEntry.Path, dirs.Commits+newCommitId+"/"+logEntry.Id+"/"+fileName)
+			mode, err := StoreObject(newCommitId, logEntry.Id, logEntry.Path)
+			if err != nil {
+				Debug("Failed to store object: %v", err)
+			}
+			*fileList = append(*fileList, newFileListEntry(logEntry.Id, newCommitId, logEntry.Path, mode))
 		case "MOD":
 			if len(*fileList) == 0 {
 				Debug("Skipping MOD operation - no files in list")
 				continue
 			}
+			mode, err := StoreObject(newCommitId, logEntry.Id, logEntry.Path)
+			if err != nil {
+				Debug("Failed to store object: %v", err)
+			}
 			for i, entry := range *fileList {
 				if logEntry.Path == entry.Path {
 					Debug("Updating file in list: %s", entry.Path)
-					(*fileList)[i].Id = logEntry.Id
-					(*fileList)[i].CommitId = newCommitId
+					(*fileList)[i] = newFileListEntry(logEntry.Id, newCommitId, logEntry.Path, mode)
+				}
+			}
+		case "REN":
+			mode, err := StoreObject(newCommitId, logEntry.Id, logEntry.Path)
+			if err != nil {
+				Debug("Failed to store object: %v", err)
+			}
+			for i, entry := range *fileList {
+				if entry.Path == logEntry.From {
+					Debug("Moving file in list: %s -> %s", entry.Path, logEntry.Path)
+					(*fileList)[i] = newFileListEntry(logEntry.Id, newCommitId, logEntry.Path, mode)
+					break
 				}
 			}
-			_, fileName := ParsePath(logEntry.Path)
-			CopyFile(logEntry.Path, dirs.Commits+newCommitId+"/"+logEntry.Id+"/"+fileName)
 		}
 	}
-	WriteJson(dirs.Commits+newCommitId+"/fileList.json", fileList)
+	if err := store.WriteFileList(newCommitId, *fileList); err != nil {
+		Debug("Failed to write file list")
+		MustSucceed(err, "operation failed")
+	}
 	Debug("File list processed successfully")
 }
 
-func WriteCommitMetadata(commitId string, message string) {
-	Debug("Writing commit metadata: id=%s, message=%s", commitId, message)
-	config := GetConfig()
-	author := Author{
-		Name:  config.Name,
-		Email: config.Email,
+// StoreObject copies a file of the working directory into the commit and returns its mode.
+func StoreObject(commitId string, fileId string, path string) (os.FileMode, error) {
+	Debug("Storing object: commit=%s, id=%s, path=%s", commitId, fileId, path)
+	_, fileName := ParsePath(path)
+	data, mode, err := ReadFileWithMode(path)
+	if err != nil {
+		Debug("Failed to read file: %s", path)
+		return 0, err
+	}
+	return mode, store.WriteObject(commitId, fileId, fileName, data, mode)
+}
+
+func newFileListEntry(id string, commitId string, path string, mode os.FileMode) FileListEntry {
+	return FileListEntry{Id: id, CommitId: commitId, Path: path, Mode: mode.Perm(), Type: FileType(mode)}
+}
+
+// RestoreObject writes a committed file back into the working directory with its mode, a symlink
+// is created again.
+func RestoreObject(file FileListEntry) error {
+	Debug("Restoring object: commit=%s, id=%s, path=%s", file.CommitId, file.Id, file.Path)
+	if err := ValidateTrackedPath(file.Path); err != nil {
+		return err
+	}
+	if err := CheckSymlinkParents(".", file.Path); err != nil {
+		return err
+	}
+	_, fileName := ParsePath(file.Path)
+	data, mode, err := store.ReadObject(file.CommitId, file.Id, fileName)
+	if err != nil {
+		return err
+	}
+	return WriteFileWithMode("./"+file.Path, data, mode)
+}
+
+func WriteCommitMetadata(commitId string, metadata CommitMetadata) {
+	Debug("Writing commit metadata: id=%s, message=%s", commitId, metadata.Message)
+	if err := store.WriteCommitMetadata(commitId, metadata); err != nil {
+		Debug("Failed to write commit metadata")
+		MustSucceed(err, "operation failed")
 	}
-	WriteJson(dirs.Commits+commitId+"/metadata.json", CommitMetadata{Author: author, Message: message})
 	Debug("Commit metadata written successfully")
 }
 
@@ -256,22 +281,20 @@ func RegisterCommitForBranch(commitId string) {
 	Debug("Registering commit for branch: %s", commitId)
 	currentBranchName := GetCurrentBranchName()
 
-	err := WithLock(dirs.Branches+currentBranchName+"/commits", DefaultLockTimeout, func() error {
-		commits, err := os.ReadFile(dirs.Branches + currentBranchName + "/commits.json")
+	err := store.WithLock(BranchCommitsLock(currentBranchName), func() error {
+		content, err := store.ReadBranchCommits(currentBranchName)
 		if err != nil {
 			Debug("Failed to read commits file")
 			return err
 		}
-		var content []Commit
-		if err = json.Unmarshal(commits, &content); err != nil {
-			Debug("Failed to unmarshal commits")
-			return err
-		}
 		content = append(content, Commit{Id: commitId, Timestamp: GetTimestamp(), Next: ""})
 		if len(content) > 1 {
 			content[len(content)-2].Next = commitId
 		}
-		WriteJson(dirs.Branches+currentBranchName+"/commits.json", content)
+		if err := store.WriteBranchCommits(currentBranchName, content); err != nil {
+			Debug("Failed to write commits file")
+			return err
+		}
 		Debug("Commit registered successfully")
 		return nil
 	})
@@ -316,14 +339,12 @@ func CopyCommitsToBranch(commitId string, targetBranch string) error {
 		return errors.New("Commit does not exist")
 	}
 
-	if err := os.Mkdir(dirs.Branches+targetBranch, 0755); err != nil {
+	index := FindIndex(commitIds, commitId)
+	*commits = (*commits)[:(index + 1)]
+	if err := store.CreateBranch(targetBranch, *commits); err != nil {
 		Debug("Branch already exists: %s", targetBranch)
 		return errors.New("Branch already exists")
 	}
-
-	index := FindIndex(commitIds, commitId)
-	*commits = (*commits)[:(index + 1)]
-	WriteJson(dirs.Branches+targetBranch+"/commits.json", *commits)
 	Debug("Commits copied successfully")
 	return nil
 }
@@ -1,8 +1,7 @@
 package main
 
 import (
-	"encoding/json"
-	"os"
+	"strconv"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -17,16 +16,43 @@ func init() {
 	setCmd.AddCommand(setDefaultBranchCmd)
 	setCmd.AddCommand(setNameCmd)
 	setCmd.AddCommand(setEmailCmd)
+	setCmd.AddCommand(setGcGracePeriodCmd)
+	setCmd.AddCommand(setLockTimeoutCmd)
+	setCmd.AddCommand(setMergeToolCmd)
+	setCmd.AddCommand(setRenameThresholdCmd)
+	setCmd.AddCommand(setLargeFileThresholdCmd)
+	setCmd.AddCommand(setExcludesFileCmd)
 
 	getCmd.AddCommand(getDefaultBranchCmd)
 	getCmd.AddCommand(getNameCmd)
 	getCmd.AddCommand(getEmailCmd)
 	getCmd.AddCommand(getUserCmd)
+	getCmd.AddCommand(getGcGracePeriodCmd)
+	getCmd.AddCommand(getLockTimeoutCmd)
+	getCmd.AddCommand(getMergeToolCmd)
+	getCmd.AddCommand(getRenameThresholdCmd)
+	getCmd.AddCommand(getLargeFileThresholdCmd)
+	getCmd.AddCommand(getExcludesFileCmd)
 }
 
 type Config struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	GcGracePeriod string `json:"gcGracePeriod,omitempty"`
+	LockTimeout   string `json:"lockTimeout,omitempty"`
+	// MergeTool is the shell command run by mergetool.
+	MergeTool string `json:"mergeTool,omitempty"`
+	// RenameThreshold is how similar, in percent, files must be to be detected as renamed or copied.
+	RenameThreshold string `json:"renameThreshold,omitempty"`
+	// LargeFileThreshold is the size from which committed files are stored in chunks, e.g. `100MB`.
+	LargeFileThreshold string `json:"largeFileThreshold,omitempty"`
+	// ExcludesFile holds ignore patterns applying to every repository, like core.excludesFile of Git.
+	ExcludesFile string `json:"excludesFile,omitempty"`
+	// Bare is set in repositories cloned with `--bare`, they have no working tree and accept pushes to any branch.
+	Bare    bool     `json:"bare,omitempty"`
+	Remotes []Remote `json:"remotes,omitempty"`
+	// Upstreams maps local branch names to the remote branch they track.
+	Upstreams map[string]Upstream `json:"upstreams,omitempty"`
 }
 
 var setCmd = &cobra.Command{
@@ -68,6 +94,72 @@ var setEmailCmd = &cobra.Command{
 	},
 }
 
+var setGcGracePeriodCmd = &cobra.Command{
+	Use:     "gc-grace-period",
+	Short:   "Set how long unreachable commits are kept before gc removes them",
+	Example: "nexio config set gc-grace-period 14d",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Setting gc grace period: %s", args[0])
+		setConfig("gc-grace-period", args[0])
+	},
+}
+
+var setLockTimeoutCmd = &cobra.Command{
+	Use:     "lock-timeout",
+	Short:   "Set how long to wait for a lock held by another process",
+	Example: "nexio config set lock-timeout 30s",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Setting lock timeout: %s", args[0])
+		setConfig("lock-timeout", args[0])
+	},
+}
+
+var setMergeToolCmd = &cobra.Command{
+	Use:     "merge-tool",
+	Short:   "Set the command mergetool runs to resolve conflicts",
+	Example: "nexio config set merge-tool 'vimdiff \"$LOCAL\" \"$MERGED\" \"$REMOTE\"'",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Setting merge tool: %s", args[0])
+		setConfig("merge-tool", args[0])
+	},
+}
+
+var setLargeFileThresholdCmd = &cobra.Command{
+	Use:     "large-file-threshold",
+	Short:   "Set the size from which committed files are stored in chunks",
+	Example: "nexio config set large-file-threshold 100MB",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Setting large file threshold: %s", args[0])
+		setConfig("large-file-threshold", args[0])
+	},
+}
+
+var setExcludesFileCmd = &cobra.Command{
+	Use:     "excludes-file",
+	Short:   "Set the file of ignore patterns applying to every repository",
+	Example: "nexio config set excludes-file ~/.gitignore",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Setting excludes file: %s", args[0])
+		setConfig("excludes-file", args[0])
+	},
+}
+
+var setRenameThresholdCmd = &cobra.Command{
+	Use:     "rename-threshold",
+	Short:   "Set how similar files must be to be detected as renamed or copied",
+	Example: "nexio config set rename-threshold 60%",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Setting rename threshold: %s", args[0])
+		setConfig("rename-threshold", args[0])
+	},
+}
+
 var getCmd = &cobra.Command{
 	Use:     "get",
 	Short:   "Get config values",
@@ -119,6 +211,72 @@ var getUserCmd = &cobra.Command{
 	},
 }
 
+var getGcGracePeriodCmd = &cobra.Command{
+	Use:     "gc-grace-period",
+	Short:   "Get gc grace period",
+	Example: "nexio config get gc-grace-period",
+	Args:    cobra.ExactArgs(0),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Getting gc grace period")
+		getConfig("gc-grace-period")
+	},
+}
+
+var getLockTimeoutCmd = &cobra.Command{
+	Use:     "lock-timeout",
+	Short:   "Get lock timeout",
+	Example: "nexio config get lock-timeout",
+	Args:    cobra.ExactArgs(0),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Getting lock timeout")
+		getConfig("lock-timeout")
+	},
+}
+
+var getMergeToolCmd = &cobra.Command{
+	Use:     "merge-tool",
+	Short:   "Get merge tool",
+	Example: "nexio config get merge-tool",
+	Args:    cobra.ExactArgs(0),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Getting merge tool")
+		getConfig("merge-tool")
+	},
+}
+
+var getLargeFileThresholdCmd = &cobra.Command{
+	Use:     "large-file-threshold",
+	Short:   "Get large file threshold",
+	Example: "nexio config get large-file-threshold",
+	Args:    cobra.ExactArgs(0),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Getting large file threshold")
+		getConfig("large-file-threshold")
+	},
+}
+
+var getExcludesFileCmd = &cobra.Command{
+	Use:     "excludes-file",
+	Short:   "Get excludes file",
+	Example: "nexio config get excludes-file",
+	Args:    cobra.ExactArgs(0),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Getting excludes file")
+		getConfig("excludes-file")
+	},
+}
+
+var getRenameThresholdCmd = &cobra.Command{
+	Use:     "rename-threshold",
+	Short:   "Get rename threshold",
+	Example: "nexio config get rename-threshold",
+	Args:    cobra.ExactArgs(0),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Getting rename threshold")
+		getConfig("rename-threshold")
+	},
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Config manager",
@@ -131,32 +289,48 @@ func setConfig(key string, value string) int {
 		Fail(COMMON_RETURN_CODES[001])
 		return 001
 	}
-	config, err := os.ReadFile(dirs.Config)
-	if err != nil {
-		Debug("Failed to read config file")
-		MustSucceed(err, "operation failed")
-	}
-
-	var content Config
-	if err = json.Unmarshal(config, &content); err != nil {
-		Debug("Failed to unmarshal config")
-		MustSucceed(err, "operation failed")
-	}
+	content := *GetConfig()
 
 	switch key {
 	case "name":
 		content.Name = value
 	case "email":
 		content.Email = value
+	case "gc-grace-period":
+		if _, err := ParseDuration(value); err != nil {
+			Debug("Invalid duration: %s", value)
+			Fail(CONFIG_RETURN_CODES[608])
+			return 608
+		}
+		content.GcGracePeriod = value
+	case "lock-timeout":
+		if _, err := ParseDuration(value); err != nil {
+			Debug("Invalid duration: %s", value)
+			Fail(CONFIG_RETURN_CODES[608])
+			return 608
+		}
+		content.LockTimeout = value
+	case "merge-tool":
+		content.MergeTool = value
+	case "rename-threshold":
+		if _, err := ParseThreshold(value); err != nil {
+			Debug("Invalid threshold: %s", value)
+			Fail(CONFIG_RETURN_CODES[610])
+			return 610
+		}
+		content.RenameThreshold = value
+	case "large-file-threshold":
+		if _, err := ParseSize(value); err != nil {
+			Debug("Invalid size: %s", value)
+			Fail(CONFIG_RETURN_CODES[611])
+			return 611
+		}
+		content.LargeFileThreshold = value
+	case "excludes-file":
+		content.ExcludesFile = value
 	}
 
-	jsonData, err := json.Marshal(content)
-	if err != nil {
-		Debug("Failed to marshal config")
-		MustSucceed(err, "operation failed")
-	}
-
-	if err = os.WriteFile(dirs.Config, jsonData, 0644); err != nil {
+	if err := store.WriteConfig(content); err != nil {
 		Debug("Failed to write config file")
 		MustSucceed(err, "operation failed")
 	}
@@ -197,6 +371,34 @@ func getConfig(key string) (returnCode int, conf Config) {
 		}
 		Debug("User: %s <%s>", config.Name, config.Email)
 		Info(Capitalize(key) + ": " + color.BlueString(config.Name+" <"+config.Email+">"))
+	case "gc-grace-period":
+		gracePeriod := config.GcGracePeriod
+		if gracePeriod == "" {
+			gracePeriod = DefaultGcGracePeriod
+		}
+		Debug("GC grace period: %s", gracePeriod)
+		Info("GC grace period: " + color.BlueString(gracePeriod))
+	case "lock-timeout":
+		Debug("Lock timeout: %v", LockTimeout())
+		Info("Lock timeout: " + color.BlueString(LockTimeout().String()))
+	case "merge-tool":
+		if config.MergeTool == "" {
+			Debug("%s", CONFIG_RETURN_CODES[609])
+			Fail(CONFIG_RETURN_CODES[609])
+			return 609, Config{}
+		}
+		Debug("Merge tool: %s", config.MergeTool)
+		Info("Merge tool: " + color.BlueString(config.MergeTool))
+	case "rename-threshold":
+		Debug("Rename threshold: %d", RenameThreshold())
+		Info("Rename threshold: " + color.BlueString(strconv.Itoa(RenameThreshold())+"%"))
+	case "large-file-threshold":
+		threshold := LargeFileThreshold(*config)
+		Debug("Large file threshold: %d", threshold)
+		Info("Large file threshold: " + color.BlueString(FormatBytes(threshold)))
+	case "excludes-file":
+		Debug("Excludes file: %s", GlobalExcludesFile())
+		Info("Excludes file: " + color.BlueString(GlobalExcludesFile()))
 	}
 	return 604, *config
 }
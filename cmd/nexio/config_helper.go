@@ -1,24 +1,22 @@
 package main
 
-import (
-	"encoding/json"
-	"os"
-)
-
 func GetConfig() *Config {
 	Debug("Reading config file")
-	config, err := os.ReadFile(dirs.Config)
+	content, err := store.ReadConfig()
 	if err != nil {
 		Debug("Failed to read config file")
 		MustSucceed(err, "failed to read config file")
 	}
 
-	var content Config
-	if err = json.Unmarshal(config, &content); err != nil {
-		Debug("Failed to unmarshal config")
-		MustSucceed(err, "failed to parse config file")
-	}
-
 	Debug("Config retrieved successfully: name=%s, email=%s", content.Name, content.Email)
 	return &content
 }
+
+// GetConfigAuthor returns the configured user as the author of new commits.
+func GetConfigAuthor() Author {
+	config := GetConfig()
+	return Author{
+		Name:  config.Name,
+		Email: config.Email,
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// ConflictVersions names the versions of a conflicting file kept in the staging area: the common
+// ancestor and the two sides that were merged.
+var ConflictVersions = []string{"base", "ours", "theirs"}
+
+type ConflictFile struct {
+	Data []byte
+	Mode os.FileMode
+}
+
+// Conflict is an unmerged path logged with the CON operation. A version missing from Versions did
+// not exist on that side, e.g. ours for a file removed on the current branch.
+type Conflict struct {
+	Id       string
+	Path     string
+	Versions map[string]ConflictFile
+}
+
+// Describe tells how the sides disagree, as the status lists it.
+func (c Conflict) Describe() string {
+	_, base := c.Versions["base"]
+	_, ours := c.Versions["ours"]
+	_, theirs := c.Versions["theirs"]
+	switch {
+	case !ours:
+		return "deleted by us"
+	case !theirs:
+		return "deleted by them"
+	case !base:
+		return "both added"
+	}
+	return "both modified"
+}
+
+// conflictVersionId is the id under which a version of a conflict is staged.
+func conflictVersionId(id string, version string) string {
+	return id + "." + version
+}
+
+// StageConflict records path as unmerged with the given versions, replacing what was staged for it.
+func StageConflict(path string, versions map[string]ConflictFile) error {
+	Debug("Staging conflict: %s", path)
+	if staged, id, op := LogEntryLookup("*", path); staged {
+		if err := RemoveFileAndLog(id, StagingOps[op]); err != nil {
+			return err
+		}
+	}
+	id := GenRandHex(20)
+	_, fileName := ParsePath(path)
+	for version, file := range versions {
+		if err := store.WriteStagedFile(StagingOps["CON"], conflictVersionId(id, version), fileName, file.Data, file.Mode); err != nil {
+			return err
+		}
+	}
+	LogOperation(id, "CON", path)
+	return nil
+}
+
+// GetConflicts returns the unmerged paths of the staging area ordered by path.
+func GetConflicts() []Conflict {
+	conflicts := []Conflict{}
+	for _, entry := range *GetStagingLogsContent() {
+		if entry.Op != "CON" {
+			continue
+		}
+		conflict := Conflict{Id: entry.Id, Path: entry.Path, Versions: map[string]ConflictFile{}}
+		_, fileName := ParsePath(entry.Path)
+		for _, version := range ConflictVersions {
+			data, mode, err := store.ReadStagedFile(StagingOps["CON"], conflictVersionId(entry.Id, version), fileName)
+			if err == nil {
+				conflict.Versions[version] = ConflictFile{Data: data, Mode: mode}
+			}
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	slices.SortFunc(conflicts, func(a, b Conflict) int { return strings.Compare(a.Path, b.Path) })
+	return conflicts
+}
+
+// RemoveConflict drops the staged versions and the log entry of a conflict, marking it resolved.
+func RemoveConflict(id string) error {
+	Debug("Removing conflict: %s", id)
+	for _, version := range ConflictVersions {
+		if err := store.RemoveStagedFile(StagingOps["CON"], conflictVersionId(id, version)); err != nil {
+			return err
+		}
+	}
+	RemoveLogEntry(id)
+	return nil
+}
+
+// UnresolvedConflicts returns the unmerged paths whose file in the working directory still has
+// conflict markers. The others are resolved once staged.
+func UnresolvedConflicts() []string {
+	unresolved := []string{}
+	for _, conflict := range GetConflicts() {
+		if data, err := os.ReadFile(conflict.Path); err == nil && HasConflictMarkers(data) {
+			unresolved = append(unresolved, conflict.Path)
+		}
+	}
+	return unresolved
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DiffContext is the number of unchanged lines shown around every change.
+const DiffContext = 3
+
+const noNewlineMarker = "\\ No newline at end of file"
+
+// DiffHunk is a block of a unified diff. Every line starts with ' ', '-' or '+' and keeps its line
+// break, the last line of a file may have none.
+type DiffHunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string
+}
+
+// SplitLines splits data after every line break, a last line without one is kept as is.
+func SplitLines(data []byte) []string {
+	lines := []string{}
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		lines = append(lines, string(data[:end]))
+		data = data[end:]
+	}
+	return lines
+}
+
+// IsBinary tells whether the content looks binary, i.e. has a NUL byte in its first 8000 bytes.
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) != -1
+}
+
+// IsBinaryFile tells whether a file is binary, by its content or by a binary pattern of the rules
+// file, e.g. for text formats that make no sense to diff.
+func IsBinaryFile(path string, data ...[]byte) bool {
+	return slices.ContainsFunc(data, IsBinary) || IsBinaryPath(path)
+}
+
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines finds the shortest edit script turning a into b with the Myers algorithm.
+func diffLines(a []string, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	// trace[d] holds the furthest x of every diagonal -d..d before step d.
+	trace := [][]int{}
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace, d)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrackDiff(a []string, b []string, trace [][]int, steps int) []diffOp {
+	ops := []diffOp{}
+	x, y := len(a), len(b)
+	for d := steps; d > 0; d-- {
+		previous := func(k int) int { return trace[d][k+d] }
+		k := x - y
+		var previousK int
+		if k == -d || (k != d && previous(k-1) < previous(k+1)) {
+			previousK = k + 1
+		} else {
+			previousK = k - 1
+		}
+		previousX := previous(previousK)
+		previousY := previousX - previousK
+		for x > previousX && y > previousY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == previousX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{' ', a[x-1]})
+		x, y = x-1, y-1
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// DiffHunks compares two file contents line by line and groups the changes into hunks with
+// context lines around them. Equal contents give no hunks.
+func DiffHunks(old []byte, new []byte, context int) []DiffHunk {
+	ops := diffLines(SplitLines(old), SplitLines(new))
+	hunks := []DiffHunk{}
+	var hunk *DiffHunk
+	oldLine, newLine := 0, 0
+	lastChange := -1
+	for i, op := range ops {
+		if op.kind != ' ' {
+			// Start a new hunk unless the previous change is close enough to share its context.
+			if hunk == nil || i-lastChange-1 > 2*context {
+				if hunk != nil {
+					closeDiffHunk(hunk, ops[lastChange+1:], context)
+					hunks = append(hunks, *hunk)
+				}
+				start := max(0, i-context)
+				hunk = &DiffHunk{OldStart: oldLine - (i - start) + 1, NewStart: newLine - (i - start) + 1}
+				for _, previous := range ops[start:i] {
+					hunk.Lines = append(hunk.Lines, " "+previous.line)
+				}
+			} else {
+				for _, between := range ops[lastChange+1 : i] {
+					hunk.Lines = append(hunk.Lines, " "+between.line)
+				}
+			}
+			hunk.Lines = append(hunk.Lines, string(op.kind)+op.line)
+			lastChange = i
+		}
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+	if hunk != nil {
+		closeDiffHunk(hunk, ops[lastChange+1:], context)
+		hunks = append(hunks, *hunk)
+	}
+	return hunks
+}
+
+// closeDiffHunk adds up to context unchanged lines following the last change and counts the lines of the hunk.
+func closeDiffHunk(hunk *DiffHunk, after []diffOp, context int) {
+	for _, op := range after[:min(len(after), context)] {
+		hunk.Lines = append(hunk.Lines, " "+op.line)
+	}
+	hunk.OldLines, hunk.NewLines = 0, 0
+	for _, line := range hunk.Lines {
+		if line[0] != '+' {
+			hunk.OldLines++
+		}
+		if line[0] != '-' {
+			hunk.NewLines++
+		}
+	}
+	// An empty side starts at the line before the change, as in `diff -u`.
+	if hunk.OldLines == 0 {
+		hunk.OldStart--
+	}
+	if hunk.NewLines == 0 {
+		hunk.NewStart--
+	}
+}
+
+// FormatHunk writes a hunk in unified diff format, marking lines without a line break.
+func FormatHunk(hunk DiffHunk) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "@@ -%s +%s @@\n", formatHunkRange(hunk.OldStart, hunk.OldLines), formatHunkRange(hunk.NewStart, hunk.NewLines))
+	for _, line := range hunk.Lines {
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n" + noNewlineMarker + "\n")
+		}
+	}
+	return out.String()
+}
+
+func formatHunkRange(start int, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
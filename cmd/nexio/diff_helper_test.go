@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func numberedLines(from int, to int) string {
+	var lines strings.Builder
+	for i := from; i <= to; i++ {
+		lines.WriteString("line " + strings.Repeat("x", i%3) + string(rune('a'+i%26)) + "\n")
+	}
+	return lines.String()
+}
+
+func Test_DiffHunks_Format(t *testing.T) {
+	old := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	new := "one\nTWO\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+	formatted := ""
+	for _, hunk := range DiffHunks([]byte(old), []byte(new), DiffContext) {
+		formatted += FormatHunk(hunk)
+	}
+	expected := "@@ -1,5 +1,5 @@\n one\n-two\n+TWO\n three\n four\n five\n" +
+		"@@ -8,3 +8,4 @@\n eight\n nine\n ten\n+eleven\n\\ No newline at end of file\n"
+	if formatted != expected {
+		t.Errorf("Expected hunks:\n%s\ngot:\n%s", expected, formatted)
+	}
+
+	// Changes at most twice the context apart share a hunk.
+	new = "one\nTWO\nthree\nfour\nfive\nsix\nseven\nEIGHT\nnine\nten\n"
+	if hunks := DiffHunks([]byte(old), []byte(new), DiffContext); len(hunks) != 1 || hunks[0].OldLines != 10 {
+		t.Errorf("Expected one hunk over all lines, got %v", hunks)
+	}
+	if hunks := DiffHunks([]byte(old), []byte(old), DiffContext); len(hunks) != 0 {
+		t.Errorf("Expected no hunks for equal contents, got %v", hunks)
+	}
+}
+
+func Test_DiffHunks_RoundTrip(t *testing.T) {
+	cases := [][2]string{
+		{"", "new file\n"},
+		{"removed\n", ""},
+		{numberedLines(1, 40), numberedLines(5, 30) + "tail\n"},
+		{"a\nb\nc", "a\nb\nc\n"},
+		{numberedLines(1, 100), strings.Replace(numberedLines(1, 100), "line xb\n", "changed\n", -1)},
+	}
+	for _, c := range cases {
+		path := "file.txt"
+		patch := FilePatch{OldPath: path, NewPath: path, Hunks: DiffHunks([]byte(c[0]), []byte(c[1]), DiffContext)}
+		files := &PatchedFiles{files: map[string]*patchedFile{path: {Data: []byte(c[0]), Mode: 0644}}}
+		if result := files.Apply(patch); !result.Clean() {
+			t.Errorf("Expected the diff of %q to apply, got %v", c[0], result)
+			continue
+		}
+		if got := string(files.files[path].Data); got != c[1] {
+			t.Errorf("Expected applying the diff to give %q, got %q", c[1], got)
+		}
+	}
+}
@@ -10,57 +10,89 @@ type Dirs struct {
 	StagingAdded         string
 	StagingModified      string
 	StagingRemoved       string
+	StagingRenamed       string
+	StagingConflicted    string
 	StagingLogs          string
 	Commits              string
+	Chunks               string
 	Branches             string
 	DefaultBranch        string
 	DefaultBranchCommits string
 	BranchesMetadata     string
+	RemoteRefs           string
 	Config               string
+	Format               string
+	Backups              string
 }
 
-var dirs = Dirs{
-	Root: namespace + ".nexio/",
-	// Staging directories for `added`, `modified`, `removed` operations.
-	Staging:         namespace + ".nexio/staging/",
-	StagingAdded:    namespace + ".nexio/staging/added/",
-	StagingModified: namespace + ".nexio/staging/modified/",
-	StagingRemoved:  namespace + ".nexio/staging/removed/",
-
-	// Log file for tracking staging operations.
-	// Format: { Id: <hash>, Op: ADD | MOD | REM, Path: path/to/file }
-	StagingLogs: namespace + ".nexio/staging/logs.json",
-
-	// Commits directory stores directories for each commit hash.
-	// `commits/<commit-hash>/<file-id>/<file-name>`: refers to the file in the commit.
-	// `commits/<commit-hash>/logs.json`: copy of the staging logs file at the time of the commit.
-	// Format: { Id: <hash>, Op: ADD | MOD | REM, Path: path/to/file }
-	// `commits/<commit-hash>/metadata.json` stores metadata for the commit, e.g. commit message, timestamp.
-	// Format: { Author: <name <email>>, Message: <commit-message> }
-	// For each commit hash a file called `commits/<commit-hash>/fileList.json` will be created. It represents the project state at the time of the commit listing all the files with commit hashes.
-	// Format: { Id: <hash>, CommitId: <hash>, Path: path/to/file }
-	// Before each commit, the `fileList.json` will be copied from the previous commit. This file will be updated according to the changes made in the commit.
-	// Whenever a file is added to the project, it is added to the `fileList.json` file.
-	// Whenever a file is modified, its commit hash is updated in the fileList.json file with the new commit hash.
-	// Whenever a file is removed from the project, it is removed from the fileList.json file.
-	Commits: namespace + ".nexio/commits/",
-
-	Branches: namespace + ".nexio/branches/",
-
-	// Initial branch is named `main`.
-	DefaultBranch: namespace + ".nexio/branches/main/",
-
-	// "branches/<branch-name>/commits.json" stores commit hashes for the given branch.
-	// Format: [ { Id: <commit-hash>, Timestamp: <timestamp> }, ... ]
-	DefaultBranchCommits: namespace + ".nexio/branches/main/commits.json",
-
-	// "branches/metadata.json" stores default branch and current branch names.
-	// Format: { Default: <branch-name>, Current: <branch-name> }
-	BranchesMetadata: namespace + ".nexio/branches/metadata.json",
-
-	// "config.json" stores Nexio config data, e.g. name, email.
-	// Format: { Name: <name>, Email: <email> }
-	Config: namespace + ".nexio/config.json",
+var dirs = NewDirs(namespace)
+
+// NewDirs returns the layout of the repository in base, which is empty or ends with a slash.
+func NewDirs(base string) Dirs {
+	return Dirs{
+		Root: base + ".nexio/",
+		// Staging directories for `added`, `modified`, `removed`, `renamed` operations.
+		Staging:         base + ".nexio/staging/",
+		StagingAdded:    base + ".nexio/staging/added/",
+		StagingModified: base + ".nexio/staging/modified/",
+		StagingRemoved:  base + ".nexio/staging/removed/",
+		// The content of moved files at their new path.
+		StagingRenamed: base + ".nexio/staging/renamed/",
+		// The base, ours and theirs versions of every conflicting file, `conflicted/<id>.<version>/<file-name>`.
+		StagingConflicted: base + ".nexio/staging/conflicted/",
+
+		// Log file for tracking staging operations.
+		// Format: { Id: <hash>, Op: ADD | MOD | REM | REN | CON, Path: path/to/file, From: path/before/rename }
+		StagingLogs: base + ".nexio/staging/logs.json",
+
+		// Commits directory stores directories for each commit hash.
+		// `commits/<commit-hash>/<file-id>/<file-name>`: refers to the file in the commit.
+		// `commits/<commit-hash>/logs.json`: copy of the staging logs file at the time of the commit.
+		// Format: { Id: <hash>, Op: ADD | MOD | REM | REN, Path: path/to/file, From: path/before/rename }
+		// `commits/<commit-hash>/metadata.json` stores metadata for the commit, e.g. commit message, timestamp.
+		// Format: { Author: <name <email>>, Message: <commit-message> }
+		// For each commit hash a file called `commits/<commit-hash>/fileList.json` will be created. It represents the project state at the time of the commit listing all the files with commit hashes.
+		// Format: { Id: <hash>, CommitId: <hash>, Path: path/to/file, Mode: <mode>, Type: file | symlink | dir }
+		// Before each commit, the `fileList.json` will be copied from the previous commit. This file will be updated according to the changes made in the commit.
+		// Whenever a file is added to the project, it is added to the `fileList.json` file.
+		// Whenever a file is modified, its commit hash is updated in the fileList.json file with the new commit hash.
+		// Whenever a file is removed from the project, it is removed from the fileList.json file.
+		// Files from large-file-threshold on are stored as `commits/<commit-hash>/<file-id>/<file-name>.chunks`
+		// instead, listing the chunks of their content.
+		// Format: { Size: <bytes>, Chunks: [ <sha256>, ... ] }
+		Commits: base + ".nexio/commits/",
+
+		// "chunks/<sha256>" stores a chunk of a large file, shared by every file and commit containing it.
+		Chunks: base + ".nexio/chunks/",
+
+		Branches: base + ".nexio/branches/",
+
+		// Initial branch is named `main`.
+		DefaultBranch: base + ".nexio/branches/main/",
+
+		// "branches/<branch-name>/commits.json" stores commit hashes for the given branch.
+		// Format: [ { Id: <commit-hash>, Timestamp: <timestamp> }, ... ]
+		DefaultBranchCommits: base + ".nexio/branches/main/commits.json",
+
+		// "branches/metadata.json" stores default branch and current branch names.
+		// Format: { Default: <branch-name>, Current: <branch-name> }
+		BranchesMetadata: base + ".nexio/branches/metadata.json",
+
+		// "refs/remotes/<remote>/<branch>" stores the commits of a remote branch as of the last fetch, push or pull.
+		// Format: [ { Id: <commit-hash>, Timestamp: <timestamp> }, ... ]
+		RemoteRefs: base + ".nexio/refs/remotes/",
+
+		// "config.json" stores Nexio config data, e.g. name, email.
+		// Format: { Name: <name>, Email: <email> }
+		Config: base + ".nexio/config.json",
+
+		// "format.json" stores the version of the on-disk format, repositories created before it existed are version 0.
+		// Format: { Version: <version> }
+		Format: base + ".nexio/format.json",
+
+		// "backups/<name>/" stores a copy of the repository taken before a format migration.
+		Backups: base + ".nexio/backups/",
+	}
 }
 
 func (d Dirs) GetDirs() []string {
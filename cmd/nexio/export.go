@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	exportCmd.Flags().StringVarP(&ExportFormat, "format", "f", "git-fast-import", "Export format, only git-fast-import is supported")
+	exportCmd.Flags().StringVarP(&ExportOutput, "output", "o", "", "File to write, the stream is written to stdout if omitted")
+	exportCmd.Flags().StringVar(&ExportMarksFile, "marks", "", "Marks file to read before and update after the export, for incremental exports")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+var (
+	ExportFormat    string
+	ExportOutput    string
+	ExportMarksFile string
+)
+
+var exportCmd = &cobra.Command{
+	Use:     "export",
+	Short:   "Export branches as a git fast-import stream",
+	Example: "nexio export | (cd ../project && git fast-import)\nnexio export main feature -o history.fi\nnexio export --marks nexio.marks -o update.fi",
+	Args:    cobra.ArbitraryArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting export command: format=%s, output=%s, marks=%s, branches=%v", ExportFormat, ExportOutput, ExportMarksFile, args)
+		runExportCommand(ExportFormat, ExportOutput, ExportMarksFile, args)
+	},
+}
+
+func runExportCommand(format string, output string, marksFile string, branches []string) int {
+	// The stream goes to stdout without an output file, messages would end up inside it.
+	quiet := output == "" || output == "-"
+	fail := func(message string) {
+		if quiet {
+			fmt.Fprintln(os.Stderr, message)
+		} else {
+			Fail(message)
+		}
+	}
+
+	if initialized := IsInitialized(); !initialized {
+		fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if !slices.Contains(ExportFormats, format) {
+		Debug("Unsupported export format: %s", format)
+		fail(EXPORT_RETURN_CODES[2202])
+		return 2202
+	}
+	existing := ListBranches()
+	if len(branches) == 0 {
+		branches = existing
+	}
+	for _, branch := range branches {
+		if !slices.Contains(existing, branch) {
+			Debug("Branch does not exist: %s", branch)
+			fail(EXPORT_RETURN_CODES[2203] + " " + branch)
+			return 2203
+		}
+	}
+
+	marks := ExportMarks{}
+	if marksFile != "" {
+		if marks, err = ReadExportMarks(marksFile); err != nil {
+			Debug("Failed to read marks file: %v", err)
+			fail(EXPORT_RETURN_CODES[2204] + " " + err.Error())
+			return 2204
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if !quiet {
+		file, err := os.Create(output)
+		if err != nil {
+			Debug("Failed to create output file: %v", err)
+			fail(EXPORT_RETURN_CODES[2205] + " " + err.Error())
+			return 2205
+		}
+		defer file.Close()
+		w = file
+	}
+	stats, err := WriteFastExport(w, store, branches, marks)
+	if err == nil && marksFile != "" {
+		err = WriteExportMarks(marksFile, marks)
+	}
+	if err != nil {
+		Debug("Failed to write export: %v", err)
+		fail(EXPORT_RETURN_CODES[2205] + " " + err.Error())
+		return 2205
+	}
+	if quiet {
+		return 2201
+	}
+
+	BreakLine()
+	Success(EXPORT_RETURN_CODES[2201])
+	Text("File: "+Code(output), "  ")
+	Text(fmt.Sprintf("Branches: %d", len(branches)), "  ")
+	Text(fmt.Sprintf("Commits: %d", stats.Commits), "  ")
+	Text(fmt.Sprintf("Files: %d", stats.Blobs), "  ")
+	BreakLine()
+	return 2201
+}
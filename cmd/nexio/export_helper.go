@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ExportFormats = []string{"git-fast-import"}
+
+// ExportMarks maps the commit and file ids already written to a stream to their marks. Keeping the marks
+// of an export and passing them to the next one exports only what is new, numbering it after them.
+type ExportMarks map[string]int
+
+// ReadExportMarks reads a marks file in the format of `git fast-export`, one `:<mark> <id>` per line.
+// A missing file gives no marks.
+func ReadExportMarks(path string) (ExportMarks, error) {
+	marks := ExportMarks{}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return marks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for number, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line == "" {
+			continue
+		}
+		mark, id, found := strings.Cut(line, " ")
+		value, err := strconv.Atoi(strings.TrimPrefix(mark, ":"))
+		if !found || !strings.HasPrefix(mark, ":") || err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid mark on line %d of %s", number+1, path)
+		}
+		marks[id] = value
+	}
+	return marks, nil
+}
+
+func WriteExportMarks(path string, marks ExportMarks) error {
+	ids := make([]string, 0, len(marks))
+	for id := range marks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return marks[ids[i]] < marks[ids[j]] })
+	var content strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&content, ":%d %s\n", marks[id], id)
+	}
+	return os.WriteFile(path, []byte(content.String()), 0644)
+}
+
+// ExportStats counts what an export wrote, commits and files already in the marks are not written again.
+type ExportStats struct {
+	Commits int
+	Blobs   int
+}
+
+// WriteFastExport writes the branches as a `git fast-import` stream: a blob for every new file version,
+// a commit for every new commit, oldest first, and a reset pointing each branch at its last commit.
+// Marks are numbered in the order things are written, so the same repository always gives the same stream.
+func WriteFastExport(w io.Writer, s Storage, branches []string, marks ExportMarks) (ExportStats, error) {
+	stats := ExportStats{}
+	out := bufio.NewWriter(w)
+	next := 1
+	for _, mark := range marks {
+		next = max(next, mark+1)
+	}
+	newMark := func(id string) int {
+		marks[id] = next
+		next++
+		return marks[id]
+	}
+
+	heads := map[string]string{}
+	for _, branch := range branches {
+		commits, err := GetBranchCommits(s, branch)
+		if err != nil {
+			return stats, err
+		}
+		parent := ""
+		for _, commit := range commits {
+			if _, exported := marks[commit.Id]; !exported {
+				if err := writeFastExportCommit(out, s, branch, commit, parent, marks, newMark, &stats); err != nil {
+					return stats, err
+				}
+			}
+			parent = commit.Id
+		}
+		heads[branch] = parent
+	}
+
+	for _, branch := range branches {
+		if heads[branch] == "" {
+			continue
+		}
+		fmt.Fprintf(out, "reset refs/heads/%s\nfrom :%d\n\n", branch, marks[heads[branch]])
+	}
+	return stats, out.Flush()
+}
+
+func writeFastExportCommit(out *bufio.Writer, s Storage, branch string, commit Commit, parent string, marks ExportMarks, newMark func(string) int, stats *ExportStats) error {
+	fileList, err := s.ReadFileList(commit.Id)
+	if err != nil {
+		return err
+	}
+	previous := map[string]string{}
+	if parent != "" {
+		parentList, err := s.ReadFileList(parent)
+		if err != nil {
+			return err
+		}
+		for _, file := range parentList {
+			if !file.IsDir() {
+				previous[file.Path] = file.Id
+			}
+		}
+	}
+	// Git has no empty directories.
+	fileList = slices.DeleteFunc(fileList, FileListEntry.IsDir)
+	sort.Slice(fileList, func(i, j int) bool { return fileList[i].Path < fileList[j].Path })
+
+	// Blobs go first, a commit can only refer to marks written before it.
+	changes := []string{}
+	current := map[string]bool{}
+	for _, file := range fileList {
+		current[file.Path] = true
+		if previous[file.Path] == file.Id {
+			continue
+		}
+		_, fileName := ParsePath(file.Path)
+		data, mode, err := s.ReadObject(file.CommitId, file.Id, fileName)
+		if err != nil {
+			return err
+		}
+		if _, exported := marks[file.Id]; !exported {
+			fmt.Fprintf(out, "blob\nmark :%d\n", newMark(file.Id))
+			writeFastExportData(out, data)
+			stats.Blobs++
+		}
+		changes = append(changes, fmt.Sprintf("M %s :%d %s", gitFileMode(mode), marks[file.Id], fastExportPath(file.Path)))
+	}
+	removed := []string{}
+	for path := range previous {
+		if !current[path] {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	for _, path := range removed {
+		changes = append(changes, "D "+fastExportPath(path))
+	}
+
+	metadata, err := s.ReadCommitMetadata(commit.Id)
+	if err != nil {
+		return err
+	}
+	signature := strings.TrimSpace(fmt.Sprintf("%s <%s> %s", metadata.Author.Name, metadata.Author.Email, fastExportTime(commit.Timestamp)))
+	fmt.Fprintf(out, "commit refs/heads/%s\nmark :%d\n", branch, newMark(commit.Id))
+	fmt.Fprintf(out, "author %s\ncommitter %s\n", signature, signature)
+	message := metadata.Message
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	writeFastExportData(out, []byte(message))
+	if parent != "" {
+		fmt.Fprintf(out, "from :%d\n", marks[parent])
+	}
+	for _, change := range changes {
+		fmt.Fprintln(out, change)
+	}
+	fmt.Fprintln(out)
+	stats.Commits++
+	return nil
+}
+
+func writeFastExportData(out *bufio.Writer, data []byte) {
+	fmt.Fprintf(out, "data %d\n", len(data))
+	out.Write(data)
+	out.WriteString("\n")
+}
+
+// fastExportTime formats an RFC 3339 timestamp as `<unix-seconds> <+hhmm>`.
+func fastExportTime(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return "0 +0000"
+	}
+	return fmt.Sprintf("%d %s", t.Unix(), t.Format("-0700"))
+}
+
+// fastExportPath quotes paths that would otherwise be misread, those starting with a quote or containing a line break.
+func fastExportPath(path string) string {
+	if strings.HasPrefix(path, "\"") || strings.ContainsAny(path, "\n\r") {
+		return strconv.Quote(path)
+	}
+	return path
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func Test_Export_Incremental(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 2)
+
+	dir := t.TempDir()
+	full, again := dir+"/full.fi", dir+"/again.fi"
+	if returnCode := runExportCommand("git-fast-import", full, "", nil); returnCode != 2201 {
+		t.Fatalf("Expected 2201, got %d", returnCode)
+	}
+	runExportCommand("git-fast-import", again, "", nil)
+	a, _ := os.ReadFile(full)
+	b, _ := os.ReadFile(again)
+	if len(a) == 0 || !bytes.Equal(a, b) {
+		t.Errorf("Expected exporting twice to give the same stream")
+	}
+	if strings.Count(string(a), "\ncommit refs/heads/"+InitBranch) != 2 || strings.Count(string(a), "blob\n") != 2 {
+		t.Errorf("Expected 2 commits and 2 blobs, got:\n%s", a)
+	}
+
+	// The first export records 4 marks, the next one only writes the new commit and continues the numbering.
+	marks := dir + "/nexio.marks"
+	runExportCommand("git-fast-import", dir+"/first.fi", marks, nil)
+	commitTestChange(t, "changed")
+	update := dir + "/update.fi"
+	if returnCode := runExportCommand("git-fast-import", update, marks, []string{InitBranch}); returnCode != 2201 {
+		t.Fatalf("Expected 2201, got %d", returnCode)
+	}
+	stream, _ := os.ReadFile(update)
+	if strings.Count(string(stream), "commit refs/heads/") != 1 || !strings.Contains(string(stream), "blob\nmark :5\n") {
+		t.Errorf("Expected one new commit with marks after the recorded ones, got:\n%s", stream)
+	}
+	if !strings.Contains(string(stream), "from :4\n") || !strings.Contains(string(stream), "M 100644 :5 "+namespace+"file1.txt\n") {
+		t.Errorf("Expected the new commit to build on the recorded one, got:\n%s", stream)
+	}
+	if recorded, _ := ReadExportMarks(marks); len(recorded) != 6 {
+		t.Errorf("Expected 6 marks after the update, got %v", recorded)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Export_GitFastImport(t *testing.T) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	os.RemoveAll(namespace)
+	runInitCommand()
+	if returnCode := runImportGitCommand("testdata/git/history.git", "", ""); returnCode != 2101 {
+		t.Fatalf("Expected 2101, got %d", returnCode)
+	}
+	runNewCommand("feature", "", "")
+
+	stream := t.TempDir() + "/history.fi"
+	if returnCode := runExportCommand("git-fast-import", stream, "", nil); returnCode != 2201 {
+		t.Fatalf("Expected 2201, got %d", returnCode)
+	}
+	repository := t.TempDir()
+	gitCommand := func(args ...string) string {
+		command := exec.Command(git, args...)
+		command.Dir = repository
+		output, err := command.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	gitCommand("init", "--quiet", "--bare")
+	input, _ := os.Open(stream)
+	defer input.Close()
+	command := exec.Command(git, "fast-import", "--quiet")
+	command.Dir, command.Stdin = repository, input
+	if output, err := command.CombinedOutput(); err != nil {
+		t.Fatalf("git fast-import failed: %v\n%s", err, output)
+	}
+
+	log := gitCommand("log", "--format=%an <%ae> %aI %s", InitBranch)
+	expected := "Ada Lovelace <ada@example.com> 2024-01-05T10:00:00+01:00 Merge feature\n"
+	if !strings.HasPrefix(log, expected) || strings.Count(log, "\n") != 4 {
+		t.Errorf("Expected the imported history in Git, got:\n%s", log)
+	}
+	if content := gitCommand("show", InitBranch+":"+namespace+"README.md"); content != "hello world\n" {
+		t.Errorf("Expected README.md of the last commit, got %q", content)
+	}
+	if tree := gitCommand("ls-tree", InitBranch+"~3", namespace); !strings.Contains(tree, "100755 blob") {
+		t.Errorf("Expected the script to keep its mode, got:\n%s", tree)
+	}
+	if gitCommand("rev-parse", "feature") != gitCommand("rev-parse", InitBranch) {
+		t.Errorf("Expected the branches sharing commits to point at the same Git commit")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Export_Errors(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	output := t.TempDir() + "/out.fi"
+	if returnCode := runExportCommand("svn", output, "", nil); returnCode != 2202 {
+		t.Errorf("Expected 2202, got %d", returnCode)
+	}
+	if returnCode := runExportCommand("git-fast-import", output, "", []string{"missing"}); returnCode != 2203 {
+		t.Errorf("Expected 2203, got %d", returnCode)
+	}
+	marks := t.TempDir() + "/broken.marks"
+	os.WriteFile(marks, []byte("not a mark\n"), 0644)
+	if returnCode := runExportCommand("git-fast-import", output, marks, nil); returnCode != 2204 {
+		t.Errorf("Expected 2204, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(fetchCmd)
+}
+
+var fetchCmd = &cobra.Command{
+	Use:     "fetch",
+	Short:   "Download the commits of a remote",
+	Example: "nexio fetch\nnexio fetch origin",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		remoteName := ""
+		if len(args) == 1 {
+			remoteName = args[0]
+		}
+		Debug("Starting fetch command: remote=%s", remoteName)
+		runFetchCommand(remoteName)
+	},
+}
+
+func runFetchCommand(remoteName string) (returnCode int, fetched []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	remote, transport, err := OpenRemote(remoteName)
+	if err != nil {
+		Debug("Failed to open remote: %v", err)
+		if err.Error() == REMOTE_RETURN_CODES[1304] {
+			Fail(REMOTE_RETURN_CODES[1304])
+			return 1304, nil
+		}
+		Fail(FETCH_RETURN_CODES[1503] + " " + err.Error())
+		return 1503, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	refs, err := transport.ListRefs()
+	if err != nil {
+		Debug("Failed to list remote refs: %v", err)
+		Fail(FETCH_RETURN_CODES[1503] + " " + err.Error())
+		return 1503, nil
+	}
+	ids := []string{}
+	for _, commits := range refs.Branches {
+		ids = append(ids, CommitIds(commits)...)
+	}
+	fetched, err = FetchMissingCommits(store, transport, ids)
+	if err != nil {
+		Debug("Failed to fetch commits: %v", err)
+		Fail(FETCH_RETURN_CODES[1503] + " " + err.Error())
+		return 1503, nil
+	}
+	if err := UpdateRemoteRefs(store, remote.Name, refs); err != nil {
+		Debug("Failed to update remote-tracking refs")
+		MustSucceed(err, "operation failed")
+	}
+
+	BreakLine()
+	if len(fetched) == 0 {
+		Info(FETCH_RETURN_CODES[1501])
+		BreakLine()
+		return 1501, fetched
+	}
+	Success(fmt.Sprintf("%s %d from %s", FETCH_RETURN_CODES[1502], len(fetched), Code(remote.Name)))
+	Tree(describeRemoteBranches(refs), false)
+	BreakLine()
+	return 1502, fetched
+}
+
+// describeRemoteBranches compares the branches of a remote with the local ones.
+func describeRemoteBranches(refs RemoteRefs) []string {
+	names := []string{}
+	for name := range refs.Branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		remoteCommits := refs.Branches[name]
+		localCommits, err := GetBranchCommits(store, name)
+		var status string
+		switch {
+		case err != nil:
+			status = "new branch"
+		case HeadOf(localCommits) == HeadOf(remoteCommits):
+			status = "up to date"
+		case IsFastForward(localCommits, remoteCommits):
+			status = fmt.Sprintf("%d new commits, run %s", len(remoteCommits)-len(localCommits), Code("nexio pull"))
+		case IsFastForward(remoteCommits, localCommits):
+			status = "local branch is ahead"
+		default:
+			status = "diverged"
+		}
+		lines = append(lines, StyledBranch(name)+": "+status)
+	}
+	return lines
+}
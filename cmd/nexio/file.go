@@ -2,19 +2,30 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// CopyFile copies a regular file with its permissions, or a symlink as a symlink to the same target.
 func CopyFile(src, dst string) error {
 	Debug("Copying file from %s to %s", src, dst)
-	sourceFileStat, err := os.Stat(src)
+	sourceFileStat, err := os.Lstat(src)
 	if err != nil {
 		Debug("Source file does not exist: %s", src)
 		return err
 	}
 
+	if sourceFileStat.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return WriteFileWithMode(dst, []byte(target), sourceFileStat.Mode())
+	}
 	if !sourceFileStat.Mode().IsRegular() {
 		Debug("Source is not a regular file: %s", src)
 		return os.ErrInvalid
@@ -69,6 +80,106 @@ func CopyFile(src, dst string) error {
 	return nil
 }
 
+// ReadFileWithMode returns the content and the mode of a file. The mode keeps the type of what is
+// tracked besides regular files: a symlink, whose content is its target, or an empty directory.
+func ReadFileWithMode(path string) ([]byte, os.FileMode, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []byte(target), os.ModeSymlink | info.Mode().Perm(), nil
+	case info.IsDir():
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(entries) > 0 {
+			return nil, 0, &os.PathError{Op: "read", Path: path, Err: errors.New("is a directory that is not empty")}
+		}
+		return nil, os.ModeDir | info.Mode().Perm(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, info.Mode().Perm(), nil
+}
+
+// CheckSymlinkParents returns an error when a directory between root and path is a symlink, writing
+// through it could leave root. The directories that do not exist yet are created by WriteFileWithMode.
+func CheckSymlinkParents(root string, path string) error {
+	dir := root
+	parents := strings.Split(path, "/")
+	for _, name := range parents[:len(parents)-1] {
+		dir = filepath.Join(dir, name)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("invalid path %q: %s is a symlink", path, dir)
+		}
+	}
+	return nil
+}
+
+// WriteFileWithMode writes data to path with the given mode, creating missing directories and
+// replacing an existing file even if it is read-only. A symlink is created to the target in data,
+// a directory is only created.
+func WriteFileWithMode(path string, data []byte, mode os.FileMode) error {
+	Debug("Writing file: %s (%v)", path, mode)
+	if info, err := os.Lstat(path); err == nil && !(info.IsDir() && mode.IsDir()) {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	parent, _ := ParsePath(path)
+	if parent != "" {
+		if err := os.MkdirAll(parent, 0700); err != nil {
+			return err
+		}
+	}
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return os.Symlink(string(data), path)
+	case mode.IsDir():
+		if err := os.MkdirAll(path, mode.Perm()); err != nil {
+			return err
+		}
+		return os.Chmod(path, mode.Perm())
+	}
+	if err := os.WriteFile(path, data, mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode.Perm())
+}
+
+// FileType names the kind of a tracked path, as the file list of a commit records it.
+func FileType(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsDir():
+		return "dir"
+	}
+	return "file"
+}
+
+// ModeChanged tells whether two modes differ in what is tracked: the type and the executable bit.
+// Other permissions depend on the umask and are left alone.
+func ModeChanged(a os.FileMode, b os.FileMode) bool {
+	return a.Type() != b.Type() || (a&0111 != 0) != (b&0111 != 0)
+}
+
 func RemoveFile(path string) {
 	Debug("Removing file/directory: %s", path)
 	err := os.RemoveAll(path)
@@ -79,6 +190,17 @@ func RemoveFile(path string) {
 	Debug("File/directory removed successfully")
 }
 
+// RemoveEmptyParents removes the directories above path left empty, up to the working directory.
+func RemoveEmptyParents(path string) {
+	for dir := filepath.Dir(path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		// Only empty directories can be removed.
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		Debug("Removed empty directory: %s", dir)
+	}
+}
+
 func EmptyDir(path string) error {
 	Debug("Emptying directory: %s", path)
 
@@ -111,9 +233,10 @@ func EmptyDir(path string) error {
 	return nil
 }
 
+// FileExists reports whether something is at path, a symlink counts even if its target is missing.
 func FileExists(path string) bool {
 	exists := false
-	if _, err := os.Stat(path); !os.IsNotExist(err) {
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
 		exists = true
 	}
 	Debug("Checking if file exists: %s = %v", path, exists)
@@ -186,3 +309,62 @@ func IsModified(file1, file2 string) (bool, error) {
 		}
 	}
 }
+
+// IsModifiedFromObject compares a file of the working directory with its committed version.
+func IsModifiedFromObject(path string, commitId string, fileId string) (bool, error) {
+	Debug("Checking if file is modified since commit %s: %s", commitId, path)
+	_, fileName := ParsePath(path)
+	data, mode, err := store.ReadObject(commitId, fileId, fileName)
+	if err != nil {
+		Debug("Failed to read object: %s/%s", commitId, fileId)
+		return false, err
+	}
+	return isContentModified(path, data, mode)
+}
+
+// IsModifiedFromStaged compares a file of the working directory with its staged version.
+func IsModifiedFromStaged(path string, op string, id string) (bool, error) {
+	Debug("Checking if file is modified since staged as %s: %s", op, path)
+	_, fileName := ParsePath(path)
+	data, mode, err := store.ReadStagedFile(op, id, fileName)
+	if err != nil {
+		Debug("Failed to read staged file: %s/%s", op, id)
+		return false, err
+	}
+	return isContentModified(path, data, mode)
+}
+
+func isContentModified(path string, data []byte, mode os.FileMode) (bool, error) {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		Debug("Failed to stat file: %s", path)
+		return false, err
+	}
+	if ModeChanged(mode, stat.Mode()) {
+		Debug("Files have different modes: %v vs %v", mode, stat.Mode())
+		return true, nil
+	}
+	// A tracked directory is unchanged as long as it is there, its files are tracked on their own.
+	if stat.IsDir() {
+		return false, nil
+	}
+	if stat.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return false, err
+		}
+		return target != string(data), nil
+	}
+	if stat.Size() != int64(len(data)) {
+		Debug("Files have different sizes")
+		return true, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		Debug("Failed to read file: %s", path)
+		return false, err
+	}
+	modified := !bytes.Equal(content, data)
+	Debug("Files are different: %v", modified)
+	return modified, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func Test_FileModes(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	os.MkdirAll(namespace+"empty", 0755)
+	os.WriteFile(namespace+"run.sh", []byte("#!/bin/sh\necho run\n"), 0755)
+	os.WriteFile(namespace+"config.yml", []byte("key: value\n"), 0644)
+	os.Symlink("config.yml", namespace+"link.yml")
+	for _, file := range []string{"empty", "run.sh", "config.yml", "link.yml"} {
+		if result := runAddCommand(namespace+file, false); result.ReturnCode != 112 {
+			t.Fatalf("Expected 112 for %s, got %d", file, result.ReturnCode)
+		}
+	}
+	returnCode, first := runCommitCommand("Add files")
+	if returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+	files, _ := commitFileMap(store, first)
+	expected := map[string]string{"empty": "dir", "run.sh": "file", "config.yml": "file", "link.yml": "symlink"}
+	for file, fileType := range expected {
+		if files[namespace+file].Type != fileType {
+			t.Errorf("Expected %s to be recorded as %s, got %v", file, fileType, files[namespace+file])
+		}
+	}
+	if files[namespace+"run.sh"].Mode != 0755 {
+		t.Errorf("Expected the mode of run.sh to be recorded, got %v", files[namespace+"run.sh"].Mode)
+	}
+	if data, mode, _ := store.ReadObject(first, files[namespace+"link.yml"].Id, "link.yml"); string(data) != "config.yml" || mode&os.ModeSymlink == 0 {
+		t.Errorf("Expected the target of the symlink as its content, got %q (%v)", data, mode)
+	}
+	if modified, deleted := GetModifiedOrDeletedFiles(); len(modified)+len(deleted) != 0 {
+		t.Errorf("Expected no changes, got %v %v", modified, deleted)
+	}
+
+	// Only the mode changed.
+	os.Chmod(namespace+"run.sh", 0644)
+	if modified, _ := GetModifiedOrDeletedFiles(); !slices.Equal(modified, []string{namespace + "run.sh"}) {
+		t.Fatalf("Expected the mode change of run.sh, got %v", modified)
+	}
+	if change := DescribeModeChange(namespace + "run.sh"); change != "mode 100755 -> 100644" {
+		t.Errorf("Expected the mode change to be described, got %q", change)
+	}
+	os.Chmod(namespace+"run.sh", 0755)
+
+	if returnCode := runNewCommand("feature", "", ""); returnCode != 206 {
+		t.Fatalf("Expected 206, got %d", returnCode)
+	}
+	os.Chmod(namespace+"run.sh", 0644)
+	runAddCommand(namespace+"run.sh", false)
+	runRmCommand([]string{namespace + "link.yml"}, false, false, true)
+	// Files put in a tracked directory do not change it.
+	os.WriteFile(namespace+"empty/new.txt", []byte("new\n"), 0644)
+	runAddCommand(namespace+"empty/new.txt", false)
+	if returnCode, _ := runCommitCommand("Change modes"); returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+
+	if returnCode := runSwitchCommand(InitBranch); returnCode != 213 {
+		t.Fatalf("Expected 213, got %d", returnCode)
+	}
+	if info, err := os.Stat(namespace + "run.sh"); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("Expected run.sh to be executable again, got %v", info)
+	}
+	if target, err := os.Readlink(namespace + "link.yml"); err != nil || target != "config.yml" {
+		t.Errorf("Expected the symlink to be restored, got %q (%v)", target, err)
+	}
+	if info, err := os.Stat(namespace + "empty"); err != nil || !info.IsDir() {
+		t.Errorf("Expected the empty directory to be restored")
+	}
+	if FileExists(namespace + "empty/new.txt") {
+		t.Errorf("Expected the file of the other branch to be removed")
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -263,6 +263,34 @@ func Test_CopyFile_ErrorCases(t *testing.T) {
 	})
 }
 
+func Test_CopyFile_Symlink(t *testing.T) {
+	tmpDir := namespace + "test_symlink"
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	// The target does not need to exist
+	srcPath := filepath.Join(tmpDir, "link")
+	dstPath := filepath.Join(tmpDir, "copied", "link")
+	if err := os.Symlink("missing.txt", srcPath); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	if err := CopyFile(srcPath, dstPath); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	if target, err := os.Readlink(dstPath); err != nil || target != "missing.txt" {
+		t.Errorf("Expected a symlink to missing.txt, got %q (%v)", target, err)
+	}
+
+	data, mode, err := ReadFileWithMode(dstPath)
+	if err != nil || string(data) != "missing.txt" || mode&os.ModeSymlink == 0 {
+		t.Errorf("Expected the target as content of the symlink, got %q (%v, %v)", data, mode, err)
+	}
+}
+
 func Test_CopyFile_PreservesExecutableBit(t *testing.T) {
 	tmpDir := namespace + "test_executable_bit"
 	defer os.RemoveAll(tmpDir)
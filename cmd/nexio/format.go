@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Format struct {
+	Version int `json:"version"`
+}
+
+// Migration upgrades a repository from Version-1 to Version.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(s Storage) error
+}
+
+// migrations must be ordered by version. Every change to the layout of `.nexio`
+// or to one of its JSON schemas needs a new entry here.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Record the format version in format.json",
+		Migrate:     func(Storage) error { return nil },
+	},
+	{
+		Version:     2,
+		Description: "Create the staging directory for conflicts",
+		Migrate:     func(s Storage) error { return createDir(s, func(d Dirs) string { return d.StagingConflicted }) },
+	},
+	{
+		Version:     3,
+		Description: "Create the staging directory for renames",
+		Migrate:     func(s Storage) error { return createDir(s, func(d Dirs) string { return d.StagingRenamed }) },
+	},
+	{
+		Version:     4,
+		Description: "Create the directory for chunks of large files",
+		Migrate:     func(s Storage) error { return createDir(s, func(d Dirs) string { return d.Chunks }) },
+	},
+}
+
+// createDir creates a directory of the `.nexio` layout, other storages have no directories.
+func createDir(s Storage, dir func(d Dirs) string) error {
+	if fileStorage, ok := s.(*FileStorage); ok {
+		return os.MkdirAll(dir(fileStorage.dirs), os.ModePerm)
+	}
+	return nil
+}
+
+// CurrentFormatVersion is the format version written and supported by this version of Nexio.
+func CurrentFormatVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ReadFormatVersion returns the format version of the repository, 0 if `format.json` does not exist.
+func ReadFormatVersion() (int, error) {
+	return store.ReadFormatVersion()
+}
+
+func WriteFormatVersion(version int) {
+	Debug("Writing format version: %d", version)
+	if err := store.WriteFormatVersion(version); err != nil {
+		Debug("Failed to write format version")
+		MustSucceed(err, "operation failed")
+	}
+}
+
+// EnsureFormat refuses repositories written by a newer Nexio and upgrades older ones.
+func EnsureFormat(s Storage) error {
+	version, err := s.ReadFormatVersion()
+	if err != nil {
+		Debug("Failed to read format version")
+		return err
+	}
+	if version > CurrentFormatVersion() {
+		return fmt.Errorf("repository format version %d is newer than the supported version %d, please upgrade Nexio", version, CurrentFormatVersion())
+	}
+	if version < CurrentFormatVersion() {
+		return MigrateRepository(s)
+	}
+	return nil
+}
+
+// MigrateRepository backs up `.nexio` and applies the pending migrations one by one,
+// recording the version after each step so an interrupted upgrade resumes where it stopped.
+func MigrateRepository(s Storage) error {
+	release, err := s.LockRepository(ExclusiveLock)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Another process may have migrated the repository while we were waiting for the lock.
+	version, err := s.ReadFormatVersion()
+	if err != nil {
+		return err
+	}
+	if version >= CurrentFormatVersion() {
+		return nil
+	}
+
+	backup, err := s.Backup(fmt.Sprintf("v%d-%s", version, time.Now().Format("20060102150405")))
+	if err != nil {
+		return fmt.Errorf("failed to back up repository: %w", err)
+	}
+	Debug("Backed up repository to %s", backup)
+
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		Debug("Migrating repository to format version %d: %s", migration.Version, migration.Description)
+		if err := migration.Migrate(s); err != nil {
+			return fmt.Errorf("migration to format version %d failed, backup kept at %s: %w", migration.Version, backup, err)
+		}
+		if err := s.WriteFormatVersion(migration.Version); err != nil {
+			return err
+		}
+	}
+
+	Info(fmt.Sprintf("Repository format upgraded from v%d to v%d, backup kept at %s", version, CurrentFormatVersion(), Code(backup)))
+	return nil
+}
+
+// CopyDir copies a directory recursively, lock files and the skip directory are skipped.
+func CopyDir(src string, dst string, skip string) error {
+	Debug("Copying directory from %s to %s", src, dst)
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && skip != "" && filepath.Clean(path) == filepath.Clean(skip) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		return CopyFile(path, target)
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	formatPatchCmd.Flags().StringVarP(&PatchOutputDirectory, "output-directory", "o", ".", "Directory to write the patch files to")
+
+	rootCmd.AddCommand(formatPatchCmd)
+}
+
+var PatchOutputDirectory string
+
+var formatPatchCmd = &cobra.Command{
+	Use:     "format-patch",
+	Short:   "Write commits as patch files to send by mail",
+	Example: "nexio format-patch HEAD\nnexio format-patch main~3..main -o outgoing/\nnexio format-patch <commit-id>..feature",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting format-patch command: range=%s, output=%s", args[0], PatchOutputDirectory)
+		runFormatPatchCommand(args[0], PatchOutputDirectory)
+	},
+}
+
+func runFormatPatchCommand(spec string, directory string) (returnCode int, files []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	parent, commits, err := ResolveRange(store, spec)
+	if err != nil {
+		Debug("Failed to resolve range: %v", err)
+		Fail(FORMAT_PATCH_RETURN_CODES[2302] + " " + err.Error())
+		return 2302, nil
+	}
+	if len(commits) == 0 {
+		Debug("%s", FORMAT_PATCH_RETURN_CODES[2303])
+		Warning(FORMAT_PATCH_RETURN_CODES[2303])
+		return 2303, nil
+	}
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		Debug("Failed to create output directory: %v", err)
+		Fail(FORMAT_PATCH_RETURN_CODES[2304] + " " + err.Error())
+		return 2304, nil
+	}
+
+	for i, commit := range commits {
+		patches, err := CommitFilePatches(store, parent, commit.Id)
+		if err != nil {
+			Debug("Failed to diff commit: %s", commit.Id)
+			MustSucceed(err, "operation failed")
+		}
+		metadata, err := store.ReadCommitMetadata(commit.Id)
+		if err != nil {
+			Debug("Failed to read commit metadata: %s", commit.Id)
+			MustSucceed(err, "operation failed")
+		}
+		file := filepath.Join(directory, patchFileName(i+1, metadata.Message))
+		if err := os.WriteFile(file, []byte(FormatPatch(commit, metadata, patches, i+1, len(commits))), 0644); err != nil {
+			Debug("Failed to write patch: %v", err)
+			Fail(FORMAT_PATCH_RETURN_CODES[2304] + " " + err.Error())
+			return 2304, files
+		}
+		files = append(files, file)
+		parent = commit.Id
+	}
+
+	BreakLine()
+	Success(FORMAT_PATCH_RETURN_CODES[2301] + " " + FormatFileCount(len(files)))
+	Tree(files, false)
+	BreakLine()
+	return 2301, files
+}
+
+var nonSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// patchFileName numbers the patch and adds the subject, e.g. `0001-fix-the-parser.patch`.
+func patchFileName(number int, message string) string {
+	slug := strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(FirstLine(message)), "-"), "-")
+	if len(slug) > 52 {
+		slug = strings.TrimRight(slug[:52], "-")
+	}
+	if slug == "" {
+		slug = "patch"
+	}
+	return fmt.Sprintf("%04d-%s.patch", number, slug)
+}
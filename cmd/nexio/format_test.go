@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Format_WrittenByInit(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	version, err := ReadFormatVersion()
+	if err != nil {
+		t.Fatalf("Failed to read format version: %v", err)
+	}
+	if version != CurrentFormatVersion() {
+		t.Errorf("Expected format version %d, got %d", CurrentFormatVersion(), version)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Format_MigratesLegacyRepository(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+	os.Remove(dirs.Format)
+	os.MkdirAll(dirs.Backups+"earlier", 0755)
+
+	if !IsInitialized() {
+		t.Fatalf("Expected repository to be initialized")
+	}
+	version, _ := ReadFormatVersion()
+	if version != CurrentFormatVersion() {
+		t.Errorf("Expected repository to be migrated to %d, got %d", CurrentFormatVersion(), version)
+	}
+
+	backups, _ := filepath.Glob(dirs.Backups + "v0-*")
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %d", len(backups))
+	}
+	if !FileExists(backups[0] + "/commits/" + GetLastCommit().Id + "/fileList.json") {
+		t.Errorf("Expected backup to contain the commits")
+	}
+	if FileExists(backups[0] + "/format.json") {
+		t.Errorf("Expected backup to be taken before migrating")
+	}
+	if matches, _ := filepath.Glob(namespace + ".nexio.backup-*"); len(matches) > 0 {
+		t.Errorf("Expected the backup to stay out of the working tree, got %v", matches)
+	}
+	if FileExists(backups[0] + "/backups") {
+		t.Errorf("Expected the backup to leave out the earlier backups")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Format_MigrationsRunInOrder(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	original := migrations
+	defer func() { migrations = original }()
+
+	second, third := CurrentFormatVersion()+1, CurrentFormatVersion()+2
+	applied := []int{}
+	migrations = append(append([]Migration{}, original...),
+		Migration{Version: second, Description: "second", Migrate: func(Storage) error {
+			applied = append(applied, second)
+			return nil
+		}},
+		Migration{Version: third, Description: "third", Migrate: func(Storage) error {
+			applied = append(applied, third)
+			return errors.New("broken migration")
+		}},
+	)
+
+	if err := EnsureFormat(store); err == nil {
+		t.Errorf("Expected failing migration to be reported")
+	}
+	if len(applied) != 2 || applied[0] != second || applied[1] != third {
+		t.Errorf("Expected migrations %d and %d to run in order, got %v", second, third, applied)
+	}
+	if version, _ := ReadFormatVersion(); version != second {
+		t.Errorf("Expected repository to stay at the last successful version %d, got %d", second, version)
+	}
+
+	migrations[len(migrations)-1].Migrate = func(Storage) error {
+		applied = append(applied, third)
+		return nil
+	}
+	if err := EnsureFormat(store); err != nil {
+		t.Errorf("Expected migration to succeed: %v", err)
+	}
+	if len(applied) != 3 {
+		t.Errorf("Expected only the pending migration to run, got %v", applied)
+	}
+	if version, _ := ReadFormatVersion(); version != third {
+		t.Errorf("Expected format version %d, got %d", third, version)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Format_RefusesNewerVersion(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	WriteFormatVersion(CurrentFormatVersion() + 1)
+	if err := EnsureFormat(store); err == nil {
+		t.Errorf("Expected newer format version to be refused")
+	}
+
+	os.RemoveAll(namespace)
+}
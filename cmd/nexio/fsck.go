@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	fsckCmd.Flags().BoolVarP(&Repair, "repair", "r", false, "Attempt to repair the problems found")
+
+	rootCmd.AddCommand(fsckCmd)
+}
+
+var Repair bool
+
+var fsckCmd = &cobra.Command{
+	Use:     "fsck",
+	Short:   "Verify the integrity of the repository",
+	Example: "nexio fsck\nnexio fsck --repair",
+	Args:    cobra.NoArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting fsck command: repair=%v", Repair)
+		runFsckCommand(store, Repair)
+	},
+}
+
+func runFsckCommand(s Storage, repair bool) (returnCode int, problems []FsckProblem) {
+	if initialized := IsStorageInitialized(s); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	mode := SharedLock
+	if repair {
+		mode = ExclusiveLock
+	}
+	release, err := s.LockRepository(mode)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	problems = CheckRepository(s)
+	BreakLine()
+	if len(problems) == 0 {
+		Debug("%s", FSCK_RETURN_CODES[1001])
+		Success(FSCK_RETURN_CODES[1001])
+		BreakLine()
+		return 1001, problems
+	}
+
+	counts := map[string]int{}
+	lines := []string{}
+	for _, problem := range problems {
+		counts[problem.Severity]++
+		lines = append(lines, StyledSeverity(problem.Severity)+" "+problem.String())
+	}
+	Warning(FSCK_RETURN_CODES[1002] + " " + Code(fmt.Sprintf("%d errors, %d warnings, %d info", counts[SeverityError], counts[SeverityWarning], counts[SeverityInfo])))
+	Tree(lines, false)
+	BreakLine()
+
+	if !repair {
+		repairable := 0
+		for _, problem := range problems {
+			if problem.Repairable() {
+				repairable++
+			}
+		}
+		if repairable > 0 {
+			Text("Use "+Code("nexio fsck --repair")+fmt.Sprintf(" to repair %d of them", repairable), "")
+			BreakLine()
+		}
+		return 1002, problems
+	}
+
+	unrepaired := []string{}
+	for _, problem := range problems {
+		if problem.Severity == SeverityInfo {
+			continue
+		}
+		if !problem.Repairable() {
+			unrepaired = append(unrepaired, problem.String())
+			continue
+		}
+		Debug("Repairing: %s", problem.String())
+		if err := problem.repair(); err != nil {
+			Debug("Repair failed: %v", err)
+			unrepaired = append(unrepaired, problem.String()+" ("+err.Error()+")")
+		}
+	}
+
+	if len(unrepaired) > 0 {
+		Fail(FSCK_RETURN_CODES[1004])
+		Tree(unrepaired, false)
+		BreakLine()
+		return 1004, problems
+	}
+	Success(FSCK_RETURN_CODES[1003])
+	BreakLine()
+	return 1003, problems
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+type FsckProblem struct {
+	Severity string
+	Object   string
+	Message  string
+	repair   func() error
+}
+
+func (p FsckProblem) Repairable() bool {
+	return p.repair != nil
+}
+
+func (p FsckProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Object, p.Message)
+}
+
+// CheckRepository runs all integrity checks and returns the problems found, ordered by severity.
+// Reads report broken documents instead of terminating the process on the first one.
+func CheckRepository(s Storage) []FsckProblem {
+	Debug("Checking repository integrity")
+	problems := []FsckProblem{}
+
+	branches, err := s.ListBranches()
+	if err != nil {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   "branches",
+			Message:  "unable to read branches directory: " + err.Error(),
+		})
+		return problems
+	}
+
+	problems = append(problems, CheckBranchesMetadata(s, branches)...)
+
+	referenced := map[string]bool{}
+	for _, branch := range branches {
+		branchProblems, commitIds := CheckBranchCommits(s, branch)
+		problems = append(problems, branchProblems...)
+		for _, id := range commitIds {
+			referenced[id] = true
+		}
+	}
+
+	commitIds, err := s.ListCommits()
+	if err != nil {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   "commits",
+			Message:  "unable to read commits directory: " + err.Error(),
+		})
+	}
+	for _, commitId := range commitIds {
+		problems = append(problems, CheckCommit(s, commitId)...)
+		if !referenced[commitId] {
+			problems = append(problems, FsckProblem{
+				Severity: SeverityInfo,
+				Object:   "commit " + commitId,
+				Message:  "not reachable from any branch, run `nexio gc` to remove it",
+			})
+		}
+	}
+
+	problems = append(problems, CheckStaging(s)...)
+
+	severityOrder := map[string]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return severityOrder[problems[i].Severity] < severityOrder[problems[j].Severity]
+	})
+	Debug("Found %d problems", len(problems))
+	return problems
+}
+
+// CheckBranchesMetadata verifies that `branches/metadata.json` parses and points at existing branches.
+func CheckBranchesMetadata(s Storage, branches []string) []FsckProblem {
+	Debug("Checking branches metadata")
+	problems := []FsckProblem{}
+
+	fallback := InitBranch
+	if !slices.Contains(branches, fallback) && len(branches) > 0 {
+		fallback = branches[0]
+	}
+
+	metadata, err := s.ReadBranchesMetadata()
+	if err != nil {
+		problem := FsckProblem{
+			Severity: SeverityError,
+			Object:   "branches/metadata.json",
+			Message:  "unable to parse: " + err.Error(),
+		}
+		if len(branches) > 0 {
+			problem.repair = func() error {
+				return s.WithLock(BranchesMetadataLock, func() error {
+					return s.WriteBranchesMetadata(BranchMetadata{Default: fallback, Current: fallback})
+				})
+			}
+		}
+		return append(problems, problem)
+	}
+
+	if len(branches) == 0 {
+		return problems
+	}
+
+	// repairMetadata points a field of the metadata at the fallback branch.
+	repairMetadata := func(update func(m *BranchMetadata)) func() error {
+		return func() error {
+			return s.WithLock(BranchesMetadataLock, func() error {
+				m, err := s.ReadBranchesMetadata()
+				if err != nil {
+					return err
+				}
+				update(&m)
+				return s.WriteBranchesMetadata(m)
+			})
+		}
+	}
+	if !slices.Contains(branches, metadata.Default) {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   "branches/metadata.json",
+			Message:  fmt.Sprintf("default branch %q does not exist", metadata.Default),
+			repair:   repairMetadata(func(m *BranchMetadata) { m.Default = fallback }),
+		})
+	}
+	if !slices.Contains(branches, metadata.Current) {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   "branches/metadata.json",
+			Message:  fmt.Sprintf("current branch %q does not exist", metadata.Current),
+			repair:   repairMetadata(func(m *BranchMetadata) { m.Current = fallback }),
+		})
+	}
+	return problems
+}
+
+// CheckBranchCommits verifies the linked list of commits of a branch: a single head (empty Next),
+// a single root, no cycles, no dangling Next and no missing commits.
+// It also returns the commit ids referenced by the branch.
+func CheckBranchCommits(s Storage, branch string) (problems []FsckProblem, commitIds []string) {
+	Debug("Checking commits of branch: %s", branch)
+	object := "branch " + branch
+
+	commits, err := s.ReadBranchCommits(branch)
+	if err != nil {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   object,
+			Message:  "unable to parse commits.json: " + err.Error(),
+		})
+		return problems, nil
+	}
+
+	repair := func() error {
+		return relinkBranchCommits(s, branch)
+	}
+
+	commitMap := map[string]Commit{}
+	hasParent := map[string]bool{}
+	for _, commit := range commits {
+		if _, exists := commitMap[commit.Id]; exists {
+			problems = append(problems, FsckProblem{
+				Severity: SeverityError,
+				Object:   object,
+				Message:  "duplicate commit " + commit.Id,
+				repair:   repair,
+			})
+			continue
+		}
+		commitMap[commit.Id] = commit
+		commitIds = append(commitIds, commit.Id)
+		if commit.Next != "" {
+			hasParent[commit.Next] = true
+		}
+	}
+
+	heads := []string{}
+	roots := []string{}
+	for _, id := range commitIds {
+		commit := commitMap[id]
+		if commit.Next == "" {
+			heads = append(heads, id)
+		} else if _, exists := commitMap[commit.Next]; !exists {
+			problems = append(problems, FsckProblem{
+				Severity: SeverityError,
+				Object:   object,
+				Message:  fmt.Sprintf("commit %s points to missing commit %s", id, commit.Next),
+				repair:   repair,
+			})
+		}
+		if !hasParent[id] {
+			roots = append(roots, id)
+		}
+		if !s.HasCommit(id) {
+			problems = append(problems, FsckProblem{
+				Severity: SeverityError,
+				Object:   object,
+				Message:  "commit directory missing for " + id,
+				repair:   repair,
+			})
+		}
+	}
+
+	if len(commitIds) == 0 {
+		return problems, commitIds
+	}
+
+	if len(heads) != 1 {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   object,
+			Message:  fmt.Sprintf("expected a single head, found %d", len(heads)),
+			repair:   repair,
+		})
+	}
+	if len(roots) != 1 {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   object,
+			Message:  fmt.Sprintf("expected a single root, found %d", len(roots)),
+			repair:   repair,
+		})
+	}
+
+	// Walk the list from every root; revisiting a commit means the list contains a cycle.
+	visited := map[string]bool{}
+	for _, root := range roots {
+		current := root
+		for current != "" {
+			if visited[current] {
+				problems = append(problems, FsckProblem{
+					Severity: SeverityError,
+					Object:   object,
+					Message:  "cycle detected at commit " + current,
+					repair:   repair,
+				})
+				break
+			}
+			visited[current] = true
+			current = commitMap[current].Next
+		}
+	}
+	if len(roots) == 0 {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   object,
+			Message:  "cycle detected, no root commit",
+			repair:   repair,
+		})
+	}
+
+	return problems, commitIds
+}
+
+// relinkBranchCommits rebuilds the linked list of a branch from the order of its commits,
+// dropping duplicates and commits that no longer exist.
+func relinkBranchCommits(s Storage, branch string) error {
+	Debug("Relinking commits of branch: %s", branch)
+	return s.WithLock(BranchCommitsLock(branch), func() error {
+		commits, err := s.ReadBranchCommits(branch)
+		if err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		relinked := []Commit{}
+		for _, commit := range commits {
+			if seen[commit.Id] {
+				continue
+			}
+			seen[commit.Id] = true
+			if !s.HasCommit(commit.Id) {
+				continue
+			}
+			relinked = append(relinked, commit)
+		}
+		for i := range relinked {
+			if i < len(relinked)-1 {
+				relinked[i].Next = relinked[i+1].Id
+			} else {
+				relinked[i].Next = ""
+			}
+		}
+		return s.WriteBranchCommits(branch, relinked)
+	})
+}
+
+// CheckCommit verifies that metadata.json, logs.json and fileList.json of a commit parse
+// and that the content of every fileList.json entry is stored, whole or in chunks that all exist.
+func CheckCommit(s Storage, commitId string) []FsckProblem {
+	Debug("Checking commit: %s", commitId)
+	problems := []FsckProblem{}
+	object := "commit " + commitId
+
+	if _, err := s.ReadCommitMetadata(commitId); err != nil {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   object,
+			Message:  "unable to parse metadata.json: " + err.Error(),
+		})
+	}
+
+	if _, err := s.ReadCommitLogs(commitId); err != nil {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityWarning,
+			Object:   object,
+			Message:  "unable to parse logs.json: " + err.Error(),
+			repair: func() error {
+				return s.WriteCommitLogs(commitId, []LogFileEntry{})
+			},
+		})
+	}
+
+	fileList, err := s.ReadFileList(commitId)
+	if err != nil {
+		problems = append(problems, FsckProblem{
+			Severity: SeverityError,
+			Object:   object,
+			Message:  "unable to parse fileList.json: " + err.Error(),
+		})
+		return problems
+	}
+	for _, entry := range fileList {
+		_, fileName := ParsePath(entry.Path)
+		if chunks, chunked, err := s.ObjectChunks(entry.CommitId, entry.Id, fileName); chunked || err != nil {
+			problems = append(problems, checkChunks(s, object, entry.Path, chunks, err)...)
+			continue
+		}
+		if !s.HasObject(entry.CommitId, entry.Id, fileName) {
+			problems = append(problems, FsckProblem{
+				Severity: SeverityError,
+				Object:   object,
+				Message:  fmt.Sprintf("missing content for %s (commits/%s/%s/%s)", entry.Path, entry.CommitId, entry.Id, fileName),
+			})
+		}
+	}
+	return problems
+}
+
+// checkChunks verifies that the chunk manifest of a large file parsed and that its chunks exist.
+func checkChunks(s Storage, object string, filePath string, chunks []string, err error) []FsckProblem {
+	if err != nil {
+		return []FsckProblem{{
+			Severity: SeverityError,
+			Object:   object,
+			Message:  "unable to parse chunk manifest of " + filePath + ": " + err.Error(),
+		}}
+	}
+	problems := []FsckProblem{}
+	for _, id := range chunks {
+		if !s.HasChunk(id) {
+			problems = append(problems, FsckProblem{
+				Severity: SeverityError,
+				Object:   object,
+				Message:  fmt.Sprintf("missing chunk of %s (chunks/%s)", filePath, id),
+			})
+		}
+	}
+	return problems
+}
+
+// CheckStaging verifies that the staging logs parse and that every log entry has a staged file.
+func CheckStaging(s Storage) []FsckProblem {
+	Debug("Checking staging area")
+	logs, err := s.ReadStagingLogs()
+	if err != nil {
+		return []FsckProblem{{
+			Severity: SeverityError,
+			Object:   "staging",
+			Message:  "unable to parse logs.json: " + err.Error(),
+			repair: func() error {
+				err := s.WithLock(StagingLogsLock, func() error {
+					return s.WriteStagingLogs([]LogFileEntry{})
+				})
+				if err != nil {
+					return err
+				}
+				return s.ClearStagedFiles()
+			},
+		}}
+	}
+
+	problems := []FsckProblem{}
+	for _, entry := range logs {
+		if isStaged(s, entry) {
+			continue
+		}
+		Debug("Found orphaned log entry: %s (path: %s)", entry.Id, entry.Path)
+		problems = append(problems, FsckProblem{
+			Severity: SeverityWarning,
+			Object:   "staging",
+			Message:  "log entry " + entry.Id + " has no staged file",
+			repair: func() error {
+				return s.WithLock(StagingLogsLock, func() error {
+					logs, err := s.ReadStagingLogs()
+					if err != nil {
+						return err
+					}
+					return s.WriteStagingLogs(slices.DeleteFunc(logs, func(logged LogFileEntry) bool { return logged.Id == entry.Id }))
+				})
+			},
+		})
+	}
+	return problems
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func commitTestFiles(t *testing.T, count int) {
+	t.Helper()
+	for i := 1; i <= count; i++ {
+		file := namespace + "file" + strconv.Itoa(i) + ".txt"
+		os.WriteFile(file, []byte("content "+strconv.Itoa(i)), 0644)
+		runAddCommand(file, false)
+		if returnCode, _ := runCommitCommand("commit " + strconv.Itoa(i)); returnCode != 702 {
+			t.Fatalf("Expected 702, got %d", returnCode)
+		}
+	}
+}
+
+func hasProblem(problems []FsckProblem, severity string, message string) bool {
+	for _, problem := range problems {
+		if problem.Severity == severity && strings.Contains(problem.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_Fsck_CleanRepository(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 3)
+
+	returnCode, problems := runFsckCommand(store, false)
+	if returnCode != 1001 {
+		t.Errorf("Expected 1001, got %d: %v", returnCode, problems)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Fsck_BrokenLinkedList(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 3)
+
+	commits := *GetCommits()
+	commits[0].Next = "deadbeef"
+	commits[2].Next = commits[0].Id
+	WriteJson(dirs.DefaultBranchCommits, commits)
+
+	returnCode, problems := runFsckCommand(store, false)
+	if returnCode != 1002 {
+		t.Errorf("Expected 1002, got %d", returnCode)
+	}
+	if !hasProblem(problems, SeverityError, "points to missing commit deadbeef") {
+		t.Errorf("Expected dangling Next to be reported, got %v", problems)
+	}
+	if !hasProblem(problems, SeverityError, "expected a single head") {
+		t.Errorf("Expected missing head to be reported, got %v", problems)
+	}
+
+	returnCode, _ = runFsckCommand(store, true)
+	if returnCode != 1003 {
+		t.Errorf("Expected 1003, got %d", returnCode)
+	}
+	returnCode, problems = runFsckCommand(store, false)
+	if returnCode != 1001 {
+		t.Errorf("Expected 1001 after repair, got %d: %v", returnCode, problems)
+	}
+	if len(*GetCommits()) != 3 {
+		t.Errorf("Expected 3 commits after repair, got %d", len(*GetCommits()))
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Fsck_MissingFileContent(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	entry := (*GetFileListContent(GetLastCommit().Id))[0]
+	os.RemoveAll(dirs.Commits + entry.CommitId + "/" + entry.Id)
+
+	returnCode, problems := runFsckCommand(store, true)
+	if returnCode != 1004 {
+		t.Errorf("Expected 1004, got %d", returnCode)
+	}
+	if !hasProblem(problems, SeverityError, "missing content for "+entry.Path) {
+		t.Errorf("Expected missing content to be reported, got %v", problems)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Fsck_BranchesMetadataAndOrphans(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	runNewCommand("feature", "", "")
+	commitTestFiles(t, 1)
+	runSwitchCommand("main")
+	os.RemoveAll(dirs.Branches + "feature")
+	WriteJson(dirs.BranchesMetadata, BranchMetadata{Default: "main", Current: "feature"})
+
+	returnCode, problems := runFsckCommand(store, false)
+	if returnCode != 1002 {
+		t.Errorf("Expected 1002, got %d", returnCode)
+	}
+	if !hasProblem(problems, SeverityError, "current branch \"feature\" does not exist") {
+		t.Errorf("Expected missing current branch to be reported, got %v", problems)
+	}
+	if !hasProblem(problems, SeverityInfo, "not reachable from any branch") {
+		t.Errorf("Expected orphaned commit to be reported, got %v", problems)
+	}
+
+	runFsckCommand(store, true)
+	if GetCurrentBranchName() != "main" {
+		t.Errorf("Expected current branch to be repaired to main, got %s", GetCurrentBranchName())
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Fsck_MemoryStorage(t *testing.T) {
+	t.Parallel()
+	if returnCode, _ := runFsckCommand(NewMemoryStorage(), false); returnCode != 001 {
+		t.Errorf("Expected 001 for an uninitialized storage, got %d", returnCode)
+	}
+
+	s := memoryRepository(t)
+	for _, path := range []string{"a.txt", "b.txt", "c.txt"} {
+		writeMemoryCommit(t, s, InitBranch, path, path)
+	}
+	if returnCode, problems := runFsckCommand(s, false); returnCode != 1001 {
+		t.Errorf("Expected 1001, got %d: %v", returnCode, problems)
+	}
+
+	commits, _ := s.ReadBranchCommits(InitBranch)
+	commits[0].Next = "deadbeef"
+	s.WriteBranchCommits(InitBranch, commits)
+	s.WriteStagingLogs([]LogFileEntry{{Id: GenRandHex(20), Op: "ADD", Path: "d.txt"}})
+
+	returnCode, problems := runFsckCommand(s, false)
+	if returnCode != 1002 {
+		t.Errorf("Expected 1002, got %d", returnCode)
+	}
+	if !hasProblem(problems, SeverityError, "points to missing commit deadbeef") {
+		t.Errorf("Expected dangling Next to be reported, got %v", problems)
+	}
+	if !hasProblem(problems, SeverityWarning, "has no staged file") {
+		t.Errorf("Expected orphaned log entry to be reported, got %v", problems)
+	}
+
+	if returnCode, _ := runFsckCommand(s, true); returnCode != 1003 {
+		t.Errorf("Expected 1003, got %d", returnCode)
+	}
+	if returnCode, problems := runFsckCommand(s, false); returnCode != 1001 {
+		t.Errorf("Expected 1001 after repair, got %d: %v", returnCode, problems)
+	}
+	if logs, _ := s.ReadStagingLogs(); len(logs) != 0 {
+		t.Errorf("Expected orphaned log entry to be removed, got %v", logs)
+	}
+}
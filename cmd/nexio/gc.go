@@ -0,0 +1,136 @@
+package main
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	gcCmd.Flags().BoolVarP(&DryRun, "dry-run", "n", false, "Show what would be removed without removing anything")
+	gcCmd.Flags().StringVarP(&GracePeriod, "grace-period", "g", "", "Keep commits that became unreachable more recently than this (e.g. 14d, 2w, 36h)")
+
+	rootCmd.AddCommand(gcCmd)
+}
+
+var (
+	DryRun      bool
+	GracePeriod string
+)
+
+var gcCmd = &cobra.Command{
+	Use:     "gc",
+	Short:   "Remove unreachable commits and stale staging data",
+	Example: "nexio gc\nnexio gc --dry-run\nnexio gc --grace-period 0d",
+	Args:    cobra.NoArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting gc command: dry-run=%v, grace-period=%s", DryRun, GracePeriod)
+		runGcCommand(store, DryRun, GracePeriod)
+	},
+}
+
+func runGcCommand(s Storage, dryRun bool, gracePeriod string) (returnCode int, candidates []GcCandidate) {
+	if initialized := IsStorageInitialized(s); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := s.LockRepository(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	var grace time.Duration
+	if gracePeriod == "" {
+		// A missing config file leaves the default grace period.
+		config, _ := s.ReadConfig()
+		grace = GetGcGracePeriod(config)
+	} else if grace, err = ParseDuration(gracePeriod); err != nil {
+		Debug("Invalid grace period: %s", gracePeriod)
+		Fail(GC_RETURN_CODES[1105])
+		return 1105, nil
+	}
+	Debug("Using grace period: %v", grace)
+
+	commits, err := UnreachableCommits(s, grace)
+	if err != nil {
+		Debug("Failed to compute unreachable commits: %v", err)
+		Fail(GC_RETURN_CODES[1104])
+		Text("Run "+Code("nexio fsck")+" for details", "")
+		return 1104, nil
+	}
+	staging, err := StagingOrphans(s)
+	if err != nil {
+		Debug("Failed to compute staging orphans: %v", err)
+		MustSucceed(err, "operation failed")
+	}
+	chunks, err := OrphanChunks(s, commits)
+	if err != nil {
+		Debug("Failed to compute orphaned chunks: %v", err)
+		MustSucceed(err, "operation failed")
+	}
+	locks, err := StaleLocks(s)
+	if err != nil {
+		Debug("Failed to find stale locks: %v", err)
+		MustSucceed(err, "operation failed")
+	}
+
+	candidates = append(append(append(commits, chunks...), staging...), locks...)
+	BreakLine()
+	if len(candidates) == 0 {
+		Debug("%s", GC_RETURN_CODES[1101])
+		Info(GC_RETURN_CODES[1101])
+		BreakLine()
+		return 1101, candidates
+	}
+
+	var reclaimed int64
+	for _, candidate := range candidates {
+		reclaimed += candidate.Size
+		if dryRun {
+			continue
+		}
+		Debug("Removing %s: %s", candidate.Kind, candidate.Path)
+		if err := candidate.remove(); err != nil {
+			Debug("Failed to remove %s", candidate.Path)
+			MustSucceed(err, "operation failed")
+		}
+	}
+
+	summary := []string{
+		FormatFileCount(len(commits)) + " unreachable commits",
+		FormatFileCount(len(chunks)) + " orphaned chunks",
+		FormatFileCount(len(staging)) + " orphaned staging entries",
+		FormatFileCount(len(locks)) + " stale lock files",
+	}
+	if dryRun {
+		Info("Would remove:")
+		paths := []string{}
+		for _, candidate := range candidates {
+			paths = append(paths, candidate.Path)
+		}
+		Tree(paths, true)
+		BreakLine()
+		List("Summary:", summary, false)
+		BreakLine()
+		Text("Space that would be reclaimed: "+Code(FormatBytes(reclaimed)), "")
+		BreakLine()
+		return 1103, candidates
+	}
+
+	if err := PruneDroppedCommits(s); err != nil {
+		Debug("Failed to prune dropped commits")
+		MustSucceed(err, "operation failed")
+	}
+
+	Success(GC_RETURN_CODES[1102])
+	BreakLine()
+	List("Removed:", summary, false)
+	BreakLine()
+	Text("Space reclaimed: "+Code(FormatBytes(reclaimed)), "")
+	BreakLine()
+	return 1102, candidates
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultGcGracePeriod = "14d"
+
+// GcCandidate is an entry gc removes, Path names it relative to the repository.
+type GcCandidate struct {
+	Kind   string
+	Path   string
+	Size   int64
+	remove func() error
+}
+
+// DroppedCommit records when a commit was removed from a branch by `branch drop`, a forced push or a rebase.
+type DroppedCommit struct {
+	Id      string `json:"id"`
+	Branch  string `json:"branch"`
+	Dropped string `json:"dropped"`
+}
+
+// RecordDroppedCommits logs the commits of before that are no longer in after, now. A commit dropped
+// again keeps only the latest entry.
+func RecordDroppedCommits(s Storage, branch string, before []Commit, after []Commit) error {
+	kept := map[string]bool{}
+	for _, commit := range after {
+		kept[commit.Id] = true
+	}
+	dropped := map[string]bool{}
+	for _, commit := range before {
+		if !kept[commit.Id] {
+			dropped[commit.Id] = true
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+	Debug("Recording %d commits dropped from %s", len(dropped), branch)
+	return s.WithLock(DroppedCommitsLock, func() error {
+		entries, err := s.ReadDroppedCommits()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		now := GetTimestamp()
+		log := []DroppedCommit{}
+		for _, entry := range entries {
+			if !dropped[entry.Id] {
+				log = append(log, entry)
+			}
+		}
+		for _, commit := range before {
+			if dropped[commit.Id] {
+				log = append(log, DroppedCommit{Id: commit.Id, Branch: branch, Dropped: now})
+			}
+		}
+		return s.WriteDroppedCommits(log)
+	})
+}
+
+// droppedTimes returns when each logged commit was last dropped.
+func droppedTimes(s Storage) (map[string]time.Time, error) {
+	entries, err := s.ReadDroppedCommits()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	times := map[string]time.Time{}
+	for _, entry := range entries {
+		if dropped, err := time.Parse(time.RFC3339, entry.Dropped); err == nil {
+			times[entry.Id] = dropped
+		}
+	}
+	return times, nil
+}
+
+// PruneDroppedCommits removes the log entries of commits that no longer exist or are reachable,
+// a commit dropped again later gets a new entry.
+func PruneDroppedCommits(s Storage) error {
+	reachable, err := ReachableCommits(s)
+	if err != nil {
+		return err
+	}
+	return s.WithLock(DroppedCommitsLock, func() error {
+		entries, err := s.ReadDroppedCommits()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		log := []DroppedCommit{}
+		for _, entry := range entries {
+			if s.HasCommit(entry.Id) && !reachable[entry.Id] {
+				log = append(log, entry)
+			}
+		}
+		return s.WriteDroppedCommits(log)
+	})
+}
+
+// GetGcGracePeriod returns the grace period configured via `nexio config set gc-grace-period`.
+func GetGcGracePeriod(config Config) time.Duration {
+	gracePeriod := config.GcGracePeriod
+	if gracePeriod == "" {
+		gracePeriod = DefaultGcGracePeriod
+	}
+	duration, err := ParseDuration(gracePeriod)
+	if err != nil {
+		Debug("Invalid gc grace period in config: %s", gracePeriod)
+		duration, _ = ParseDuration(DefaultGcGracePeriod)
+	}
+	return duration
+}
+
+// ReachableCommits returns every commit referenced by a branch or a remote-tracking ref, either
+// directly or indirectly through the file list entries of those commits.
+func ReachableCommits(s Storage) (map[string]bool, error) {
+	Debug("Computing reachable commits")
+	branches, err := s.ListBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := map[string]bool{}
+	for _, branch := range branches {
+		commits, err := s.ReadBranchCommits(branch)
+		if err != nil {
+			Debug("Failed to read commits of branch: %s", branch)
+			return nil, err
+		}
+		for _, commit := range commits {
+			reachable[commit.Id] = true
+		}
+	}
+
+	// Fetched commits are kept until the remote-tracking ref pointing to them is pruned.
+	remotes, err := s.ListTrackedRemotes()
+	if err != nil {
+		return nil, err
+	}
+	for _, remote := range remotes {
+		refs, err := s.ListRemoteRefs(remote)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			commits, err := s.ReadRemoteRef(remote, ref)
+			if err != nil {
+				Debug("Failed to read remote-tracking ref: %s/%s", remote, ref)
+				return nil, err
+			}
+			for _, commit := range commits {
+				reachable[commit.Id] = true
+			}
+		}
+	}
+
+	// The commits of a branch being rebased are kept until the rebase is finished or aborted.
+	rebase, err := s.ReadRebaseState()
+	if err != nil && !os.IsNotExist(err) {
+		Debug("Failed to read rebase state")
+		return nil, err
+	}
+	for _, commit := range rebase.Original {
+		reachable[commit.Id] = true
+	}
+
+	for commitId := range reachable {
+		fileList, err := s.ReadFileList(commitId)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			Debug("Failed to read file list of commit: %s", commitId)
+			return nil, err
+		}
+		for _, entry := range fileList {
+			reachable[entry.CommitId] = true
+		}
+	}
+	Debug("Found %d reachable commits", len(reachable))
+	return reachable, nil
+}
+
+// UnreachableCommits lists the commits not reachable from any branch that were dropped from one
+// longer than the grace period ago. For commits never dropped from a branch, e.g. those of an
+// interrupted transfer, the grace period starts when the commit was last written.
+func UnreachableCommits(s Storage, gracePeriod time.Duration) ([]GcCandidate, error) {
+	reachable, err := ReachableCommits(s)
+	if err != nil {
+		return nil, err
+	}
+	commitIds, err := s.ListCommits()
+	if err != nil {
+		return nil, err
+	}
+	dropped, err := droppedTimes(s)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []GcCandidate{}
+	for _, commitId := range commitIds {
+		if reachable[commitId] {
+			continue
+		}
+		unreachableSince, logged := dropped[commitId]
+		if !logged {
+			if unreachableSince, err = s.CommitTime(commitId); err != nil {
+				return nil, err
+			}
+		}
+		if time.Since(unreachableSince) < gracePeriod {
+			Debug("Keeping unreachable commit within grace period: %s", commitId)
+			continue
+		}
+		path := "commits/" + commitId
+		candidates = append(candidates, GcCandidate{Kind: "commit", Path: path, Size: s.Usage(path), remove: func() error {
+			return s.RemoveCommit(commitId)
+		}})
+	}
+	return candidates, nil
+}
+
+// OrphanChunks lists the chunks of large files not listed by the chunk manifest of any file kept,
+// commits in removed are about to be deleted.
+func OrphanChunks(s Storage, removed []GcCandidate) ([]GcCandidate, error) {
+	deleted := map[string]bool{}
+	for _, candidate := range removed {
+		deleted[candidate.Path] = true
+	}
+	commitIds, err := s.ListCommits()
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, commitId := range commitIds {
+		if deleted["commits/"+commitId] {
+			continue
+		}
+		fileList, err := s.ReadFileList(commitId)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			Debug("Failed to read file list of commit: %s", commitId)
+			return nil, err
+		}
+		for _, entry := range fileList {
+			if deleted["commits/"+entry.CommitId] {
+				continue
+			}
+			_, fileName := ParsePath(entry.Path)
+			chunks, _, err := s.ObjectChunks(entry.CommitId, entry.Id, fileName)
+			if err != nil {
+				Debug("Failed to read chunk manifest of %s in commit %s", entry.Path, entry.CommitId)
+				return nil, err
+			}
+			for _, id := range chunks {
+				used[id] = true
+			}
+		}
+	}
+
+	ids, err := s.ListChunks()
+	if err != nil {
+		return nil, err
+	}
+	candidates := []GcCandidate{}
+	for _, id := range ids {
+		if used[id] {
+			continue
+		}
+		path := "chunks/" + id
+		candidates = append(candidates, GcCandidate{Kind: "chunk", Path: path, Size: s.Usage(path), remove: func() error {
+			return s.RemoveChunk(id)
+		}})
+	}
+	return candidates, nil
+}
+
+// StagingOrphans lists staged files that have no corresponding entry in the staging logs.
+func StagingOrphans(s Storage) ([]GcCandidate, error) {
+	logs, err := s.ReadStagingLogs()
+	if err != nil {
+		return nil, err
+	}
+	logged := map[string]bool{}
+	for _, entry := range logs {
+		logged[entry.Id] = true
+	}
+
+	ops := []string{}
+	for _, op := range StagingOps {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	candidates := []GcCandidate{}
+	for _, op := range ops {
+		ids, err := s.ListStagedFiles(op)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			// The versions of a conflict are staged as `<id>.<version>`.
+			if logId, _, _ := strings.Cut(id, "."); logged[logId] {
+				continue
+			}
+			path := "staging/" + op + "/" + id
+			candidates = append(candidates, GcCandidate{Kind: "staging", Path: path, Size: s.Usage(path), remove: func() error {
+				return s.RemoveStagedFile(op, id)
+			}})
+		}
+	}
+	return candidates, nil
+}
+
+// StaleLocks lists lock files left behind by processes that did not release them. Taken again
+// since they were found stale, they are kept.
+func StaleLocks(s Storage) ([]GcCandidate, error) {
+	locks, err := s.ListLocks()
+	if err != nil {
+		return nil, err
+	}
+	candidates := []GcCandidate{}
+	for _, lock := range locks {
+		if !lock.Stale || lock.Path == RepoLockName {
+			continue
+		}
+		candidates = append(candidates, GcCandidate{Kind: "lock", Path: lock.Path, Size: s.Usage(lock.Path), remove: func() error {
+			s.RemoveLock(lock.Path, false)
+			return nil
+		}})
+	}
+	return candidates, nil
+}
+
+func DirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_Gc_UnreachableCommits(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+	mainCommit := GetLastCommit().Id
+
+	runNewCommand("feature", "", "")
+	file := namespace + "feature.txt"
+	os.WriteFile(file, []byte("feature"), 0644)
+	runAddCommand(file, false)
+	_, featureCommit := runCommitCommand("feature commit")
+	runSwitchCommand("main")
+	runDropCommand("feature")
+
+	returnCode, _ := runGcCommand(store, false, "")
+	if returnCode != 1101 {
+		t.Errorf("Expected 1101 within the grace period, got %d", returnCode)
+	}
+
+	returnCode, candidates := runGcCommand(store, true, "0d")
+	if returnCode != 1103 {
+		t.Errorf("Expected 1103, got %d", returnCode)
+	}
+	if len(candidates) != 1 || candidates[0].Path != "commits/"+featureCommit {
+		t.Errorf("Expected only %s to be collected, got %v", featureCommit, candidates)
+	}
+	if !FileExists(dirs.Commits + featureCommit) {
+		t.Errorf("Dry run should not remove commit %s", featureCommit)
+	}
+
+	returnCode, _ = runGcCommand(store, false, "0d")
+	if returnCode != 1102 {
+		t.Errorf("Expected 1102, got %d", returnCode)
+	}
+	if FileExists(dirs.Commits + featureCommit) {
+		t.Errorf("Expected commit %s to be removed", featureCommit)
+	}
+	if !FileExists(dirs.Commits + mainCommit) {
+		t.Errorf("Expected reachable commit %s to be kept", mainCommit)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Gc_GracePeriodStartsWhenDropped(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	runNewCommand("feature", "", "")
+	file := namespace + "feature.txt"
+	os.WriteFile(file, []byte("feature"), 0644)
+	runAddCommand(file, false)
+	_, featureCommit := runCommitCommand("feature commit")
+	runSwitchCommand("main")
+
+	// Committed long ago, the commit only becomes unreachable now.
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	os.Chtimes(dirs.Commits+featureCommit, old, old)
+	runDropCommand("feature")
+
+	dropped, err := store.ReadDroppedCommits()
+	index := slices.IndexFunc(dropped, func(entry DroppedCommit) bool { return entry.Id == featureCommit })
+	if err != nil || index < 0 || dropped[index].Branch != "feature" {
+		t.Fatalf("Expected the dropped commit to be logged, got %v, %v", dropped, err)
+	}
+	if returnCode, _ := runGcCommand(store, true, "14d"); returnCode != 1101 {
+		t.Errorf("Expected 1101 for a commit dropped within the grace period, got %d", returnCode)
+	}
+
+	dropped[index].Dropped = old.Format(time.RFC3339)
+	store.WriteDroppedCommits(dropped)
+	if returnCode, _ := runGcCommand(store, false, "14d"); returnCode != 1102 {
+		t.Errorf("Expected 1102 for a commit dropped before the grace period, got %d", returnCode)
+	}
+	if dropped, _ := store.ReadDroppedCommits(); len(dropped) != 0 {
+		t.Errorf("Expected the log entry of the removed commit to be pruned, got %v", dropped)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Gc_OrphanChunks(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	setConfig("large-file-threshold", "0")
+	commitTestFile(t, "kept.txt", "kept", "kept")
+
+	runNewCommand("feature", "", "")
+	commitTestFile(t, "feature.txt", "feature", "feature")
+	runSwitchCommand("main")
+	runDropCommand("feature")
+
+	returnCode, candidates := runGcCommand(store, true, "0d")
+	if returnCode != 1103 {
+		t.Errorf("Expected 1103, got %d", returnCode)
+	}
+	chunks := []string{}
+	for _, candidate := range candidates {
+		if candidate.Kind == "chunk" {
+			chunks = append(chunks, candidate.Path)
+		}
+	}
+	if len(chunks) != 1 || chunks[0] != "chunks/"+ChunkId([]byte("feature")) {
+		t.Errorf("Expected only the chunk of the dropped commit to be collected, got %v", chunks)
+	}
+
+	runGcCommand(store, false, "0d")
+	if content, err := os.ReadFile(dirs.Chunks + ChunkId([]byte("kept"))); err != nil || string(content) != "kept" {
+		t.Errorf("Expected the chunk of the kept commit to remain, got %q %v", content, err)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Gc_StagingOrphansAndLocks(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	file := namespace + "staged.txt"
+	os.WriteFile(file, []byte("staged"), 0644)
+	runAddCommand(file, false)
+	_, stagedId, _ := LogEntryLookup("ADD", file)
+
+	orphan := dirs.StagingAdded + "orphan"
+	os.MkdirAll(orphan, 0755)
+	os.WriteFile(orphan+"/file.txt", []byte("orphan"), 0644)
+
+	staleLock := dirs.StagingLogs + ".lock"
+	os.WriteFile(staleLock, []byte("12345\n"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(staleLock, old, old)
+
+	returnCode, candidates := runGcCommand(store, false, "")
+	if returnCode != 1102 {
+		t.Errorf("Expected 1102, got %d", returnCode)
+	}
+	if len(candidates) != 2 {
+		t.Errorf("Expected 2 candidates, got %v", candidates)
+	}
+	if FileExists(orphan) {
+		t.Errorf("Expected orphaned staging entry to be removed")
+	}
+	if FileExists(staleLock) {
+		t.Errorf("Expected stale lock to be removed")
+	}
+	if !FileExists(dirs.StagingAdded + stagedId) {
+		t.Errorf("Expected staged file to be kept")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Gc_MemoryStorage(t *testing.T) {
+	t.Parallel()
+	if returnCode, _ := runGcCommand(NewMemoryStorage(), false, ""); returnCode != 001 {
+		t.Errorf("Expected 001 for an uninitialized storage, got %d", returnCode)
+	}
+
+	s := memoryRepository(t)
+	mainCommit := writeMemoryCommit(t, s, InitBranch, "main.txt", "main")
+	commits, _ := s.ReadBranchCommits(InitBranch)
+	s.CreateBranch("feature", commits)
+	featureCommit := writeMemoryCommit(t, s, "feature", "feature.txt", "feature")
+	s.WriteStagedFile(StagingOps["ADD"], "orphan", "orphan.txt", []byte("orphan"), 0644)
+
+	feature, _ := s.ReadBranchCommits("feature")
+	s.RemoveBranch("feature")
+	RecordDroppedCommits(s, "feature", feature, nil)
+	if returnCode, candidates := runGcCommand(s, true, "14d"); returnCode != 1103 || len(candidates) != 1 || candidates[0].Kind != "staging" {
+		t.Errorf("Expected only the orphaned staging entry within the grace period, got %d: %v", returnCode, candidates)
+	}
+
+	returnCode, candidates := runGcCommand(s, false, "0d")
+	if returnCode != 1102 {
+		t.Errorf("Expected 1102, got %d", returnCode)
+	}
+	if len(candidates) != 2 || candidates[0].Path != "commits/"+featureCommit || candidates[0].Size != int64(len("feature")) {
+		t.Errorf("Expected the dropped commit and the staging entry to be collected, got %v", candidates)
+	}
+	if s.HasCommit(featureCommit) || !s.HasCommit(mainCommit) {
+		t.Errorf("Expected only the dropped commit to be removed")
+	}
+	if s.HasStagedFile(StagingOps["ADD"], "orphan") {
+		t.Errorf("Expected orphaned staging entry to be removed")
+	}
+	if dropped, _ := s.ReadDroppedCommits(); len(dropped) != 0 {
+		t.Errorf("Expected the log of dropped commits to be pruned, got %v", dropped)
+	}
+}
+
+func Test_ParseDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"14d", 14 * 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"36h", 36 * time.Hour, true},
+		{"0d", 0, true},
+		{"xd", 0, false},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		duration, err := ParseDuration(test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("Input: '%s' - expected valid=%v, got error %v", test.value, test.valid, err)
+		}
+		if test.valid && duration != test.expected {
+			t.Errorf("Input: '%s' - expected %v, got %v", test.value, test.expected, duration)
+		}
+	}
+}
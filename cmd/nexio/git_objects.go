@@ -0,0 +1,517 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reading of Git repositories for `nexio import-git`: refs, loose objects and packfiles (index version 2).
+// Only what an import needs is supported, the repository is never written.
+
+const (
+	gitObjectCommit   = 1
+	gitObjectTree     = 2
+	gitObjectBlob     = 3
+	gitObjectTag      = 4
+	gitObjectOfsDelta = 6
+	gitObjectRefDelta = 7
+)
+
+var gitObjectTypes = map[string]int{"commit": gitObjectCommit, "tree": gitObjectTree, "blob": gitObjectBlob, "tag": gitObjectTag}
+
+var errGitObjectNotFound = errors.New("git object not found")
+
+type GitRepository struct {
+	// dir is the `.git` directory, or the repository itself if it is bare.
+	dir   string
+	packs []*gitPack
+}
+
+type GitCommit struct {
+	Id        string
+	Tree      string
+	Parents   []string
+	Author    Author
+	Timestamp time.Time
+	Message   string
+}
+
+type GitTreeEntry struct {
+	Mode string
+	Name string
+	Id   string
+}
+
+// OpenGitRepository opens a working tree containing `.git`, or a `.git` directory or bare repository.
+func OpenGitRepository(path string) (*GitRepository, error) {
+	dir := path
+	if info, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		dir = filepath.Join(path, ".git")
+		if !info.IsDir() {
+			// A `.git` file points to the real directory, e.g. in worktrees and submodules.
+			content, err := os.ReadFile(dir)
+			if err != nil {
+				return nil, err
+			}
+			gitDir, found := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+			if !found {
+				return nil, fmt.Errorf("invalid .git file in %s", path)
+			}
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(path, gitDir)
+			}
+			dir = gitDir
+		}
+	}
+	if !FileExists(filepath.Join(dir, "HEAD")) || !FileExists(filepath.Join(dir, "objects")) {
+		return nil, fmt.Errorf("%s is not a Git repository", path)
+	}
+
+	repository := &GitRepository{dir: dir}
+	indexes, err := filepath.Glob(filepath.Join(dir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		pack, err := openGitPack(strings.TrimSuffix(index, ".idx"))
+		if err != nil {
+			repository.Close()
+			return nil, err
+		}
+		repository.packs = append(repository.packs, pack)
+	}
+	return repository, nil
+}
+
+func (r *GitRepository) Close() {
+	for _, pack := range r.packs {
+		pack.file.Close()
+	}
+}
+
+// HeadBranch returns the branch HEAD points to, empty if HEAD is detached.
+func (r *GitRepository) HeadBranch() string {
+	content, err := os.ReadFile(filepath.Join(r.dir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, found := strings.CutPrefix(strings.TrimSpace(string(content)), "ref: ")
+	if !found {
+		return ""
+	}
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
+
+// Branches lists the local branches, loose and packed.
+func (r *GitRepository) Branches() ([]string, error) {
+	branches := map[string]bool{}
+	heads := filepath.Join(r.dir, "refs", "heads")
+	err := filepath.WalkDir(heads, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			relative, _ := filepath.Rel(heads, path)
+			branches[filepath.ToSlash(relative)] = true
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	packed, err := r.packedRefs()
+	if err != nil {
+		return nil, err
+	}
+	for ref := range packed {
+		if branch, found := strings.CutPrefix(ref, "refs/heads/"); found {
+			branches[branch] = true
+		}
+	}
+	names := []string{}
+	for branch := range branches {
+		names = append(names, branch)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ResolveBranch returns the commit a branch points to.
+func (r *GitRepository) ResolveBranch(branch string) (string, error) {
+	ref := "refs/heads/" + branch
+	if content, err := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(content)), nil
+	}
+	packed, err := r.packedRefs()
+	if err != nil {
+		return "", err
+	}
+	if id, exists := packed[ref]; exists {
+		return id, nil
+	}
+	return "", fmt.Errorf("branch %s does not exist", branch)
+}
+
+func (r *GitRepository) packedRefs() (map[string]string, error) {
+	refs := map[string]string{}
+	content, err := os.ReadFile(filepath.Join(r.dir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		// Comments start with `#`, peeled tags with `^`.
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		if id, ref, found := strings.Cut(line, " "); found {
+			refs[ref] = id
+		}
+	}
+	return refs, nil
+}
+
+// ReadObject returns the type and content of an object.
+func (r *GitRepository) ReadObject(id string) (int, []byte, error) {
+	objectType, data, err := r.readLooseObject(id)
+	if err == nil || !os.IsNotExist(err) {
+		return objectType, data, err
+	}
+	for _, pack := range r.packs {
+		if offset, found := pack.find(id); found {
+			return pack.readObject(r, offset)
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: %s", errGitObjectNotFound, id)
+}
+
+func (r *GitRepository) readLooseObject(id string) (int, []byte, error) {
+	if len(id) != 40 {
+		return 0, nil, fmt.Errorf("invalid object id %s", id)
+	}
+	file, err := os.Open(filepath.Join(r.dir, "objects", id[:2], id[2:]))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+	reader, err := zlib.NewReader(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	// Loose objects start with `<type> <size>\0`.
+	header, data, found := bytes.Cut(content, []byte{0})
+	if !found {
+		return 0, nil, fmt.Errorf("invalid object %s", id)
+	}
+	typeName, _, _ := strings.Cut(string(header), " ")
+	objectType, known := gitObjectTypes[typeName]
+	if !known {
+		return 0, nil, fmt.Errorf("unknown type %q of object %s", typeName, id)
+	}
+	return objectType, data, nil
+}
+
+func (r *GitRepository) readTyped(id string, expected int) ([]byte, error) {
+	objectType, data, err := r.ReadObject(id)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != expected {
+		return nil, fmt.Errorf("object %s has type %d, expected %d", id, objectType, expected)
+	}
+	return data, nil
+}
+
+func (r *GitRepository) ReadCommit(id string) (GitCommit, error) {
+	data, err := r.readTyped(id, gitObjectCommit)
+	if err != nil {
+		return GitCommit{}, err
+	}
+	commit := GitCommit{Id: id}
+	headers, message, _ := strings.Cut(string(data), "\n\n")
+	commit.Message = strings.TrimRight(message, "\n")
+	for _, line := range strings.Split(headers, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			commit.Author, commit.Timestamp = parseGitSignature(value)
+		}
+	}
+	return commit, nil
+}
+
+// parseGitSignature parses `Name <email> <unix-seconds> <+hhmm>`.
+func parseGitSignature(signature string) (Author, time.Time) {
+	author := Author{}
+	start, end := strings.Index(signature, "<"), strings.Index(signature, ">")
+	if start == -1 || end < start {
+		return Author{Name: signature}, time.Unix(0, 0)
+	}
+	author.Name = strings.TrimSpace(signature[:start])
+	author.Email = signature[start+1 : end]
+
+	fields := strings.Fields(signature[end+1:])
+	if len(fields) != 2 {
+		return author, time.Unix(0, 0)
+	}
+	seconds, _ := strconv.ParseInt(fields[0], 10, 64)
+	timestamp := time.Unix(seconds, 0)
+	if zone, err := strconv.Atoi(fields[1]); err == nil {
+		offset := (zone/100*60 + zone%100) * 60
+		timestamp = timestamp.In(time.FixedZone("", offset))
+	}
+	return author, timestamp
+}
+
+func (r *GitRepository) ReadTree(id string) ([]GitTreeEntry, error) {
+	data, err := r.readTyped(id, gitObjectTree)
+	if err != nil {
+		return nil, err
+	}
+	// Entries are `<mode> <name>\0<20-byte id>`.
+	entries := []GitTreeEntry{}
+	for len(data) > 0 {
+		header, rest, found := bytes.Cut(data, []byte{0})
+		if !found || len(rest) < 20 {
+			return nil, fmt.Errorf("invalid tree %s", id)
+		}
+		mode, name, _ := strings.Cut(string(header), " ")
+		entries = append(entries, GitTreeEntry{Mode: mode, Name: name, Id: hex.EncodeToString(rest[:20])})
+		data = rest[20:]
+	}
+	return entries, nil
+}
+
+func (r *GitRepository) ReadBlob(id string) ([]byte, error) {
+	return r.readTyped(id, gitObjectBlob)
+}
+
+// FirstParentHistory returns the commits reachable from id through first parents, oldest first.
+func (r *GitRepository) FirstParentHistory(id string) ([]GitCommit, error) {
+	history := []GitCommit{}
+	for id != "" {
+		commit, err := r.ReadCommit(id)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, commit)
+		id = ""
+		if len(commit.Parents) > 0 {
+			id = commit.Parents[0]
+		}
+	}
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history, nil
+}
+
+type gitPack struct {
+	file    *os.File
+	ids     [][20]byte
+	offsets []int64
+}
+
+// openGitPack reads the index of a pack: a fan-out table, the sorted object ids, their CRCs and their offsets.
+func openGitPack(base string) (*gitPack, error) {
+	index, err := os.ReadFile(base + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	if len(index) < 8+256*4 || !bytes.Equal(index[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(index[4:8]) != 2 {
+		return nil, fmt.Errorf("unsupported pack index %s.idx", base)
+	}
+	count := int(binary.BigEndian.Uint32(index[8+255*4:]))
+	idsStart := 8 + 256*4
+	offsetsStart := idsStart + count*20 + count*4
+	largeStart := offsetsStart + count*4
+	if len(index) < largeStart {
+		return nil, fmt.Errorf("truncated pack index %s.idx", base)
+	}
+
+	pack := &gitPack{ids: make([][20]byte, count), offsets: make([]int64, count)}
+	for i := range count {
+		copy(pack.ids[i][:], index[idsStart+i*20:])
+		offset := binary.BigEndian.Uint32(index[offsetsStart+i*4:])
+		if offset&0x80000000 != 0 {
+			// Offsets beyond 2 GiB are stored in a separate table of 8-byte entries.
+			large := largeStart + int(offset&0x7fffffff)*8
+			if len(index) < large+8 {
+				return nil, fmt.Errorf("truncated pack index %s.idx", base)
+			}
+			pack.offsets[i] = int64(binary.BigEndian.Uint64(index[large:]))
+		} else {
+			pack.offsets[i] = int64(offset)
+		}
+	}
+
+	pack.file, err = os.Open(base + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+func (p *gitPack) find(id string) (int64, bool) {
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) != 20 {
+		return 0, false
+	}
+	index := sort.Search(len(p.ids), func(i int) bool { return bytes.Compare(p.ids[i][:], raw) >= 0 })
+	if index < len(p.ids) && bytes.Equal(p.ids[index][:], raw) {
+		return p.offsets[index], true
+	}
+	return 0, false
+}
+
+// readObject reads the object at offset, resolving deltas against their base objects.
+func (p *gitPack) readObject(r *GitRepository, offset int64) (int, []byte, error) {
+	reader := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+
+	// The header holds the type in bits 4-6 of the first byte and the size in the remaining bits,
+	// continued in 7-bit groups while the high bit is set.
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	objectType := int(b>>4) & 7
+	size := int64(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(b&0x7f) << shift
+	}
+
+	var baseType int
+	var base []byte
+	switch objectType {
+	case gitObjectOfsDelta:
+		// The base is at a negative offset encoded big-endian in 7-bit groups, adding one per continuation.
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		distance := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = reader.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			distance = (distance+1)<<7 | int64(b&0x7f)
+		}
+		if baseType, base, err = p.readObject(r, offset-distance); err != nil {
+			return 0, nil, err
+		}
+	case gitObjectRefDelta:
+		var baseId [20]byte
+		if _, err := io.ReadFull(reader, baseId[:]); err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = r.ReadObject(hex.EncodeToString(baseId[:])); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	inflater, err := zlib.NewReader(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer inflater.Close()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(inflater, data); err != nil {
+		return 0, nil, err
+	}
+	if base == nil {
+		return objectType, data, nil
+	}
+	result, err := applyGitDelta(base, data)
+	return baseType, result, err
+}
+
+// applyGitDelta rebuilds an object from its base and a delta: the base and result sizes followed by
+// instructions copying a range of the base or inserting literal bytes.
+func applyGitDelta(base []byte, delta []byte) ([]byte, error) {
+	readSize := func() int {
+		size, shift := 0, 0
+		for len(delta) > 0 {
+			b := delta[0]
+			delta = delta[1:]
+			size |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		return size
+	}
+	if readSize() != len(base) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	result := make([]byte, 0, readSize())
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// Bits 0-3 select the offset bytes, bits 4-6 the size bytes, a size of zero means 64 KiB.
+			var offset, size int
+			for i := range 4 {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta")
+					}
+					offset |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := range 3 {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta")
+					}
+					size |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, errors.New("delta copies beyond its base")
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errors.New("truncated delta")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errors.New("invalid delta instruction")
+		}
+	}
+	return result, nil
+}
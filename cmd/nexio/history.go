@@ -1,9 +1,7 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -39,6 +37,14 @@ func runHistoryCommand() (returnCode int, history []History) {
 		return
 	}
 
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		color.Red(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
 	commits := GetCommits()
 	if len(*commits) == 0 {
 		Debug("No commits found.")
@@ -57,42 +63,43 @@ func runHistoryCommand() (returnCode int, history []History) {
 	BreakLine()
 	for i, commit := range *commits {
 		Debug("Processing commit: %s", commit.Id)
-		data, err := os.ReadFile(dirs.Commits + commit.Id + "/metadata.json")
+		metadata, err := store.ReadCommitMetadata(commit.Id)
 		if err != nil {
 			Debug("Failed to read commit metadata")
 			MustSucceed(err, "operation failed")
 		}
-		var metadata CommitMetadata
-		if err = json.Unmarshal(data, &metadata); err != nil {
-			Debug("Failed to unmarshal commit metadata")
-			MustSucceed(err, "operation failed")
-		}
 
 		author := metadata.Author.Name + " <" + metadata.Author.Email + ">"
 		if metadata.Author.Name == "" || metadata.Author.Email == "" {
 			author = "Unknown"
 		}
 
-		data, err = os.ReadFile(dirs.Commits + commit.Id + "/logs.json")
+		logs, err := store.ReadCommitLogs(commit.Id)
 		if err != nil {
 			Debug("Failed to read commit logs")
 			MustSucceed(err, "operation failed")
 		}
-		var logs []LogFileEntry
-		if err = json.Unmarshal(data, &logs); err != nil {
-			Debug("Failed to unmarshal commit logs")
+		Debug("Displaying %d log entries for commit", len(logs))
+
+		parentId := ""
+		if i > 0 {
+			parentId = (*commits)[i-1].Id
+		}
+		renames, err := DetectCommitRenames(store, parentId, commit.Id)
+		if err != nil {
+			Debug("Failed to detect renames")
 			MustSucceed(err, "operation failed")
 		}
-		Debug("Displaying %d log entries for commit", len(logs))
+		folded := FoldRenames(logs, renames)
 
-		logsFormatted := FormatLogs(logs)
+		logsFormatted := FormatLogs(folded)
 		boxContent := fmt.Sprintf("Author:  %s\nDate:    %s\nMessage: %s",
 			author,
 			TimeAgo(commit.Timestamp),
 			metadata.Message,
 		)
 
-		add, mod, rem := CountOps(logs)
+		add, mod, rem := CountOps(folded)
 
 		if logsFormatted != "" {
 			boxContent += "\nFiles: " + Code(fmt.Sprintf("+%d -%d ~%d", add, rem, mod)) + "\n" + logsFormatted
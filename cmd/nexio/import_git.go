@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	importGitCmd.Flags().StringVarP(&ImportGitBranch, "branch", "b", "", "Git branch to import (default the branch HEAD points to)")
+	importGitCmd.Flags().StringVar(&ImportGitInto, "into", "", "Branch to import into, created if missing (default the name of the Git branch)")
+
+	rootCmd.AddCommand(importGitCmd)
+}
+
+var (
+	ImportGitBranch string
+	ImportGitInto   string
+)
+
+var importGitCmd = &cobra.Command{
+	Use:     "import-git",
+	Short:   "Import the history of a Git branch",
+	Example: "nexio import-git ../project\nnexio import-git ../project.git --branch develop --into imported",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting import-git command: path=%s, branch=%s, into=%s", args[0], ImportGitBranch, ImportGitInto)
+		runImportGitCommand(args[0], ImportGitBranch, ImportGitInto)
+	},
+}
+
+func runImportGitCommand(path string, branch string, into string) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006
+	}
+
+	repository, err := OpenGitRepository(path)
+	if err != nil {
+		Debug("Failed to open Git repository: %v", err)
+		Fail(IMPORT_GIT_RETURN_CODES[2102] + " " + path)
+		return 2102
+	}
+	defer repository.Close()
+
+	if branch == "" {
+		branch = repository.HeadBranch()
+	}
+	head, err := repository.ResolveBranch(branch)
+	if err != nil {
+		Debug("Failed to resolve Git branch: %v", err)
+		Fail(IMPORT_GIT_RETURN_CODES[2103] + " " + Code(branch))
+		if branches, err := repository.Branches(); err == nil && len(branches) > 0 {
+			Text("Available: "+strings.Join(branches, ", "), "")
+		}
+		return 2103
+	}
+	if into == "" {
+		into = branch
+	}
+	if !IsValidBranchName(into) {
+		Debug("Invalid branch name: %s", into)
+		Fail(IMPORT_GIT_RETURN_CODES[2106] + " " + into)
+		return 2106
+	}
+	exists := slices.Contains(ListBranches(), into)
+	if exists {
+		if commits, err := store.ReadBranchCommits(into); err != nil || len(commits) > 0 {
+			Debug("Target branch is not empty: %s", into)
+			Fail(IMPORT_GIT_RETURN_CODES[2104] + " " + StyledBranch(into))
+			Text("Import into a new branch with "+Code("--into <branch>"), "")
+			return 2104
+		}
+	}
+
+	history, err := repository.FirstParentHistory(head)
+	if err != nil {
+		Debug("Failed to read Git history: %v", err)
+		Fail(IMPORT_GIT_RETURN_CODES[2105] + " " + err.Error())
+		return 2105
+	}
+	result, err := ImportGitHistory(store, repository, history)
+	if err != nil {
+		Debug("Failed to import Git history: %v", err)
+		for _, commit := range result.Commits {
+			store.RemoveCommit(commit.Id)
+		}
+		Fail(IMPORT_GIT_RETURN_CODES[2105] + " " + err.Error())
+		return 2105
+	}
+
+	if exists {
+		err = store.WriteBranchCommits(into, result.Commits)
+	} else {
+		err = store.CreateBranch(into, result.Commits)
+	}
+	if err != nil {
+		Debug("Failed to write branch commits")
+		MustSucceed(err, "operation failed")
+	}
+	if into == GetCurrentBranchName() {
+		CheckoutFiles("", HeadOf(result.Commits))
+	}
+
+	BreakLine()
+	Success(IMPORT_GIT_RETURN_CODES[2101])
+	Text("Branch: "+StyledBranch(branch)+" -> "+StyledBranch(into), "  ")
+	Text(fmt.Sprintf("Commits: %d", len(result.Commits)), "  ")
+	if result.Skipped > 0 {
+		Text(fmt.Sprintf("Skipped: %d commits without changes", result.Skipped), "  ")
+	}
+	if len(result.Unsupported) > 0 {
+		Warning("Submodules are not supported, left out " + strings.Join(result.Unsupported, ", "))
+	}
+	BreakLine()
+	return 2101
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+type GitImportResult struct {
+	Commits []Commit
+	// Skipped counts the Git commits left out because they changed no imported file, e.g. merges
+	// of already imported changes or commits touching only ignored files.
+	Skipped int
+	// Unsupported lists the paths left out because they are submodules.
+	Unsupported []string
+}
+
+type gitFile struct {
+	Id   string
+	Mode os.FileMode
+}
+
+// ImportGitHistory replays the Git commits, oldest first, as Nexio commits and returns them linked in order.
+// The files are stored under their path in the Git tree, filtered by the rules file.
+func ImportGitHistory(s Storage, repository *GitRepository, history []GitCommit) (GitImportResult, error) {
+	result := GitImportResult{}
+	ignored := map[string]bool{}
+	shouldIgnore := func(path string) bool {
+		if _, checked := ignored[path]; !checked {
+			ignored[path] = ShouldIgnore(path)
+		}
+		return ignored[path]
+	}
+
+	unsupported := map[string]bool{}
+	previous := map[string]gitFile{}
+	fileList := []FileListEntry{}
+	for _, gitCommit := range history {
+		files := map[string]gitFile{}
+		if err := flattenGitTree(repository, gitCommit.Tree, "", files, unsupported); err != nil {
+			return result, err
+		}
+		for path := range files {
+			if shouldIgnore(namespace + path) {
+				delete(files, path)
+			}
+		}
+
+		logs := []LogFileEntry{}
+		for _, path := range sortedKeys(files) {
+			old, exists := previous[path]
+			switch {
+			case !exists:
+				logs = append(logs, LogFileEntry{Id: GenRandHex(20), Op: "ADD", Path: namespace + path})
+			case old != files[path]:
+				logs = append(logs, LogFileEntry{Id: GenRandHex(20), Op: "MOD", Path: namespace + path})
+			}
+		}
+		for _, path := range sortedKeys(previous) {
+			if _, exists := files[path]; !exists {
+				logs = append(logs, LogFileEntry{Id: GenRandHex(20), Op: "REM", Path: namespace + path})
+			}
+		}
+		previous = files
+		if len(logs) == 0 {
+			Debug("Skipping Git commit without changes: %s", gitCommit.Id)
+			result.Skipped++
+			continue
+		}
+
+		commitId := GenRandHex(20)
+		for _, log := range logs {
+			index := slices.IndexFunc(fileList, func(entry FileListEntry) bool { return entry.Path == log.Path })
+			file := files[log.Path[len(namespace):]]
+			switch log.Op {
+			case "ADD":
+				fileList = append(fileList, newFileListEntry(log.Id, commitId, log.Path, file.Mode))
+			case "MOD":
+				fileList[index] = newFileListEntry(log.Id, commitId, log.Path, file.Mode)
+			case "REM":
+				fileList = slices.Delete(fileList, index, index+1)
+				continue
+			}
+			data, err := repository.ReadBlob(file.Id)
+			if err != nil {
+				return result, err
+			}
+			_, fileName := ParsePath(log.Path)
+			if err := s.WriteObject(commitId, log.Id, fileName, data, file.Mode); err != nil {
+				return result, err
+			}
+		}
+
+		if err := s.WriteFileList(commitId, fileList); err != nil {
+			return result, err
+		}
+		if err := s.WriteCommitLogs(commitId, logs); err != nil {
+			return result, err
+		}
+		metadata := CommitMetadata{Author: gitCommit.Author, Message: gitCommit.Message}
+		if err := s.WriteCommitMetadata(commitId, metadata); err != nil {
+			return result, err
+		}
+		if len(result.Commits) > 0 {
+			result.Commits[len(result.Commits)-1].Next = commitId
+		}
+		result.Commits = append(result.Commits, Commit{Id: commitId, Timestamp: gitCommit.Timestamp.Format(time.RFC3339)})
+		Debug("Imported Git commit %s as %s", gitCommit.Id, commitId)
+	}
+	for path := range unsupported {
+		result.Unsupported = append(result.Unsupported, path)
+	}
+	sort.Strings(result.Unsupported)
+	return result, nil
+}
+
+// flattenGitTree collects the files and symlinks of a tree by their slash-separated path, and the paths
+// of the entries it has to leave out.
+func flattenGitTree(repository *GitRepository, treeId string, prefix string, files map[string]gitFile, unsupported map[string]bool) error {
+	entries, err := repository.ReadTree(treeId)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		switch entry.Mode {
+		case "40000":
+			if err := flattenGitTree(repository, entry.Id, prefix+entry.Name+"/", files, unsupported); err != nil {
+				return err
+			}
+		case "100755":
+			files[prefix+entry.Name] = gitFile{Id: entry.Id, Mode: 0755}
+		case "100644", "100664":
+			files[prefix+entry.Name] = gitFile{Id: entry.Id, Mode: 0644}
+		case "120000":
+			// The blob of a symlink is its target.
+			files[prefix+entry.Name] = gitFile{Id: entry.Id, Mode: os.ModeSymlink | 0777}
+		default:
+			// 160000 is a submodule.
+			Debug("Skipping unsupported Git tree entry: %s%s (%s)", prefix, entry.Name, entry.Mode)
+			unsupported[prefix+entry.Name] = true
+		}
+	}
+	return nil
+}
+
+func sortedKeys(files map[string]gitFile) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_ImportGit_History(t *testing.T) {
+	for _, fixture := range []string{"testdata/git/history.git", "testdata/git/packed.git"} {
+		os.RemoveAll(namespace)
+		runInitCommand()
+
+		if returnCode := runImportGitCommand(fixture, "", ""); returnCode != 2101 {
+			t.Fatalf("Expected 2101 for %s, got %d", fixture, returnCode)
+		}
+		commits := mustBranchCommits(t, store)
+		if len(commits) != 4 {
+			t.Fatalf("Expected the 4 first-parent commits of main from %s, got %d", fixture, len(commits))
+		}
+		if commits[0].Timestamp != "2024-01-01T10:00:00+01:00" {
+			t.Errorf("Expected the Git author date, got %s", commits[0].Timestamp)
+		}
+		messages := []string{}
+		for _, commit := range commits {
+			metadata, _ := store.ReadCommitMetadata(commit.Id)
+			messages = append(messages, metadata.Message)
+			if metadata.Author != (Author{Name: "Ada Lovelace", Email: "ada@example.com"}) {
+				t.Errorf("Expected the Git author, got %v", metadata.Author)
+			}
+		}
+		expected := []string{"Initial commit", "Extend guide\n\nAlso keep the build log.", "Remove script", "Merge feature"}
+		if !slices.Equal(messages, expected) {
+			t.Errorf("Expected messages %q, got %q", expected, messages)
+		}
+
+		first := *GetFileListContent(commits[0].Id)
+		script := slices.IndexFunc(first, func(entry FileListEntry) bool { return entry.Path == namespace+"run.sh" })
+		if script == -1 {
+			t.Fatalf("Expected run.sh in the first commit, got %v", first)
+		}
+		if _, mode, err := store.ReadObject(commits[0].Id, first[script].Id, "run.sh"); err != nil || mode != 0755 {
+			t.Errorf("Expected run.sh to be executable, got %v (%v)", mode, err)
+		}
+
+		// The files of main are checked out, the symlink as a symlink.
+		if content, _ := os.ReadFile(namespace + "README.md"); string(content) != "hello world\n" {
+			t.Errorf("Expected README.md of the last commit, got %q", content)
+		}
+		if guide, _ := os.ReadFile(namespace + "docs/guide.md"); strings.Count(string(guide), "\n") != 81 {
+			t.Errorf("Expected the extended guide, got %d lines", strings.Count(string(guide), "\n"))
+		}
+		if FileExists(namespace + "run.sh") {
+			t.Errorf("Expected the removed script to be gone")
+		}
+		if target, err := os.Readlink(namespace + "link.md"); err != nil || target != "README.md" {
+			t.Errorf("Expected link.md to link to README.md, got %q (%v)", target, err)
+		}
+		if !FileExists(namespace + "feature.txt") {
+			t.Errorf("Expected feature.txt from the merge commit")
+		}
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_ImportGit_BranchAndRules(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	if err := os.WriteFile(".nexio.rules.yml", []byte("ignore:\n  - \"*.log\"\n  - \"docs\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test rules file: %v", err)
+	}
+	defer os.Remove(".nexio.rules.yml")
+
+	if returnCode := runImportGitCommand("testdata/git/packed.git", "feature", "imported"); returnCode != 2101 {
+		t.Fatalf("Expected 2101, got %d", returnCode)
+	}
+	commits, _ := store.ReadBranchCommits("imported")
+	if len(commits) != 2 {
+		t.Fatalf("Expected the commit touching only ignored files to be skipped, got %d commits", len(commits))
+	}
+	for _, commit := range commits {
+		for _, entry := range *GetFileListContent(commit.Id) {
+			if strings.HasSuffix(entry.Path, ".log") || strings.Contains(entry.Path, "docs/") {
+				t.Errorf("Expected ignored files not to be imported, got %s", entry.Path)
+			}
+		}
+	}
+	if FileExists(namespace + "feature.txt") {
+		t.Errorf("Expected the working directory to be untouched when importing into another branch")
+	}
+	if returnCode := runImportGitCommand("testdata/git/packed.git", "feature", "imported"); returnCode != 2104 {
+		t.Errorf("Expected 2104 when the branch already has commits, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_ImportGit_Errors(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	if returnCode := runImportGitCommand(t.TempDir(), "", ""); returnCode != 2102 {
+		t.Errorf("Expected 2102 for a directory that is not a Git repository, got %d", returnCode)
+	}
+	if returnCode := runImportGitCommand("testdata/git/history.git", "missing", ""); returnCode != 2103 {
+		t.Errorf("Expected 2103 for a missing Git branch, got %d", returnCode)
+	}
+	if returnCode := runImportGitCommand("testdata/git/history.git", "", "bad..name"); returnCode != 2106 {
+		t.Errorf("Expected 2106 for an invalid branch name, got %d", returnCode)
+	}
+	if len(mustBranchCommits(t, store)) != 0 {
+		t.Errorf("Expected failed imports not to create commits")
+	}
+
+	os.RemoveAll(namespace)
+}
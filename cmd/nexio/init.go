@@ -23,7 +23,7 @@ var initCmd = &cobra.Command{
 }
 
 func runInitCommand() {
-	if _, err := os.Stat(dirs.Root); !os.IsNotExist(err) {
+	if store.Exists() {
 		Debug("%s", COMMON_RETURN_CODES[003])
 		BreakLine()
 		Fail(COMMON_RETURN_CODES[003])
@@ -31,72 +31,23 @@ func runInitCommand() {
 		return
 	}
 
-	Debug("Creating staging directories")
-	if err := os.MkdirAll(dirs.StagingAdded, os.ModePerm); err != nil {
-		Debug("Failed to create staging/added directory")
-		MustSucceed(err, "operation failed")
-	}
-	if err := os.MkdirAll(dirs.StagingModified, os.ModePerm); err != nil {
-		Debug("Failed to create staging/modified directory")
-		MustSucceed(err, "operation failed")
-	}
-	if err := os.MkdirAll(dirs.StagingRemoved, os.ModePerm); err != nil {
-		Debug("Failed to create staging/removed directory")
-		MustSucceed(err, "operation failed")
-	}
-
-	Debug("Creating staging logs file")
-	f, err := os.Create(dirs.StagingLogs)
-	if err != nil {
-		Debug("Failed to create staging logs file")
-		MustSucceed(err, "operation failed")
-	}
-	_, err = f.WriteString("[]")
-	if err != nil {
-		Debug("Failed to write initial staging logs")
-		MustSucceed(err, "operation failed")
-	}
-	f.Close()
-
-	Debug("Creating commits directory")
-	if err := os.MkdirAll(dirs.Commits, os.ModePerm); err != nil {
-		Debug("Failed to create commits directory")
+	Debug("Creating staging area, commits and default branch")
+	if err := store.Initialize(); err != nil {
+		Debug("Failed to initialize storage")
 		MustSucceed(err, "operation failed")
 	}
 
-	Debug("Creating default branch directory")
-	if err := os.MkdirAll(dirs.DefaultBranch, os.ModePerm); err != nil {
-		Debug("Failed to create default branch directory")
-		MustSucceed(err, "operation failed")
-	}
-	Debug("Creating default branch commits file")
-	f, err = os.Create(dirs.DefaultBranchCommits)
-	if err != nil {
-		Debug("Failed to create default branch commits file")
-		MustSucceed(err, "operation failed")
-	}
-	_, err = f.WriteString("[]")
-	if err != nil {
-		Debug("Failed to write initial default branch commits")
-		MustSucceed(err, "operation failed")
-	}
-	f.Close()
-
 	Debug("Creating branches metadata")
 	CreateBranchesMetadata()
 
 	Debug("Creating config file")
-	f, err = os.Create(dirs.Config)
-	if err != nil {
-		Debug("Failed to create config file")
-		MustSucceed(err, "operation failed")
-	}
-	_, err = f.WriteString("{ \"name\": \"\", \"email\": \"\" }")
-	if err != nil {
+	if err := store.WriteConfig(Config{}); err != nil {
 		Debug("Failed to write initial config")
 		MustSucceed(err, "operation failed")
 	}
-	f.Close()
+
+	Debug("Writing format version")
+	WriteFormatVersion(CurrentFormatVersion())
 
 	Debug("Nexio initialized successfully")
 	BreakLine()
@@ -1,13 +1,15 @@
 package main
 
-import (
-	"os"
-)
-
 func IsInitialized() bool {
+	return IsStorageInitialized(store)
+}
+
+// IsStorageInitialized tells whether s holds a repository, upgrading its format if it is older.
+func IsStorageInitialized(s Storage) bool {
 	Debug("Checking if Nexio is initialized")
-	if _, err := os.Stat(dirs.Root); !os.IsNotExist(err) {
+	if s.Exists() {
 		Debug("Nexio is initialized")
+		MustSucceed(EnsureFormat(s), "unsupported repository format")
 		return true
 	}
 	Debug("Nexio is not initialized")
@@ -1,48 +1,96 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
-const DefaultLockTimeout = 5 * time.Second
+const (
+	DefaultLockTimeout = 5 * time.Second
+	// Locks are held for milliseconds, a lock that cannot be verified otherwise is stale after this.
+	StaleLockAge = time.Minute
+)
+
+var errLockHeld = errors.New("lock held by another process")
 
 type Lock struct {
 	path     string
 	lockFile *os.File
 }
 
+// LockOwner is written into the lock file so that other processes can tell
+// whether the lock was left behind by a process that no longer exists.
+type LockOwner struct {
+	Pid      int    `json:"pid"`
+	Hostname string `json:"hostname"`
+	Acquired string `json:"acquired"`
+}
+
+type LockInfo struct {
+	Path  string
+	Owner *LockOwner
+	Stale bool
+}
+
 func NewLock(path string) *Lock {
 	return &Lock{
 		path: path + ".lock",
 	}
 }
 
+// LockTimeout returns the lock acquisition timeout. It can be configured with the
+// NEXIO_LOCK_TIMEOUT environment variable or `nexio config set lock-timeout <duration>`.
+func LockTimeout() time.Duration {
+	value := os.Getenv("NEXIO_LOCK_TIMEOUT")
+	if value == "" {
+		// The config file is read without MustSucceed, locks are also taken while the repository is being initialized.
+		if config, err := store.ReadConfig(); err == nil {
+			value = config.LockTimeout
+		}
+	}
+	if value == "" {
+		return DefaultLockTimeout
+	}
+	timeout, err := ParseDuration(value)
+	if err != nil {
+		Debug("Invalid lock timeout: %s, using default", value)
+		return DefaultLockTimeout
+	}
+	return timeout
+}
+
 func (l *Lock) Acquire(timeout time.Duration) error {
 	Debug("Attempting to acquire lock: %s", l.path)
 	deadline := time.Now().Add(timeout)
 
 	for {
-		/*
-			- os.O_CREATE - create the file if it doesn't exist
-			- os.O_EXCL - exclusive creation - fail if file already exists
-			- os.O_WRONLY - open for write-only access
-			- Combined effect: atomically create the file ONLY if it doesn't exist
-		*/
-		lockFile, err := os.OpenFile(l.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		lockFile, err := openLockFile(l.path)
 		if err == nil {
-			// Acquire lock, write PID to lock file (for debugging)
 			l.lockFile = lockFile
-			pid := os.Getpid()
-			fmt.Fprintf(lockFile, "%d\n", pid)
-			Debug("Lock acquired: %s (PID: %d)", l.path, pid)
+			owner := currentLockOwner()
+			if err := writeLockOwner(lockFile, owner); err != nil {
+				Debug("Failed to write lock owner: %v", err)
+			}
+			Debug("Lock acquired: %s (PID: %d)", l.path, owner.Pid)
 			return nil
 		}
+		if !errors.Is(err, errLockHeld) {
+			Debug("Failed to open lock file: %v", err)
+			return err
+		}
+
+		// With flock the kernel releases the lock of a dead process, stale lock files only block O_EXCL locks.
+		if !flockSupported && stealStaleLock(l.path) {
+			continue
+		}
 
 		if time.Now().After(deadline) {
-			Debug("Lock acquisition timout: %s", l.path)
+			Debug("Lock acquisition timeout: %s", l.path)
 			return errors.New("Lock acquisition timeout")
 		}
 
@@ -57,16 +105,8 @@ func (l *Lock) Release() error {
 	}
 
 	Debug("Releasing lock: %s", l.path)
-
-	// Close lock file
-	if err := l.lockFile.Close(); err != nil {
-		Debug("Error closing lock file: %v", err)
-		return err
-	}
-
-	// Remove lock file
-	if err := os.Remove(l.path); err != nil {
-		Debug("Error removing lock file: %v", err)
+	if err := releaseLockFile(l.path, l.lockFile); err != nil {
+		Debug("Error releasing lock file: %v", err)
 		return err
 	}
 
@@ -90,3 +130,135 @@ func WithLock(lockPath string, timeout time.Duration, fn func() error) error {
 
 	return fn()
 }
+
+func currentLockOwner() LockOwner {
+	hostname, _ := os.Hostname()
+	return LockOwner{
+		Pid:      os.Getpid(),
+		Hostname: hostname,
+		Acquired: GetTimestamp(),
+	}
+}
+
+func writeLockOwner(f *os.File, owner LockOwner) error {
+	data, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(append(data, '\n'), 0)
+	return err
+}
+
+// ReadLockOwner parses the owner of a lock file. Lock files written by older
+// versions only contain the PID, those are assumed to belong to this host.
+func ReadLockOwner(path string) (*LockOwner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var owner LockOwner
+	if err := json.Unmarshal(data, &owner); err == nil {
+		return &owner, nil
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.New("unrecognized lock file content")
+	}
+	hostname, _ := os.Hostname()
+	return &LockOwner{Pid: pid, Hostname: hostname}, nil
+}
+
+// IsLockStale reports whether a lock file was left behind by a process that no longer holds it.
+// Without flock, the owner PID is checked on the same host; for locks taken on another host
+// (shared filesystems) only the age of the lock can be used.
+func IsLockStale(path string) (owner *LockOwner, stale bool) {
+	owner, err := ReadLockOwner(path)
+	if flockSupported {
+		return owner, !isLockHeld(path)
+	}
+	if err != nil {
+		info, statErr := os.Stat(path)
+		return nil, statErr == nil && time.Since(info.ModTime()) > StaleLockAge
+	}
+	hostname, _ := os.Hostname()
+	if owner.Hostname == hostname && processCheckSupported {
+		return owner, !processAlive(owner.Pid)
+	}
+	acquired, err := time.Parse(time.RFC3339, owner.Acquired)
+	return owner, err == nil && time.Since(acquired) > StaleLockAge
+}
+
+// stealStaleLock removes a stale lock file. Stealing is serialized through a separate `.steal`
+// file, the lock is checked again while holding it so that two processes cannot both remove
+// the lock and the second one delete a lock that was just acquired by the first.
+func stealStaleLock(path string) bool {
+	if _, stale := IsLockStale(path); !stale {
+		return false
+	}
+
+	stealPath := path + ".steal"
+	stealFile, err := os.OpenFile(stealPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if info, statErr := os.Stat(stealPath); statErr == nil && time.Since(info.ModTime()) > DefaultLockTimeout {
+			Debug("Removing abandoned steal lock: %s", stealPath)
+			os.Remove(stealPath)
+		}
+		return false
+	}
+	defer func() {
+		stealFile.Close()
+		os.Remove(stealPath)
+	}()
+
+	owner, stale := IsLockStale(path)
+	if !stale {
+		return false
+	}
+	if err := os.Remove(path); err != nil {
+		Debug("Failed to remove stale lock: %v", err)
+		return false
+	}
+	if owner != nil {
+		Debug("Stole stale lock: %s (PID: %d, host: %s)", path, owner.Pid, owner.Hostname)
+	}
+	return true
+}
+
+// RemoveStaleLock removes a lock file unless a process holds it. With flock the lock is taken
+// before the file is removed, a process locking it after the check keeps its lock.
+func RemoveStaleLock(path string) bool {
+	if !flockSupported {
+		return stealStaleLock(path)
+	}
+	f, err := openLockFile(path)
+	if err != nil {
+		Debug("Lock is held, keeping it: %s", path)
+		return false
+	}
+	return releaseLockFile(path, f) == nil
+}
+
+// ListLocks returns every lock file below root, with paths relative to it.
+func ListLocks(root string) ([]LockInfo, error) {
+	locks := []LockInfo{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		owner, stale := IsLockStale(path)
+		name := strings.TrimPrefix(path, root)
+		if name == RepoLockName && flockSupported && stale {
+			// With flock the repository lock file is kept between commands, it is only relevant while held.
+			return nil
+		}
+		locks = append(locks, LockInfo{Path: name, Owner: owner, Stale: stale})
+		return nil
+	})
+	return locks, err
+}
@@ -0,0 +1,77 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// On Linux locks are advisory flock locks, the kernel releases them when the owning process dies.
+const flockSupported = true
+
+func openLockFile(path string) (*os.File, error) {
+	return flockFile(path, syscall.LOCK_EX)
+}
+
+// openSharedLockFile locks the file in shared mode, any number of shared holders may coexist.
+func openSharedLockFile(path string) (*os.File, error) {
+	return flockFile(path, syscall.LOCK_SH)
+}
+
+func flockFile(path string, how int) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, errLockHeld
+			}
+			return nil, err
+		}
+
+		// The previous owner removes the lock file on release. If that happened between
+		// opening and locking, the locked inode is no longer the lock file on disk: retry.
+		var opened, onDisk syscall.Stat_t
+		if err := syscall.Fstat(int(f.Fd()), &opened); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := syscall.Stat(path, &onDisk); err != nil || opened.Ino != onDisk.Ino || opened.Dev != onDisk.Dev {
+			f.Close()
+			continue
+		}
+		return f, nil
+	}
+}
+
+func releaseLockFile(path string, f *os.File) error {
+	// Remove before unlocking, otherwise another process could lock the file we are about to remove.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func isLockHeld(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		return true
+	}
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return false
+}
+
+// The repository lock file is never removed, shared holders may still hold it when one of them releases.
+func releaseRepoLockFile(_ string, f *os.File) error {
+	return f.Close()
+}
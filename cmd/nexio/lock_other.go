@@ -0,0 +1,48 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+)
+
+// Elsewhere the lock file is created exclusively, stale locks are detected from the owner written into it.
+const flockSupported = false
+
+func openLockFile(path string) (*os.File, error) {
+	/*
+		- os.O_CREATE - create the file if it doesn't exist
+		- os.O_EXCL - exclusive creation - fail if file already exists
+		- os.O_RDWR - open for read-write access
+		- Combined effect: atomically create the file ONLY if it doesn't exist
+	*/
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, errLockHeld
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func releaseLockFile(path string, f *os.File) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func isLockHeld(path string) bool {
+	_, stale := IsLockStale(path)
+	return FileExists(path) && !stale
+}
+
+// Without flock there is no shared mode, shared locks are taken exclusively.
+func openSharedLockFile(path string) (*os.File, error) {
+	return openLockFile(path)
+}
+
+func releaseRepoLockFile(path string, f *os.File) error {
+	return releaseLockFile(path, f)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func deadProcessPid(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", "exit 0")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Unable to spawn process: %v", err)
+	}
+	return cmd.ProcessState.Pid()
+}
+
+func Test_Lock_HeldLockTimesOut(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	first := NewLock(dirs.StagingLogs)
+	if err := first.Acquire(LockTimeout()); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	second := NewLock(dirs.StagingLogs)
+	if err := second.Acquire(50 * time.Millisecond); err == nil {
+		t.Errorf("Expected lock acquisition to time out while the lock is held")
+	}
+
+	owner, err := ReadLockOwner(dirs.StagingLogs + ".lock")
+	if err != nil {
+		t.Fatalf("Failed to read lock owner: %v", err)
+	}
+	if owner.Pid != os.Getpid() {
+		t.Errorf("Expected owner PID %d, got %d", os.Getpid(), owner.Pid)
+	}
+	if _, stale := IsLockStale(dirs.StagingLogs + ".lock"); stale {
+		t.Errorf("Expected held lock not to be stale")
+	}
+
+	first.Release()
+	if err := second.Acquire(50 * time.Millisecond); err != nil {
+		t.Errorf("Expected lock to be acquired after release: %v", err)
+	}
+	second.Release()
+
+	if FileExists(dirs.StagingLogs + ".lock") {
+		t.Errorf("Expected lock file to be removed on release")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Lock_StaleLockIsRecovered(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	hostname, _ := os.Hostname()
+	owner, _ := json.Marshal(LockOwner{Pid: deadProcessPid(t), Hostname: hostname, Acquired: GetTimestamp()})
+	os.WriteFile(dirs.StagingLogs+".lock", owner, 0644)
+
+	if _, stale := IsLockStale(dirs.StagingLogs + ".lock"); !stale {
+		t.Errorf("Expected lock of a dead process to be stale")
+	}
+
+	// Without stale lock recovery this would time out.
+	file := namespace + "file.txt"
+	os.WriteFile(file, []byte("content"), 0644)
+	os.Setenv("NEXIO_LOCK_TIMEOUT", "200ms")
+	defer os.Unsetenv("NEXIO_LOCK_TIMEOUT")
+	result := runAddCommand(file, false)
+	if result.ReturnCode != 112 {
+		t.Errorf("Expected 112, got %d", result.ReturnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Unlock(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	returnCode, _ := runUnlockCommand(store, false)
+	if returnCode != 1201 {
+		t.Errorf("Expected 1201, got %d", returnCode)
+	}
+
+	held := NewLock(dirs.BranchesMetadata)
+	if err := held.Acquire(LockTimeout()); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer held.Release()
+	staleLock := dirs.DefaultBranch + "commits.lock"
+	os.WriteFile(staleLock, []byte("0\n"), 0644)
+
+	returnCode, locks := runUnlockCommand(store, false)
+	if returnCode != 1204 {
+		t.Errorf("Expected 1204, got %d", returnCode)
+	}
+	if len(locks) != 2 {
+		t.Errorf("Expected 2 locks, got %d", len(locks))
+	}
+	if FileExists(staleLock) {
+		t.Errorf("Expected stale lock to be removed")
+	}
+	if !FileExists(dirs.BranchesMetadata + ".lock") {
+		t.Errorf("Expected held lock to be kept without --force")
+	}
+
+	returnCode, _ = runUnlockCommand(store, true)
+	if returnCode != 1203 {
+		t.Errorf("Expected 1203, got %d", returnCode)
+	}
+	if FileExists(dirs.BranchesMetadata + ".lock") {
+		t.Errorf("Expected held lock to be removed with --force")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Unlock_MemoryStorage(t *testing.T) {
+	t.Parallel()
+	if returnCode, _ := runUnlockCommand(NewMemoryStorage(), false); returnCode != 001 {
+		t.Errorf("Expected 001 for an uninitialized storage, got %d", returnCode)
+	}
+
+	s := memoryRepository(t)
+	release, err := s.LockRepository(ExclusiveLock)
+	if err != nil {
+		t.Fatalf("Failed to lock repository: %v", err)
+	}
+	release()
+	if returnCode, locks := runUnlockCommand(s, false); returnCode != 1201 || len(locks) != 0 {
+		t.Errorf("Expected 1201 without lock files, got %d: %v", returnCode, locks)
+	}
+}
+
+func Test_RemoveStaleLock(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	path := dirs.StagingLogs + ".lock"
+	hostname, _ := os.Hostname()
+	owner, _ := json.Marshal(LockOwner{Pid: deadProcessPid(t), Hostname: hostname, Acquired: GetTimestamp()})
+	os.WriteFile(path, owner, 0644)
+	if _, stale := IsLockStale(path); !stale {
+		t.Fatalf("Expected lock of a dead process to be stale")
+	}
+
+	// Acquired between the check and the removal, the lock must be kept.
+	lock := NewLock(dirs.StagingLogs)
+	if err := lock.Acquire(LockTimeout()); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if RemoveStaleLock(path) {
+		t.Errorf("Expected a held lock not to be removed")
+	}
+	if !FileExists(path) {
+		t.Errorf("Expected held lock file to be kept")
+	}
+	lock.Release()
+
+	os.WriteFile(path, owner, 0644)
+	if !RemoveStaleLock(path) || FileExists(path) {
+		t.Errorf("Expected stale lock to be removed")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_LockTimeout(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	if LockTimeout() != DefaultLockTimeout {
+		t.Errorf("Expected default lock timeout, got %v", LockTimeout())
+	}
+	if returnCode := setConfig("lock-timeout", "30s"); returnCode != 603 {
+		t.Errorf("Expected 603, got %d", returnCode)
+	}
+	if LockTimeout() != 30*time.Second {
+		t.Errorf("Expected 30s lock timeout, got %v", LockTimeout())
+	}
+	if returnCode := setConfig("lock-timeout", "soon"); returnCode != 608 {
+		t.Errorf("Expected 608, got %d", returnCode)
+	}
+
+	os.Setenv("NEXIO_LOCK_TIMEOUT", "1s")
+	defer os.Unsetenv("NEXIO_LOCK_TIMEOUT")
+	if LockTimeout() != time.Second {
+		t.Errorf("Expected environment to override config, got %v", LockTimeout())
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+const (
+	conflictStart     = "<<<<<<< "
+	conflictSeparator = "======="
+	conflictEnd       = ">>>>>>> "
+)
+
+// MergeFile merges the versions of the file at path with MergeLines. Files matching a binary pattern
+// are never merged line by line, they conflict as a whole like binary contents.
+func MergeFile(path string, base []byte, ours []byte, theirs []byte, oursLabel string, theirsLabel string) (merged []byte, conflicts int) {
+	if !bytes.Equal(ours, theirs) && !bytes.Equal(ours, base) && !bytes.Equal(theirs, base) && IsBinaryPath(path) {
+		Debug("Binary file changed on both sides: %s", path)
+		return ours, 1
+	}
+	return MergeLines(base, ours, theirs, oursLabel, theirsLabel)
+}
+
+// MergeLines merges the changes ours and theirs made to base line by line. Regions changed on both
+// sides in different ways are conflicts, written between markers labelled with oursLabel and theirsLabel.
+// Binary contents changed on both sides conflict as a whole and keep ours, without markers.
+func MergeLines(base []byte, ours []byte, theirs []byte, oursLabel string, theirsLabel string) (merged []byte, conflicts int) {
+	switch {
+	case bytes.Equal(ours, theirs), bytes.Equal(theirs, base):
+		return ours, 0
+	case bytes.Equal(ours, base):
+		return theirs, 0
+	case IsBinary(base) || IsBinary(ours) || IsBinary(theirs):
+		return ours, 1
+	}
+
+	baseLines, oursLines, theirsLines := SplitLines(base), SplitLines(ours), SplitLines(theirs)
+	oursMatch := matchLines(baseLines, oursLines)
+	theirsMatch := matchLines(baseLines, theirsLines)
+
+	var out bytes.Buffer
+	i, o, t := 0, 0, 0
+	for i < len(baseLines) || o < len(oursLines) || t < len(theirsLines) {
+		if i < len(baseLines) && oursMatch[i] == o && theirsMatch[i] == t {
+			out.WriteString(baseLines[i])
+			i, o, t = i+1, o+1, t+1
+			continue
+		}
+		// The unstable region ends at the next base line both sides kept.
+		end, oursEnd, theirsEnd := i, len(oursLines), len(theirsLines)
+		for ; end < len(baseLines); end++ {
+			if oursMatch[end] != -1 && theirsMatch[end] != -1 {
+				oursEnd, theirsEnd = oursMatch[end], theirsMatch[end]
+				break
+			}
+		}
+		baseChunk, oursChunk, theirsChunk := baseLines[i:end], oursLines[o:oursEnd], theirsLines[t:theirsEnd]
+		switch {
+		case slices.Equal(oursChunk, theirsChunk), slices.Equal(theirsChunk, baseChunk):
+			writeLines(&out, oursChunk)
+		case slices.Equal(oursChunk, baseChunk):
+			writeLines(&out, theirsChunk)
+		default:
+			conflicts++
+			out.WriteString(conflictStart + oursLabel + "\n")
+			writeLines(&out, oursChunk)
+			endLine(&out)
+			out.WriteString(conflictSeparator + "\n")
+			writeLines(&out, theirsChunk)
+			endLine(&out)
+			out.WriteString(conflictEnd + theirsLabel + "\n")
+		}
+		i, o, t = end, oursEnd, theirsEnd
+	}
+	return out.Bytes(), conflicts
+}
+
+// HasConflictMarkers reports whether data still holds a conflict written by MergeLines.
+func HasConflictMarkers(data []byte) bool {
+	start, separator, end := false, false, false
+	for _, line := range SplitLines(data) {
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, conflictStart):
+			start = true
+		case start && line == conflictSeparator:
+			separator = true
+		case separator && strings.HasPrefix(line, conflictEnd):
+			end = true
+		}
+	}
+	return end
+}
+
+// matchLines maps every line of a to the index of the same line in b, or -1 if the diff removed it.
+func matchLines(a []string, b []string) []int {
+	match := make([]int, len(a))
+	x, y := 0, 0
+	for _, op := range diffLines(a, b) {
+		switch op.kind {
+		case ' ':
+			match[x] = y
+			x, y = x+1, y+1
+		case '-':
+			match[x] = -1
+			x++
+		case '+':
+			y++
+		}
+	}
+	return match
+}
+
+func writeLines(out *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// endLine makes sure a conflict marker starts on a line of its own.
+func endLine(out *bytes.Buffer) {
+	if out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+		out.WriteByte('\n')
+	}
+}
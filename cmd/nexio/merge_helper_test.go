@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_MergeLines(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\nfive\n"
+	cases := []struct {
+		name, ours, theirs, merged string
+		conflicts                  int
+	}{
+		{"unchanged", base, base, base, 0},
+		{"ours only", "ONE\ntwo\nthree\nfour\nfive\n", base, "ONE\ntwo\nthree\nfour\nfive\n", 0},
+		{"theirs only", base, "one\ntwo\nthree\nfour\nFIVE\n", "one\ntwo\nthree\nfour\nFIVE\n", 0},
+		{"both, apart", "ONE\ntwo\nthree\nfour\nfive\n", "one\ntwo\nthree\nfour\nfive\nsix\n", "ONE\ntwo\nthree\nfour\nfive\nsix\n", 0},
+		{"both, same", "one\nTWO\nthree\nfour\nfive\n", "one\nTWO\nthree\nfour\nfive\n", "one\nTWO\nthree\nfour\nfive\n", 0},
+		{"removed and changed apart", "one\nthree\nfour\nfive\n", "one\ntwo\nthree\nFOUR\nfive\n", "one\nthree\nFOUR\nfive\n", 0},
+		{
+			"conflict", "one\nTWO\nthree\nfour\nfive\n", "one\nzwei\nthree\nfour\nFIVE\n",
+			"one\n<<<<<<< HEAD\nTWO\n=======\nzwei\n>>>>>>> abc fix\nthree\nfour\nFIVE\n", 1,
+		},
+		{
+			"conflict without line break", "one\ntwo\nthree\nfour\nfive", "one\ntwo\nthree\nfour\nsix",
+			"one\ntwo\nthree\nfour\n<<<<<<< HEAD\nfive\n=======\nsix\n>>>>>>> abc fix\n", 1,
+		},
+	}
+	for _, c := range cases {
+		merged, conflicts := MergeLines([]byte(base), []byte(c.ours), []byte(c.theirs), HeadRevision, "abc fix")
+		if string(merged) != c.merged || conflicts != c.conflicts {
+			t.Errorf("%s: expected %d conflicts in\n%s\ngot %d in\n%s", c.name, c.conflicts, c.merged, conflicts, merged)
+		}
+		if HasConflictMarkers(merged) != (c.conflicts > 0) {
+			t.Errorf("%s: expected conflict markers only with conflicts", c.name)
+		}
+	}
+
+	if merged, conflicts := MergeLines([]byte("a\x00"), []byte("b\x00"), []byte("c\x00"), HeadRevision, "abc"); string(merged) != "b\x00" || conflicts != 1 {
+		t.Errorf("Expected a binary conflict to keep ours, got %q %d", merged, conflicts)
+	}
+}
+
+func Test_MergeFile_BinaryPattern(t *testing.T) {
+	os.WriteFile(".nexio.rules.yml", []byte("binary:\n  - \"*.svg\"\n"), 0644)
+	defer os.Remove(".nexio.rules.yml")
+
+	base, ours, theirs := "one\ntwo\nthree\n", "ONE\ntwo\nthree\n", "one\ntwo\nTHREE\n"
+	if merged, conflicts := MergeFile("logo.svg", []byte(base), []byte(ours), []byte(theirs), HeadRevision, "abc fix"); string(merged) != ours || conflicts != 1 {
+		t.Errorf("Expected binary files changed on both sides to conflict as a whole, got %d %q", conflicts, merged)
+	}
+	if merged, conflicts := MergeFile("logo.svg", []byte(base), []byte(base), []byte(theirs), HeadRevision, "abc fix"); string(merged) != theirs || conflicts != 0 {
+		t.Errorf("Expected binary files changed on one side to be taken, got %d %q", conflicts, merged)
+	}
+	if merged, conflicts := MergeFile("notes.txt", []byte(base), []byte(ours), []byte(theirs), HeadRevision, "abc fix"); string(merged) != "ONE\ntwo\nTHREE\n" || conflicts != 0 {
+		t.Errorf("Expected text files to be merged line by line, got %d %q", conflicts, merged)
+	}
+}
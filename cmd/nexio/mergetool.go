@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(mergetoolCmd)
+}
+
+var mergetoolCmd = &cobra.Command{
+	Use:     "mergetool",
+	Short:   "Resolve unmerged paths with the configured merge tool",
+	Example: "nexio mergetool\nnexio mergetool <path>...",
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting mergetool command: paths=%v", args)
+		runMergetoolCommand(args)
+	},
+}
+
+func runMergetoolCommand(paths []string) (returnCode int, resolved []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	conflicts := GetConflicts()
+	if len(conflicts) == 0 {
+		Debug("%s", MERGETOOL_RETURN_CODES[3002])
+		Info(MERGETOOL_RETURN_CODES[3002])
+		return 3002, nil
+	}
+	if len(paths) > 0 {
+		for _, path := range paths {
+			if !slices.ContainsFunc(conflicts, func(conflict Conflict) bool { return conflict.Path == filepath.Clean(path) }) {
+				Debug("Path is not unmerged: %s", path)
+				Fail(MERGETOOL_RETURN_CODES[3005] + " " + path)
+				return 3005, nil
+			}
+		}
+		conflicts = slices.DeleteFunc(conflicts, func(conflict Conflict) bool {
+			return !slices.ContainsFunc(paths, func(path string) bool { return conflict.Path == filepath.Clean(path) })
+		})
+	}
+	tool := GetConfig().MergeTool
+	if tool == "" {
+		Debug("%s", MERGETOOL_RETURN_CODES[3003])
+		Fail(MERGETOOL_RETURN_CODES[3003])
+		Text("Set one with "+Code("nexio config set merge-tool <command>"), "")
+		return 3003, nil
+	}
+
+	BreakLine()
+	unresolved := []string{}
+	for _, conflict := range conflicts {
+		Info("Merging " + conflict.Path + " (" + conflict.Describe() + ")")
+		if err := runMergeTool(tool, conflict); err != nil {
+			Debug("Merge tool failed on %s: %v", conflict.Path, err)
+			Warning("Not resolved: " + conflict.Path + " " + err.Error())
+			unresolved = append(unresolved, conflict.Path)
+			continue
+		}
+		if data, err := os.ReadFile(conflict.Path); err == nil && HasConflictMarkers(data) {
+			Warning("Not resolved: " + conflict.Path + " still has conflict markers")
+			unresolved = append(unresolved, conflict.Path)
+			continue
+		}
+		returnCode := runAddCommandInternal(conflict.Path, true, nil)
+		Debug("Staged file %s: %d", conflict.Path, returnCode)
+		resolved = append(resolved, conflict.Path)
+	}
+
+	BreakLine()
+	if len(unresolved) > 0 {
+		Fail(MERGETOOL_RETURN_CODES[3004])
+		Tree(unresolved, true)
+		BreakLine()
+		return 3004, resolved
+	}
+	Success(MERGETOOL_RETURN_CODES[3001] + " " + FormatFileCount(len(resolved)))
+	BreakLine()
+	return 3001, resolved
+}
+
+// runMergeTool writes the versions of a conflict to temporary files and runs the tool on them. The tool
+// finds them in $BASE, $LOCAL and $REMOTE and writes the result to $MERGED, the file in the working
+// directory. A version missing on one side is an empty file.
+func runMergeTool(tool string, conflict Conflict) error {
+	dir, err := os.MkdirTemp("", "nexio-mergetool-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	_, fileName := ParsePath(conflict.Path)
+	env := os.Environ()
+	for version, name := range map[string]string{"base": "BASE", "ours": "LOCAL", "theirs": "REMOTE"} {
+		file := conflict.Versions[version]
+		path := filepath.Join(dir, name+"_"+fileName)
+		if err := os.WriteFile(path, file.Data, 0644); err != nil {
+			return err
+		}
+		env = append(env, name+"="+path)
+	}
+	env = append(env, "MERGED="+conflict.Path)
+
+	cmd := exec.Command("sh", "-c", tool)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// stopOnConflict leaves file1.txt unmerged by cherry-picking a commit changing the same line.
+func stopOnConflict(t *testing.T) {
+	t.Helper()
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\ntwo\nthree\n", "Add file1")
+	runNewCommand("feature", "", "")
+	conflicting := commitTestFile(t, "file1.txt", "one\nzwei\nthree\n", "Translate two")
+	runSwitchCommand(InitBranch)
+	commitTestFile(t, "file1.txt", "one\nTWO\nthree\n", "Shout two")
+	if returnCode, _ := runCherryPickCommand([]string{conflicting}); returnCode != 2802 {
+		t.Fatalf("Expected the cherry-pick to stop on conflicts, got %d", returnCode)
+	}
+}
+
+func Test_Conflicts(t *testing.T) {
+	stopOnConflict(t)
+
+	conflicts := GetConflicts()
+	if len(conflicts) != 1 || conflicts[0].Path != namespace+"file1.txt" || conflicts[0].Describe() != "both modified" {
+		t.Fatalf("Expected file1.txt to be unmerged, got %v", conflicts)
+	}
+	expected := map[string]string{"base": "one\ntwo\nthree\n", "ours": "one\nTWO\nthree\n", "theirs": "one\nzwei\nthree\n"}
+	for version, content := range expected {
+		if string(conflicts[0].Versions[version].Data) != content {
+			t.Errorf("Expected %s version %q, got %q", version, content, conflicts[0].Versions[version].Data)
+		}
+	}
+	if errors := ValidateStagingIntegrity(); len(errors) != 0 {
+		t.Errorf("Expected the staged conflict to be valid, got %v", errors)
+	}
+	if returnCode, _ := runStatusCommand(); returnCode != 502 {
+		t.Errorf("Expected 502, got %d", returnCode)
+	}
+	if returnCode, _ := runCommitCommand("Merge"); returnCode != 703 {
+		t.Errorf("Expected 703 with unmerged paths, got %d", returnCode)
+	}
+
+	// Adding the file marks it resolved and stages it.
+	os.WriteFile(namespace+"file1.txt", []byte("one\nTWO zwei\nthree\n"), 0644)
+	runAddCommand(namespace+"file1.txt", false)
+	if conflicts := GetConflicts(); len(conflicts) != 0 {
+		t.Errorf("Expected no unmerged paths after add, got %v", conflicts)
+	}
+	if modified, _, _ := LogEntryLookup("MOD", namespace+"file1.txt"); !modified {
+		t.Errorf("Expected file1.txt to be staged as modified")
+	}
+	if returnCode, _ := runCherryPickContinueCommand(); returnCode != 2801 {
+		t.Errorf("Expected 2801, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Conflicts_Describe(t *testing.T) {
+	file := ConflictFile{Data: []byte("data\n"), Mode: 0644}
+	tests := map[string]map[string]ConflictFile{
+		"both modified":   {"base": file, "ours": file, "theirs": file},
+		"both added":      {"ours": file, "theirs": file},
+		"deleted by us":   {"base": file, "theirs": file},
+		"deleted by them": {"base": file, "ours": file},
+	}
+	for expected, versions := range tests {
+		if description := (Conflict{Versions: versions}).Describe(); description != expected {
+			t.Errorf("Expected %q, got %q", expected, description)
+		}
+	}
+}
+
+func Test_Mergetool(t *testing.T) {
+	stopOnConflict(t)
+
+	if returnCode, _ := runMergetoolCommand(nil); returnCode != 3003 {
+		t.Errorf("Expected 3003 without a merge tool, got %d", returnCode)
+	}
+	if returnCode, _ := runMergetoolCommand([]string{namespace + "file2.txt"}); returnCode != 3005 {
+		t.Errorf("Expected 3005 for a path that is not unmerged, got %d", returnCode)
+	}
+
+	// A tool that gives up leaves the conflict in place.
+	setConfig("merge-tool", "exit 1")
+	if returnCode, resolved := runMergetoolCommand(nil); returnCode != 3004 || len(resolved) != 0 {
+		t.Errorf("Expected 3004 when the tool fails, got %d %v", returnCode, resolved)
+	}
+
+	setConfig("merge-tool", `cat "$REMOTE" > "$MERGED"`)
+	returnCode, resolved := runMergetoolCommand(nil)
+	if returnCode != 3001 || len(resolved) != 1 {
+		t.Fatalf("Expected 3001, got %d %v", returnCode, resolved)
+	}
+	if content := readTestFile("file1.txt"); content != "one\nzwei\nthree\n" {
+		t.Errorf("Expected the merged file written by the tool, got %q", content)
+	}
+	if len(GetConflicts()) != 0 || IsStagingLogsEmpty() {
+		t.Errorf("Expected the resolution to be staged")
+	}
+	if returnCode, _ := runMergetoolCommand(nil); returnCode != 3002 {
+		t.Errorf("Expected 3002 without unmerged paths, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	mvCmd.Flags().BoolVarP(&MoveForce, "force", "f", false, "Move files with unstaged modifications")
+
+	rootCmd.AddCommand(mvCmd)
+}
+
+var MoveForce bool
+
+var mvCmd = &cobra.Command{
+	Use:     "mv",
+	Short:   "Move or rename a file or directory and stage the move",
+	Example: "nexio mv <source> <destination>\nnexio mv <source> <directory>",
+	Args:    cobra.ExactArgs(2),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting mv command: source=%s, destination=%s, force=%t", args[0], args[1], MoveForce)
+		runMoveCommand(args[0], args[1], MoveForce)
+	},
+}
+
+func runMoveCommand(source string, destination string, force bool) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006
+	}
+
+	source, destination = filepath.Clean(source), filepath.Clean(destination)
+	if ValidatePath(source) != nil || ValidatePath(destination) != nil {
+		Debug("%s", COMMON_RETURN_CODES[004])
+		Fail(COMMON_RETURN_CODES[004])
+		return 004
+	}
+	if _, err := os.Lstat(source); err != nil {
+		Debug("%s", MOVE_RETURN_CODES[3102])
+		Fail(MOVE_RETURN_CODES[3102] + " " + source)
+		return 3102
+	}
+	// Files deleted from the working directory stay where they are.
+	tracked := slices.DeleteFunc(TrackedFiles(source), func(file string) bool { return !FileExists(file) })
+	if len(tracked) == 0 {
+		Debug("%s", MOVE_RETURN_CODES[3103])
+		Fail(MOVE_RETURN_CODES[3103] + " " + source)
+		return 3103
+	}
+	// Moving into an existing directory keeps the name.
+	if info, err := os.Stat(destination); err == nil && info.IsDir() {
+		destination = filepath.Join(destination, filepath.Base(source))
+	}
+	if destination == source || strings.HasPrefix(destination, source+"/") {
+		Debug("%s", MOVE_RETURN_CODES[3106])
+		Fail(MOVE_RETURN_CODES[3106] + " " + destination)
+		return 3106
+	}
+	if _, err := os.Lstat(destination); err == nil {
+		Debug("%s", MOVE_RETURN_CODES[3104])
+		Fail(MOVE_RETURN_CODES[3104] + " " + destination)
+		return 3104
+	}
+	if !force {
+		modified := slices.DeleteFunc(slices.Clone(tracked), func(file string) bool { return !HasUnstagedChanges(file) })
+		if len(modified) > 0 {
+			Debug("%s", MOVE_RETURN_CODES[3105])
+			Fail(MOVE_RETURN_CODES[3105])
+			Tree(modified, true)
+			return 3105
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+		Debug("Failed to create the destination directory")
+		MustSucceed(err, "operation failed")
+	}
+	if err := os.Rename(source, destination); err != nil {
+		Debug("Failed to move %s", source)
+		MustSucceed(err, "operation failed")
+	}
+	for _, file := range tracked {
+		stageMove(file, destination+strings.TrimPrefix(file, source))
+	}
+
+	Success(MOVE_RETURN_CODES[3101] + " " + source + " -> " + destination)
+	return 3101
+}
+
+// stageMove stages a file moved in the working directory. The move is staged from the committed file,
+// if there is one: a file staged as added is added again at its new path, and moving a moved file
+// again keeps the path it was committed at.
+func stageMove(source string, destination string) {
+	Debug("Staging move: %s -> %s", source, destination)
+	added, addedId, _ := LogEntryLookup("ADD", source)
+	renamed, rename := RenameLookup(source)
+	from := source
+	switch {
+	case added:
+		MustSucceed(RemoveFileAndLog(addedId, StagingOps["ADD"]), "operation failed")
+		from = ""
+	case renamed:
+		MustSucceed(RemoveFileAndLog(rename.Id, StagingOps["REN"]), "operation failed")
+		from = rename.From
+	default:
+		if staged, id, op := LogEntryLookup("*", source); staged {
+			MustSucceed(RemoveFileAndLog(id, StagingOps[op]), "operation failed")
+		}
+	}
+	destinationCommitted, _, _ := GetFileMetadata(destination)
+	if from == "" || from == destination || destinationCommitted || IsFileStaged(destination) {
+		// Not a rename of a committed file to a new path, the paths are staged like any change.
+		for _, path := range []string{from, destination} {
+			if path != "" {
+				returnCode := runAddCommandInternal(path, true, nil)
+				Debug("Staged file %s: %d", path, returnCode)
+			}
+		}
+		return
+	}
+	id := GenRandHex(20)
+	MustSucceed(AddToStaging(id, destination, StagingOps["REN"]), "operation failed")
+	LogRename(id, from, destination)
+}
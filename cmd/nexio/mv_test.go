@@ -0,0 +1,232 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_Move(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	first := commitTestFile(t, "file1.txt", "one\ntwo\nthree\nfour\n", "Add file1")
+	source, destination := namespace+"file1.txt", namespace+"dir/moved.txt"
+
+	if returnCode := runMoveCommand(namespace+"missing.txt", destination, false); returnCode != 3102 {
+		t.Errorf("Expected 3102 for a missing source, got %d", returnCode)
+	}
+	os.WriteFile(namespace+"untracked.txt", []byte("untracked\n"), 0644)
+	if returnCode := runMoveCommand(namespace+"untracked.txt", destination, false); returnCode != 3103 {
+		t.Errorf("Expected 3103 for an untracked source, got %d", returnCode)
+	}
+	if returnCode := runMoveCommand(source, namespace+"untracked.txt", false); returnCode != 3104 {
+		t.Errorf("Expected 3104 for an existing destination, got %d", returnCode)
+	}
+	os.Remove(namespace + "untracked.txt")
+
+	if returnCode := runMoveCommand(source, destination, false); returnCode != 3101 {
+		t.Fatalf("Expected 3101, got %d", returnCode)
+	}
+	if FileExists(source) || readTestFile("dir/moved.txt") != "one\ntwo\nthree\nfour\n" {
+		t.Errorf("Expected the file to be moved in the working directory")
+	}
+	renamed, rename := RenameLookup(destination)
+	if !renamed || rename.From != source {
+		t.Fatalf("Expected the move to be staged, got %v", rename)
+	}
+	if modified, deleted := GetModifiedOrDeletedFiles(); len(modified)+len(deleted) != 0 || slices.Contains(GetUntrackedFiles(), destination) {
+		t.Errorf("Expected no unstaged changes, got %v %v", modified, deleted)
+	}
+	if result := runAddCommand(source, false); result.ReturnCode != 114 {
+		t.Errorf("Expected 114 when adding the old path, got %d", result.ReturnCode)
+	}
+	os.WriteFile(destination, []byte("one\nTWO\nthree\nfour\n"), 0644)
+	if result := runAddCommand(destination, false); result.ReturnCode != 115 {
+		t.Errorf("Expected 115 when adding the changed file, got %d", result.ReturnCode)
+	}
+	if returnCode, _ := runStatusCommand(); returnCode != 502 {
+		t.Errorf("Expected 502, got %d", returnCode)
+	}
+
+	returnCode, second := runCommitCommand("Move file1")
+	if returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+	files, _ := commitFileMap(store, second)
+	if _, exists := files[source]; exists || files[destination].CommitId != second {
+		t.Errorf("Expected only the new path in the file list, got %v", files)
+	}
+	if logs, _ := store.ReadCommitLogs(second); len(logs) != 1 || logs[0].Op != "REN" || logs[0].From != source {
+		t.Errorf("Expected the rename in the commit logs, got %v", logs)
+	}
+
+	// Blame follows the file to its old path.
+	_, lines := runBlameCommand(destination, "", false)
+	expected := []string{first, second, first, first}
+	for i, line := range lines {
+		if line.Commit.Id != expected[i] {
+			t.Errorf("Expected line %d to be from %s, got %v", i+1, expected[i], line)
+		}
+	}
+	if returnCode, _ := runHistoryCommand(); returnCode != 401 {
+		t.Errorf("Expected 401, got %d", returnCode)
+	}
+
+	// The patch renames the file, and applies as a rename.
+	outgoing := t.TempDir()
+	_, patches := runFormatPatchCommand(first+"..HEAD", outgoing)
+	patch, _ := os.ReadFile(patches[0])
+	for _, expected := range []string{"similarity index 78%\n", "rename from " + source + "\n", "rename to " + destination + "\n", "-two\n+TWO\n"} {
+		if !strings.Contains(string(patch), expected) {
+			t.Errorf("Expected the patch to contain %q, got:\n%s", expected, patch)
+		}
+	}
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\ntwo\nthree\nfour\n", "Add file1")
+	if returnCode, _ := runAmCommand(patches); returnCode != 2501 {
+		t.Fatalf("Expected 2501, got %d", returnCode)
+	}
+	if FileExists(source) || readTestFile("dir/moved.txt") != "one\nTWO\nthree\nfour\n" {
+		t.Errorf("Expected the patch to move the file")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Move_StagedFiles(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\n", "Add file1")
+
+	// A new file is added again at its new path.
+	os.WriteFile(namespace+"new.txt", []byte("new\n"), 0644)
+	runAddCommand(namespace+"new.txt", false)
+	runMoveCommand(namespace+"new.txt", namespace+"newer.txt", false)
+	if added, _, _ := LogEntryLookup("ADD", namespace+"newer.txt"); !added || IsFileStaged(namespace+"new.txt") {
+		t.Errorf("Expected newer.txt to be staged as added instead of new.txt")
+	}
+
+	// Moving twice keeps the committed path, moving back leaves nothing to stage.
+	runMoveCommand(namespace+"file1.txt", namespace+"file2.txt", false)
+	runMoveCommand(namespace+"file2.txt", namespace+"file3.txt", false)
+	if renamed, rename := RenameLookup(namespace + "file3.txt"); !renamed || rename.From != namespace+"file1.txt" || IsFileStaged(namespace+"file2.txt") {
+		t.Errorf("Expected a single move from file1.txt, got %v", rename)
+	}
+	runMoveCommand(namespace+"file3.txt", namespace+"file1.txt", false)
+	if IsFileStaged(namespace+"file1.txt") || IsFileStaged(namespace+"file3.txt") {
+		t.Errorf("Expected the move back to leave nothing staged")
+	}
+
+	// A moved file deleted afterwards is staged as removed.
+	runMoveCommand(namespace+"file1.txt", namespace+"file2.txt", false)
+	os.Remove(namespace + "file2.txt")
+	if result := runAddCommand(namespace+"file2.txt", false); result.ReturnCode != 117 {
+		t.Errorf("Expected 117, got %d", result.ReturnCode)
+	}
+	if removed, _, _ := LogEntryLookup("REM", namespace+"file1.txt"); !removed || IsFileStaged(namespace+"file2.txt") {
+		t.Errorf("Expected file1.txt to be staged as removed")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_DetectedRenames(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	first := commitTestFile(t, "old.txt", "one\ntwo\nthree\nfour\n", "Add old")
+	commitTestFile(t, "kept.txt", "kept\nlines\n", "Add kept")
+
+	// Removing and adding a similar file is shown as a rename, a file like a modified one as a copy.
+	os.Remove(namespace + "old.txt")
+	os.WriteFile(namespace+"new.txt", []byte("one\ntwo\nthree\nfour\nfive\n"), 0644)
+	os.WriteFile(namespace+"kept.txt", []byte("kept\nlines\nchanged\n"), 0644)
+	os.WriteFile(namespace+"copy.txt", []byte("kept\nlines\n"), 0644)
+	for _, file := range []string{"old.txt", "new.txt", "kept.txt", "copy.txt"} {
+		runAddCommand(namespace+file, false)
+	}
+	staged := FoldRenames(*GetStagingLogsContent(), DetectStagedRenames(*GetStagingLogsContent()))
+	ops := map[string]string{}
+	for _, entry := range staged {
+		ops[entry.Path] = entry.Op + " " + entry.From
+	}
+	expected := map[string]string{namespace + "new.txt": "REN " + namespace + "old.txt", namespace + "copy.txt": "CPY " + namespace + "kept.txt", namespace + "kept.txt": "MOD "}
+	if len(ops) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, ops)
+	}
+	for path, op := range expected {
+		if ops[path] != op {
+			t.Errorf("Expected %s to be %q, got %q", path, op, ops[path])
+		}
+	}
+
+	_, commitId := runCommitCommand("Rename old")
+	renames, err := DetectCommitRenames(store, (*GetCommits())[1].Id, commitId)
+	if err != nil || len(renames) != 2 {
+		t.Fatalf("Expected the rename and the copy of the commit, got %v %v", renames, err)
+	}
+	if _, lines := runBlameCommand(namespace+"new.txt", "", false); len(lines) != 5 || lines[0].Commit.Id != first || lines[4].Commit.Id != commitId {
+		t.Errorf("Expected blame to follow the rename, got %v", lines)
+	}
+
+	// Without enough similarity there is no rename.
+	setConfig("rename-threshold", "100%")
+	if renames, _ := DetectCommitRenames(store, (*GetCommits())[1].Id, commitId); len(renames) != 1 || !renames[0].Copy {
+		t.Errorf("Expected only the identical copy, got %v", renames)
+	}
+	if returnCode := setConfig("rename-threshold", "abc"); returnCode != 610 {
+		t.Errorf("Expected 610, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Move_Directory(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	os.MkdirAll(namespace+"src/sub", 0755)
+	os.WriteFile(namespace+"src/a.txt", []byte("a\n"), 0644)
+	os.WriteFile(namespace+"src/sub/b.txt", []byte("b\n"), 0644)
+	runAddCommand(namespace+"src/a.txt", false)
+	runAddCommand(namespace+"src/sub/b.txt", false)
+	if returnCode, _ := runCommitCommand("Add src"); returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+	os.MkdirAll(namespace+"lib", 0755)
+
+	if returnCode := runMoveCommand(namespace+"src", namespace+"src/sub", false); returnCode != 3106 {
+		t.Errorf("Expected 3106 for a move into itself, got %d", returnCode)
+	}
+	os.WriteFile(namespace+"src/a.txt", []byte("changed\n"), 0644)
+	if returnCode := runMoveCommand(namespace+"src", namespace+"lib", false); returnCode != 3105 {
+		t.Errorf("Expected 3105 for unstaged modifications, got %d", returnCode)
+	}
+	if !FileExists(namespace + "src/a.txt") {
+		t.Fatalf("Expected nothing to be moved")
+	}
+	os.WriteFile(namespace+"src/a.txt", []byte("a\n"), 0644)
+
+	// An existing directory is moved into.
+	if returnCode := runMoveCommand(namespace+"src", namespace+"lib", false); returnCode != 3101 {
+		t.Fatalf("Expected 3101, got %d", returnCode)
+	}
+	for _, file := range []string{"src/a.txt", "src/sub/b.txt"} {
+		if renamed, rename := RenameLookup(namespace + "lib/" + file); !renamed || rename.From != namespace+file {
+			t.Errorf("Expected the move of %s to be staged, got %v", file, rename)
+		}
+	}
+	if readTestFile("lib/src/sub/b.txt") != "b\n" || FileExists(namespace+"src/a.txt") {
+		t.Errorf("Expected the directory to be moved in the working directory")
+	}
+
+	os.WriteFile(namespace+"lib/src/a.txt", []byte("changed\n"), 0644)
+	if returnCode := runMoveCommand(namespace+"lib/src/a.txt", namespace+"a.txt", true); returnCode != 3101 {
+		t.Errorf("Expected 3101 with --force, got %d", returnCode)
+	}
+	if renamed, rename := RenameLookup(namespace + "a.txt"); !renamed || rename.From != namespace+"src/a.txt" {
+		t.Errorf("Expected the move to keep the committed path, got %v", rename)
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -0,0 +1,644 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxFuzz is how many context lines at either end of a hunk may be ignored to find where it applies.
+const MaxFuzz = 2
+
+// Patch is one commit read from a patch file: the author and message of its mail headers, if any, and its file diffs.
+type Patch struct {
+	Author  Author
+	Date    string
+	Message string
+	Files   []FilePatch
+}
+
+// FilePatch is the diff of one file. OldPath is empty for a new file and NewPath for a deleted one,
+// the modes are zero unless the patch sets them. Paths that differ make a rename, or a copy that
+// keeps the old file, of Similarity percent.
+type FilePatch struct {
+	OldPath    string
+	NewPath    string
+	OldMode    os.FileMode
+	NewMode    os.FileMode
+	Similarity int
+	Copy       bool
+	Binary     bool
+	Hunks      []DiffHunk
+}
+
+func (p FilePatch) Path() string {
+	if p.NewPath != "" {
+		return p.NewPath
+	}
+	return p.OldPath
+}
+
+// CommitFilePatches compares the files of a commit with those of its parent, either may be empty.
+// Renamed and copied files are compared with the file they come from.
+func CommitFilePatches(s Storage, parentId string, commitId string) ([]FilePatch, error) {
+	readContent := func(file FileListEntry) ([]byte, os.FileMode, error) {
+		_, fileName := ParsePath(file.Path)
+		return s.ReadObject(file.CommitId, file.Id, fileName)
+	}
+	oldFiles, err := commitFileMap(s, parentId)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := commitFileMap(s, commitId)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for path := range oldFiles {
+		paths = append(paths, path)
+	}
+	for path := range newFiles {
+		if _, exists := oldFiles[path]; !exists {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	renames, err := DetectCommitRenames(s, parentId, commitId)
+	if err != nil {
+		return nil, err
+	}
+	renamedFrom, movedAway := map[string]Rename{}, map[string]bool{}
+	for _, rename := range renames {
+		renamedFrom[rename.To] = rename
+		movedAway[rename.From] = movedAway[rename.From] || !rename.Copy
+	}
+
+	patches := []FilePatch{}
+	for _, path := range paths {
+		oldFile, hadFile := oldFiles[path]
+		newFile, hasFile := newFiles[path]
+		// A removed file that was renamed is part of the patch of its new path. Empty directories
+		// have no place in a patch.
+		if hadFile && hasFile && oldFile.Id == newFile.Id || movedAway[path] && !hasFile || oldFile.IsDir() || newFile.IsDir() {
+			continue
+		}
+		patch := FilePatch{}
+		var oldData, newData []byte
+		if rename, renamed := renamedFrom[path]; renamed && !hadFile {
+			oldFile, hadFile = oldFiles[rename.From]
+			patch.Similarity, patch.Copy = rename.Similarity, rename.Copy
+		}
+		if hadFile {
+			patch.OldPath = oldFile.Path
+			if oldData, patch.OldMode, err = readContent(oldFile); err != nil {
+				return nil, err
+			}
+		}
+		if hasFile {
+			patch.NewPath = path
+			if newData, patch.NewMode, err = readContent(newFile); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case bytes.Equal(oldData, newData):
+			// Only the path or the mode changed.
+		case IsBinaryFile(path, oldData, newData):
+			patch.Binary = true
+		default:
+			patch.Hunks = DiffHunks(oldData, newData, DiffContext)
+		}
+		if len(patch.Hunks) == 0 && !patch.Binary && hadFile && hasFile && patch.OldMode == patch.NewMode && patch.OldPath == patch.NewPath {
+			continue
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
+
+// FormatPatch writes a commit as a mail in mbox format, in the layout of `git format-patch`.
+func FormatPatch(commit Commit, metadata CommitMetadata, files []FilePatch, number int, total int) string {
+	var out strings.Builder
+	subject, body, _ := strings.Cut(strings.TrimSpace(metadata.Message), "\n")
+	prefix := "[PATCH]"
+	if total > 1 {
+		prefix = fmt.Sprintf("[PATCH %d/%d]", number, total)
+	}
+	date := time.Unix(0, 0).UTC()
+	if t, err := time.Parse(time.RFC3339, commit.Timestamp); err == nil {
+		date = t
+	}
+
+	// The date in the separator line is fixed, mail tools only look for the `From ` prefix.
+	fmt.Fprintf(&out, "From %s Mon Sep 17 00:00:00 2001\n", commit.Id)
+	fmt.Fprintf(&out, "From: %s <%s>\n", metadata.Author.Name, metadata.Author.Email)
+	fmt.Fprintf(&out, "Date: %s\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Subject: %s %s\n\n", prefix, subject)
+	if body = strings.TrimSpace(body); body != "" {
+		out.WriteString(body + "\n\n")
+	}
+	out.WriteString("---\n\n")
+	for _, file := range files {
+		out.WriteString(FormatFilePatch(file))
+	}
+	out.WriteString("-- \nnexio\n\n")
+	return out.String()
+}
+
+// FormatFilePatch writes the diff of one file with a `diff --git` header, so Git can apply it too.
+func FormatFilePatch(file FilePatch) string {
+	var out strings.Builder
+	oldName, newName := "a/"+file.Path(), "b/"+file.Path()
+	if file.OldPath != "" && file.NewPath != "" {
+		oldName, newName = "a/"+file.OldPath, "b/"+file.NewPath
+	}
+	fmt.Fprintf(&out, "diff --git %s %s\n", oldName, newName)
+	switch {
+	case file.OldPath == "":
+		fmt.Fprintf(&out, "new file mode %s\n", gitFileMode(file.NewMode))
+		oldName = "/dev/null"
+	case file.NewPath == "":
+		fmt.Fprintf(&out, "deleted file mode %s\n", gitFileMode(file.OldMode))
+		newName = "/dev/null"
+	case file.OldMode != file.NewMode:
+		fmt.Fprintf(&out, "old mode %s\nnew mode %s\n", gitFileMode(file.OldMode), gitFileMode(file.NewMode))
+	}
+	if file.OldPath != "" && file.NewPath != "" && file.OldPath != file.NewPath {
+		kind := "rename"
+		if file.Copy {
+			kind = "copy"
+		}
+		fmt.Fprintf(&out, "similarity index %d%%\n%s from %s\n%s to %s\n", file.Similarity, kind, file.OldPath, kind, file.NewPath)
+	}
+	if file.Binary {
+		fmt.Fprintf(&out, "Binary files %s and %s differ\n", oldName, newName)
+		return out.String()
+	}
+	if len(file.Hunks) > 0 {
+		fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	}
+	for _, hunk := range file.Hunks {
+		out.WriteString(FormatHunk(hunk))
+	}
+	return out.String()
+}
+
+func gitFileMode(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return "120000"
+	case mode.IsDir():
+		return "040000"
+	case mode&0111 != 0:
+		return "100755"
+	}
+	return "100644"
+}
+
+func parseGitFileMode(mode string) os.FileMode {
+	value, err := strconv.ParseUint(strings.TrimSpace(mode), 8, 32)
+	if err != nil {
+		return 0
+	}
+	switch {
+	case value&0170000 == 0120000:
+		return os.ModeSymlink | 0777
+	case value&0111 != 0:
+		return 0755
+	}
+	return 0644
+}
+
+var (
+	mboxSeparator = regexp.MustCompile(`^From [0-9a-f]{7,} `)
+	hunkHeader    = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+	subjectPrefix = regexp.MustCompile(`^\[PATCH[^\]]*\]\s*`)
+)
+
+// ParsePatches reads an mbox file of patches, as written by FormatPatch or `git format-patch`, or a plain
+// unified diff, which gives a single patch without author and message.
+func ParsePatches(data []byte) ([]Patch, error) {
+	lines := SplitLines(data)
+	patches := []Patch{}
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], "\r\n")
+		switch {
+		case mboxSeparator.MatchString(line):
+			patch, next := parseMailHeaders(lines, i+1)
+			patches = append(patches, patch)
+			i = next
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if len(patches) == 0 {
+				patches = append(patches, Patch{})
+			}
+			file, next, err := parseFilePatch(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			patches[len(patches)-1].Files = append(patches[len(patches)-1].Files, file)
+			i = next
+		default:
+			i++
+		}
+	}
+	patches = slices.DeleteFunc(patches, func(patch Patch) bool { return len(patch.Files) == 0 })
+	return patches, nil
+}
+
+// parseMailHeaders reads the headers and the message body of a mail, up to the `---` line or the first diff.
+func parseMailHeaders(lines []string, i int) (Patch, int) {
+	patch := Patch{}
+	headers := map[string]string{}
+	last := ""
+	for ; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if line == "" {
+			i++
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && last != "" {
+			headers[last] += " " + strings.TrimSpace(line)
+			continue
+		}
+		if name, value, found := strings.Cut(line, ":"); found {
+			last = strings.ToLower(name)
+			headers[last] = strings.TrimSpace(value)
+		}
+	}
+	patch.Author = parseMailAddress(headers["from"])
+	patch.Date = headers["date"]
+
+	body := []string{}
+	for ; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if line == "---" || strings.HasPrefix(line, "diff --git ") || mboxSeparator.MatchString(line) {
+			break
+		}
+		body = append(body, line)
+	}
+	patch.Message = subjectPrefix.ReplaceAllString(headers["subject"], "")
+	if text := strings.TrimSpace(strings.Join(body, "\n")); text != "" {
+		patch.Message += "\n\n" + text
+	}
+	return patch, i
+}
+
+func parseMailAddress(address string) Author {
+	start, end := strings.LastIndex(address, "<"), strings.LastIndex(address, ">")
+	if start == -1 || end < start {
+		return Author{Email: strings.TrimSpace(address)}
+	}
+	return Author{Name: strings.Trim(strings.TrimSpace(address[:start]), `"`), Email: address[start+1 : end]}
+}
+
+func parseFilePatch(lines []string, i int) (FilePatch, int, error) {
+	file := FilePatch{}
+	header := strings.TrimRight(lines[i], "\r\n")
+	if rest, found := strings.CutPrefix(header, "diff --git "); found {
+		if separator := strings.LastIndex(rest, " b/"); separator != -1 {
+			file.OldPath = strings.TrimPrefix(rest[:separator], "a/")
+			file.NewPath = rest[separator+3:]
+		}
+		for i++; i < len(lines); i++ {
+			line := strings.TrimRight(lines[i], "\r\n")
+			if value, found := strings.CutPrefix(line, "new file mode "); found {
+				file.OldPath, file.NewMode = "", parseGitFileMode(value)
+			} else if value, found := strings.CutPrefix(line, "deleted file mode "); found {
+				file.NewPath, file.OldMode = "", parseGitFileMode(value)
+			} else if value, found := strings.CutPrefix(line, "old mode "); found {
+				file.OldMode = parseGitFileMode(value)
+			} else if value, found := strings.CutPrefix(line, "new mode "); found {
+				file.NewMode = parseGitFileMode(value)
+			} else if value, found := strings.CutPrefix(line, "rename from "); found {
+				file.OldPath = value
+			} else if value, found := strings.CutPrefix(line, "rename to "); found {
+				file.NewPath = value
+			} else if value, found := strings.CutPrefix(line, "copy from "); found {
+				file.OldPath, file.Copy = value, true
+			} else if value, found := strings.CutPrefix(line, "copy to "); found {
+				file.NewPath = value
+			} else if value, found := strings.CutPrefix(line, "similarity index "); found {
+				file.Similarity = atoiOr(strings.TrimSuffix(value, "%"), 0)
+			} else if strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch" {
+				file.Binary = true
+			} else if !strings.HasPrefix(line, "index ") && !strings.HasPrefix(line, "dissimilarity index ") {
+				break
+			}
+		}
+	}
+
+	if i+1 < len(lines) && strings.HasPrefix(lines[i], "--- ") && strings.HasPrefix(lines[i+1], "+++ ") {
+		oldPath, newPath := parseDiffPath(lines[i][4:], "a/"), parseDiffPath(lines[i+1][4:], "b/")
+		if !strings.HasPrefix(header, "diff --git ") {
+			file.OldPath, file.NewPath = oldPath, newPath
+		}
+		i += 2
+	}
+
+	for i < len(lines) {
+		match := hunkHeader.FindStringSubmatch(lines[i])
+		if match == nil {
+			break
+		}
+		hunk := DiffHunk{OldStart: atoiOr(match[1], 0), OldLines: atoiOr(match[2], 1), NewStart: atoiOr(match[3], 0), NewLines: atoiOr(match[4], 1)}
+		oldLeft, newLeft := hunk.OldLines, hunk.NewLines
+		for i++; i < len(lines) && (oldLeft > 0 || newLeft > 0); i++ {
+			line := lines[i]
+			// Some mail programs strip the space of empty context lines.
+			if line == "\n" || line == "\r\n" {
+				line = " " + line
+			}
+			switch line[0] {
+			case ' ':
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+			case '-':
+				oldLeft--
+			case '+':
+				newLeft--
+			case '\\':
+				stripLastNewline(&hunk)
+				continue
+			default:
+				return file, i, fmt.Errorf("invalid line %d in hunk of %s", i+1, file.Path())
+			}
+			hunk.Lines = append(hunk.Lines, line)
+		}
+		if oldLeft > 0 || newLeft > 0 {
+			return file, i, fmt.Errorf("truncated hunk in %s", file.Path())
+		}
+		if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+			stripLastNewline(&hunk)
+			i++
+		}
+		file.Hunks = append(file.Hunks, hunk)
+	}
+	if file.Path() == "" {
+		return file, i, fmt.Errorf("diff without file name on line %d", i+1)
+	}
+	return file, i, nil
+}
+
+func parseDiffPath(path string, prefix string) string {
+	// A tab separates an optional timestamp.
+	path, _, _ = strings.Cut(strings.TrimRight(path, "\r\n"), "\t")
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+func stripLastNewline(hunk *DiffHunk) {
+	if len(hunk.Lines) > 0 {
+		last := &hunk.Lines[len(hunk.Lines)-1]
+		*last = strings.TrimSuffix(strings.TrimSuffix(*last, "\n"), "\r")
+	}
+}
+
+func atoiOr(value string, fallback int) int {
+	if number, err := strconv.Atoi(value); err == nil {
+		return number
+	}
+	return fallback
+}
+
+// HunkResult tells where a hunk applied: Offset lines away from where the patch expected it,
+// ignoring Fuzz context lines at each end.
+type HunkResult struct {
+	Hunk    DiffHunk
+	Applied bool
+	Offset  int
+	Fuzz    int
+}
+
+type FileApplyResult struct {
+	Patch FilePatch
+	Hunks []HunkResult
+	// Error is set if the file cannot be patched at all, e.g. a new file that already exists.
+	Error string
+}
+
+func (r FileApplyResult) Rejected() []DiffHunk {
+	rejected := []DiffHunk{}
+	for _, hunk := range r.Hunks {
+		if !hunk.Applied {
+			rejected = append(rejected, hunk.Hunk)
+		}
+	}
+	return rejected
+}
+
+func (r FileApplyResult) Clean() bool {
+	return r.Error == "" && len(r.Rejected()) == 0
+}
+
+type patchedFile struct {
+	Data    []byte
+	Mode    os.FileMode
+	Deleted bool
+}
+
+// PatchedFiles holds the files changed by applying patches until they are written to the working directory,
+// so that several patches touching the same file apply on top of each other.
+type PatchedFiles struct {
+	files map[string]*patchedFile
+	order []string
+}
+
+func NewPatchedFiles() *PatchedFiles {
+	return &PatchedFiles{files: map[string]*patchedFile{}}
+}
+
+func (p *PatchedFiles) read(path string) (*patchedFile, bool) {
+	if file, changed := p.files[path]; changed {
+		return file, !file.Deleted
+	}
+	data, mode, err := ReadFileWithMode(path)
+	if err != nil {
+		return nil, false
+	}
+	return &patchedFile{Data: data, Mode: mode}, true
+}
+
+func (p *PatchedFiles) set(path string, file *patchedFile) {
+	if _, changed := p.files[path]; !changed {
+		p.order = append(p.order, path)
+	}
+	p.files[path] = file
+}
+
+// Apply applies the hunks of a file patch that match, rejected hunks leave the file unchanged where they would apply.
+func (p *PatchedFiles) Apply(patch FilePatch) FileApplyResult {
+	result := FileApplyResult{Patch: patch}
+	if patch.Binary {
+		result.Error = "binary patches are not supported"
+		return result
+	}
+	current := &patchedFile{Mode: 0644}
+	if patch.OldPath == "" {
+		if _, exists := p.read(patch.NewPath); exists {
+			result.Error = "already exists"
+			return result
+		}
+	} else {
+		file, exists := p.read(patch.OldPath)
+		if !exists {
+			result.Error = "does not exist"
+			return result
+		}
+		if _, exists := p.read(patch.NewPath); exists && patch.NewPath != "" && patch.NewPath != patch.OldPath {
+			result.Error = "already exists"
+			return result
+		}
+		current = file
+	}
+
+	lines := SplitLines(current.Data)
+	patched := []string{}
+	position, offset := 0, 0
+	for _, hunk := range patch.Hunks {
+		hunkResult := applyHunk(lines, position, offset, hunk)
+		result.Hunks = append(result.Hunks, hunkResult.HunkResult)
+		if !hunkResult.Applied {
+			continue
+		}
+		patched = append(patched, lines[position:hunkResult.start]...)
+		patched = append(patched, hunkResult.replacement...)
+		position, offset = hunkResult.end, hunkResult.Offset
+	}
+	patched = append(patched, lines[position:]...)
+	data := []byte(strings.Join(patched, ""))
+
+	if patch.NewPath == "" {
+		if len(data) > 0 {
+			result.Error = "does not match the deleted content"
+			return result
+		}
+		p.set(patch.OldPath, &patchedFile{Deleted: true})
+		return result
+	}
+	mode := current.Mode
+	if patch.NewMode != 0 {
+		mode = patch.NewMode
+	}
+	if patch.OldPath != "" && patch.OldPath != patch.NewPath && !patch.Copy {
+		p.set(patch.OldPath, &patchedFile{Deleted: true})
+	}
+	p.set(patch.NewPath, &patchedFile{Data: data, Mode: mode})
+	return result
+}
+
+type hunkPlacement struct {
+	HunkResult
+	start       int
+	end         int
+	replacement []string
+}
+
+// applyHunk looks for the lines a hunk replaces at or after position, nearest to where the patch expects them.
+// Failing that, it ignores up to MaxFuzz context lines at each end of the hunk.
+func applyHunk(lines []string, position int, offset int, hunk DiffHunk) hunkPlacement {
+	oldSide, newSide := []string{}, []string{}
+	for _, line := range hunk.Lines {
+		if line[0] != '+' {
+			oldSide = append(oldSide, line[1:])
+		}
+		if line[0] != '-' {
+			newSide = append(newSide, line[1:])
+		}
+	}
+	leading, trailing := 0, 0
+	for leading < len(hunk.Lines) && hunk.Lines[leading][0] == ' ' {
+		leading++
+	}
+	for trailing < len(hunk.Lines)-leading && hunk.Lines[len(hunk.Lines)-1-trailing][0] == ' ' {
+		trailing++
+	}
+
+	expected := hunk.OldStart - 1
+	if hunk.OldLines == 0 {
+		// A hunk without old lines inserts after line OldStart.
+		expected = hunk.OldStart
+	}
+	for fuzz := 0; fuzz <= MaxFuzz; fuzz++ {
+		skipStart, skipEnd := min(fuzz, leading), min(fuzz, trailing)
+		if fuzz > 0 && skipStart+skipEnd == 0 {
+			break
+		}
+		want := oldSide[skipStart : len(oldSide)-skipEnd]
+		target := expected + skipStart + offset
+		for distance := 0; ; distance++ {
+			candidates := []int{target - distance, target + distance}
+			if candidates[0] < position && candidates[1] > len(lines)-len(want) {
+				break
+			}
+			for _, start := range candidates {
+				if start >= position && start <= len(lines)-len(want) && slices.Equal(lines[start:start+len(want)], want) {
+					return hunkPlacement{
+						HunkResult:  HunkResult{Hunk: hunk, Applied: true, Offset: start - skipStart - expected, Fuzz: fuzz},
+						start:       start,
+						end:         start + len(want),
+						replacement: newSide[skipStart : len(newSide)-skipEnd],
+					}
+				}
+			}
+		}
+	}
+	return hunkPlacement{HunkResult: HunkResult{Hunk: hunk}}
+}
+
+// Paths returns the changed files in the order they were first patched.
+func (p *PatchedFiles) Paths() []string {
+	return p.order
+}
+
+// ValidatePatchPaths checks the paths of every file patch before anything is written: they must stay
+// inside the working tree, outside of `.nexio`, and not lead through a symlink.
+func ValidatePatchPaths(patches []Patch) error {
+	for _, patch := range patches {
+		for _, file := range patch.Files {
+			for _, path := range []string{file.OldPath, file.NewPath} {
+				if path == "" {
+					continue
+				}
+				if err := ValidateTrackedPath(path); err != nil {
+					return err
+				}
+				if err := CheckSymlinkParents(".", path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Write writes the patched files to the working directory and removes the deleted ones.
+func (p *PatchedFiles) Write() error {
+	for _, path := range p.order {
+		file := p.files[path]
+		if file.Deleted {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := WriteFileWithMode(path, file.Data, file.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatRejects writes the rejected hunks of a file the way they appeared in the patch.
+func FormatRejects(result FileApplyResult) string {
+	file := result.Patch
+	file.Hunks = result.Rejected()
+	return FormatFilePatch(file)
+}
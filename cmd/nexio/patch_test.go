@@ -0,0 +1,201 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Patch_FormatPatchAndAm(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	setConfig("name", "Ada Lovelace")
+	setConfig("email", "ada@example.com")
+	commitTestFiles(t, 2)
+	base := HeadOf(mustBranchCommits(t, store))
+
+	file1, script, file2 := namespace+"file1.txt", namespace+"run.sh", namespace+"file2.txt"
+	os.WriteFile(file1, []byte("content 1\nmore\n"), 0644)
+	os.WriteFile(script, []byte("echo hi\n"), 0755)
+	runAddCommand(file1, false)
+	runAddCommand(script, false)
+	runCommitCommand("Extend file1\n\nAnd add a script.")
+	os.Remove(file2)
+	runAddCommand(file2, false)
+	runCommitCommand("Remove file2")
+
+	outgoing := t.TempDir()
+	returnCode, files := runFormatPatchCommand(base+"..HEAD", outgoing)
+	if returnCode != 2301 || len(files) != 2 {
+		t.Fatalf("Expected 2301 with 2 patches, got %d %v", returnCode, files)
+	}
+	if !strings.HasSuffix(files[0], "0001-extend-file1.patch") {
+		t.Errorf("Expected the patch to be named after its subject, got %s", files[0])
+	}
+	first, _ := os.ReadFile(files[0])
+	for _, expected := range []string{"From: Ada Lovelace <ada@example.com>\n", "Subject: [PATCH 1/2] Extend file1\n", "new file mode 100755\n", "@@ -1 +1,2 @@\n-content 1\n\\ No newline at end of file\n+content 1\n+more\n"} {
+		if !strings.Contains(string(first), expected) {
+			t.Errorf("Expected the patch to contain %q, got:\n%s", expected, first)
+		}
+	}
+
+	// Apply the patches to a repository with the same base commits, as someone else.
+	os.RemoveAll(namespace)
+	runInitCommand()
+	setConfig("name", "Someone Else")
+	commitTestFiles(t, 2)
+	returnCode, commits := runAmCommand(files)
+	if returnCode != 2501 || len(commits) != 2 {
+		t.Fatalf("Expected 2501 with 2 commits, got %d %v", returnCode, commits)
+	}
+	metadata, _ := store.ReadCommitMetadata(commits[0])
+	if metadata.Author != (Author{Name: "Ada Lovelace", Email: "ada@example.com"}) || metadata.Message != "Extend file1\n\nAnd add a script." {
+		t.Errorf("Expected the original author and message, got %v", metadata)
+	}
+	if content, _ := os.ReadFile(file1); string(content) != "content 1\nmore\n" {
+		t.Errorf("Expected the patched file1, got %q", content)
+	}
+	if _, mode, _ := ReadFileWithMode(script); mode != 0755 {
+		t.Errorf("Expected the new script to be executable, got %v", mode)
+	}
+	if FileExists(file2) || !IsStagingLogsEmpty() {
+		t.Errorf("Expected file2 to be removed and everything committed")
+	}
+
+	// The changes are already there, the patches no longer apply.
+	if returnCode, _ := runAmCommand(files); returnCode != 2503 {
+		t.Errorf("Expected 2503, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Patch_AmAlreadyApplied(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	script := namespace + "run.sh"
+	commitTestFile(t, "run.sh", "echo hi\n", "Add a script")
+	base := HeadOf(mustBranchCommits(t, store))
+	os.Chmod(script, 0755)
+	runAddCommand(script, false)
+	runCommitCommand("Make the script executable")
+	_, files := runFormatPatchCommand(base+"..HEAD", t.TempDir())
+
+	// The mode change applies cleanly, but it is already there.
+	before := mustBranchCommits(t, store)
+	returnCode, commits := runAmCommand(files)
+	if returnCode != 2501 || len(commits) != 0 {
+		t.Errorf("Expected 2501 without commits, got %d %v", returnCode, commits)
+	}
+	if after := mustBranchCommits(t, store); len(after) != len(before) || !IsStagingLogsEmpty() {
+		t.Errorf("Expected the patch to be skipped without staging or committing anything")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Patch_ApplyWithOffsetFuzzAndRejects(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	file := namespace + "lines.txt"
+	original := numberedLines(1, 30)
+	os.WriteFile(file, []byte(original), 0644)
+	lines := SplitLines([]byte(original))
+	changed := append(append([]string{}, lines[:9]...), "changed 10\n")
+	changed = append(changed, lines[10:24]...)
+	changed = append(changed, "changed 25\n")
+	changed = append(changed, lines[25:]...)
+	patch := FormatFilePatch(FilePatch{OldPath: file, NewPath: file, Hunks: DiffHunks([]byte(original), []byte(strings.Join(changed, "")), DiffContext)})
+	patchFile := t.TempDir() + "/change.diff"
+	os.WriteFile(patchFile, []byte(patch), 0644)
+
+	// Two new lines at the top move the first hunk, a changed context line needs fuzz for the second.
+	shifted := strings.Replace("new 1\nnew 2\n"+original, lines[27], "edited\n", 1)
+	os.WriteFile(file, []byte(shifted), 0644)
+	if returnCode := runApplyCommand(patchFile, true, false); returnCode != 2402 {
+		t.Errorf("Expected 2402 from the check, got %d", returnCode)
+	}
+	if content, _ := os.ReadFile(file); string(content) != shifted {
+		t.Errorf("Expected the check to change nothing")
+	}
+	if returnCode := runApplyCommand(patchFile, false, true); returnCode != 2401 {
+		t.Fatalf("Expected 2401, got %d", returnCode)
+	}
+	content, _ := os.ReadFile(file)
+	if !strings.Contains(string(content), "changed 10\n") || !strings.Contains(string(content), "changed 25\n") || !strings.Contains(string(content), "edited\n") {
+		t.Errorf("Expected both hunks to apply, got:\n%s", content)
+	}
+	if added, _, _ := LogEntryLookup("ADD", file); !added {
+		t.Errorf("Expected the patched file to be staged")
+	}
+
+	// Applying again rejects both hunks and writes them next to the file.
+	if returnCode := runApplyCommand(patchFile, true, false); returnCode != 2403 {
+		t.Errorf("Expected 2403 from the check, got %d", returnCode)
+	}
+	if returnCode := runApplyCommand(patchFile, false, false); returnCode != 2404 {
+		t.Errorf("Expected 2404, got %d", returnCode)
+	}
+	rejects, err := os.ReadFile(file + ".rej")
+	if err != nil || strings.Count(string(rejects), "@@ -") != 2 {
+		t.Errorf("Expected 2 rejected hunks, got %q (%v)", rejects, err)
+	}
+
+	empty := t.TempDir() + "/empty.diff"
+	os.WriteFile(empty, []byte("nothing to see\n"), 0644)
+	if returnCode := runApplyCommand(empty, false, false); returnCode != 2406 {
+		t.Errorf("Expected 2406, got %d", returnCode)
+	}
+	if returnCode := runApplyCommand(t.TempDir()+"/missing.diff", false, false); returnCode != 2405 {
+		t.Errorf("Expected 2405, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Patch_RejectsEscapingPaths(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	newFile := func(path string) string {
+		return "diff --git a/" + path + " b/" + path + "\nnew file mode 100644\n--- /dev/null\n+++ b/" + path + "\n@@ -0,0 +1 @@\n+pwned\n"
+	}
+	patch := t.TempDir() + "/escape.diff"
+	for _, path := range []string{"../outside.txt", ".nexio/pwn.txt", namespace + ".nexio/pwn.txt"} {
+		// The valid file is not written either, the whole patch is refused.
+		os.WriteFile(patch, []byte(newFile(namespace+"valid.txt")+newFile(path)), 0644)
+		if returnCode := runApplyCommand(patch, false, false); returnCode != 2407 {
+			t.Errorf("Expected 2407 for %s, got %d", path, returnCode)
+		}
+		if returnCode, _ := runAmCommand([]string{patch}); returnCode != 2506 {
+			t.Errorf("Expected 2506 for %s, got %d", path, returnCode)
+		}
+		if FileExists(path) || FileExists(namespace+"valid.txt") {
+			t.Errorf("Expected nothing to be written for %s", path)
+		}
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Patch_Errors(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	if returnCode, _ := runFormatPatchCommand("unknown..HEAD", t.TempDir()); returnCode != 2302 {
+		t.Errorf("Expected 2302, got %d", returnCode)
+	}
+	if returnCode, _ := runFormatPatchCommand("HEAD..HEAD", t.TempDir()); returnCode != 2303 {
+		t.Errorf("Expected 2303, got %d", returnCode)
+	}
+	os.WriteFile(namespace+"staged.txt", []byte("staged"), 0644)
+	runAddCommand(namespace+"staged.txt", false)
+	if returnCode, _ := runAmCommand([]string{"any.patch"}); returnCode != 2502 {
+		t.Errorf("Expected 2502 with staged changes, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+// The owner process cannot be checked on this platform, stale locks are only detected by age.
+const processCheckSupported = false
+
+func processAlive(pid int) bool {
+	return pid > 0
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(pullCmd)
+}
+
+var pullCmd = &cobra.Command{
+	Use:     "pull",
+	Short:   "Fetch a branch from a remote and fast-forward the local branch",
+	Example: "nexio pull\nnexio pull origin main",
+	Args:    cobra.MaximumNArgs(2),
+	Run: func(_ *cobra.Command, args []string) {
+		remoteName, branch := "", ""
+		if len(args) > 0 {
+			remoteName = args[0]
+		}
+		if len(args) > 1 {
+			branch = args[1]
+		}
+		Debug("Starting pull command: remote=%s, branch=%s", remoteName, branch)
+		runPullCommand(remoteName, branch)
+	},
+}
+
+func runPullCommand(remoteName string, branch string) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006
+	}
+
+	currentBranch := GetCurrentBranchName()
+	if branch == "" {
+		branch = currentBranch
+	}
+	if branch == currentBranch && HasUncommittedChanges() {
+		Debug("%s", PULL_RETURN_CODES[1704])
+		Fail(PULL_RETURN_CODES[1704])
+		Text("Commit your changes before pulling", "")
+		return 1704
+	}
+	remoteName, remoteBranch := ResolveRemoteBranch(remoteName, branch)
+
+	remote, transport, err := OpenRemote(remoteName)
+	if err != nil {
+		Debug("Failed to open remote: %v", err)
+		if err.Error() == REMOTE_RETURN_CODES[1304] {
+			Fail(REMOTE_RETURN_CODES[1304])
+			return 1304
+		}
+		Fail(PULL_RETURN_CODES[1706] + " " + err.Error())
+		return 1706
+	}
+	refs, err := transport.ListRefs()
+	if err != nil {
+		Debug("Failed to list remote refs: %v", err)
+		Fail(PULL_RETURN_CODES[1706] + " " + err.Error())
+		return 1706
+	}
+	remoteCommits, exists := refs.Branches[remoteBranch]
+	if !exists {
+		Debug("Remote branch does not exist: %s", remoteBranch)
+		Fail(PULL_RETURN_CODES[1705])
+		return 1705
+	}
+
+	// Fetch before comparing, so the remote-tracking ref is recorded even if the branches diverged.
+	if _, err := FetchMissingCommits(store, transport, CommitIds(remoteCommits)); err != nil {
+		Debug("Failed to fetch commits: %v", err)
+		Fail(PULL_RETURN_CODES[1706] + " " + err.Error())
+		return 1706
+	}
+	if err := store.WriteRemoteRef(remote.Name, remoteBranch, remoteCommits); err != nil {
+		Debug("Failed to write remote-tracking ref")
+		MustSucceed(err, "operation failed")
+	}
+
+	branchExists := slices.Contains(ListBranches(), branch)
+	localCommits := []Commit{}
+	if branchExists {
+		if localCommits, err = GetBranchCommits(store, branch); err != nil {
+			Debug("Failed to read branch commits")
+			MustSucceed(err, "operation failed")
+		}
+	}
+	if HeadOf(localCommits) == HeadOf(remoteCommits) || (branchExists && IsFastForward(remoteCommits, localCommits)) {
+		Debug("%s", PULL_RETURN_CODES[1701])
+		Info(PULL_RETURN_CODES[1701])
+		return 1701
+	}
+	if !IsFastForward(localCommits, remoteCommits) {
+		Debug("%s", PULL_RETURN_CODES[1703])
+		Fail(PULL_RETURN_CODES[1703])
+		return 1703
+	}
+
+	if err := UpdateRef(store, branch, HeadOf(localCommits), remoteCommits, false); err != nil {
+		Debug("Failed to update branch: %v", err)
+		MustSucceed(err, "operation failed")
+	}
+	if branch == currentBranch {
+		CheckoutFiles(HeadOf(localCommits), HeadOf(remoteCommits))
+	}
+
+	BreakLine()
+	Success(fmt.Sprintf("%s %s by %d commits", PULL_RETURN_CODES[1702], StyledBranch(branch), len(remoteCommits)-len(localCommits)))
+	BreakLine()
+	return 1702
+}
@@ -28,6 +28,14 @@ func runPurgeCommand() {
 		Fail(COMMON_RETURN_CODES[001])
 		return
 	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return
+	}
+	defer release()
 	BreakLine()
 	Warning("WARNING: Destructive Operation")
 	BreakLine()
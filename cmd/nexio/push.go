@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	pushCmd.Flags().BoolVarP(&ForcePush, "force", "f", false, "Overwrite the remote branch even if it is not a fast-forward")
+	pushCmd.Flags().BoolVarP(&SetUpstreamOnPush, "set-upstream", "u", false, "Track the remote branch after pushing")
+
+	rootCmd.AddCommand(pushCmd)
+}
+
+var (
+	ForcePush         bool
+	SetUpstreamOnPush bool
+)
+
+var pushCmd = &cobra.Command{
+	Use:     "push",
+	Short:   "Upload the commits of a branch to a remote",
+	Example: "nexio push\nnexio push -u origin feature\nnexio push origin main --force",
+	Args:    cobra.MaximumNArgs(2),
+	Run: func(_ *cobra.Command, args []string) {
+		remoteName, branch := "", ""
+		if len(args) > 0 {
+			remoteName = args[0]
+		}
+		if len(args) > 1 {
+			branch = args[1]
+		}
+		Debug("Starting push command: remote=%s, branch=%s, force=%v, set-upstream=%v", remoteName, branch, ForcePush, SetUpstreamOnPush)
+		runPushCommand(remoteName, branch, ForcePush, SetUpstreamOnPush)
+	},
+}
+
+func runPushCommand(remoteName string, branch string, force bool, setUpstream bool) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	// Pushing records the remote-tracking ref, which needs the exclusive lock.
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if branch == "" {
+		branch = GetCurrentBranchName()
+	}
+	if !slices.Contains(ListBranches(), branch) {
+		Debug("Branch does not exist: %s", branch)
+		Fail(PUSH_RETURN_CODES[1606])
+		return 1606
+	}
+	remoteName, remoteBranch := ResolveRemoteBranch(remoteName, branch)
+
+	remote, transport, err := OpenRemote(remoteName)
+	if err != nil {
+		Debug("Failed to open remote: %v", err)
+		if err.Error() == REMOTE_RETURN_CODES[1304] {
+			Fail(REMOTE_RETURN_CODES[1304])
+			return 1304
+		}
+		Fail(PUSH_RETURN_CODES[1605] + " " + err.Error())
+		return 1605
+	}
+	localCommits, err := GetBranchCommits(store, branch)
+	if err != nil {
+		Debug("Failed to read branch commits")
+		MustSucceed(err, "operation failed")
+	}
+
+	refs, err := transport.ListRefs()
+	if err != nil {
+		Debug("Failed to list remote refs: %v", err)
+		Fail(PUSH_RETURN_CODES[1605] + " " + err.Error())
+		return 1605
+	}
+	remoteCommits, exists := refs.Branches[remoteBranch]
+	if exists && HeadOf(remoteCommits) == HeadOf(localCommits) {
+		Debug("%s", PUSH_RETURN_CODES[1601])
+		recordPush(remote.Name, remoteBranch, branch, localCommits, setUpstream)
+		Info(PUSH_RETURN_CODES[1601])
+		return 1601
+	}
+	if exists && !IsFastForward(remoteCommits, localCommits) && !force {
+		Debug("Push is not a fast-forward")
+		Fail(PUSH_RETURN_CODES[1603])
+		Text("Run "+Code("nexio pull")+" first, or "+Code("nexio push --force")+" to overwrite the remote branch", "")
+		return 1603
+	}
+
+	missing, err := transport.MissingCommits(CommitIds(localCommits))
+	if err != nil {
+		Debug("Failed to negotiate commits: %v", err)
+		Fail(PUSH_RETURN_CODES[1605] + " " + err.Error())
+		return 1605
+	}
+	bundles := []CommitBundle{}
+	for _, id := range missing {
+		bundle, err := ReadCommitBundle(store, id)
+		if err != nil {
+			Debug("Failed to read commit %s", id)
+			MustSucceed(err, "operation failed")
+		}
+		bundles = append(bundles, bundle)
+	}
+	if err := transport.PushCommits(bundles); err != nil {
+		Debug("Failed to upload commits: %v", err)
+		Fail(PUSH_RETURN_CODES[1605] + " " + err.Error())
+		return 1605
+	}
+	if err := transport.UpdateRef(remoteBranch, HeadOf(remoteCommits), localCommits, force); err != nil {
+		Debug("Failed to update remote branch: %v", err)
+		if errors.Is(err, errRefChanged) {
+			Fail(PUSH_RETURN_CODES[1604])
+			return 1604
+		}
+		if errors.Is(err, errCurrentBranch) {
+			Fail(PUSH_RETURN_CODES[1607])
+			Text("Push to a repository cloned with "+Code("nexio clone --bare")+".", "  ")
+			return 1607
+		}
+		Fail(PUSH_RETURN_CODES[1605] + " " + err.Error())
+		return 1605
+	}
+
+	recordPush(remote.Name, remoteBranch, branch, localCommits, setUpstream)
+
+	BreakLine()
+	Success(PUSH_RETURN_CODES[1602])
+	Text(fmt.Sprintf("%s -> %s, %d commits uploaded", StyledBranch(branch), Code(remote.Name+"/"+remoteBranch), len(bundles)), "  ")
+	BreakLine()
+	return 1602
+}
+
+// recordPush updates the remote-tracking ref of a pushed branch and sets the upstream if requested.
+func recordPush(remote string, remoteBranch string, branch string, commits []Commit, setUpstream bool) {
+	if err := store.WriteRemoteRef(remote, remoteBranch, commits); err != nil {
+		Debug("Failed to write remote-tracking ref")
+		MustSucceed(err, "operation failed")
+	}
+	if setUpstream {
+		SetUpstream(branch, Upstream{Remote: remote, Branch: remoteBranch})
+		Text(StyledBranch(branch)+" now tracks "+Code(remote+"/"+remoteBranch), "  ")
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rebaseCmd.Flags().BoolVarP(&RebaseInteractive, "interactive", "i", false, "Edit the list of commits to replay before starting")
+	rebaseCmd.Flags().BoolVar(&RebaseContinue, "continue", false, "Commit the resolved conflicts and replay the remaining commits")
+	rebaseCmd.Flags().BoolVar(&RebaseSkip, "skip", false, "Drop the commit with conflicts and replay the remaining commits")
+	rebaseCmd.Flags().BoolVar(&RebaseAbort, "abort", false, "Give up and restore the branch as it was before the rebase")
+	rebaseCmd.MarkFlagsMutuallyExclusive("interactive", "continue", "skip", "abort")
+
+	rootCmd.AddCommand(rebaseCmd)
+}
+
+var (
+	RebaseInteractive bool
+	RebaseContinue    bool
+	RebaseSkip        bool
+	RebaseAbort       bool
+)
+
+var rebaseCmd = &cobra.Command{
+	Use:     "rebase",
+	Short:   "Replay the commits of the current branch on top of another branch",
+	Example: "nexio rebase main\nnexio rebase -i main\nnexio rebase --continue\nnexio rebase --abort",
+	Args: func(_ *cobra.Command, args []string) error {
+		if RebaseContinue || RebaseSkip || RebaseAbort {
+			return cobra.NoArgs(nil, args)
+		}
+		return cobra.ExactArgs(1)(nil, args)
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting rebase command: args=%v, interactive=%t, continue=%t, skip=%t, abort=%t", args, RebaseInteractive, RebaseContinue, RebaseSkip, RebaseAbort)
+		switch {
+		case RebaseContinue:
+			runRebaseContinueCommand()
+		case RebaseSkip:
+			runRebaseSkipCommand()
+		case RebaseAbort:
+			runRebaseAbortCommand()
+		default:
+			runRebaseCommand(args[0], RebaseInteractive)
+		}
+	},
+}
+
+func runRebaseCommand(upstream string, interactive bool) (returnCode int, commits []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006, nil
+	}
+
+	if _, err := store.ReadRebaseState(); err == nil {
+		Debug("%s", REBASE_RETURN_CODES[2903])
+		Fail(REBASE_RETURN_CODES[2903])
+		Text("Finish it with "+Code("nexio rebase --continue")+" or "+Code("nexio rebase --abort"), "")
+		return 2903, nil
+	}
+	if HasUncommittedChanges() {
+		Debug("%s", REBASE_RETURN_CODES[2905])
+		Fail(REBASE_RETURN_CODES[2905])
+		return 2905, nil
+	}
+
+	branch := GetCurrentBranchName()
+	original, err := GetBranchCommits(store, branch)
+	if err != nil {
+		Debug("Failed to read branch commits")
+		MustSucceed(err, "operation failed")
+	}
+	upstreamCommits, index, err := locateRevision(store, upstream)
+	if err == nil && upstreamCommits[index].Timestamp == "" {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		Debug("Failed to resolve upstream %s: %v", upstream, err)
+		Fail(REBASE_RETURN_CODES[2906] + " " + upstream)
+		return 2906, nil
+	}
+	onto := append([]Commit{}, upstreamCommits[:index+1]...)
+	onto[len(onto)-1].Next = ""
+	ontoId := HeadOf(onto)
+
+	fork := ForkPoint(original, onto)
+	if !interactive && fork != -1 && original[fork].Id == ontoId {
+		Info(REBASE_RETURN_CODES[2909])
+		return 2909, nil
+	}
+	steps := []RebaseStep{}
+	for i := fork + 1; i < len(original); i++ {
+		step := RebaseStep{Action: "pick", Id: original[i].Id}
+		if i > 0 {
+			step.Parent = original[i-1].Id
+		}
+		steps = append(steps, step)
+	}
+
+	if interactive {
+		subjects := map[string]string{}
+		for _, step := range steps {
+			metadata, err := store.ReadCommitMetadata(step.Id)
+			if err != nil {
+				Debug("Failed to read commit metadata of %s", step.Id)
+				MustSucceed(err, "operation failed")
+			}
+			subjects[step.Id] = FirstLine(metadata.Message)
+		}
+		todo, err := EditFile("rebase-todo", FormatRebaseTodo(steps, subjects, ontoId))
+		if err != nil {
+			Fail(REBASE_RETURN_CODES[2912] + " " + err.Error())
+			return 2912, nil
+		}
+		if steps, err = ParseRebaseTodo(todo, steps); err != nil {
+			Fail(REBASE_RETURN_CODES[2910] + " " + err.Error())
+			return 2910, nil
+		}
+		if len(steps) == 0 {
+			Warning(REBASE_RETURN_CODES[2911])
+			return 2911, nil
+		}
+	}
+
+	err = store.WithLock(BranchCommitsLock(branch), func() error {
+		return store.WriteBranchCommits(branch, onto)
+	})
+	if err != nil {
+		Debug("Failed to reset branch %s", branch)
+		MustSucceed(err, "operation failed")
+	}
+	CheckoutFiles(HeadOf(original), ontoId)
+
+	state := RebaseState{Branch: branch, Onto: ontoId, Original: original, Todo: steps, Commits: []string{}}
+	writeRebaseState(state)
+	BreakLine()
+	Info("Rebasing " + StyledBranch(branch) + " onto " + StyledCommit(ontoId))
+	return runRebaseSteps(state)
+}
+
+func runRebaseContinueCommand() (returnCode int, commits []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	state, returnCode := readRebaseState()
+	if returnCode != 0 {
+		return returnCode, nil
+	}
+	if unresolved := UnresolvedConflicts(); len(unresolved) > 0 {
+		Debug("Unresolved conflicts: %v", unresolved)
+		Fail(REBASE_RETURN_CODES[2907])
+		Tree(unresolved, true)
+		return 2907, nil
+	}
+
+	BreakLine()
+	if state.Current.Id != "" {
+		stageFiles(state.Paths)
+		state.Current, state.Paths, state.Conflicts, state.Staged = RebaseStep{}, nil, nil, nil
+		state = commitRebaseStep(state)
+		writeRebaseState(state)
+	}
+	return runRebaseSteps(state)
+}
+
+func runRebaseSkipCommand() (returnCode int, commits []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	state, returnCode := readRebaseState()
+	if returnCode != 0 {
+		return returnCode, nil
+	}
+
+	BreakLine()
+	if state.Current.Id != "" {
+		// Put back the files as they were before the skipped commit: staged by the commits squashed
+		// together so far, otherwise committed.
+		headFiles, err := commitFileMap(store, GetLastCommit().Id)
+		if err != nil {
+			Debug("Failed to read the file list of the head")
+			MustSucceed(err, "operation failed")
+		}
+		// A conflict replaced what was staged for its path, ours is the file from before the commit.
+		for _, conflict := range GetConflicts() {
+			restoreConflictOurs(conflict)
+		}
+		// The files staged when the step stopped were not staged before it.
+		for _, path := range state.Staged {
+			if staged, id, op := LogEntryLookup("*", path); staged {
+				MustSucceed(RemoveFileAndLog(id, StagingOps[op]), "operation failed")
+			}
+		}
+		for _, path := range state.Paths {
+			restoreRebasePath(path, headFiles)
+		}
+		Info("Skipped " + StyledCommit(state.Current.Id))
+		state.Current, state.Paths, state.Conflicts, state.Staged = RebaseStep{}, nil, nil, nil
+		if IsStagingLogsEmpty() {
+			state.Metadata, state.Edit = CommitMetadata{}, false
+		} else {
+			state = commitRebaseStep(state)
+		}
+		writeRebaseState(state)
+	}
+	return runRebaseSteps(state)
+}
+
+func runRebaseAbortCommand() int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	state, returnCode := readRebaseState()
+	if returnCode != 0 {
+		return returnCode
+	}
+
+	var head string
+	err = store.WithLock(BranchCommitsLock(state.Branch), func() error {
+		commits, err := GetBranchCommits(store, state.Branch)
+		if err != nil {
+			return err
+		}
+		head = HeadOf(commits)
+		if err := store.WriteBranchCommits(state.Branch, state.Original); err != nil {
+			return err
+		}
+		return RecordDroppedCommits(store, state.Branch, commits, state.Original)
+	})
+	if err != nil {
+		Debug("Failed to restore branch %s", state.Branch)
+		MustSucceed(err, "operation failed")
+	}
+	TruncateLogs()
+	if err := store.ClearStagedFiles(); err != nil {
+		Debug("Failed to clear staged files")
+		MustSucceed(err, "operation failed")
+	}
+	for _, path := range state.Paths {
+		RemoveFile(path)
+	}
+	original := HeadOf(state.Original)
+	CheckoutFiles(head, original)
+	if err := store.RemoveRebaseState(); err != nil {
+		Debug("Failed to remove rebase state")
+		MustSucceed(err, "operation failed")
+	}
+	Success(REBASE_RETURN_CODES[2908] + " Back at " + StyledCommit(original))
+	return 2908
+}
+
+// runRebaseSteps works through the todo list and stops at the first step with conflicts. The state is
+// saved after every step, so an interrupted rebase can be continued.
+func runRebaseSteps(state RebaseState) (int, []string) {
+	for len(state.Todo) > 0 {
+		step := state.Todo[0]
+		state.Todo = state.Todo[1:]
+		if step.Action == "drop" {
+			writeRebaseState(state)
+			continue
+		}
+		metadata, err := store.ReadCommitMetadata(step.Id)
+		if err != nil {
+			Debug("Failed to read commit metadata of %s", step.Id)
+			MustSucceed(err, "operation failed")
+		}
+		switch step.Action {
+		case "squash":
+			state.Metadata.Message += "\n\n" + metadata.Message
+			state.Edit = true
+		case "fixup":
+		default:
+			state.Metadata = CommitMetadata{Author: metadata.Author, Message: metadata.Message}
+			state.Edit = step.Action == "reword"
+		}
+
+		result, err := PickCommit(store, CherryPick{Id: step.Id, Parent: step.Parent})
+		if err != nil {
+			Debug("Failed to replay commit %s", step.Id)
+			MustSucceed(err, "operation failed")
+		}
+		if len(result.Conflicts) > 0 {
+			state.Staged = StagePickedPaths(result)
+			state.Current, state.Paths, state.Conflicts = step, result.Paths, result.Conflicts
+			writeRebaseState(state)
+			Fail(REBASE_RETURN_CODES[2902] + " " + StyledCommit(step.Id) + " " + FirstLine(metadata.Message))
+			Tree(result.Conflicts, true)
+			Text("Resolve them and run "+Code("nexio rebase --continue")+", drop the commit with "+Code("nexio rebase --skip")+" or give up with "+Code("nexio rebase --abort"), "")
+			BreakLine()
+			return 2902, state.Commits
+		}
+		stageFiles(result.Paths)
+		state = commitRebaseStep(state)
+		writeRebaseState(state)
+	}
+
+	// The original commits were kept reachable by the rebase state until now.
+	commits, err := GetBranchCommits(store, state.Branch)
+	if err != nil {
+		Debug("Failed to read commits of branch %s", state.Branch)
+		MustSucceed(err, "operation failed")
+	}
+	if err := RecordDroppedCommits(store, state.Branch, state.Original, commits); err != nil {
+		Debug("Failed to record dropped commits")
+		MustSucceed(err, "operation failed")
+	}
+	if err := store.RemoveRebaseState(); err != nil {
+		Debug("Failed to remove rebase state")
+		MustSucceed(err, "operation failed")
+	}
+	Success(REBASE_RETURN_CODES[2901] + " " + StyledBranch(state.Branch) + " is based on " + StyledCommit(state.Onto))
+	BreakLine()
+	return 2901, state.Commits
+}
+
+// commitRebaseStep commits the staged changes of the commit being built, unless the next step squashes
+// another commit into it.
+func commitRebaseStep(state RebaseState) RebaseState {
+	if len(state.Todo) > 0 && (state.Todo[0].Action == "squash" || state.Todo[0].Action == "fixup") {
+		return state
+	}
+	message := state.Metadata.Message
+	if state.Edit {
+		edited, err := EditFile("rebase-message", message+"\n\n# Edit the commit message, lines starting with # are ignored.\n")
+		if err != nil {
+			Warning(REBASE_RETURN_CODES[2912] + " Keeping the message: " + err.Error())
+		} else if edited != "" {
+			message = edited
+		}
+	}
+	if IsStagingLogsEmpty() {
+		Warning("Commit changes nothing, skipped: " + FirstLine(message))
+	} else {
+		_, commitId := commitStagedChanges(CommitMetadata{Author: state.Metadata.Author, Message: message})
+		state.Commits = append(state.Commits, commitId)
+		Text(StyledCommit(commitId)+" "+FirstLine(message), "  ")
+	}
+	state.Metadata, state.Edit = CommitMetadata{}, false
+	return state
+}
+
+// restoreConflictOurs resolves a conflict with our version of the file, staging it again if it differs
+// from the head.
+func restoreConflictOurs(conflict Conflict) {
+	MustSucceed(RemoveConflict(conflict.Id), "operation failed")
+	if ours, exists := conflict.Versions["ours"]; exists {
+		MustSucceed(WriteFileWithMode(conflict.Path, ours.Data, ours.Mode), "operation failed")
+	} else {
+		RemoveFile(conflict.Path)
+	}
+	runAddCommandInternal(conflict.Path, true, nil)
+}
+
+// restoreRebasePath puts back the staged version of a file, or the committed one if it is not staged.
+func restoreRebasePath(path string, headFiles map[string]FileListEntry) {
+	if staged, id, op := LogEntryLookup("*", path); staged {
+		if op == "REM" {
+			RemoveFile(path)
+			return
+		}
+		_, fileName := ParsePath(path)
+		data, mode, err := store.ReadStagedFile(StagingOps[op], id, fileName)
+		if err == nil {
+			err = WriteFileWithMode(path, data, mode)
+		}
+		MustSucceed(err, "operation failed")
+		return
+	}
+	if file, exists := headFiles[path]; exists {
+		MustSucceed(RestoreObject(file), "operation failed")
+		return
+	}
+	RemoveFile(path)
+}
+
+func writeRebaseState(state RebaseState) {
+	if err := store.WriteRebaseState(state); err != nil {
+		Debug("Failed to write rebase state")
+		MustSucceed(err, "operation failed")
+	}
+}
+
+// readRebaseState returns the rebase in progress, or a return code if there is none.
+func readRebaseState() (RebaseState, int) {
+	state, err := store.ReadRebaseState()
+	if os.IsNotExist(err) {
+		Debug("%s", REBASE_RETURN_CODES[2904])
+		Fail(REBASE_RETURN_CODES[2904])
+		return state, 2904
+	}
+	if err != nil {
+		Debug("Failed to read rebase state")
+		MustSucceed(err, "operation failed")
+	}
+	return state, 0
+}
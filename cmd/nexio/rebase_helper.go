@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// RebaseActions maps the commands of a todo list, and their one letter abbreviations, to the action.
+var RebaseActions = map[string]string{
+	"pick": "pick", "p": "pick",
+	"reword": "reword", "r": "reword",
+	"squash": "squash", "s": "squash",
+	"fixup": "fixup", "f": "fixup",
+	"drop": "drop", "d": "drop",
+}
+
+// RebaseStep is a line of the todo list: what to do with a commit and the commit before it on the
+// rebased branch, the base of the three-way merge.
+type RebaseStep struct {
+	Action string `json:"action"`
+	Id     string `json:"id"`
+	Parent string `json:"parent"`
+}
+
+// RebaseState is the progress of a rebase. Original are the commits of the branch before the rebase,
+// Current the step stopped by conflicts whose changes are in the working directory.
+type RebaseState struct {
+	Branch   string       `json:"branch"`
+	Onto     string       `json:"onto"`
+	Original []Commit     `json:"original"`
+	Todo     []RebaseStep `json:"todo"`
+	Current  RebaseStep   `json:"current"`
+	// Paths are the files the current step changed, Conflicts those of them still to resolve.
+	Paths     []string `json:"paths"`
+	Conflicts []string `json:"conflicts"`
+	// Staged are the files of the current step staged when it stopped, unstaged again by --skip.
+	Staged []string `json:"staged,omitempty"`
+	// Metadata is the commit being built from a picked commit and the ones squashed into it,
+	// Edit tells whether its message is opened in the editor before committing.
+	Metadata CommitMetadata `json:"metadata"`
+	Edit     bool           `json:"edit"`
+	// Commits are the commits created so far.
+	Commits []string `json:"commits"`
+}
+
+// ForkPoint returns the position of the last commit of the branch that is also on the upstream,
+// -1 if they have no commit in common. Both are oldest first.
+func ForkPoint(branch []Commit, upstream []Commit) int {
+	for i := len(branch) - 1; i >= 0; i-- {
+		if slices.ContainsFunc(upstream, func(commit Commit) bool { return commit.Id == branch[i].Id }) {
+			return i
+		}
+	}
+	return -1
+}
+
+// FormatRebaseTodo writes the todo list opened by `rebase -i`, one line per step with the subject of
+// its commit, followed by help on the commands.
+func FormatRebaseTodo(steps []RebaseStep, subjects map[string]string, onto string) string {
+	var todo strings.Builder
+	for _, step := range steps {
+		todo.WriteString(step.Action + " " + shortCommitId(step.Id) + " " + subjects[step.Id] + "\n")
+	}
+	fmt.Fprintf(&todo, "\n# Rebase onto %s (%d commands)\n", shortCommitId(onto), len(steps))
+	todo.WriteString(`#
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash", but discard this commit's message
+# d, drop <commit> = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+# If you remove everything, the rebase will be aborted.
+`)
+	return todo.String()
+}
+
+// ParseRebaseTodo reads an edited todo list. Commits are named by a prefix of their id and must be
+// among steps, which give the parent of every commit. A squash or fixup needs a commit before it.
+func ParseRebaseTodo(todo string, steps []RebaseStep) ([]RebaseStep, error) {
+	parsed := []RebaseStep{}
+	picked := false
+	for number, line := range strings.Split(todo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		action, exists := RebaseActions[fields[0]]
+		if !exists {
+			return nil, fmt.Errorf("line %d: unknown command %s", number+1, fields[0])
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: missing commit", number+1)
+		}
+		matches := slices.DeleteFunc(slices.Clone(steps), func(step RebaseStep) bool { return !strings.HasPrefix(step.Id, fields[1]) })
+		if len(matches) != 1 {
+			return nil, fmt.Errorf("line %d: %s is not one of the rebased commits", number+1, fields[1])
+		}
+		switch action {
+		case "squash", "fixup":
+			if !picked {
+				return nil, fmt.Errorf("line %d: cannot %s without a previous commit", number+1, action)
+			}
+		case "pick", "reword":
+			picked = true
+		}
+		parsed = append(parsed, RebaseStep{Action: action, Id: matches[0].Id, Parent: matches[0].Parent})
+	}
+	return parsed, nil
+}
+
+// EditFile opens a file with the given name and content in the editor named by $EDITOR, vi if it is
+// not set, and returns the lines of the saved file that are not comments. The file is temporary, it
+// is not part of the repository.
+func EditFile(name string, content string) (string, error) {
+	dir, err := os.MkdirTemp("", "nexio-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor may come with arguments, e.g. `code --wait`.
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.New("editor " + editor + " failed: " + err.Error())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+func shortCommitId(commitId string) string {
+	return commitId[:min(len(commitId), 10)]
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// setupRebaseBranches commits file1.txt on main, a feature branch adding file2.txt and changing file1.txt
+// to featureFile, then a change of the first line of file1.txt on main. The feature branch is checked out.
+func setupRebaseBranches(t *testing.T, featureFile string) (feature []string, main []Commit) {
+	t.Helper()
+	commitTestFile(t, "file1.txt", "one\ntwo\nthree\nfour\n", "Add file1")
+	runNewCommand("feature", "", "")
+	feature = append(feature, commitTestFile(t, "file2.txt", "new\n", "Add file2"))
+	feature = append(feature, commitTestFile(t, "file1.txt", featureFile, "Change file1 on feature"))
+	runSwitchCommand(InitBranch)
+	commitTestFile(t, "file1.txt", "ONE\ntwo\nthree\nfour\n", "Change file1 on main")
+	main = mustBranchCommits(t, store)
+	runSwitchCommand("feature")
+	return feature, main
+}
+
+func Test_Rebase(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	feature, main := setupRebaseBranches(t, "one\ntwo\nthree\nFOUR\n")
+
+	returnCode, commits := runRebaseCommand(InitBranch, false)
+	if returnCode != 2901 || len(commits) != 2 {
+		t.Fatalf("Expected both commits to be replayed, got %d %v", returnCode, commits)
+	}
+	branchCommits, _ := GetBranchCommits(store, "feature")
+	if !slices.Equal(CommitIds(branchCommits), append(CommitIds(main), commits...)) {
+		t.Errorf("Expected the replayed commits on top of main, got %v", CommitIds(branchCommits))
+	}
+	if readTestFile("file1.txt") != "ONE\ntwo\nthree\nFOUR\n" || readTestFile("file2.txt") != "new\n" {
+		t.Errorf("Expected the changes of both branches, got %q and %q", readTestFile("file1.txt"), readTestFile("file2.txt"))
+	}
+	if metadata, _ := store.ReadCommitMetadata(commits[1]); metadata.Message != "Change file1 on feature" {
+		t.Errorf("Expected the original message, got %v", metadata)
+	}
+	if slices.Contains(commits, feature[0]) || HasUncommittedChanges() {
+		t.Errorf("Expected new commits and a clean working directory")
+	}
+	if FileExists(dirs.Root + "rebase") {
+		t.Errorf("Expected the rebase state to be removed")
+	}
+	if returnCode, _ := runRebaseCommand(InitBranch, false); returnCode != 2909 {
+		t.Errorf("Expected 2909 when already up to date, got %d", returnCode)
+	}
+	if returnCode, _ := runRebaseCommand("unknown", false); returnCode != 2906 {
+		t.Errorf("Expected 2906 for an unknown upstream, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Rebase_OntoRemoteBranch(t *testing.T) {
+	setupRemote(t)
+	first := mustBranchCommits(t, store)
+	pushed := commitTestFile(t, "remote.txt", "remote\n", "Add remote.txt")
+	runPushCommand("", "", false, false)
+
+	// Diverge from the pushed commit, then catch up by rebasing onto the fetched branch.
+	store.WriteBranchCommits(InitBranch, first)
+	CheckoutFiles(pushed, HeadOf(first))
+	local := commitTestFile(t, "local.txt", "local\n", "Add local.txt")
+	runFetchCommand("")
+	upstream := DefaultRemote + "/" + InitBranch
+	if returnCode := runPullCommand("", ""); returnCode == 1702 {
+		t.Fatalf("Expected the diverged branch not to fast-forward")
+	}
+	returnCode, commits := runRebaseCommand(upstream, false)
+	if returnCode != 2901 || len(commits) != 1 {
+		t.Fatalf("Expected the local commit to be replayed onto %s, got %d %v", upstream, returnCode, commits)
+	}
+	branchCommits := mustBranchCommits(t, store)
+	if !slices.Equal(CommitIds(branchCommits), append(CommitIds(first), pushed, commits[0])) || commits[0] == local {
+		t.Errorf("Expected the local commit on top of %s, got %v", upstream, CommitIds(branchCommits))
+	}
+	if readTestFile("remote.txt") != "remote\n" || readTestFile("local.txt") != "local\n" {
+		t.Errorf("Expected the files of both sides")
+	}
+	if returnCode, _ := runRebaseCommand(upstream+"~1", false); returnCode != 2909 {
+		t.Errorf("Expected 2909 for an ancestor of %s, got %d", upstream, returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Rebase_Conflicts(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	feature, _ := setupRebaseBranches(t, "uno\ntwo\nthree\nfour\n")
+	original, _ := GetBranchCommits(store, "feature")
+
+	if returnCode, _ := runRebaseCommand(InitBranch, false); returnCode != 2902 {
+		t.Fatalf("Expected the rebase to stop on conflicts, got %d", returnCode)
+	}
+	if state, err := store.ReadRebaseState(); err != nil || state.Current.Id != feature[1] || !slices.Equal(state.Conflicts, []string{namespace + "file1.txt"}) {
+		t.Errorf("Expected the stopped step in the rebase state, got %v (%v)", state, err)
+	}
+	if returnCode, _ := runRebaseCommand(InitBranch, false); returnCode != 2903 {
+		t.Errorf("Expected 2903 while a rebase is in progress, got %d", returnCode)
+	}
+	if returnCode, _ := runRebaseContinueCommand(); returnCode != 2907 {
+		t.Errorf("Expected 2907 with unresolved conflicts, got %d", returnCode)
+	}
+
+	if returnCode := runRebaseAbortCommand(); returnCode != 2908 {
+		t.Fatalf("Expected 2908, got %d", returnCode)
+	}
+	branchCommits, _ := GetBranchCommits(store, "feature")
+	if !slices.Equal(branchCommits, original) || readTestFile("file1.txt") != "uno\ntwo\nthree\nfour\n" || HasUncommittedChanges() {
+		t.Errorf("Expected the branch as before the rebase, got %v", CommitIds(branchCommits))
+	}
+
+	// Skipping the conflicting commit keeps the other one.
+	runRebaseCommand(InitBranch, false)
+	returnCode, commits := runRebaseSkipCommand()
+	if returnCode != 2901 || len(commits) != 1 {
+		t.Fatalf("Expected one commit after skipping, got %d %v", returnCode, commits)
+	}
+	if readTestFile("file1.txt") != "ONE\ntwo\nthree\nfour\n" || readTestFile("file2.txt") != "new\n" {
+		t.Errorf("Expected main's file1.txt and the added file2.txt")
+	}
+
+	// Resolving the conflict commits the resolution.
+	runSwitchCommand(InitBranch)
+	runNewCommand("other", "", "")
+	commitTestFile(t, "file1.txt", "un\ntwo\nthree\nfour\n", "Change file1 on other")
+	runSwitchCommand(InitBranch)
+	commitTestFile(t, "file1.txt", "ONE!\ntwo\nthree\nfour\n", "Shout one")
+	runSwitchCommand("other")
+	if returnCode, _ := runRebaseCommand(InitBranch, false); returnCode != 2902 {
+		t.Fatalf("Expected the rebase to stop on conflicts, got %d", returnCode)
+	}
+	os.WriteFile(namespace+"file1.txt", []byte("ONE! un\ntwo\nthree\nfour\n"), 0644)
+	if returnCode, commits := runRebaseContinueCommand(); returnCode != 2901 || len(commits) != 1 {
+		t.Errorf("Expected the resolution to be committed, got %d %v", returnCode, commits)
+	}
+	if readTestFile("file1.txt") != "ONE! un\ntwo\nthree\nfour\n" || HasUncommittedChanges() {
+		t.Errorf("Expected the resolved file1.txt to be committed")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Rebase_StagesCleanChanges(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\ntwo\nthree\n", "Add file1")
+	runNewCommand("feature", "", "")
+	commitConflictingChange(t, "one\nzwei\nthree\n")
+	runSwitchCommand(InitBranch)
+	commitTestFile(t, "file1.txt", "one\nTWO\nthree\n", "Shout two")
+	runSwitchCommand("feature")
+
+	if returnCode, _ := runRebaseCommand(InitBranch, false); returnCode != 2902 {
+		t.Fatalf("Expected the rebase to stop on conflicts, got %d", returnCode)
+	}
+	g := namespace + "g.txt"
+	if state, _ := store.ReadRebaseState(); !IsFileStaged(g) || !slices.Equal(state.Staged, []string{g}) {
+		t.Errorf("Expected g.txt to be staged and recorded in the rebase state, got %v", state.Staged)
+	}
+
+	// Skipping the commit unstages and removes what it staged.
+	if returnCode, commits := runRebaseSkipCommand(); returnCode != 2901 || len(commits) != 0 {
+		t.Fatalf("Expected the rebase to finish without commits, got %d %v", returnCode, commits)
+	}
+	if FileExists(g) || IsFileStaged(g) || !IsStagingLogsEmpty() {
+		t.Errorf("Expected g.txt to be removed and nothing staged after skipping")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Rebase_Interactive(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\n", "Add file1")
+	runNewCommand("feature", "", "")
+	a := commitTestFile(t, "file2.txt", "a\n", "Add file2")
+	b := commitTestFile(t, "file2.txt", "a\nb\n", "Extend file2")
+	c := commitTestFile(t, "file3.txt", "c\n", "Add file3")
+	d := commitTestFile(t, "file1.txt", "one\ntwo\n", "Extend file1")
+
+	// The editor writes the todo list prepared below and rewords every message.
+	dir := t.TempDir()
+	todo := "pick " + a[:10] + "\nf " + b[:10] + " Extend file2\n# drop the next one\nd " + c[:10] + "\nreword " + d + "\n"
+	os.WriteFile(dir+"/todo", []byte(todo), 0644)
+	os.WriteFile(dir+"/editor.sh", []byte("#!/bin/sh\necho \"$1\" >> "+dir+"/edited\ncase \"$1\" in\n*todo) cp "+dir+"/todo \"$1\" ;;\n*) echo Reworded > \"$1\" ;;\nesac\n"), 0755)
+	t.Setenv("EDITOR", dir+"/editor.sh")
+
+	returnCode, commits := runRebaseCommand(InitBranch, true)
+	if returnCode != 2901 || len(commits) != 2 {
+		t.Fatalf("Expected two commits, got %d %v", returnCode, commits)
+	}
+	if metadata, _ := store.ReadCommitMetadata(commits[0]); metadata.Message != "Add file2" {
+		t.Errorf("Expected the fixup to keep the first message, got %q", metadata.Message)
+	}
+	if metadata, _ := store.ReadCommitMetadata(commits[1]); metadata.Message != "Reworded" {
+		t.Errorf("Expected the reworded message, got %q", metadata.Message)
+	}
+	if readTestFile("file2.txt") != "a\nb\n" || FileExists(namespace+"file3.txt") || readTestFile("file1.txt") != "one\ntwo\n" {
+		t.Errorf("Expected the squashed, dropped and reworded changes in the working directory")
+	}
+	// The todo list and the message are edited outside of the repository.
+	if edited, _ := os.ReadFile(dir + "/edited"); strings.Count(string(edited), "\n") != 2 || strings.Contains(string(edited), ".nexio") {
+		t.Errorf("Expected the todo list and one message edited in temporary files, got %q", edited)
+	}
+
+	os.WriteFile(dir+"/todo", []byte("# everything removed\n"), 0644)
+	if returnCode, _ := runRebaseCommand(InitBranch, true); returnCode != 2911 {
+		t.Errorf("Expected 2911 for an empty todo list, got %d", returnCode)
+	}
+	os.WriteFile(dir+"/todo", []byte("squash "+commits[0][:10]+"\n"), 0644)
+	if returnCode, _ := runRebaseCommand(InitBranch, true); returnCode != 2910 {
+		t.Errorf("Expected 2910 for a todo list starting with squash, got %d", returnCode)
+	}
+	if _, err := store.ReadRebaseState(); !os.IsNotExist(err) {
+		t.Errorf("Expected no rebase in progress, got %v", err)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_ParseRebaseTodo(t *testing.T) {
+	steps := []RebaseStep{{"pick", "aaaa1", ""}, {"pick", "aaaa2", "aaaa1"}, {"pick", "bbbb3", "aaaa2"}}
+	parsed, err := ParseRebaseTodo("p bbbb\n\n# comment\ns aaaa1 subject\ndrop aaaa2", steps)
+	expected := []RebaseStep{{"pick", "bbbb3", "aaaa2"}, {"squash", "aaaa1", ""}, {"drop", "aaaa2", "aaaa1"}}
+	if err != nil || !slices.Equal(parsed, expected) {
+		t.Errorf("Expected %v, got %v (%v)", expected, parsed, err)
+	}
+	for _, todo := range []string{"edit aaaa1", "pick aaaa", "pick cccc", "pick", "fixup aaaa1\npick aaaa2"} {
+		if _, err := ParseRebaseTodo(todo, steps); err == nil {
+			t.Errorf("Expected %q to be invalid", todo)
+		}
+	}
+}
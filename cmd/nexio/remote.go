@@ -0,0 +1,157 @@
+package main
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(remoteCmd)
+
+	remoteCmd.AddCommand(remoteAddCmd)
+	remoteCmd.AddCommand(remoteListCmd)
+	remoteCmd.AddCommand(remoteRemoveCmd)
+}
+
+var remoteCmd = &cobra.Command{
+	Use:     "remote",
+	Short:   "Manage remote repositories",
+	Example: "nexio remote\nnexio remote add origin /mnt/share/project\nnexio remote remove origin",
+	Args:    cobra.NoArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting remote command")
+		runRemoteListCommand()
+	},
+}
+
+var remoteAddCmd = &cobra.Command{
+	Use:     "add",
+	Short:   "Add a remote, a path or a file:// URL",
+	Example: "nexio remote add origin /mnt/share/project\nnexio remote add backup file:///srv/nexio/project",
+	Args:    cobra.ExactArgs(2),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting remote add command: name=%s, url=%s", args[0], args[1])
+		runRemoteAddCommand(args[0], args[1])
+	},
+}
+
+var remoteListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List remotes",
+	Example: "nexio remote list",
+	Args:    cobra.NoArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting remote list command")
+		runRemoteListCommand()
+	},
+}
+
+var remoteRemoveCmd = &cobra.Command{
+	Use:     "remove",
+	Short:   "Remove a remote",
+	Example: "nexio remote remove origin",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting remote remove command: name=%s", args[0])
+		runRemoteRemoveCommand(args[0])
+	},
+}
+
+func runRemoteAddCommand(name string, url string) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	if !IsValidRemoteName(name) || url == "" {
+		Debug("Invalid remote: name=%s, url=%s", name, url)
+		Fail(REMOTE_RETURN_CODES[1305])
+		return 1305
+	}
+	if _, exists := FindRemote(name); exists {
+		Debug("Remote already exists: %s", name)
+		Fail(REMOTE_RETURN_CODES[1302])
+		return 1302
+	}
+
+	config := GetConfig()
+	config.Remotes = append(config.Remotes, Remote{Name: name, Url: url})
+	if err := store.WriteConfig(*config); err != nil {
+		Debug("Failed to write config file")
+		MustSucceed(err, "operation failed")
+	}
+	Debug("Remote added: %s -> %s", name, url)
+	Success(REMOTE_RETURN_CODES[1301] + " " + Code(name) + " " + url)
+	return 1301
+}
+
+func runRemoteListCommand() (returnCode int, remotes []Remote) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	remotes = GetRemotes()
+	if len(remotes) == 0 {
+		Debug("%s", REMOTE_RETURN_CODES[1306])
+		Info(REMOTE_RETURN_CODES[1306])
+		Text("Add one with "+Code("nexio remote add <name> <path>"), "")
+		return 1306, remotes
+	}
+
+	lines := []string{}
+	for _, remote := range remotes {
+		lines = append(lines, remote.Name+"  "+remote.Url)
+	}
+	BreakLine()
+	Info("Remotes " + FormatFileCount(len(remotes)))
+	Tree(lines, true)
+	BreakLine()
+	return 1307, remotes
+}
+
+func runRemoteRemoveCommand(name string) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005
+	}
+	defer release()
+
+	config := GetConfig()
+	index := slices.IndexFunc(config.Remotes, func(remote Remote) bool { return remote.Name == name })
+	if index == -1 {
+		Debug("Remote does not exist: %s", name)
+		Fail(REMOTE_RETURN_CODES[1304])
+		return 1304
+	}
+	config.Remotes = slices.Delete(config.Remotes, index, index+1)
+	maps.DeleteFunc(config.Upstreams, func(_ string, upstream Upstream) bool { return upstream.Remote == name })
+	if err := store.WriteConfig(*config); err != nil {
+		Debug("Failed to write config file")
+		MustSucceed(err, "operation failed")
+	}
+	if err := UpdateRemoteRefs(store, name, RemoteRefs{}); err != nil {
+		Debug("Failed to remove remote-tracking refs")
+		MustSucceed(err, "operation failed")
+	}
+	Debug("Remote removed: %s", name)
+	Success(REMOTE_RETURN_CODES[1303] + " " + Code(name))
+	return 1303
+}
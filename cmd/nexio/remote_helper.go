@@ -0,0 +1,571 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const DefaultRemote = "origin"
+
+type Remote struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// Upstream is the remote branch a local branch tracks, e.g. `origin/main`.
+type Upstream struct {
+	Remote string `json:"remote"`
+	Branch string `json:"branch"`
+}
+
+func (u Upstream) String() string {
+	return u.Remote + "/" + u.Branch
+}
+
+// RemoteRefs lists the branches of a remote with their commits in order, oldest first.
+type RemoteRefs struct {
+	Default  string              `json:"default"`
+	Branches map[string][]Commit `json:"branches"`
+}
+
+// CommitBundle carries a commit between repositories: its documents and the objects it introduced.
+type CommitBundle struct {
+	Id       string          `json:"id"`
+	Metadata CommitMetadata  `json:"metadata"`
+	Logs     []LogFileEntry  `json:"logs"`
+	FileList []FileListEntry `json:"fileList"`
+	Objects  []BundleObject  `json:"objects"`
+}
+
+type BundleObject struct {
+	FileId   string      `json:"fileId"`
+	FileName string      `json:"fileName"`
+	Mode     os.FileMode `json:"mode"`
+	Data     []byte      `json:"data"`
+}
+
+var (
+	errNotARepository = errors.New("remote is not a Nexio repository")
+	errRefChanged     = errors.New("remote branch was updated by someone else")
+	errNotFastForward = errors.New("update is not a fast-forward of the branch")
+	errForceDisabled  = errors.New("forced updates are disabled on the remote")
+	errCurrentBranch  = errors.New("branch is checked out in the remote repository, push to a repository cloned with --bare")
+)
+
+// objectIdPattern matches the ids of commits and files, see GenRandHex.
+var objectIdPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+func IsValidObjectId(id string) bool {
+	return objectIdPattern.MatchString(id)
+}
+
+// isValidFileName reports whether name can be stored as a single path element.
+func isValidFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\x00")
+}
+
+// ValidateTrackedPath checks a path received from another repository before it is written to the
+// working directory: it must be relative, stay inside the working directory and outside of `.nexio`.
+func ValidateTrackedPath(path string) error {
+	if path == "" || filepath.IsAbs(path) || strings.Contains(path, "\x00") || slices.Contains(strings.Split(path, "/"), "..") {
+		return fmt.Errorf("invalid path %q", path)
+	}
+	if IsInternalPath(path) {
+		return fmt.Errorf("invalid path %q: inside the repository directory", path)
+	}
+	return ValidatePath(path)
+}
+
+// IsInternalPath reports whether path is in the repository directory, either of this repository or,
+// when it is written to another directory, of that one.
+func IsInternalPath(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return strings.EqualFold(first, ".nexio") || strings.HasPrefix(path+"/", dirs.Root)
+}
+
+// ValidateCommitBundle checks the ids and paths of a commit received from another repository, they
+// become paths in `.nexio` and in the working directory.
+func ValidateCommitBundle(bundle CommitBundle) error {
+	if !IsValidObjectId(bundle.Id) {
+		return fmt.Errorf("invalid commit id %q", bundle.Id)
+	}
+	for _, object := range bundle.Objects {
+		if !IsValidObjectId(object.FileId) {
+			return fmt.Errorf("commit %s: invalid file id %q", bundle.Id, object.FileId)
+		}
+		if !isValidFileName(object.FileName) {
+			return fmt.Errorf("commit %s: invalid file name %q", bundle.Id, object.FileName)
+		}
+	}
+	for _, entry := range bundle.FileList {
+		if !IsValidObjectId(entry.Id) || !IsValidObjectId(entry.CommitId) {
+			return fmt.Errorf("commit %s: invalid file list entry for %q", bundle.Id, entry.Path)
+		}
+		if err := ValidateTrackedPath(entry.Path); err != nil {
+			return fmt.Errorf("commit %s: %w", bundle.Id, err)
+		}
+	}
+	for _, entry := range bundle.Logs {
+		if err := ValidateTrackedPath(entry.Path); err != nil {
+			return fmt.Errorf("commit %s: %w", bundle.Id, err)
+		}
+		if entry.From != "" {
+			if err := ValidateTrackedPath(entry.From); err != nil {
+				return fmt.Errorf("commit %s: %w", bundle.Id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Transport talks to a remote repository.
+type Transport interface {
+	ListRefs() (RemoteRefs, error)
+	// MissingCommits returns the ids the remote does not have.
+	MissingCommits(ids []string) ([]string, error)
+	FetchCommits(ids []string) ([]CommitBundle, error)
+	PushCommits(bundles []CommitBundle) error
+	// UpdateRef replaces the commits of the branch if its head is still expectedHead, "" for a branch
+	// the remote does not have yet. Otherwise it fails with errRefChanged. Unless force is set, the
+	// new commits must be a fast-forward of the current ones.
+	UpdateRef(branch string, expectedHead string, commits []Commit, force bool) error
+}
+
+// NewTransport returns the transport for a remote URL. `http://` and `https://` URLs point to `nexio serve`,
+// `file://` URLs and plain paths to a repository on disk or a bundle file.
+func NewTransport(url string) (Transport, error) {
+	Debug("Opening transport: %s", url)
+	if IsHttpUrl(url) {
+		return NewHttpTransport(url), nil
+	}
+	path := RemotePath(url)
+	if IsBundleFile(url) {
+		return NewBundleTransport(path)
+	}
+	storage := NewFileStorage(NewDirs(strings.TrimSuffix(path, "/") + "/"))
+	if !storage.Exists() {
+		return nil, errNotARepository
+	}
+	return &LocalTransport{storage: storage}, nil
+}
+
+// RemotePath returns the directory of a `file://` or plain path remote.
+func RemotePath(url string) string {
+	path, _ := strings.CutPrefix(url, "file://")
+	return path
+}
+
+func IsValidRemoteName(name string) bool {
+	return regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`).MatchString(name)
+}
+
+func GetRemotes() []Remote {
+	return GetConfig().Remotes
+}
+
+func FindRemote(name string) (Remote, bool) {
+	for _, remote := range GetRemotes() {
+		if remote.Name == name {
+			return remote, true
+		}
+	}
+	return Remote{}, false
+}
+
+// OpenRemote resolves a remote by name, an empty name refers to `origin`.
+func OpenRemote(name string) (remote Remote, transport Transport, err error) {
+	if name == "" {
+		name = DefaultRemote
+	}
+	remote, exists := FindRemote(name)
+	if !exists {
+		return remote, nil, errors.New(REMOTE_RETURN_CODES[1304])
+	}
+	transport, err = NewTransport(remote.Url)
+	return remote, transport, err
+}
+
+// HeadOf returns the last commit id of commits ordered oldest first.
+func HeadOf(commits []Commit) string {
+	if len(commits) == 0 {
+		return ""
+	}
+	return commits[len(commits)-1].Id
+}
+
+func CommitIds(commits []Commit) []string {
+	ids := []string{}
+	for _, commit := range commits {
+		ids = append(ids, commit.Id)
+	}
+	return ids
+}
+
+// IsFastForward reports whether to only adds commits on top of from.
+func IsFastForward(from []Commit, to []Commit) bool {
+	if len(from) > len(to) {
+		return false
+	}
+	return slices.Equal(CommitIds(from), CommitIds(to[:len(from)]))
+}
+
+// GetBranchCommits returns the commits of a branch ordered oldest first.
+func GetBranchCommits(s Storage, branch string) ([]Commit, error) {
+	commits, err := s.ReadBranchCommits(branch)
+	if err != nil {
+		return nil, err
+	}
+	return sortCommitsByLinkedList(commits), nil
+}
+
+// ReadCommitBundle collects a commit and the objects it introduced.
+func ReadCommitBundle(s Storage, commitId string) (CommitBundle, error) {
+	bundle := CommitBundle{Id: commitId, Objects: []BundleObject{}}
+	var err error
+	if bundle.Metadata, err = s.ReadCommitMetadata(commitId); err != nil {
+		return bundle, err
+	}
+	if bundle.Logs, err = s.ReadCommitLogs(commitId); err != nil {
+		return bundle, err
+	}
+	if bundle.FileList, err = s.ReadFileList(commitId); err != nil {
+		return bundle, err
+	}
+	for _, entry := range bundle.FileList {
+		if entry.CommitId != commitId {
+			continue
+		}
+		_, fileName := ParsePath(entry.Path)
+		data, mode, err := s.ReadObject(commitId, entry.Id, fileName)
+		if err != nil {
+			return bundle, err
+		}
+		bundle.Objects = append(bundle.Objects, BundleObject{FileId: entry.Id, FileName: fileName, Mode: mode, Data: data})
+	}
+	return bundle, nil
+}
+
+// ValidateRemoteRefs checks the branch names and commit ids listed by another repository, branches
+// become files in `.nexio`.
+func ValidateRemoteRefs(refs RemoteRefs) error {
+	if refs.Default != "" && !IsValidBranchName(refs.Default) {
+		return fmt.Errorf("invalid branch name %q", refs.Default)
+	}
+	for branch, commits := range refs.Branches {
+		if !IsValidBranchName(branch) {
+			return fmt.Errorf("invalid branch name %q", branch)
+		}
+		for _, commit := range commits {
+			if !IsValidObjectId(commit.Id) {
+				return fmt.Errorf("branch %s: invalid commit id %q", branch, commit.Id)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteCommitBundle stores a commit received from another repository, objects first so that
+// an interrupted transfer never leaves a file list pointing to missing objects. Nothing is written
+// unless the bundle passes ValidateCommitBundle.
+func WriteCommitBundle(s Storage, bundle CommitBundle) error {
+	Debug("Writing commit bundle: %s (%d objects)", bundle.Id, len(bundle.Objects))
+	if err := ValidateCommitBundle(bundle); err != nil {
+		return err
+	}
+	for _, object := range bundle.Objects {
+		if err := s.WriteObject(bundle.Id, object.FileId, object.FileName, object.Data, object.Mode); err != nil {
+			return err
+		}
+	}
+	if err := s.WriteCommitMetadata(bundle.Id, bundle.Metadata); err != nil {
+		return err
+	}
+	if err := s.WriteCommitLogs(bundle.Id, bundle.Logs); err != nil {
+		return err
+	}
+	return s.WriteFileList(bundle.Id, bundle.FileList)
+}
+
+// FetchMissingCommits downloads the commits the storage does not have yet.
+func FetchMissingCommits(s Storage, transport Transport, ids []string) (fetched []string, err error) {
+	missing := []string{}
+	for _, id := range ids {
+		if !s.HasCommit(id) && !slices.Contains(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return missing, nil
+	}
+	bundles, err := transport.FetchCommits(missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, bundle := range bundles {
+		if err := WriteCommitBundle(s, bundle); err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// LocalTransport reads and writes a repository on disk, e.g. on a network mount.
+type LocalTransport struct {
+	storage Storage
+}
+
+func (t *LocalTransport) ListRefs() (RemoteRefs, error) {
+	release, err := t.storage.LockRepository(SharedLock)
+	if err != nil {
+		return RemoteRefs{}, err
+	}
+	defer release()
+
+	metadata, err := t.storage.ReadBranchesMetadata()
+	if err != nil {
+		return RemoteRefs{}, err
+	}
+	refs := RemoteRefs{Default: metadata.Default, Branches: map[string][]Commit{}}
+	branches, err := t.storage.ListBranches()
+	if err != nil {
+		return refs, err
+	}
+	for _, branch := range branches {
+		if refs.Branches[branch], err = GetBranchCommits(t.storage, branch); err != nil {
+			return refs, err
+		}
+	}
+	return refs, ValidateRemoteRefs(refs)
+}
+
+func (t *LocalTransport) MissingCommits(ids []string) ([]string, error) {
+	missing := []string{}
+	for _, id := range ids {
+		if !t.storage.HasCommit(id) {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+func (t *LocalTransport) FetchCommits(ids []string) ([]CommitBundle, error) {
+	release, err := t.storage.LockRepository(SharedLock)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	bundles := []CommitBundle{}
+	for _, id := range ids {
+		bundle, err := ReadCommitBundle(t.storage, id)
+		if err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+func (t *LocalTransport) PushCommits(bundles []CommitBundle) error {
+	release, err := t.storage.LockRepository(ExclusiveLock)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// A rejected bundle must not leave the ones before it behind.
+	for _, bundle := range bundles {
+		if err := ValidateCommitBundle(bundle); err != nil {
+			return err
+		}
+	}
+	for _, bundle := range bundles {
+		if err := WriteCommitBundle(t.storage, bundle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *LocalTransport) UpdateRef(branch string, expectedHead string, commits []Commit, force bool) error {
+	release, err := t.storage.LockRepository(ExclusiveLock)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Like receive.denyCurrentBranch of Git, the working tree of the remote would not follow the branch.
+	config, err := t.storage.ReadConfig()
+	if err != nil {
+		return err
+	}
+	if !config.Bare {
+		metadata, err := t.storage.ReadBranchesMetadata()
+		if err != nil {
+			return err
+		}
+		if metadata.Current == branch {
+			return errCurrentBranch
+		}
+	}
+	return UpdateRef(t.storage, branch, expectedHead, commits, force)
+}
+
+// UpdateRef replaces the commits of a branch if its head is still expectedHead, "" creates the branch.
+// The new commits must be a fast-forward of the current ones unless force is set.
+func UpdateRef(s Storage, branch string, expectedHead string, commits []Commit, force bool) error {
+	if !IsValidBranchName(branch) {
+		return errors.New("invalid branch name " + branch)
+	}
+	for _, commit := range commits {
+		if !IsValidObjectId(commit.Id) || !s.HasCommit(commit.Id) {
+			return errors.New("missing commit " + commit.Id)
+		}
+	}
+	if expectedHead == "" {
+		// Creating the branch is atomic, an existing branch may still be empty.
+		if err := s.CreateBranch(branch, commits); !os.IsExist(err) {
+			return err
+		}
+	}
+	return s.WithLock(BranchCommitsLock(branch), func() error {
+		current, err := GetBranchCommits(s, branch)
+		if err != nil {
+			return err
+		}
+		if HeadOf(current) != expectedHead {
+			Debug("Ref %s changed: expected %s, found %s", branch, expectedHead, HeadOf(current))
+			return errRefChanged
+		}
+		if !force && !IsFastForward(current, commits) {
+			Debug("Ref %s update is not a fast-forward", branch)
+			return errNotFastForward
+		}
+		if err := s.WriteBranchCommits(branch, commits); err != nil {
+			return err
+		}
+		return RecordDroppedCommits(s, branch, current, commits)
+	})
+}
+
+// CloneDirectory returns the directory a remote is cloned into by default, e.g. `project` for `/srv/project`
+// and `host` for `http://host:8080/`.
+func CloneDirectory(url string) string {
+	if IsHttpUrl(url) {
+		if parsed, err := neturl.Parse(url); err == nil {
+			if path := strings.Trim(parsed.Path, "/"); path != "" {
+				return filepath.Base(path)
+			}
+			return parsed.Hostname()
+		}
+	}
+	return filepath.Base(strings.TrimSuffix(RemotePath(url), "/"))
+}
+
+// ParseUpstream splits `<remote>/<branch>`, the branch name may contain slashes.
+func ParseUpstream(name string) (Upstream, bool) {
+	remote, branch, found := strings.Cut(name, "/")
+	if !found || !IsValidRemoteName(remote) || !IsValidBranchName(branch) {
+		return Upstream{}, false
+	}
+	return Upstream{Remote: remote, Branch: branch}, true
+}
+
+func GetUpstream(branch string) (Upstream, bool) {
+	upstream, exists := GetConfig().Upstreams[branch]
+	return upstream, exists
+}
+
+// SetUpstream makes branch track upstream, the caller must hold the exclusive repository lock.
+func SetUpstream(branch string, upstream Upstream) {
+	Debug("Setting upstream of %s to %s", branch, upstream)
+	config := GetConfig()
+	if config.Upstreams == nil {
+		config.Upstreams = map[string]Upstream{}
+	}
+	config.Upstreams[branch] = upstream
+	if err := store.WriteConfig(*config); err != nil {
+		Debug("Failed to write config file")
+		MustSucceed(err, "operation failed")
+	}
+}
+
+// ResolveRemoteBranch returns the remote and remote branch a command on branch talks to. Without an explicit
+// remote, or with the remote of the upstream, the upstream is used, otherwise the branch of the same name.
+func ResolveRemoteBranch(remoteName string, branch string) (string, string) {
+	if upstream, exists := GetUpstream(branch); exists && (remoteName == "" || remoteName == upstream.Remote) {
+		return upstream.Remote, upstream.Branch
+	}
+	if remoteName == "" {
+		remoteName = DefaultRemote
+	}
+	return remoteName, branch
+}
+
+// UpdateRemoteRefs records the branches of a remote, refs of branches the remote no longer has are removed.
+func UpdateRemoteRefs(s Storage, remote string, refs RemoteRefs) error {
+	tracked, err := s.ListRemoteRefs(remote)
+	if err != nil {
+		return err
+	}
+	for _, branch := range tracked {
+		if _, exists := refs.Branches[branch]; !exists {
+			Debug("Pruning remote-tracking ref %s/%s", remote, branch)
+			if err := s.RemoveRemoteRef(remote, branch); err != nil {
+				return err
+			}
+		}
+	}
+	for branch, commits := range refs.Branches {
+		if err := s.WriteRemoteRef(remote, branch, commits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AheadBehind counts the commits only on the local branch and only on its upstream, as of the last fetch.
+// Both lists start at the first commit, so they share a prefix up to where they diverged.
+func AheadBehind(local []Commit, upstream []Commit) (ahead int, behind int) {
+	common := 0
+	for common < len(local) && common < len(upstream) && local[common].Id == upstream[common].Id {
+		common++
+	}
+	return len(local) - common, len(upstream) - common
+}
+
+// DescribeTracking summarizes how a branch relates to its upstream, e.g. `origin/main: ahead 1, behind 2`.
+// It returns an empty string for branches without an upstream.
+func DescribeTracking(branch string) string {
+	upstream, exists := GetUpstream(branch)
+	if !exists {
+		return ""
+	}
+	remoteCommits, err := store.ReadRemoteRef(upstream.Remote, upstream.Branch)
+	if err != nil {
+		Debug("Remote-tracking ref missing: %s", upstream)
+		return upstream.String() + ": gone"
+	}
+	localCommits, err := GetBranchCommits(store, branch)
+	if err != nil {
+		Debug("Failed to read branch commits: %s", branch)
+		return upstream.String()
+	}
+	ahead, behind := AheadBehind(localCommits, sortCommitsByLinkedList(remoteCommits))
+	switch {
+	case ahead == 0 && behind == 0:
+		return upstream.String() + ": up to date"
+	case behind == 0:
+		return fmt.Sprintf("%s: ahead %d", upstream, ahead)
+	case ahead == 0:
+		return fmt.Sprintf("%s: behind %d", upstream, behind)
+	default:
+		return fmt.Sprintf("%s: ahead %d, behind %d", upstream, ahead, behind)
+	}
+}
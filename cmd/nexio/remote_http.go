@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The HTTP protocol exposes a Transport over JSON. Every request carries the `Nexio-Protocol` header,
+// requests with a different version are refused with 400.
+//
+//	GET  /refs             -> RemoteRefs
+//	POST /commits/missing  {"ids": [...]} -> {"ids": [...]}, the ids the server does not have
+//	POST /commits/fetch    {"ids": [...]} -> [CommitBundle, ...]
+//	POST /commits/push     [CommitBundle, ...] -> 204
+//	POST /refs/update      {"branch", "expectedHead", "commits", "force"} -> 204, 409 if the head changed,
+//	                       403 if it is not a fast-forward and force is not set or not allowed
+//
+// Errors are returned as {"error": "<message>"}.
+const (
+	RemoteProtocolHeader  = "Nexio-Protocol"
+	RemoteProtocolVersion = "1"
+)
+
+type commitIdsMessage struct {
+	Ids []string `json:"ids"`
+}
+
+type updateRefMessage struct {
+	Branch       string   `json:"branch"`
+	ExpectedHead string   `json:"expectedHead"`
+	Commits      []Commit `json:"commits"`
+	Force        bool     `json:"force,omitempty"`
+}
+
+type errorMessage struct {
+	Error string `json:"error"`
+}
+
+// NewRemoteHandler serves the repository in storage over the HTTP protocol. Branches can only be
+// fast-forwarded unless allowForce is set.
+func NewRemoteHandler(storage Storage, allowForce bool) http.Handler {
+	transport := &LocalTransport{storage: storage}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /refs", func(w http.ResponseWriter, r *http.Request) {
+		refs, err := transport.ListRefs()
+		writeResponse(w, refs, err)
+	})
+	mux.HandleFunc("POST /commits/missing", func(w http.ResponseWriter, r *http.Request) {
+		var request commitIdsMessage
+		if !readRequest(w, r, &request) {
+			return
+		}
+		ids, err := transport.MissingCommits(request.Ids)
+		writeResponse(w, commitIdsMessage{Ids: ids}, err)
+	})
+	mux.HandleFunc("POST /commits/fetch", func(w http.ResponseWriter, r *http.Request) {
+		var request commitIdsMessage
+		if !readRequest(w, r, &request) {
+			return
+		}
+		bundles, err := transport.FetchCommits(request.Ids)
+		writeResponse(w, bundles, err)
+	})
+	mux.HandleFunc("POST /commits/push", func(w http.ResponseWriter, r *http.Request) {
+		var bundles []CommitBundle
+		if !readRequest(w, r, &bundles) {
+			return
+		}
+		writeResponse(w, nil, transport.PushCommits(bundles))
+	})
+	mux.HandleFunc("POST /refs/update", func(w http.ResponseWriter, r *http.Request) {
+		var request updateRefMessage
+		if !readRequest(w, r, &request) {
+			return
+		}
+		if request.Force && !allowForce {
+			writeError(w, http.StatusForbidden, errForceDisabled.Error())
+			return
+		}
+		writeResponse(w, nil, transport.UpdateRef(request.Branch, request.ExpectedHead, request.Commits, request.Force))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Debug("Serving %s %s", r.Method, r.URL.Path)
+		if version := r.Header.Get(RemoteProtocolHeader); version != RemoteProtocolVersion {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported protocol version %q, expected %s", version, RemoteProtocolVersion))
+			return
+		}
+		w.Header().Set(RemoteProtocolHeader, RemoteProtocolVersion)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeResponse(w http.ResponseWriter, v any, err error) {
+	if errors.Is(err, errRefChanged) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, errNotFastForward) || errors.Is(err, errCurrentBranch) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		Debug("Request failed: %v", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorMessage{Error: message})
+}
+
+// HttpTransport talks to a repository served by `nexio serve`.
+type HttpTransport struct {
+	url    string
+	client *http.Client
+}
+
+func NewHttpTransport(url string) *HttpTransport {
+	return &HttpTransport{url: strings.TrimSuffix(url, "/"), client: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+func IsHttpUrl(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// call sends request as JSON, a nil request sends a GET, and decodes the response into response if it is not nil.
+func (t *HttpTransport) call(path string, request any, response any) error {
+	method, body := http.MethodGet, io.Reader(nil)
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		method, body = http.MethodPost, bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, t.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(RemoteProtocolHeader, RemoteProtocolVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		return errRefChanged
+	}
+	if res.StatusCode >= 300 {
+		var message errorMessage
+		if json.NewDecoder(res.Body).Decode(&message) != nil || message.Error == "" {
+			return fmt.Errorf("remote returned %s", res.Status)
+		}
+		if message.Error == errCurrentBranch.Error() {
+			return errCurrentBranch
+		}
+		return errors.New(message.Error)
+	}
+	if res.Header.Get(RemoteProtocolHeader) != RemoteProtocolVersion {
+		return errNotARepository
+	}
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(response)
+}
+
+func (t *HttpTransport) ListRefs() (RemoteRefs, error) {
+	var refs RemoteRefs
+	if err := t.call("/refs", nil, &refs); err != nil {
+		return refs, err
+	}
+	return refs, ValidateRemoteRefs(refs)
+}
+
+func (t *HttpTransport) MissingCommits(ids []string) ([]string, error) {
+	var response commitIdsMessage
+	err := t.call("/commits/missing", commitIdsMessage{Ids: ids}, &response)
+	return response.Ids, err
+}
+
+func (t *HttpTransport) FetchCommits(ids []string) ([]CommitBundle, error) {
+	var bundles []CommitBundle
+	err := t.call("/commits/fetch", commitIdsMessage{Ids: ids}, &bundles)
+	return bundles, err
+}
+
+func (t *HttpTransport) PushCommits(bundles []CommitBundle) error {
+	return t.call("/commits/push", bundles, nil)
+}
+
+func (t *HttpTransport) UpdateRef(branch string, expectedHead string, commits []Commit, force bool) error {
+	return t.call("/refs/update", updateRefMessage{Branch: branch, ExpectedHead: expectedHead, Commits: commits, Force: force}, nil)
+}
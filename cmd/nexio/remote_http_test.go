@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// setupHttpRemote serves the remote created by setupRemote and registers it as `http`.
+func setupHttpRemote(t *testing.T) (remote Storage, server *httptest.Server) {
+	t.Helper()
+	remote = setupRemote(t)
+	server = httptest.NewServer(NewRemoteHandler(remote, false))
+	t.Cleanup(server.Close)
+	if returnCode := runRemoteAddCommand("http", server.URL); returnCode != 1301 {
+		t.Fatalf("Expected 1301, got %d", returnCode)
+	}
+	return remote, server
+}
+
+func Test_Http_PushFetchPull(t *testing.T) {
+	remote, _ := setupHttpRemote(t)
+
+	if returnCode := runPushCommand("http", "", false, false); returnCode != 1601 {
+		t.Errorf("Expected 1601, got %d", returnCode)
+	}
+	first := mustBranchCommits(t, store)
+	head := commitTestChange(t, "second")
+	if returnCode := runPushCommand("http", "", false, false); returnCode != 1602 {
+		t.Errorf("Expected 1602, got %d", returnCode)
+	}
+	if HeadOf(mustBranchCommits(t, remote)) != head {
+		t.Errorf("Expected remote head %s after push", head)
+	}
+
+	// Drop the pushed commit locally, fetch and pull download it again.
+	store.WriteBranchCommits(InitBranch, first)
+	CheckoutFiles(head, HeadOf(first))
+	store.RemoveCommit(head)
+	if returnCode, fetched := runFetchCommand("http"); returnCode != 1502 || len(fetched) != 1 {
+		t.Errorf("Expected 1502 with one commit, got %d %v", returnCode, fetched)
+	}
+	if returnCode := runPullCommand("http", ""); returnCode != 1702 {
+		t.Errorf("Expected 1702, got %d", returnCode)
+	}
+	if content, _ := os.ReadFile(namespace + "file1.txt"); string(content) != "second" {
+		t.Errorf("Expected pulled working file, got %q", content)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Http_RefChanged(t *testing.T) {
+	remote, server := setupHttpRemote(t)
+
+	transport := NewHttpTransport(server.URL)
+	commits := mustBranchCommits(t, remote)
+	if err := transport.UpdateRef(InitBranch, "stale", commits, false); err != errRefChanged {
+		t.Errorf("Expected errRefChanged, got %v", err)
+	}
+	rewound := commits[:len(commits)-1]
+	if err := transport.UpdateRef(InitBranch, HeadOf(commits), rewound, false); err == nil || err.Error() != errNotFastForward.Error() {
+		t.Errorf("Expected errNotFastForward, got %v", err)
+	}
+	if err := transport.UpdateRef(InitBranch, HeadOf(commits), rewound, true); err == nil || err.Error() != errForceDisabled.Error() {
+		t.Errorf("Expected errForceDisabled, got %v", err)
+	}
+	if HeadOf(mustBranchCommits(t, remote)) != HeadOf(commits) {
+		t.Errorf("Expected the remote branch to be left alone")
+	}
+	forced := httptest.NewServer(NewRemoteHandler(remote, true))
+	defer forced.Close()
+	if err := NewHttpTransport(forced.URL).UpdateRef(InitBranch, HeadOf(commits), rewound, true); err != nil {
+		t.Errorf("Expected a forced update to succeed when allowed, got %v", err)
+	}
+	if missing, err := transport.MissingCommits([]string{HeadOf(commits), "unknown"}); err != nil || len(missing) != 1 || missing[0] != "unknown" {
+		t.Errorf("Expected only the unknown commit to be missing, got %v (%v)", missing, err)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Http_PushCurrentBranch(t *testing.T) {
+	remote, _ := setupHttpRemote(t)
+	config, _ := remote.ReadConfig()
+	config.Bare = false
+	remote.WriteConfig(config)
+
+	commitTestChange(t, "second")
+	if returnCode := runPushCommand("http", "", false, false); returnCode != 1607 {
+		t.Errorf("Expected 1607 for the branch checked out in the served repository, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Http_Clone(t *testing.T) {
+	remote, server := setupHttpRemote(t)
+
+	path := t.TempDir() + "/clone"
+	if returnCode := runCloneCommand(server.URL, path, false); returnCode != 1401 {
+		t.Fatalf("Expected 1401, got %d", returnCode)
+	}
+	cloned := NewFileStorage(NewDirs(path + "/"))
+	if HeadOf(mustBranchCommits(t, cloned)) != HeadOf(mustBranchCommits(t, remote)) {
+		t.Errorf("Expected cloned head to match the served repository")
+	}
+	if config, _ := cloned.ReadConfig(); len(config.Remotes) != 1 || config.Remotes[0].Url != server.URL {
+		t.Errorf("Expected origin to point to the server, got %v", config.Remotes)
+	}
+
+	other := httptest.NewServer(http.NotFoundHandler())
+	defer other.Close()
+	if returnCode := runCloneCommand(other.URL, t.TempDir()+"/other", false); returnCode != 1404 {
+		t.Errorf("Expected 1404 for a server that is not a Nexio repository, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Http_ProtocolVersion(t *testing.T) {
+	server := httptest.NewServer(NewRemoteHandler(NewMemoryStorage(), false))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/refs")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 without protocol header, got %d", res.StatusCode)
+	}
+}
+
+func Test_Http_RejectsInvalidBundles(t *testing.T) {
+	remote := NewMemoryStorage()
+	server := httptest.NewServer(NewRemoteHandler(remote, false))
+	defer server.Close()
+	transport := NewHttpTransport(server.URL)
+
+	commitId, fileId := GenRandHex(20), GenRandHex(20)
+	valid := CommitBundle{
+		Id:       commitId,
+		FileList: []FileListEntry{{Id: fileId, CommitId: commitId, Path: "src/a.txt"}},
+		Objects:  []BundleObject{{FileId: fileId, FileName: "a.txt", Mode: 0644, Data: []byte("a")}},
+	}
+	invalid := map[string]func(bundle *CommitBundle){
+		"commit id":      func(bundle *CommitBundle) { bundle.Id = "../../.." },
+		"file id":        func(bundle *CommitBundle) { bundle.Objects[0].FileId = "." },
+		"file name":      func(bundle *CommitBundle) { bundle.Objects[0].FileName = "../pwned.txt" },
+		"nul file name":  func(bundle *CommitBundle) { bundle.Objects[0].FileName = "a\x00.txt" },
+		"file list path": func(bundle *CommitBundle) { bundle.FileList[0].Path = "../a.txt" },
+		"absolute path":  func(bundle *CommitBundle) { bundle.FileList[0].Path = "/etc/a.txt" },
+		"log path":       func(bundle *CommitBundle) { bundle.Logs = []LogFileEntry{{Op: "ADD", Path: "src/../../a.txt"}} },
+	}
+	for name, corrupt := range invalid {
+		bundle := valid
+		bundle.Objects = []BundleObject{valid.Objects[0]}
+		bundle.FileList = []FileListEntry{valid.FileList[0]}
+		corrupt(&bundle)
+		// The valid bundle sent first must not be written either.
+		if err := transport.PushCommits([]CommitBundle{valid, bundle}); err == nil {
+			t.Errorf("Expected a bundle with an invalid %s to be rejected", name)
+		}
+		if remote.HasCommit(commitId) {
+			t.Fatalf("Expected nothing to be written for an invalid %s", name)
+		}
+	}
+	if err := transport.PushCommits([]CommitBundle{valid}); err != nil || !remote.HasCommit(commitId) {
+		t.Errorf("Expected the valid bundle to be written, got %v", err)
+	}
+}
+
+func Test_ServeUrl(t *testing.T) {
+	for addr, expected := range map[string]string{
+		":8080":           "http://<host>:8080",
+		"127.0.0.1:18080": "http://127.0.0.1:18080",
+		"server:9000":     "http://server:9000",
+	} {
+		if url := ServeUrl(addr); url != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, addr, url)
+		}
+	}
+}
+
+func Test_CloneDirectory(t *testing.T) {
+	for url, expected := range map[string]string{
+		"/srv/project/":             "project",
+		"file:///srv/project":       "project",
+		"http://host:8080":          "host",
+		"https://host/repos/nexio/": "nexio",
+	} {
+		if directory := CloneDirectory(url); directory != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, url, directory)
+		}
+	}
+}
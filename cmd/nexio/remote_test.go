@@ -0,0 +1,418 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+// setupRemote commits to the test repository and clones it into a temporary directory registered as `origin`.
+func setupRemote(t *testing.T) (remote Storage) {
+	t.Helper()
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	path := t.TempDir() + "/remote"
+	if returnCode := runCloneCommand(namespace, path, true); returnCode != 1401 {
+		t.Fatalf("Expected 1401, got %d", returnCode)
+	}
+	if returnCode := runRemoteAddCommand(DefaultRemote, path); returnCode != 1301 {
+		t.Fatalf("Expected 1301, got %d", returnCode)
+	}
+	return NewFileStorage(NewDirs(path + "/"))
+}
+
+func commitTestChange(t *testing.T, content string) string {
+	t.Helper()
+	file := namespace + "file1.txt"
+	os.WriteFile(file, []byte(content), 0644)
+	runAddCommand(file, false)
+	returnCode, commitId := runCommitCommand(content)
+	if returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+	return commitId
+}
+
+func Test_Remote_AddListRemove(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+
+	if returnCode, _ := runRemoteListCommand(); returnCode != 1306 {
+		t.Errorf("Expected 1306, got %d", returnCode)
+	}
+	if returnCode := runRemoteAddCommand("origin", "/srv/project"); returnCode != 1301 {
+		t.Errorf("Expected 1301, got %d", returnCode)
+	}
+	if returnCode := runRemoteAddCommand("origin", "/srv/other"); returnCode != 1302 {
+		t.Errorf("Expected 1302, got %d", returnCode)
+	}
+	if returnCode := runRemoteAddCommand("-bad", "/srv/other"); returnCode != 1305 {
+		t.Errorf("Expected 1305, got %d", returnCode)
+	}
+	returnCode, remotes := runRemoteListCommand()
+	if returnCode != 1307 || len(remotes) != 1 || remotes[0].Url != "/srv/project" {
+		t.Errorf("Expected origin to be listed, got %d %v", returnCode, remotes)
+	}
+	if returnCode := runRemoteRemoveCommand("origin"); returnCode != 1303 {
+		t.Errorf("Expected 1303, got %d", returnCode)
+	}
+	if returnCode := runRemoteRemoveCommand("origin"); returnCode != 1304 {
+		t.Errorf("Expected 1304, got %d", returnCode)
+	}
+	if returnCode := runPushCommand("origin", "", false, false); returnCode != 1304 {
+		t.Errorf("Expected push to unknown remote to return 1304, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Clone(t *testing.T) {
+	remote := setupRemote(t)
+
+	config, err := remote.ReadConfig()
+	if err != nil || len(config.Remotes) != 1 || config.Remotes[0].Name != DefaultRemote {
+		t.Errorf("Expected clone to record origin, got %v (%v)", config.Remotes, err)
+	}
+	local, _ := GetBranchCommits(store, InitBranch)
+	cloned, _ := GetBranchCommits(remote, InitBranch)
+	if HeadOf(cloned) != HeadOf(local) {
+		t.Errorf("Expected cloned head %s, got %s", HeadOf(local), HeadOf(cloned))
+	}
+	if upstream := config.Upstreams[InitBranch]; upstream.String() != DefaultRemote+"/"+InitBranch {
+		t.Errorf("Expected clone to track origin/%s, got %v", InitBranch, config.Upstreams)
+	}
+	if metadata, _ := remote.ReadBranchesMetadata(); metadata.Current != InitBranch {
+		t.Errorf("Expected clone to check out %s, got %s", InitBranch, metadata.Current)
+	}
+	if returnCode := runCloneCommand(namespace, namespace, false); returnCode != 1402 {
+		t.Errorf("Expected 1402 for a non-empty destination, got %d", returnCode)
+	}
+	if returnCode := runCloneCommand(t.TempDir(), t.TempDir()+"/clone", false); returnCode != 1403 {
+		t.Errorf("Expected 1403 for a directory without a repository, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Clone_RejectsEscapingPaths(t *testing.T) {
+	remote := setupRemote(t)
+	config, _ := store.ReadConfig()
+	url := config.Remotes[0].Url
+	commits, _ := GetBranchCommits(remote, InitBranch)
+	head := HeadOf(commits)
+	fileList, _ := remote.ReadFileList(head)
+	outside := t.TempDir()
+
+	// A symlink out of the clone, followed by a file written through it.
+	symlink := FileListEntry{Id: GenRandHex(20), CommitId: head, Path: "esc", Mode: 0777, Type: "symlink"}
+	file := FileListEntry{Id: GenRandHex(20), CommitId: head, Path: "esc/pwned.txt", Mode: 0644, Type: "file"}
+	remote.WriteObject(head, symlink.Id, "esc", []byte(outside), os.ModeSymlink|0777)
+	remote.WriteObject(head, file.Id, "pwned.txt", []byte("pwned"), 0644)
+	remote.WriteFileList(head, append(slices.Clone(fileList), symlink, file))
+	if returnCode := runCloneCommand(url, t.TempDir()+"/clone", false); returnCode != 1404 {
+		t.Errorf("Expected 1404 for a path through a symlink, got %d", returnCode)
+	}
+	if FileExists(outside + "/pwned.txt") {
+		t.Error("Expected nothing to be written outside of the clone")
+	}
+
+	// A file in the repository directory of the clone.
+	internal := FileListEntry{Id: GenRandHex(20), CommitId: head, Path: ".nexio/config.json", Mode: 0644, Type: "file"}
+	remote.WriteObject(head, internal.Id, "config.json", []byte("{}"), 0644)
+	remote.WriteFileList(head, append(slices.Clone(fileList), internal))
+	if returnCode := runCloneCommand(url, t.TempDir()+"/clone", false); returnCode != 1404 {
+		t.Errorf("Expected 1404 for a path in .nexio, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Push(t *testing.T) {
+	remote := setupRemote(t)
+
+	if returnCode := runPushCommand("", "", false, false); returnCode != 1601 {
+		t.Errorf("Expected 1601, got %d", returnCode)
+	}
+	head := commitTestChange(t, "second")
+	if returnCode := runPushCommand("", "", false, false); returnCode != 1602 {
+		t.Errorf("Expected 1602, got %d", returnCode)
+	}
+	if commits, _ := GetBranchCommits(remote, InitBranch); HeadOf(commits) != head {
+		t.Errorf("Expected remote head %s, got %s", head, HeadOf(commits))
+	}
+	if returnCode := runPushCommand("", "missing", false, false); returnCode != 1606 {
+		t.Errorf("Expected 1606, got %d", returnCode)
+	}
+
+	// Rewind the local branch and commit something else, the remote no longer fast-forwards.
+	commits, _ := GetBranchCommits(store, InitBranch)
+	commits = commits[:1]
+	commits[0].Next = ""
+	store.WriteBranchCommits(InitBranch, commits)
+	CheckoutFiles(head, commits[0].Id)
+	diverged := commitTestChange(t, "diverged")
+	if returnCode := runPushCommand("", "", false, false); returnCode != 1603 {
+		t.Errorf("Expected 1603, got %d", returnCode)
+	}
+	if returnCode := runPushCommand("", "", true, false); returnCode != 1602 {
+		t.Errorf("Expected forced push to return 1602, got %d", returnCode)
+	}
+	if commits, _ := GetBranchCommits(remote, InitBranch); HeadOf(commits) != diverged {
+		t.Errorf("Expected remote head %s after forced push, got %s", diverged, HeadOf(commits))
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_PushRefChanged(t *testing.T) {
+	remote := setupRemote(t)
+
+	commits, _ := GetBranchCommits(remote, InitBranch)
+	if err := UpdateRef(remote, InitBranch, "stale", commits, false); err != errRefChanged {
+		t.Errorf("Expected errRefChanged, got %v", err)
+	}
+	if err := UpdateRef(remote, "feature", "", commits, false); err != nil {
+		t.Errorf("Expected new branch to be created, got %v", err)
+	}
+	if err := UpdateRef(remote, "feature", "", commits, false); err != errRefChanged {
+		t.Errorf("Expected creating an existing non-empty branch to fail, got %v", err)
+	}
+	rewound := commits[:len(commits)-1]
+	if err := UpdateRef(remote, "feature", HeadOf(commits), rewound, false); err != errNotFastForward {
+		t.Errorf("Expected an update that is not a fast-forward to fail, got %v", err)
+	}
+	if err := UpdateRef(remote, "feature", HeadOf(commits), rewound, true); err != nil {
+		t.Errorf("Expected a forced update to succeed, got %v", err)
+	}
+	for _, branch := range []string{"../../escape", "a/../b", ""} {
+		if err := UpdateRef(remote, branch, "", commits, false); err == nil {
+			t.Errorf("Expected invalid branch name %q to be refused", branch)
+		}
+	}
+	if err := UpdateRef(remote, "other", "", []Commit{{Id: "../commits"}}, false); err == nil {
+		t.Errorf("Expected an invalid commit id to be refused")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_PushCurrentBranch(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 1)
+
+	path := t.TempDir() + "/remote"
+	if returnCode := runCloneCommand(namespace, path, false); returnCode != 1401 {
+		t.Fatalf("Expected 1401, got %d", returnCode)
+	}
+	runRemoteAddCommand(DefaultRemote, path)
+	remote := NewFileStorage(NewDirs(path + "/"))
+	before := mustBranchCommits(t, remote)
+
+	commitTestChange(t, "second")
+	if returnCode := runPushCommand("", "", false, false); returnCode != 1607 {
+		t.Errorf("Expected 1607 for the branch checked out in the remote, got %d", returnCode)
+	}
+	if HeadOf(mustBranchCommits(t, remote)) != HeadOf(before) {
+		t.Errorf("Expected the checked out branch of the remote to be left unchanged")
+	}
+	if returnCode := runPushCommand("", "feature", false, false); returnCode != 1606 {
+		t.Errorf("Expected 1606, got %d", returnCode)
+	}
+	runNewCommand("feature", "", "")
+	if returnCode := runPushCommand("", "feature", false, false); returnCode != 1602 {
+		t.Errorf("Expected other branches to be pushed, got %d", returnCode)
+	}
+
+	bare := t.TempDir() + "/bare"
+	if returnCode := runCloneCommand(namespace, bare, true); returnCode != 1401 {
+		t.Fatalf("Expected 1401, got %d", returnCode)
+	}
+	if entries, _ := os.ReadDir(bare); len(entries) != 1 {
+		t.Errorf("Expected a bare clone to only hold .nexio, got %d entries", len(entries))
+	}
+	if config, _ := NewFileStorage(NewDirs(bare + "/")).ReadConfig(); !config.Bare {
+		t.Errorf("Expected a bare clone to be marked as bare")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_FetchAndPull(t *testing.T) {
+	setupRemote(t)
+
+	first, _ := GetBranchCommits(store, InitBranch)
+	head := commitTestChange(t, "second")
+	runPushCommand("", "", false, false)
+
+	// Rewind the local branch, pulling brings the pushed commit back.
+	store.WriteBranchCommits(InitBranch, first)
+	CheckoutFiles(head, HeadOf(first))
+	if content, _ := os.ReadFile(namespace + "file1.txt"); string(content) != "content 1" {
+		t.Fatalf("Expected rewound working file, got %q", content)
+	}
+	if returnCode, fetched := runFetchCommand(""); returnCode != 1501 || len(fetched) != 0 {
+		t.Errorf("Expected 1501 as the commit is still stored locally, got %d %v", returnCode, fetched)
+	}
+
+	os.WriteFile(namespace+"file1.txt", []byte("uncommitted"), 0644)
+	if returnCode := runPullCommand("", ""); returnCode != 1704 {
+		t.Errorf("Expected 1704, got %d", returnCode)
+	}
+	os.WriteFile(namespace+"file1.txt", []byte("content 1"), 0644)
+
+	if returnCode := runPullCommand("", ""); returnCode != 1702 {
+		t.Errorf("Expected 1702, got %d", returnCode)
+	}
+	if commits, _ := GetBranchCommits(store, InitBranch); HeadOf(commits) != head {
+		t.Errorf("Expected local head %s after pull, got %s", head, HeadOf(commits))
+	}
+	if content, _ := os.ReadFile(namespace + "file1.txt"); string(content) != "second" {
+		t.Errorf("Expected pulled working file, got %q", content)
+	}
+	if returnCode := runPullCommand("", ""); returnCode != 1701 {
+		t.Errorf("Expected 1701, got %d", returnCode)
+	}
+	if returnCode := runPullCommand("", "missing"); returnCode != 1705 {
+		t.Errorf("Expected 1705, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_FetchNewCommits(t *testing.T) {
+	remote := setupRemote(t)
+
+	// A commit that only the remote has.
+	bundle, _ := ReadCommitBundle(store, HeadOf(mustBranchCommits(t, store)))
+	bundle.Id = GenRandHex(20)
+	for i := range bundle.FileList {
+		bundle.FileList[i].CommitId = bundle.Id
+	}
+	WriteCommitBundle(remote, bundle)
+	commits := mustBranchCommits(t, remote)
+	commits[len(commits)-1].Next = bundle.Id
+	remote.CreateBranch("feature", append(commits, Commit{Id: bundle.Id, Timestamp: GetTimestamp()}))
+
+	returnCode, fetched := runFetchCommand("")
+	if returnCode != 1502 || len(fetched) != 1 || fetched[0] != bundle.Id {
+		t.Errorf("Expected the remote-only commit to be fetched, got %d %v", returnCode, fetched)
+	}
+	if !store.HasCommit(bundle.Id) {
+		t.Errorf("Expected fetched commit to be stored")
+	}
+	if returnCode := runPullCommand("", "feature"); returnCode != 1702 {
+		t.Errorf("Expected pulling a new branch to return 1702, got %d", returnCode)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func mustBranchCommits(t *testing.T, s Storage) []Commit {
+	t.Helper()
+	commits, err := GetBranchCommits(s, InitBranch)
+	if err != nil {
+		t.Fatalf("Failed to read branch commits: %v", err)
+	}
+	return commits
+}
+
+func Test_AheadBehind(t *testing.T) {
+	a, b, c, d := Commit{Id: "a"}, Commit{Id: "b"}, Commit{Id: "c"}, Commit{Id: "d"}
+	cases := []struct {
+		local, upstream []Commit
+		ahead, behind   int
+	}{
+		{[]Commit{a, b}, []Commit{a, b}, 0, 0},
+		{[]Commit{a, b, c}, []Commit{a}, 2, 0},
+		{[]Commit{a}, []Commit{a, b, c}, 0, 2},
+		{[]Commit{a, b, c}, []Commit{a, d}, 2, 1},
+		{nil, []Commit{a}, 0, 1},
+	}
+	for _, c := range cases {
+		if ahead, behind := AheadBehind(c.local, c.upstream); ahead != c.ahead || behind != c.behind {
+			t.Errorf("Expected ahead %d, behind %d for %v and %v, got %d, %d", c.ahead, c.behind, c.local, c.upstream, ahead, behind)
+		}
+	}
+}
+
+func Test_Upstream(t *testing.T) {
+	remote := setupRemote(t)
+
+	if returnCode := runSetUpstreamCommand("origin", ""); returnCode != 218 {
+		t.Errorf("Expected 218, got %d", returnCode)
+	}
+	if returnCode := runSetUpstreamCommand("origin/main", "missing"); returnCode != 219 {
+		t.Errorf("Expected 219, got %d", returnCode)
+	}
+	if returnCode := runSetUpstreamCommand("backup/main", ""); returnCode != 1304 {
+		t.Errorf("Expected 1304, got %d", returnCode)
+	}
+	if returnCode := runSetUpstreamCommand("origin/main", ""); returnCode != 220 {
+		t.Errorf("Expected 220 before fetching, got %d", returnCode)
+	}
+	if DescribeTracking(InitBranch) != "" {
+		t.Errorf("Expected no tracking without an upstream")
+	}
+
+	runFetchCommand("")
+	if returnCode := runSetUpstreamCommand("origin/main", ""); returnCode != 221 {
+		t.Errorf("Expected 221, got %d", returnCode)
+	}
+	if tracking := DescribeTracking(InitBranch); tracking != "origin/main: up to date" {
+		t.Errorf("Expected up to date, got %q", tracking)
+	}
+
+	commitTestChange(t, "second")
+	if tracking := DescribeTracking(InitBranch); tracking != "origin/main: ahead 1" {
+		t.Errorf("Expected ahead 1, got %q", tracking)
+	}
+	runPushCommand("", "", false, false)
+	if tracking := DescribeTracking(InitBranch); tracking != "origin/main: up to date" {
+		t.Errorf("Expected up to date after push, got %q", tracking)
+	}
+
+	// Someone else pushes to the remote, fetch reveals it.
+	commits := mustBranchCommits(t, remote)
+	bundle, _ := ReadCommitBundle(remote, HeadOf(commits))
+	bundle.Id = GenRandHex(20)
+	WriteCommitBundle(remote, bundle)
+	commits[len(commits)-1].Next = bundle.Id
+	remote.WriteBranchCommits(InitBranch, append(commits, Commit{Id: bundle.Id, Timestamp: GetTimestamp()}))
+	commitTestChange(t, "third")
+	runFetchCommand("")
+	if tracking := DescribeTracking(InitBranch); tracking != "origin/main: ahead 1, behind 1" {
+		t.Errorf("Expected ahead 1, behind 1, got %q", tracking)
+	}
+
+	if returnCode := runRemoteRemoveCommand("origin"); returnCode != 1303 {
+		t.Errorf("Expected 1303, got %d", returnCode)
+	}
+	if _, exists := GetUpstream(InitBranch); exists {
+		t.Errorf("Expected upstream to be removed with the remote")
+	}
+	if refs, _ := store.ListRemoteRefs("origin"); len(refs) != 0 {
+		t.Errorf("Expected remote-tracking refs to be removed with the remote, got %v", refs)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_PushSetUpstream(t *testing.T) {
+	setupRemote(t)
+
+	runNewCommand("feature", "", "")
+	if returnCode := runPushCommand("", "", false, true); returnCode != 1602 {
+		t.Errorf("Expected 1602, got %d", returnCode)
+	}
+	if upstream, exists := GetUpstream("feature"); !exists || upstream.String() != "origin/feature" {
+		t.Errorf("Expected feature to track origin/feature, got %v", upstream)
+	}
+	if tracking := DescribeTracking("feature"); tracking != "origin/feature: up to date" {
+		t.Errorf("Expected up to date, got %q", tracking)
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -27,17 +27,25 @@ func runRemoveCommand(filePath string) {
 		return
 	}
 
-	isLogged, logId, operation := LogEntryLookup("*", filePath)
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return
+	}
+	defer release()
 
-	ops := map[string]string{
-		"ADD": "added",
-		"MOD": "modified",
-		"REM": "removed",
+	if RefuseDuringBisect() {
+		return
 	}
 
+	isLogged, logId, operation := LogEntryLookup("*", filePath)
+
 	if isLogged {
-		RemoveFile(dirs.Staging + ops[operation] + "/" + logId)
-		RemoveLogEntry(logId)
+		if err := RemoveFileAndLog(logId, StagingOps[operation]); err != nil {
+			Debug("Failed to remove staged file: %v", err)
+			MustSucceed(err, "operation failed")
+		}
 		Success(REMOVE_RETURN_CODES[801])
 	} else {
 		Info(REMOVE_RETURN_CODES[802])
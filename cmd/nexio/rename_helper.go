@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultRenameThreshold is the similarity, in percent, from which an added file is taken as renamed
+// or copied from another one when rename-threshold is not configured.
+const DefaultRenameThreshold = 50
+
+// RenameFile is a file taking part in rename detection.
+type RenameFile struct {
+	Path string
+	Data []byte
+}
+
+// Rename is a file added as the rename of a removed file, or as a copy of a kept one. Similarity is
+// in percent.
+type Rename struct {
+	From       string
+	To         string
+	Similarity int
+	Copy       bool
+}
+
+// RenameThreshold returns the configured similarity threshold of rename detection.
+func RenameThreshold() int {
+	threshold, err := ParseThreshold(GetConfig().RenameThreshold)
+	if err != nil {
+		Debug("Invalid rename threshold, using the default: %v", err)
+		return DefaultRenameThreshold
+	}
+	return threshold
+}
+
+// ParseThreshold reads a percentage such as `60` or `60%`, the default if it is empty.
+func ParseThreshold(value string) (int, error) {
+	if value == "" {
+		return DefaultRenameThreshold, nil
+	}
+	threshold, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || threshold < 0 || threshold > 100 {
+		return 0, errors.New("invalid threshold " + value + ", expected a percentage from 0 to 100")
+	}
+	return threshold, nil
+}
+
+// Similarity tells how much of the larger of two files the other one shares, in percent. It counts
+// the bytes of the lines found in both, wherever they are, so moved blocks still match.
+func Similarity(a []byte, b []byte) int {
+	if len(a) == 0 && len(b) == 0 {
+		return 100
+	}
+	counts := map[string]int{}
+	for _, line := range SplitLines(a) {
+		counts[line]++
+	}
+	common := 0
+	for _, line := range SplitLines(b) {
+		if counts[line] > 0 {
+			counts[line]--
+			common += len(line)
+		}
+	}
+	return common * 100 / max(len(a), len(b))
+}
+
+// DetectRenames pairs added files with the removed file they were renamed from, or with one of the
+// sources, files that were kept, they were copied from. Files with identical content are paired first,
+// then the most similar ones from threshold on. A removed file is renamed once, empty files are left
+// alone. The renames are ordered by their new path.
+func DetectRenames(removed []RenameFile, added []RenameFile, sources []RenameFile, threshold int) []Rename {
+	renames := []Rename{}
+	renamed := map[string]bool{}
+	removedByHash := map[[32]byte][]string{}
+	for _, file := range removed {
+		if len(file.Data) > 0 {
+			sum := sha256.Sum256(file.Data)
+			removedByHash[sum] = append(removedByHash[sum], file.Path)
+		}
+	}
+	sourceByHash := map[[32]byte]string{}
+	for _, file := range sources {
+		if sum := sha256.Sum256(file.Data); len(file.Data) > 0 && sourceByHash[sum] == "" {
+			sourceByHash[sum] = file.Path
+		}
+	}
+
+	pending := slices.DeleteFunc(slices.Clone(added), func(file RenameFile) bool {
+		if len(file.Data) == 0 {
+			return true
+		}
+		sum := sha256.Sum256(file.Data)
+		if paths := removedByHash[sum]; len(paths) > 0 {
+			removedByHash[sum] = paths[1:]
+			renamed[paths[0]] = true
+			renames = append(renames, Rename{From: paths[0], To: file.Path, Similarity: 100})
+			return true
+		}
+		if path, exists := sourceByHash[sum]; exists {
+			renames = append(renames, Rename{From: path, To: file.Path, Similarity: 100, Copy: true})
+			return true
+		}
+		return false
+	})
+
+	candidates := []Rename{}
+	for _, file := range pending {
+		for _, from := range removed {
+			if !renamed[from.Path] && len(from.Data) > 0 {
+				candidates = appendSimilar(candidates, from, file, threshold, false)
+			}
+		}
+		for _, from := range sources {
+			candidates = appendSimilar(candidates, from, file, threshold, true)
+		}
+	}
+	// The most similar first, renames before copies and then by path so the result is stable.
+	slices.SortFunc(candidates, func(a, b Rename) int {
+		if a.Similarity != b.Similarity {
+			return b.Similarity - a.Similarity
+		}
+		if a.Copy != b.Copy {
+			if a.Copy {
+				return 1
+			}
+			return -1
+		}
+		if a.To != b.To {
+			return strings.Compare(a.To, b.To)
+		}
+		return strings.Compare(a.From, b.From)
+	})
+	paired := map[string]bool{}
+	for _, candidate := range candidates {
+		if paired[candidate.To] || !candidate.Copy && renamed[candidate.From] {
+			continue
+		}
+		paired[candidate.To] = true
+		if !candidate.Copy {
+			renamed[candidate.From] = true
+		}
+		renames = append(renames, candidate)
+	}
+
+	slices.SortFunc(renames, func(a, b Rename) int { return strings.Compare(a.To, b.To) })
+	return renames
+}
+
+func appendSimilar(candidates []Rename, from RenameFile, to RenameFile, threshold int, copied bool) []Rename {
+	// Files whose sizes differ too much cannot reach the threshold.
+	if min(len(from.Data), len(to.Data))*100 < threshold*max(len(from.Data), len(to.Data)) {
+		return candidates
+	}
+	if similarity := Similarity(from.Data, to.Data); similarity >= threshold {
+		candidates = append(candidates, Rename{From: from.Path, To: to.Path, Similarity: similarity, Copy: copied})
+	}
+	return candidates
+}
+
+// DetectCommitRenames finds the renames and copies of a commit, comparing its files with those of the
+// parent. The renames logged by `nexio mv` are taken as they are.
+func DetectCommitRenames(s Storage, parentId string, commitId string) ([]Rename, error) {
+	oldFiles, err := commitFileMap(s, parentId)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := commitFileMap(s, commitId)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := s.ReadCommitLogs(commitId)
+	if err != nil {
+		return nil, err
+	}
+	read := func(files map[string]FileListEntry, path string) (RenameFile, error) {
+		data, _, _, err := readCommitFile(s, files, path)
+		return RenameFile{Path: path, Data: data}, err
+	}
+
+	logged := []Rename{}
+	moved := map[string]bool{}
+	for _, entry := range logs {
+		if entry.Op != "REN" {
+			continue
+		}
+		from, err := read(oldFiles, entry.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := read(newFiles, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		logged = append(logged, Rename{From: entry.From, To: entry.Path, Similarity: Similarity(from.Data, to.Data)})
+		moved[entry.From], moved[entry.Path] = true, true
+	}
+
+	var removed, added, sources []RenameFile
+	for path, file := range oldFiles {
+		newFile, kept := newFiles[path]
+		if moved[path] || kept && newFile.Id == file.Id {
+			continue
+		}
+		old, err := read(oldFiles, path)
+		if err != nil {
+			return nil, err
+		}
+		if kept {
+			sources = append(sources, old)
+		} else {
+			removed = append(removed, old)
+		}
+	}
+	for path := range newFiles {
+		if _, existed := oldFiles[path]; existed || moved[path] {
+			continue
+		}
+		file, err := read(newFiles, path)
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, file)
+	}
+	sortRenameFiles(removed, added, sources)
+	renames := append(logged, DetectRenames(removed, added, sources, RenameThreshold())...)
+	slices.SortFunc(renames, func(a, b Rename) int { return strings.Compare(a.To, b.To) })
+	return renames, nil
+}
+
+// DetectStagedRenames finds the renames and copies among the staged changes: removed files are
+// paired with added ones, and added files with the committed version of modified ones.
+func DetectStagedRenames(entries []LogFileEntry) []Rename {
+	var removed, added, sources []RenameFile
+	for _, entry := range entries {
+		_, fileName := ParsePath(entry.Path)
+		switch entry.Op {
+		case "ADD", "REM":
+			data, _, err := store.ReadStagedFile(StagingOps[entry.Op], entry.Id, fileName)
+			if err != nil {
+				Debug("Failed to read staged file %s: %v", entry.Path, err)
+				continue
+			}
+			if entry.Op == "ADD" {
+				added = append(added, RenameFile{Path: entry.Path, Data: data})
+			} else {
+				removed = append(removed, RenameFile{Path: entry.Path, Data: data})
+			}
+		case "MOD":
+			_, commitId, fileId := GetFileMetadata(entry.Path)
+			data, _, err := store.ReadObject(commitId, fileId, fileName)
+			if err != nil {
+				Debug("Failed to read committed file %s: %v", entry.Path, err)
+				continue
+			}
+			sources = append(sources, RenameFile{Path: entry.Path, Data: data})
+		}
+	}
+	sortRenameFiles(removed, added, sources)
+	return DetectRenames(removed, added, sources, RenameThreshold())
+}
+
+// FoldRenames replaces the added and removed entries paired by renames with REN entries, and the
+// added entries of copies with CPY entries, as status and history list them.
+func FoldRenames(entries []LogFileEntry, renames []Rename) []LogFileEntry {
+	folded := slices.Clone(entries)
+	for _, rename := range renames {
+		index := slices.IndexFunc(folded, func(entry LogFileEntry) bool { return entry.Op == "ADD" && entry.Path == rename.To })
+		if index == -1 {
+			continue
+		}
+		folded[index].Op, folded[index].From = "REN", rename.From
+		if rename.Copy {
+			folded[index].Op = "CPY"
+			continue
+		}
+		folded = slices.DeleteFunc(folded, func(entry LogFileEntry) bool { return entry.Op == "REM" && entry.Path == rename.From })
+	}
+	return folded
+}
+
+// sortRenameFiles orders the files by path, map iteration would make the pairing of equally similar
+// files random.
+func sortRenameFiles(lists ...[]RenameFile) {
+	for _, files := range lists {
+		slices.SortFunc(files, func(a, b RenameFile) int { return strings.Compare(a.Path, b.Path) })
+	}
+}
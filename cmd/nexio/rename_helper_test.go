@@ -0,0 +1,106 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_Similarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"one\ntwo\n", "one\ntwo\n", 100},
+		{"one\ntwo\n", "two\none\n", 100},
+		{"one\ntwo\n", "one\nTWO\n", 50},
+		{"one\n", "two\n", 0},
+		{"", "", 100},
+		{"one\n", "", 0},
+	}
+	for _, test := range tests {
+		if similarity := Similarity([]byte(test.a), []byte(test.b)); similarity != test.expected {
+			t.Errorf("Expected %d%% for %q and %q, got %d%%", test.expected, test.a, test.b, similarity)
+		}
+	}
+}
+
+func Test_ParseThreshold(t *testing.T) {
+	for value, expected := range map[string]int{"": DefaultRenameThreshold, "60": 60, "75%": 75, "0": 0, "100%": 100} {
+		if threshold, err := ParseThreshold(value); err != nil || threshold != expected {
+			t.Errorf("Expected %d for %q, got %d %v", expected, value, threshold, err)
+		}
+	}
+	for _, value := range []string{"abc", "-1", "101", "50%%"} {
+		if _, err := ParseThreshold(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func Test_DetectRenames(t *testing.T) {
+	lines := func(n int, changed string) []byte {
+		var content strings.Builder
+		for i := 0; i < n; i++ {
+			content.WriteString("line " + string(rune('a'+i)) + "\n")
+		}
+		return []byte(content.String() + changed)
+	}
+	removed := []RenameFile{
+		{Path: "exact.txt", Data: lines(4, "")},
+		{Path: "similar.txt", Data: lines(8, "")},
+		{Path: "gone.txt", Data: []byte("nothing alike\n")},
+		{Path: "empty.txt", Data: nil},
+	}
+	added := []RenameFile{
+		{Path: "moved/exact.txt", Data: lines(4, "")},
+		{Path: "moved/similar.txt", Data: lines(8, "one more line\n")},
+		{Path: "copy.txt", Data: []byte("kept\nfile\n")},
+		{Path: "new.txt", Data: []byte("brand new\n")},
+		{Path: "empty2.txt", Data: nil},
+	}
+	sources := []RenameFile{{Path: "kept.txt", Data: []byte("kept\nfile\n")}}
+
+	renames := DetectRenames(removed, added, sources, 50)
+	expected := []Rename{
+		{From: "kept.txt", To: "copy.txt", Similarity: 100, Copy: true},
+		{From: "exact.txt", To: "moved/exact.txt", Similarity: 100},
+		{From: "similar.txt", To: "moved/similar.txt", Similarity: 80},
+	}
+	if !slices.Equal(renames, expected) {
+		t.Errorf("Expected %v, got %v", expected, renames)
+	}
+
+	// Below the threshold, only identical files are paired.
+	renames = DetectRenames(removed, added, sources, 90)
+	if len(renames) != 2 || slices.ContainsFunc(renames, func(rename Rename) bool { return rename.To == "moved/similar.txt" }) {
+		t.Errorf("Expected only the exact rename and copy, got %v", renames)
+	}
+
+	// A removed file is renamed once, the other identical file stays added.
+	renames = DetectRenames(removed[:1], []RenameFile{{Path: "a.txt", Data: lines(4, "")}, {Path: "b.txt", Data: lines(4, "")}}, nil, 50)
+	if len(renames) != 1 || renames[0].To != "a.txt" {
+		t.Errorf("Expected a single rename, got %v", renames)
+	}
+}
+
+func Test_FoldRenames(t *testing.T) {
+	entries := []LogFileEntry{
+		{Id: "1", Op: "REM", Path: "old.txt"},
+		{Id: "2", Op: "ADD", Path: "new.txt"},
+		{Id: "3", Op: "ADD", Path: "copy.txt"},
+		{Id: "4", Op: "MOD", Path: "kept.txt"},
+	}
+	folded := FoldRenames(entries, []Rename{{From: "old.txt", To: "new.txt"}, {From: "kept.txt", To: "copy.txt", Copy: true}})
+	expected := []LogFileEntry{
+		{Id: "2", Op: "REN", Path: "new.txt", From: "old.txt"},
+		{Id: "3", Op: "CPY", Path: "copy.txt", From: "kept.txt"},
+		{Id: "4", Op: "MOD", Path: "kept.txt"},
+	}
+	if !slices.Equal(folded, expected) {
+		t.Errorf("Expected %v, got %v", expected, folded)
+	}
+	if add, mod, rem := CountOps(folded); add != 1 || mod != 2 || rem != 0 {
+		t.Errorf("Expected +1 ~2 -0, got +%d ~%d -%d", add, mod, rem)
+	}
+}
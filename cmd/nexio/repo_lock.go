@@ -0,0 +1,17 @@
+package main
+
+const (
+	// Taken by read-only commands (status, history), any number of them may run at the same time.
+	SharedLock = "shared"
+	// Taken by mutating commands (commit, switch, add, remove, branch drop), nothing else runs meanwhile.
+	ExclusiveLock = "exclusive"
+)
+
+// RepoLockName is the name of the repository lock file in `.nexio`.
+const RepoLockName = "repo.lock"
+
+// AcquireRepoLock takes the repository-wide lock for multi-step commands and returns a function releasing it.
+// The lock is not reentrant, commands calling each other must share the lock taken by the outermost one.
+func AcquireRepoLock(mode string) (release func(), err error) {
+	return store.LockRepository(mode)
+}
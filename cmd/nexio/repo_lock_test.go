@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Test_RepoLockHelperProcess is not a real test, it holds the repository lock on behalf of
+// Test_RepoLock_CrossProcess until its stdin is closed.
+func Test_RepoLockHelperProcess(t *testing.T) {
+	mode := os.Getenv("NEXIO_REPO_LOCK_HELPER")
+	if mode == "" {
+		return
+	}
+	release, err := AcquireRepoLock(mode)
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Stdout.WriteString("locked\n")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	release()
+	os.Exit(0)
+}
+
+func holdRepoLockInHelperProcess(t *testing.T, mode string) (release func()) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_RepoLockHelperProcess$")
+	cmd.Env = append(os.Environ(), "NEXIO_REPO_LOCK_HELPER="+mode)
+	stdin, _ := cmd.StdinPipe()
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start helper process: %v", err)
+	}
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+		t.Fatalf("Helper process failed to acquire the repository lock")
+	}
+	return func() {
+		stdin.Close()
+		cmd.Wait()
+	}
+}
+
+func Test_RepoLock_SharedAndExclusive(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	os.Setenv("NEXIO_LOCK_TIMEOUT", "100ms")
+	defer os.Unsetenv("NEXIO_LOCK_TIMEOUT")
+
+	releaseFirst, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		t.Fatalf("Failed to acquire shared lock: %v", err)
+	}
+	releaseSecond, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		t.Errorf("Expected shared locks to coexist: %v", err)
+	} else {
+		releaseSecond()
+	}
+
+	if _, err := AcquireRepoLock(ExclusiveLock); err == nil {
+		t.Errorf("Expected exclusive lock to time out while a shared lock is held")
+	}
+	if returnCode, _ := runCommitCommand("blocked"); returnCode != 005 {
+		t.Errorf("Expected 005, got %d", returnCode)
+	}
+	releaseFirst()
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		t.Fatalf("Failed to acquire exclusive lock: %v", err)
+	}
+	if returnCode, _ := runStatusCommand(); returnCode != 005 {
+		t.Errorf("Expected 005 while an exclusive lock is held, got %d", returnCode)
+	}
+	release()
+
+	os.RemoveAll(namespace)
+}
+
+func Test_RepoLock_CrossProcess(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	os.Setenv("NEXIO_LOCK_TIMEOUT", "100ms")
+	defer os.Unsetenv("NEXIO_LOCK_TIMEOUT")
+
+	releaseHelper := holdRepoLockInHelperProcess(t, ExclusiveLock)
+	file := namespace + "file.txt"
+	os.WriteFile(file, []byte("content"), 0644)
+	if result := runAddCommand(file, false); result.ReturnCode != 005 {
+		t.Errorf("Expected 005 while another process holds the lock, got %d", result.ReturnCode)
+	}
+	releaseHelper()
+
+	if result := runAddCommand(file, false); result.ReturnCode != 112 {
+		t.Errorf("Expected 112 after the other process released the lock, got %d", result.ReturnCode)
+	}
+
+	if flockSupported {
+		releaseHelper = holdRepoLockInHelperProcess(t, SharedLock)
+		if returnCode, _ := runStatusCommand(); returnCode != 502 {
+			t.Errorf("Expected shared lock to be available across processes, got %d", returnCode)
+		}
+		if returnCode, _ := runCommitCommand("blocked"); returnCode != 005 {
+			t.Errorf("Expected 005 while another process holds a shared lock, got %d", returnCode)
+		}
+		releaseHelper()
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_RepoLock_ConcurrentCommits(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	os.Setenv("NEXIO_LOCK_TIMEOUT", "30s")
+	defer os.Unsetenv("NEXIO_LOCK_TIMEOUT")
+
+	const workers = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	committed := 0
+	files := []string{}
+	for i := range workers {
+		file := namespace + "concurrent" + strconv.Itoa(i) + ".txt"
+		files = append(files, file)
+		os.WriteFile(file, []byte("content "+strconv.Itoa(i)), 0644)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runAddCommand(file, false)
+			if returnCode, _ := runCommitCommand("concurrent " + strconv.Itoa(i)); returnCode == 702 {
+				mu.Lock()
+				committed++
+				mu.Unlock()
+			}
+			runStatusCommand()
+		}()
+	}
+	wg.Wait()
+
+	for _, problem := range CheckRepository(store) {
+		if problem.Severity != SeverityInfo {
+			t.Errorf("Unexpected problem after concurrent commits: %s", problem.String())
+		}
+	}
+	if CountCommits() != committed {
+		t.Errorf("Expected %d commits, got %d", committed, CountCommits())
+	}
+	fileList := GetFileListContent(GetLastCommit().Id)
+	if len(*fileList) != workers {
+		t.Errorf("Expected %d committed files, got %d", workers, len(*fileList))
+	}
+	if !IsStagingLogsEmpty() {
+		t.Errorf("Expected staging to be empty after all commits")
+	}
+
+	// A commit racing a branch switch must leave the repository consistent.
+	runNewCommand("racing", "", "")
+	runSwitchCommand(InitBranch)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		os.WriteFile(files[0], []byte("modified"), 0644)
+		runAddCommand(files[0], false)
+		runCommitCommand("racing switch")
+	}()
+	go func() {
+		defer wg.Done()
+		runSwitchCommand("racing")
+	}()
+	wg.Wait()
+
+	for _, problem := range CheckRepository(store) {
+		if problem.Severity != SeverityInfo {
+			t.Errorf("Unexpected problem after racing switch: %s", problem.String())
+		}
+	}
+
+	os.RemoveAll(namespace)
+}
@@ -5,6 +5,8 @@ var COMMON_RETURN_CODES = map[int]string{
 	002: "Path ignored by one of the rules defined in the rules file.",
 	003: "Nexio already initialized.",
 	004: "Invalid path.",
+	005: "Repository is locked by another operation.",
+	006: "A bisect is in progress.",
 }
 
 var ADD_RETURN_CODES = map[int]string{
@@ -22,6 +24,10 @@ var ADD_RETURN_CODES = map[int]string{
 	111: "File not modified.",                                      // file committed, not staged, not modified
 	112: "File added to staging.",                                  // file not committed, not staged -> staged (ADD)
 	113: "File restored to committed state, removed from staging.", // file was staged (REM), but it got added back without modifications
+	114: "File moved, the move is already staged.",                 // the old path of a staged move (REN)
+	115: "Staged file updated.",                                    // file was moved (REN), but it got modified
+	116: "File already staged.",                                    // the user staged the same file again (REN)
+	117: "File no longer exists, staged for removal.",              // file was moved (REN), but it got removed
 }
 
 var BRANCH_RETURN_CODES = map[int]string{
@@ -42,6 +48,10 @@ var BRANCH_RETURN_CODES = map[int]string{
 	215: "Target branch already set as default.",            // config
 	216: "Branch does not exist.",                           // config
 	217: "No branches found. .nexio folder seems to be corrupted!",
+	218: "Invalid upstream, expected <remote>/<branch>.",                   // upstream
+	219: "Branch does not exist.",                                          // upstream
+	220: "Remote-tracking branch does not exist, run `nexio fetch` first.", // upstream
+	221: "Upstream set.",                                                   // upstream
 }
 
 var WORKDIR_RETURN_CODES = map[int]string{
@@ -68,11 +78,16 @@ var CONFIG_RETURN_CODES = map[int]string{
 	605: "Name not set.",
 	606: "Email not set.",
 	607: "Name and/or email not set.",
+	608: "Invalid duration.",
+	609: "Merge tool not set.",
+	610: "Invalid threshold.",
+	611: "Invalid size.",
 }
 
 var COMMIT_RETURN_CODES = map[int]string{
 	701: "Nothing to commit.",
 	702: "Commit success.",
+	703: "Unmerged paths must be resolved before committing.",
 }
 
 var REMOVE_RETURN_CODES = map[int]string{
@@ -84,3 +99,217 @@ var PURGE_RETURN_CODES = map[int]string{
 	901: "Nexio purged successfully.",
 	902: "Cancelled.",
 }
+
+var FSCK_RETURN_CODES = map[int]string{
+	1001: "No problems found.",
+	1002: "Problems found.",
+	1003: "Problems repaired.",
+	1004: "Some problems could not be repaired.",
+}
+
+var GC_RETURN_CODES = map[int]string{
+	1101: "Nothing to clean up.",
+	1102: "Garbage collection completed.",
+	1103: "Dry run completed, nothing was removed.",
+	1104: "Unable to determine reachable commits.",
+	1105: "Invalid grace period.",
+}
+
+var UNLOCK_RETURN_CODES = map[int]string{
+	1201: "No locks found.",
+	1202: "Stale locks removed.",
+	1203: "Locks removed.",
+	1204: "Locks are held by running processes.",
+}
+
+var REMOTE_RETURN_CODES = map[int]string{
+	1301: "Remote added.",
+	1302: "Remote already exists.",
+	1303: "Remote removed.",
+	1304: "Remote does not exist.",
+	1305: "Invalid remote name.",
+	1306: "No remotes configured.",
+	1307: "Get remotes success.",
+}
+
+var CLONE_RETURN_CODES = map[int]string{
+	1401: "Repository cloned successfully.",
+	1402: "Destination already exists and is not empty.",
+	1403: "Remote is not a Nexio repository.",
+	1404: "Clone failed.",
+}
+
+var FETCH_RETURN_CODES = map[int]string{
+	1501: "Already up to date.",
+	1502: "Commits fetched.",
+	1503: "Unable to reach remote.",
+}
+
+var PUSH_RETURN_CODES = map[int]string{
+	1601: "Everything up to date.",
+	1602: "Pushed successfully.",
+	1603: "Push rejected, the remote branch has commits that are not in your branch.",
+	1604: "Push rejected, the remote branch changed during the push.",
+	1605: "Unable to reach remote.",
+	1606: "Branch does not exist.",
+	1607: "Push rejected, the branch is checked out in the remote repository.",
+}
+
+var PULL_RETURN_CODES = map[int]string{
+	1701: "Already up to date.",
+	1702: "Fast-forwarded.",
+	1703: "Branches have diverged, cannot fast-forward.",
+	1704: "Cannot pull with uncommitted changes.",
+	1705: "Remote branch does not exist.",
+	1706: "Unable to reach remote.",
+}
+
+var SERVE_RETURN_CODES = map[int]string{
+	1801: "Server stopped.",
+	1802: "Unable to start server.",
+}
+
+var BUNDLE_RETURN_CODES = map[int]string{
+	1901: "Bundle created.",
+	1902: "Bundle is valid.",
+	1903: "Bundle is corrupted.",
+	1904: "Bundle requires commits this repository does not have.",
+	1905: "Bundle imported.",
+	1906: "Invalid branch or range.",
+	1907: "Unable to read bundle.",
+	1908: "Unable to write bundle.",
+	1909: "Nothing to bundle, the range is empty.",
+}
+
+var ARCHIVE_RETURN_CODES = map[int]string{
+	2001: "Archive created.",
+	2002: "Revision not found.",
+	2003: "Unsupported archive format, use tar, tar.gz or zip.",
+	2004: "No files match the given paths.",
+	2005: "Unable to write archive.",
+}
+
+var IMPORT_GIT_RETURN_CODES = map[int]string{
+	2101: "Git history imported.",
+	2102: "Not a Git repository.",
+	2103: "Git branch does not exist.",
+	2104: "Target branch already has commits.",
+	2105: "Unable to read Git repository.",
+	2106: "Invalid target branch name.",
+}
+
+var EXPORT_RETURN_CODES = map[int]string{
+	2201: "Export written.",
+	2202: "Unsupported export format, use git-fast-import.",
+	2203: "Branch does not exist.",
+	2204: "Unable to read marks file.",
+	2205: "Unable to write export.",
+}
+
+var FORMAT_PATCH_RETURN_CODES = map[int]string{
+	2301: "Patches written.",
+	2302: "Invalid revision or range.",
+	2303: "No commits in range.",
+	2304: "Unable to write patches.",
+}
+
+var APPLY_RETURN_CODES = map[int]string{
+	2401: "Patch applied.",
+	2402: "Patch applies cleanly.",
+	2403: "Patch does not apply.",
+	2404: "Patch applied with rejects.",
+	2405: "Unable to read patch.",
+	2406: "No changes found in patch.",
+	2407: "Patch changes a path outside of the working tree.",
+}
+
+var AM_RETURN_CODES = map[int]string{
+	2501: "Patches applied and committed.",
+	2502: "Cannot apply patches with staged changes.",
+	2503: "Patch does not apply, stopped.",
+	2504: "Unable to read patch.",
+	2505: "No patches found.",
+	2506: "Patch changes a path outside of the working tree.",
+}
+
+var BLAME_RETURN_CODES = map[int]string{
+	2601: "Blame shown.",
+	2602: "File is not tracked on the current branch.",
+	2603: "Invalid line range.",
+	2604: "Cannot blame a binary file.",
+}
+
+var BISECT_RETURN_CODES = map[int]string{
+	2701: "Bisect started.",
+	2702: "A bisect is already in progress.",
+	2703: "No bisect in progress.",
+	2704: "Cannot bisect with uncommitted changes.",
+	2705: "Revision is not on the bisected branch.",
+	2706: "First bad commit found.",
+	2707: "Checked out the next commit to test.",
+	2708: "Waiting for both a good and a bad commit.",
+	2709: "Only skipped commits are left, the first bad commit cannot be determined.",
+	2710: "Bisect reset.",
+	2711: "Good commit is not older than the bad commit.",
+	2712: "Bisect run stopped.",
+	2713: "Branch has no commits to bisect.",
+	2714: "Bisect log shown.",
+}
+
+var CHERRY_PICK_RETURN_CODES = map[int]string{
+	2801: "Commits picked.",
+	2802: "Cherry-pick stopped on conflicts.",
+	2803: "A cherry-pick is already in progress.",
+	2804: "No cherry-pick in progress.",
+	2805: "Cannot cherry-pick with uncommitted changes.",
+	2806: "Revision not found.",
+	2807: "Conflicts are not resolved yet.",
+	2808: "Cherry-pick aborted.",
+}
+
+var REBASE_RETURN_CODES = map[int]string{
+	2901: "Rebase finished.",
+	2902: "Rebase stopped on conflicts.",
+	2903: "A rebase is already in progress.",
+	2904: "No rebase in progress.",
+	2905: "Cannot rebase with uncommitted changes.",
+	2906: "Upstream not found.",
+	2907: "Conflicts are not resolved yet.",
+	2908: "Rebase aborted.",
+	2909: "Current branch is up to date.",
+	2910: "Invalid todo list.",
+	2911: "Nothing to do, rebase aborted.",
+	2912: "Unable to open the editor.",
+}
+
+var MERGETOOL_RETURN_CODES = map[int]string{
+	3001: "Conflicts resolved.",
+	3002: "No unmerged paths.",
+	3003: "Merge tool not set.",
+	3004: "Some conflicts are not resolved.",
+	3005: "Path is not unmerged.",
+}
+
+var MOVE_RETURN_CODES = map[int]string{
+	3101: "Moved.",
+	3102: "Source does not exist.",
+	3103: "Source is not tracked.",
+	3104: "Destination already exists.",
+	3105: "Source has unstaged modifications, use --force to move it anyway.",
+	3106: "Cannot move a directory into itself.",
+}
+
+var RM_RETURN_CODES = map[int]string{
+	3201: "Files removed.",
+	3202: "Path is not tracked.",
+	3203: "Files have local modifications, use --cached to keep them or --force to remove them.",
+	3204: "Not removing a directory without -r.",
+}
+
+var CLEAN_RETURN_CODES = map[int]string{
+	3301: "Removed:",
+	3302: "Would remove:",
+	3303: "Nothing to clean.",
+	3304: "Refusing to clean without -f, -n or -i.",
+	3305: "Nothing selected.",
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HeadRevision names the last commit of the current branch.
+const HeadRevision = "HEAD"
+
+// ResolveRevision returns the commit a revision names: `HEAD`, a branch (its last commit), a commit id or an
+// unambiguous prefix of one. A `~N` suffix goes N commits back, `~` alone one commit.
+// The timestamp is empty for commits that are not on any branch.
+func ResolveRevision(s Storage, revision string) (Commit, error) {
+	commits, index, err := locateRevision(s, revision)
+	if err != nil {
+		return Commit{}, err
+	}
+	return commits[index], nil
+}
+
+// ResolveRange returns the commits a range names, oldest first, and the commit before the first of them.
+// `<from>..<to>` names the commits after from up to to, a single revision only the commit itself.
+func ResolveRange(s Storage, spec string) (parent string, commits []Commit, err error) {
+	from, to, isRange := strings.Cut(spec, "..")
+	if !isRange {
+		to = spec
+	}
+	branchCommits, index, err := locateRevision(s, to)
+	if err != nil {
+		return "", nil, err
+	}
+	start := index
+	if isRange {
+		fromCommit, err := ResolveRevision(s, from)
+		if err != nil {
+			return "", nil, err
+		}
+		start = slices.IndexFunc(branchCommits[:index+1], func(commit Commit) bool { return commit.Id == fromCommit.Id }) + 1
+		if start == 0 {
+			return "", nil, fmt.Errorf("%s is not an ancestor of %s", from, to)
+		}
+	}
+	if start > 0 {
+		parent = branchCommits[start-1].Id
+	}
+	return parent, branchCommits[start : index+1], nil
+}
+
+// locateRevision returns the commits of the branch a revision is on and its position among them.
+// A commit on no branch is returned on its own.
+func locateRevision(s Storage, revision string) (commits []Commit, index int, err error) {
+	base, back, err := parseRevision(revision)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	branches, err := s.ListBranches()
+	if err != nil {
+		return nil, 0, err
+	}
+	metadata, err := s.ReadBranchesMetadata()
+	if err != nil {
+		return nil, 0, err
+	}
+	// The current branch is searched first, a commit on several branches resolves to the same position.
+	branches = slices.DeleteFunc(branches, func(branch string) bool { return branch == metadata.Current })
+	branches = append([]string{metadata.Current}, branches...)
+
+	if base == HeadRevision || slices.Contains(branches, base) {
+		branch := base
+		if base == HeadRevision {
+			branch = metadata.Current
+		}
+		commits, err := GetBranchCommits(s, branch)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(commits) == 0 {
+			return nil, 0, fmt.Errorf("branch %s has no commits", branch)
+		}
+		return walkBack(commits, len(commits)-1, back, revision)
+	}
+	// A remote-tracking ref, e.g. origin/main, unless a local branch has the same name.
+	if remote, branch, found := strings.Cut(base, "/"); found && IsValidRemoteName(remote) && IsValidBranchName(branch) {
+		if commits, err := s.ReadRemoteRef(remote, branch); err == nil && len(commits) > 0 {
+			return walkBack(sortCommitsByLinkedList(commits), len(commits)-1, back, revision)
+		}
+	}
+
+	commitId, err := resolveCommitId(s, base)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, branch := range branches {
+		commits, err := GetBranchCommits(s, branch)
+		if err != nil {
+			return nil, 0, err
+		}
+		if index := slices.IndexFunc(commits, func(commit Commit) bool { return commit.Id == commitId }); index != -1 {
+			return walkBack(commits, index, back, revision)
+		}
+	}
+	if back > 0 {
+		return nil, 0, fmt.Errorf("commit %s is not on any branch, cannot resolve %s", commitId, revision)
+	}
+	return []Commit{{Id: commitId}}, 0, nil
+}
+
+func parseRevision(revision string) (base string, back int, err error) {
+	base, suffix, found := strings.Cut(revision, "~")
+	if base == "" {
+		base = HeadRevision
+	}
+	if !found {
+		return base, 0, nil
+	}
+	if suffix == "" {
+		return base, 1, nil
+	}
+	back, err = strconv.Atoi(suffix)
+	if err != nil || back < 0 {
+		return "", 0, fmt.Errorf("invalid revision %s", revision)
+	}
+	return base, back, nil
+}
+
+func walkBack(commits []Commit, index int, back int, revision string) ([]Commit, int, error) {
+	if index-back < 0 {
+		return nil, 0, fmt.Errorf("revision %s goes back further than the first commit", revision)
+	}
+	return commits, index - back, nil
+}
+
+// resolveCommitId expands an unambiguous prefix of a commit id.
+func resolveCommitId(s Storage, prefix string) (string, error) {
+	if s.HasCommit(prefix) {
+		return prefix, nil
+	}
+	ids, err := s.ListCommits()
+	if err != nil {
+		return "", err
+	}
+	matches := []string{}
+	for _, id := range ids {
+		if strings.HasPrefix(id, prefix) {
+			matches = append(matches, id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown revision %s", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("ambiguous revision %s matches %d commits", prefix, len(matches))
+	}
+}
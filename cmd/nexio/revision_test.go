@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_ResolveRevision(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFiles(t, 3)
+	commits := mustBranchCommits(t, store)
+	runNewCommand("feature", "", "")
+	head := commitTestChange(t, "feature")
+
+	for revision, expected := range map[string]string{
+		InitBranch:          commits[2].Id,
+		InitBranch + "~2":   commits[0].Id,
+		commits[1].Id:       commits[1].Id,
+		commits[1].Id[:8]:   commits[1].Id,
+		commits[2].Id + "~": commits[1].Id,
+		HeadRevision:        head,
+		HeadRevision + "~1": commits[2].Id,
+		"~3":                commits[0].Id,
+		"feature~0":         head,
+	} {
+		commit, err := ResolveRevision(store, revision)
+		if err != nil || commit.Id != expected {
+			t.Errorf("Expected %s to resolve to %s, got %s (%v)", revision, expected, commit.Id, err)
+		}
+	}
+	for _, revision := range []string{"unknown", InitBranch + "~3", HeadRevision + "~x"} {
+		if _, err := ResolveRevision(store, revision); err == nil {
+			t.Errorf("Expected %s not to resolve", revision)
+		}
+	}
+
+	os.RemoveAll(namespace)
+}
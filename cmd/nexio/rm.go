@@ -0,0 +1,119 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rmCmd.Flags().BoolVar(&RmCached, "cached", false, "Only stage the removal, keep the files in the working directory")
+	rmCmd.Flags().BoolVarP(&RmRecursive, "recursive", "r", false, "Remove the tracked files of directories")
+	rmCmd.Flags().BoolVarP(&RmForce, "force", "f", false, "Remove files with staged or unstaged modifications")
+
+	rootCmd.AddCommand(rmCmd)
+}
+
+var (
+	RmCached    bool
+	RmRecursive bool
+	RmForce     bool
+)
+
+var rmCmd = &cobra.Command{
+	Use:     "rm",
+	Short:   "Remove files from the working directory and stage the removal",
+	Example: "nexio rm <path>...\nnexio rm --cached <path>\nnexio rm -r <directory>",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting rm command: paths=%v, cached=%t, recursive=%t, force=%t", args, RmCached, RmRecursive, RmForce)
+		runRmCommand(args, RmCached, RmRecursive, RmForce)
+	},
+}
+
+func runRmCommand(paths []string, cached bool, recursive bool, force bool) (returnCode int, removed []string) {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	release, err := AcquireRepoLock(ExclusiveLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
+
+	if RefuseDuringBisect() {
+		return 006, nil
+	}
+
+	// Every path is checked before anything is removed.
+	files := []string{}
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if ValidatePath(path) != nil {
+			Debug("%s", COMMON_RETURN_CODES[004])
+			Fail(COMMON_RETURN_CODES[004])
+			return 004, nil
+		}
+		tracked := TrackedFiles(path)
+		if len(tracked) == 0 {
+			Debug("%s", RM_RETURN_CODES[3202])
+			Fail(RM_RETURN_CODES[3202] + " " + path)
+			return 3202, nil
+		}
+		if !recursive && !slices.Equal(tracked, []string{path}) {
+			Debug("%s", RM_RETURN_CODES[3204])
+			Fail(RM_RETURN_CODES[3204] + " " + path)
+			return 3204, nil
+		}
+		files = append(files, tracked...)
+	}
+	slices.Sort(files)
+	files = slices.Compact(files)
+	if !force && !cached {
+		// Changes not committed yet would be lost.
+		modified := slices.DeleteFunc(slices.Clone(files), func(file string) bool {
+			staged, _, op := LogEntryLookup("*", file)
+			return !HasUnstagedChanges(file) && !(staged && op != "REM")
+		})
+		if len(modified) > 0 {
+			Debug("%s", RM_RETURN_CODES[3203])
+			Fail(RM_RETURN_CODES[3203])
+			Tree(modified, true)
+			return 3203, nil
+		}
+	}
+
+	for _, file := range files {
+		stageRemoval(file)
+		if !cached {
+			RemoveFile(file)
+			RemoveEmptyParents(file)
+		}
+	}
+
+	Success(RM_RETURN_CODES[3201])
+	Tree(files, true)
+	return 3201, files
+}
+
+// stageRemoval replaces what is staged for a file with its removal. A file staged as added is no
+// longer tracked, the removal of a moved file is staged from the path it was committed at.
+func stageRemoval(path string) {
+	Debug("Staging removal: %s", path)
+	if renamed, rename := RenameLookup(path); renamed {
+		MustSucceed(RemoveFileAndLog(rename.Id, StagingOps["REN"]), "operation failed")
+		path = rename.From
+	} else if staged, id, op := LogEntryLookup("*", path); staged {
+		MustSucceed(RemoveFileAndLog(id, StagingOps[op]), "operation failed")
+	}
+	if committed, commitId, fileId := GetFileMetadata(path); committed {
+		id := GenRandHex(20)
+		MustSucceed(StageRemoval(id, path, commitId, fileId), "operation failed")
+		LogOperation(id, "REM", path)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func Test_Rm(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	os.MkdirAll(namespace+"dir", 0755)
+	os.WriteFile(namespace+"dir/a.txt", []byte("a\n"), 0644)
+	os.WriteFile(namespace+"dir/b.txt", []byte("b\n"), 0644)
+	runAddCommand(namespace+"dir/a.txt", false)
+	runAddCommand(namespace+"dir/b.txt", false)
+	commitTestFile(t, "file1.txt", "one\n", "Add files")
+
+	if returnCode, _ := runRmCommand([]string{namespace + "untracked.txt"}, false, false, false); returnCode != 3202 {
+		t.Errorf("Expected 3202 for an untracked path, got %d", returnCode)
+	}
+	if returnCode, _ := runRmCommand([]string{namespace + "dir"}, false, false, false); returnCode != 3204 {
+		t.Errorf("Expected 3204 for a directory without -r, got %d", returnCode)
+	}
+	os.WriteFile(namespace+"file1.txt", []byte("changed\n"), 0644)
+	if returnCode, _ := runRmCommand([]string{namespace + "dir", namespace + "file1.txt"}, false, true, false); returnCode != 3203 {
+		t.Errorf("Expected 3203 for local modifications, got %d", returnCode)
+	}
+	if !FileExists(namespace + "dir/a.txt") {
+		t.Fatalf("Expected nothing to be removed")
+	}
+
+	// --cached keeps the file.
+	returnCode, removed := runRmCommand([]string{namespace + "file1.txt"}, true, false, false)
+	if returnCode != 3201 || !slices.Equal(removed, []string{namespace + "file1.txt"}) {
+		t.Fatalf("Expected 3201, got %d %v", returnCode, removed)
+	}
+	if staged, _, op := LogEntryLookup("*", namespace+"file1.txt"); !staged || op != "REM" || readTestFile("file1.txt") != "changed\n" {
+		t.Errorf("Expected the removal to be staged and the file kept, got %s", op)
+	}
+
+	if returnCode, removed := runRmCommand([]string{namespace + "dir"}, false, true, false); returnCode != 3201 || len(removed) != 2 {
+		t.Fatalf("Expected 3201 and both files, got %d %v", returnCode, removed)
+	}
+	if _, err := os.Stat(namespace + "dir"); !os.IsNotExist(err) {
+		t.Errorf("Expected the emptied directory to be removed")
+	}
+	returnCode, commitId := runCommitCommand("Remove files")
+	if returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+	if files, _ := commitFileMap(store, commitId); len(files) != 0 {
+		t.Errorf("Expected no files left, got %v", files)
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_Rm_StagedFiles(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	commitTestFile(t, "file1.txt", "one\n", "Add file1")
+	os.WriteFile(namespace+"new.txt", []byte("new\n"), 0644)
+	runAddCommand(namespace+"new.txt", false)
+
+	if returnCode, _ := runRmCommand([]string{namespace + "new.txt"}, false, false, false); returnCode != 3203 {
+		t.Errorf("Expected 3203 for a staged file, got %d", returnCode)
+	}
+	if returnCode, _ := runRmCommand([]string{namespace + "new.txt"}, false, false, true); returnCode != 3201 {
+		t.Fatalf("Expected 3201 with --force, got %d", returnCode)
+	}
+	if FileExists(namespace+"new.txt") || IsFileStaged(namespace+"new.txt") {
+		t.Errorf("Expected the added file to be removed and unstaged")
+	}
+
+	// A moved file is removed from the path it was committed at.
+	runMoveCommand(namespace+"file1.txt", namespace+"moved.txt", false)
+	if returnCode, _ := runRmCommand([]string{namespace + "moved.txt"}, false, false, true); returnCode != 3201 {
+		t.Fatalf("Expected 3201, got %d", returnCode)
+	}
+	logs := *GetStagingLogsContent()
+	if len(logs) != 1 || logs[0].Op != "REM" || logs[0].Path != namespace+"file1.txt" {
+		t.Errorf("Expected the removal of the committed path, got %v", logs)
+	}
+
+	os.RemoveAll(namespace)
+}
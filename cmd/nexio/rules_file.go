@@ -1,135 +1,293 @@
 package main
 
 import (
+	"bufio"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// RulesFileName is the name of the rules files, in the root of the repository and in any directory below.
+// The patterns of a rules file are relative to its directory, those of deeper files take precedence.
+const RulesFileName = ".nexio.rules.yml"
+
 // default: all files are allowed (tracked).
-// ignore: defines patterns to exclude.
-// allow: defines patterns to re-include (override an ignore).
+// ignore: defines patterns to exclude, in the syntax of .gitignore. The last matching pattern wins,
+// a pattern starting with `!` re-includes what an earlier one excluded.
+// allow: defines patterns to re-include, read as negated patterns following those of ignore.
+// binary: defines patterns of files never diffed or merged line by line, whatever their content.
 
 type Rules struct {
 	Ignore []string `yaml:"ignore"`
 	Allow  []string `yaml:"allow"`
+	Binary []string `yaml:"binary"`
+}
+
+// Rule is a compiled pattern. Base is the directory of the rules file it comes from, empty or
+// ending with a slash, the pattern matches paths relative to it.
+type Rule struct {
+	Pattern *regexp.Regexp
+	Base    string
+	Negate  bool
+	DirOnly bool
 }
 
-func readRules() (*Rules, error) {
-	Debug("Reading rules...")
-	_, err := os.Stat(".nexio.rules.yml")
+func readRules(path string) (*Rules, error) {
+	Debug("Reading rules: %s", path)
+	_, err := os.Stat(path)
 	if os.IsNotExist(err) {
-		Debug("\".nexio.rules.yml\" doesn't exist.")
+		Debug("\"%s\" doesn't exist.", path)
 		return nil, err
 	}
 
 	if err != nil {
-		Debug("Unable to get metadata for \".nexio.rules.yml\".")
+		Debug("Unable to get metadata for \"%s\".", path)
 		MustSucceed(err, "operation failed")
 		return nil, err
 	}
 
 	var content Rules
-	rulesFile, err := os.ReadFile(".nexio.rules.yml")
+	rulesFile, err := os.ReadFile(path)
 	if err != nil {
-		Debug("Unable to read \".nexio.rules.yml\" file.")
+		Debug("Unable to read \"%s\" file.", path)
 		MustSucceed(err, "operation failed")
 		return nil, err
 	}
 	if err = yaml.Unmarshal(rulesFile, &content); err != nil {
-		Debug("Unable to unmarshal `.nexio.rules.yml` file.")
+		Debug("Unable to unmarshal `%s` file.", path)
 		MustSucceed(err, "operation failed")
 		return nil, err
 	}
-	Debug("`.nexio.rules.yml` found.")
+	Debug("`%s` found.", path)
 	Debug("Content: %+v", content)
 	return &content, nil
 }
 
-func pathToRegexp() (ignore []*regexp.Regexp, allow []*regexp.Regexp, err error) {
-	rules, err := readRules()
-	if err != nil {
-		return nil, nil, err
+// ParseRule compiles a pattern of a rules file in base. Like in .gitignore, a leading `!` negates
+// the pattern, a trailing slash only matches directories and a slash at the start or in the middle
+// anchors the pattern to base, otherwise it matches names at any depth. `*` and `?` do not match a
+// slash, `**` does. Patterns prefixed with `regex:` are regular expressions matched against the
+// relative path. Blank patterns and comments starting with `#` give no rule.
+func ParseRule(pattern string, base string) (rule Rule, ok bool, err error) {
+	if strings.TrimSpace(pattern) == "" || strings.HasPrefix(pattern, "#") {
+		return Rule{}, false, nil
 	}
+	rule.Base = base
+	if rest, negated := strings.CutPrefix(pattern, "!"); negated {
+		rule.Negate, pattern = true, rest
+	}
+	if expression, isRegexp := strings.CutPrefix(pattern, "regex:"); isRegexp {
+		rule.Pattern, err = regexp.Compile(expression)
+		return rule, err == nil, err
+	}
+	if trimmed, isDir := strings.CutSuffix(pattern, "/"); isDir {
+		rule.DirOnly, pattern = true, trimmed
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
 
-	var ignoreRegexps []*regexp.Regexp
-	for _, rule := range rules.Ignore {
-		pattern, err := regexp.Compile(rule)
-		if err != nil {
-			patternRegexp, err := patternToRegexp(rule)
-			if err != nil {
-				return nil, nil, err
-			}
-			ignoreRegexps = append(ignoreRegexps, patternRegexp)
-		} else {
-			ignoreRegexps = append(ignoreRegexps, pattern)
-		}
+	expression := "^"
+	if !anchored {
+		expression += "(?:.*/)?"
 	}
+	rule.Pattern, err = regexp.Compile(expression + globToRegexp(pattern) + "$")
+	return rule, err == nil, err
+}
 
-	var allowRegexps []*regexp.Regexp
-	for _, rule := range rules.Allow {
-		pattern, err := regexp.Compile(rule)
-		if err != nil {
-			patternRegexp, err := patternToRegexp(rule)
-			if err != nil {
-				return nil, nil, err
+func globToRegexp(pattern string) string {
+	var expression strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			// Any number of directories, none included.
+			expression.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expression.WriteString(".*")
+			i++
+		case c == '*':
+			expression.WriteString("[^/]*")
+		case c == '?':
+			expression.WriteString("[^/]")
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '[' && strings.Contains(pattern[i+1:], "]"):
+			end := i + 1 + strings.Index(pattern[i+1:], "]")
+			class := pattern[i+1 : end]
+			if negated, found := strings.CutPrefix(class, "!"); found {
+				class = "^" + negated
 			}
-			allowRegexps = append(allowRegexps, patternRegexp)
-		} else {
-			allowRegexps = append(allowRegexps, pattern)
+			expression.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		default:
+			expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
 		}
 	}
+	return expression.String()
+}
 
-	return ignoreRegexps, allowRegexps, nil
+// Match tells whether the rule matches the path, a directory if isDir is set.
+func (r Rule) Match(path string, isDir bool) bool {
+	if r.DirOnly && !isDir || !strings.HasPrefix(path, r.Base) || path == r.Base {
+		return false
+	}
+	return r.Pattern.MatchString(path[len(r.Base):])
 }
 
-func patternToRegexp(pattern string) (*regexp.Regexp, error) {
-	regexpPattern := strings.ReplaceAll(pattern, "**", "__DOUBLE_STAR__")
-	regexpPattern = strings.ReplaceAll(regexpPattern, "*", "__STAR__")
-	regexpPattern = regexp.QuoteMeta(regexpPattern)
-	regexpPattern = strings.ReplaceAll(regexpPattern, "__STAR__", "[^/]*")
-	regexpPattern = strings.ReplaceAll(regexpPattern, "__DOUBLE_STAR__", ".*")
+// compileRules compiles the patterns of a rules file in base, skipping the invalid ones.
+func compileRules(patterns []string, base string) []Rule {
+	rules := []Rule{}
+	for _, pattern := range patterns {
+		rule, ok, err := ParseRule(pattern, base)
+		if err != nil {
+			Debug("Skipping invalid pattern %s: %v", pattern, err)
+			continue
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
 
-	isPath := strings.Contains(pattern, "/")
-	if isPath {
-		regexpPattern = "^" + regexpPattern + "$"
-	} else {
-		regexpPattern = "(^|/)" + regexpPattern + "(/|$)"
+// GlobalExcludesFile returns the path of the excludes file applying to every repository, set with
+// `nexio config set excludes-file`, `~/.config/nexio/ignore` by default.
+func GlobalExcludesFile() string {
+	// Reading the config must not fail outside of a repository.
+	config, _ := store.ReadConfig()
+	if config.ExcludesFile != "" {
+		if rest, found := strings.CutPrefix(config.ExcludesFile, "~/"); found {
+			if home, err := os.UserHomeDir(); err == nil {
+				return filepath.Join(home, rest)
+			}
+		}
+		return config.ExcludesFile
 	}
-	return regexp.Compile(regexpPattern)
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "nexio", "ignore")
 }
 
-func ShouldIgnore(path string) bool {
-	Debug("Checking if %s should be ignored...", path)
-	ignoreRegexps, allowRegexps, err := pathToRegexp()
+// readExcludesFile reads the global excludes file, one pattern per line like a .gitignore file.
+func readExcludesFile() []string {
+	path := GlobalExcludesFile()
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			Debug("Rules file not found, not ignoring path: %s", path)
-			return false
+		if !os.IsNotExist(err) {
+			Debug("Unable to read excludes file %s: %v", path, err)
 		}
-		Debug("Error reading rules file: %v", err)
-		return false
+		return nil
 	}
-
-	if len(allowRegexps) == 0 && len(ignoreRegexps) == 0 {
-		return false
+	defer file.Close()
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Trailing spaces are ignored unless escaped.
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		trimmed := strings.TrimRight(line, " ")
+		if strings.HasSuffix(trimmed, `\`) && len(trimmed) < len(line) {
+			trimmed += " "
+		}
+		patterns = append(patterns, trimmed)
 	}
+	return patterns
+}
 
-	for _, pattern := range allowRegexps {
-		if pattern.MatchString(path) {
-			Debug("Path should not be ignored: %s", path)
-			return false
+// rulesFor returns the ignore and binary rules applying to a path: those of the global excludes
+// file, then of the rules files from the root of the repository down to the directory of the path.
+func rulesFor(path string) (ignore []Rule, binary []Rule) {
+	ignore = compileRules(readExcludesFile(), "")
+	binary = []Rule{}
+	ruleDirs := []string{""}
+	for i, c := range path {
+		if c == '/' {
+			ruleDirs = append(ruleDirs, path[:i+1])
+		}
+	}
+	for _, dir := range ruleDirs {
+		rules, err := readRules(dir + RulesFileName)
+		if err != nil {
+			continue
+		}
+		ignore = append(ignore, compileRules(rules.Ignore, dir)...)
+		for _, pattern := range rules.Allow {
+			if !strings.HasPrefix(pattern, "!") {
+				pattern = "!" + pattern
+			}
+			ignore = append(ignore, compileRules([]string{pattern}, dir)...)
 		}
+		binary = append(binary, compileRules(rules.Binary, dir)...)
 	}
+	return ignore, binary
+}
 
-	for _, pattern := range ignoreRegexps {
-		if pattern.MatchString(path) {
-			Debug("Path should be ignored: %s", path)
+// matchRules tells whether the path is matched by the rules: by the last rule matching it, or
+// by one matching a directory above it, as the content of an excluded directory cannot be
+// re-included.
+func matchRules(rules []Rule, path string, isDir bool) bool {
+	last := func(path string, isDir bool) bool {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].Match(path, isDir) {
+				return !rules[i].Negate
+			}
+		}
+		return false
+	}
+	for i, c := range path {
+		if c == '/' && last(path[:i], true) {
 			return true
 		}
 	}
+	return last(path, isDir)
+}
+
+// normalizeRulePath makes the path relative to the root of the repository with forward slashes,
+// as the patterns are matched against.
+func normalizeRulePath(path string) string {
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+	if path == "." {
+		return ""
+	}
+	return path
+}
+
+func ShouldIgnore(path string) bool {
+	Debug("Checking if %s should be ignored...", path)
+	path = normalizeRulePath(path)
+	if path == "" {
+		return false
+	}
+	ignore, _ := rulesFor(path)
+	if len(ignore) == 0 {
+		return false
+	}
+	info, err := os.Lstat(path)
+	if matchRules(ignore, path, err == nil && info.IsDir()) {
+		Debug("Path should be ignored: %s", path)
+		return true
+	}
 	Debug("Path should not be ignored: %s", path)
 	return false
 }
+
+// IsBinaryPath tells whether the path matches the binary patterns of the rules files.
+func IsBinaryPath(path string) bool {
+	path = normalizeRulePath(path)
+	if path == "" {
+		return false
+	}
+	if _, binary := rulesFor(path); matchRules(binary, path, false) {
+		Debug("Path is binary: %s", path)
+		return true
+	}
+	return false
+}
@@ -5,43 +5,66 @@ import (
 	"testing"
 )
 
-func Test_patternToRegexp(t *testing.T) {
+func Test_ParseRule(t *testing.T) {
 	tests := []struct {
 		pattern     string
 		testPath    string
+		isDir       bool
 		shouldMatch bool
 		description string
 	}{
-		{"*.txt", "file.txt", true, "simple wildcard should match"},
-		{"*.txt", "dir/file.txt", true, "wildcard should match in subdirectory"},
-		{"*.go", "main.go", true, "wildcard should match go files"},
-		{"*.go", "main.txt", false, "wildcard should not match different extension"},
-		{"test*", "test.txt", true, "prefix wildcard should match"},
-		{"test*", "testing.go", true, "prefix wildcard should match longer name"},
-		{"**/*.txt", "a/b/c/file.txt", true, "double wildcard should match nested paths"},
-		{"**/*.go", "src/main.go", true, "double wildcard should match"},
-		{"dir/file.txt", "dir/file.txt", true, "exact path should match"},
-		{"dir/file.txt", "other/file.txt", false, "exact path should not match different dir"},
-		{"node_modules", "node_modules", true, "directory name should match"},
-		{"node_modules", "src/node_modules", true, "directory name should match in subdirectory"},
-		{"*.log", "app.log", true, "log files should match"},
+		{"*.txt", "file.txt", false, true, "simple wildcard should match"},
+		{"*.txt", "dir/file.txt", false, true, "wildcard should match in subdirectory"},
+		{"*.go", "main.txt", false, false, "wildcard should not match different extension"},
+		{"test*", "testing.go", false, true, "prefix wildcard should match longer name"},
+		{"file?.go", "file1.go", false, true, "question mark should match one character"},
+		{"file[0-9].go", "file1.go", false, true, "range should match"},
+		{"file[!0-9].go", "file1.go", false, false, "negated range should not match"},
+		{"a.b", "axb", false, false, "dot should not be a regexp"},
+		{"**/*.txt", "a/b/c/file.txt", false, true, "double wildcard should match nested paths"},
+		{"**/*.txt", "file.txt", false, true, "double wildcard should match no directory"},
+		{"a/**/b", "a/x/y/b", false, true, "double wildcard should match directories in between"},
+		{"a/**/b", "a/b", false, true, "double wildcard should match no directory in between"},
+		{"logs/**", "logs/a/b.log", false, true, "trailing double wildcard should match everything inside"},
+		{"logs/**", "logs", true, false, "trailing double wildcard should not match the directory"},
+		{"dir/file.txt", "dir/file.txt", false, true, "exact path should match"},
+		{"dir/file.txt", "other/dir/file.txt", false, false, "path should be anchored"},
+		{"/todo.txt", "todo.txt", false, true, "leading slash should match in the root"},
+		{"/todo.txt", "src/todo.txt", false, false, "leading slash should not match in subdirectory"},
+		{"node_modules", "src/node_modules", true, true, "name should match in subdirectory"},
+		{"build/", "build", true, true, "trailing slash should match directory"},
+		{"build/", "build", false, false, "trailing slash should not match file"},
+		{"build/", "src/build", true, true, "trailing slash should match directory in subdirectory"},
+		{`\#notes`, "#notes", false, true, "escaped hash should match"},
+		{`\!important`, "!important", false, true, "escaped exclamation mark should match"},
+		{`regex:^src/.*\.gen\.go$`, "src/a/b.gen.go", false, true, "regex should match"},
+		{`regex:^src/.*\.gen\.go$`, "lib/b.gen.go", false, false, "regex should not match"},
 	}
 
 	for _, test := range tests {
 		t.Run(test.description, func(t *testing.T) {
-			regex, err := patternToRegexp(test.pattern)
-			if err != nil {
-				t.Errorf("Failed to compile pattern '%s': %v", test.pattern, err)
-				return
+			rule, ok, err := ParseRule(test.pattern, "")
+			if err != nil || !ok {
+				t.Fatalf("Failed to compile pattern '%s': %v", test.pattern, err)
 			}
-
-			matched := regex.MatchString(test.testPath)
-			if matched != test.shouldMatch {
+			if matched := rule.Match(test.testPath, test.isDir); matched != test.shouldMatch {
 				t.Errorf("Pattern '%s' against path '%s': expected match=%v, got match=%v",
 					test.pattern, test.testPath, test.shouldMatch, matched)
 			}
 		})
 	}
+
+	for _, pattern := range []string{"", "  ", "# comment"} {
+		if _, ok, _ := ParseRule(pattern, ""); ok {
+			t.Errorf("Expected no rule for '%s'", pattern)
+		}
+	}
+	if rule, _, _ := ParseRule("!*.log", "src/"); !rule.Negate || !rule.Match("src/a/app.log", false) || rule.Match("app.log", false) {
+		t.Errorf("Expected a negated rule relative to its directory, got %+v", rule)
+	}
+	if _, _, err := ParseRule("regex:[", ""); err == nil {
+		t.Errorf("Expected an invalid regex to fail")
+	}
 }
 
 func Test_ShouldIgnore_NoRulesFile(t *testing.T) {
@@ -96,27 +119,159 @@ allow:
 	}
 }
 
-func Test_pathToRegexp_EmptyRules(t *testing.T) {
-	// Create a rules file with empty rules
+func Test_ShouldIgnore_EmptyRules(t *testing.T) {
 	emptyRules := `ignore: []
 allow: []
 `
-	err := os.WriteFile(".nexio.rules.yml", []byte(emptyRules), 0644)
-	if err != nil {
+	if err := os.WriteFile(".nexio.rules.yml", []byte(emptyRules), 0644); err != nil {
 		t.Fatalf("Failed to create test rules file: %v", err)
 	}
 	defer os.Remove(".nexio.rules.yml")
 
-	ignore, allow, err := pathToRegexp()
-	if err != nil {
-		t.Errorf("pathToRegexp should not fail with empty rules: %v", err)
+	if ignore, binary := rulesFor("test.log"); len(ignore) != 0 || len(binary) != 0 {
+		t.Errorf("Expected no rules, got %v %v", ignore, binary)
+	}
+	if ShouldIgnore("test.log") {
+		t.Errorf("Expected nothing to be ignored with empty rules")
+	}
+}
+
+func Test_ShouldIgnore_OrderAndNegation(t *testing.T) {
+	os.RemoveAll(namespace)
+	os.MkdirAll(namespace+"build", 0755)
+	os.MkdirAll(namespace+"docs", 0755)
+	rulesContent := `ignore:
+  - "*.log"
+  - "!keep.log"
+  - "keep.log"
+  - "!important.log"
+  - "__test__/build/"
+  - "!__test__/build/keep.txt"
+  - "__test__/docs/*"
+  - "!__test__/docs/index.md"
+  - "/root.txt"
+`
+	if err := os.WriteFile(".nexio.rules.yml", []byte(rulesContent), 0644); err != nil {
+		t.Fatalf("Failed to create test rules file: %v", err)
+	}
+	defer os.Remove(".nexio.rules.yml")
+
+	tests := []struct {
+		path         string
+		shouldIgnore bool
+		description  string
+	}{
+		{"app.log", true, "log files should be ignored"},
+		{"keep.log", true, "a later pattern should win over a negation"},
+		{"src/important.log", false, "negated pattern should re-include"},
+		{namespace + "build", true, "directory should be ignored"},
+		{namespace + "build/keep.txt", true, "files in an ignored directory cannot be re-included"},
+		{namespace + "docs/guide.md", true, "files matched by a wildcard should be ignored"},
+		{namespace + "docs/index.md", false, "files matched by a wildcard can be re-included"},
+		{"root.txt", true, "anchored pattern should match in the root"},
+		{namespace + "root.txt", false, "anchored pattern should not match in subdirectory"},
+		{"./app.log", true, "paths should be normalized"},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if result := ShouldIgnore(test.path); result != test.shouldIgnore {
+				t.Errorf("Path '%s': expected ignore=%v, got ignore=%v", test.path, test.shouldIgnore, result)
+			}
+		})
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_ShouldIgnore_NestedRulesFiles(t *testing.T) {
+	os.RemoveAll(namespace)
+	os.MkdirAll(namespace+"src/gen", 0755)
+	if err := os.WriteFile(".nexio.rules.yml", []byte("ignore:\n  - \"*.gen.go\"\n  - \"*.tmp\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test rules file: %v", err)
+	}
+	defer os.Remove(".nexio.rules.yml")
+	os.WriteFile(namespace+"src/"+RulesFileName, []byte("ignore:\n  - \"!keep.gen.go\"\n  - \"/gen/\"\nbinary:\n  - \"*.dat\"\n"), 0644)
+
+	tests := []struct {
+		path         string
+		shouldIgnore bool
+		description  string
+	}{
+		{namespace + "src/a.gen.go", true, "root rules should apply in subdirectories"},
+		{namespace + "src/keep.gen.go", false, "nested rules should take precedence"},
+		{namespace + "keep.gen.go", true, "nested rules should not apply outside their directory"},
+		{namespace + "src/gen/a.go", true, "anchored pattern should be relative to the rules file"},
+		{namespace + "gen/a.go", false, "anchored pattern should not match outside its directory"},
+		{namespace + "src/a.tmp", true, "root rules should still apply"},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if result := ShouldIgnore(test.path); result != test.shouldIgnore {
+				t.Errorf("Path '%s': expected ignore=%v, got ignore=%v", test.path, test.shouldIgnore, result)
+			}
+		})
+	}
+	if !IsBinaryPath(namespace+"src/a.dat") || IsBinaryPath(namespace+"a.dat") {
+		t.Errorf("Expected binary patterns of nested rules files to apply to their directory")
+	}
+
+	os.RemoveAll(namespace)
+}
+
+func Test_ShouldIgnore_GlobalExcludesFile(t *testing.T) {
+	os.RemoveAll(namespace)
+	runInitCommand()
+	excludesFile := namespace + "excludes"
+	os.WriteFile(excludesFile, []byte("# editor files\n*.swp\n.idea/\n*.bak\n"), 0644)
+	if returnCode := setConfig("excludes-file", excludesFile); returnCode != 603 {
+		t.Fatalf("Expected 603, got %d", returnCode)
+	}
+	if GlobalExcludesFile() != excludesFile {
+		t.Errorf("Expected the configured excludes file, got %s", GlobalExcludesFile())
+	}
+	if !ShouldIgnore("main.go.swp") || !ShouldIgnore("src/.idea/x.xml") || ShouldIgnore("main.go") {
+		t.Errorf("Expected the patterns of the excludes file to apply")
 	}
 
-	if len(ignore) != 0 {
-		t.Errorf("Expected 0 ignore patterns, got %d", len(ignore))
+	// The rules of the repository take precedence.
+	os.WriteFile(".nexio.rules.yml", []byte("ignore:\n  - \"!keep.bak\"\n"), 0644)
+	defer os.Remove(".nexio.rules.yml")
+	if !ShouldIgnore("old.bak") || ShouldIgnore("keep.bak") {
+		t.Errorf("Expected the rules file to override the excludes file")
 	}
 
-	if len(allow) != 0 {
-		t.Errorf("Expected 0 allow patterns, got %d", len(allow))
+	os.RemoveAll(namespace)
+}
+
+func Test_IsBinaryPath(t *testing.T) {
+	if IsBinaryPath("logo.svg") {
+		t.Errorf("Expected no binary paths without a rules file")
+	}
+	rulesContent := `binary:
+  - "*.svg"
+  - "assets/**"
+`
+	if err := os.WriteFile(".nexio.rules.yml", []byte(rulesContent), 0644); err != nil {
+		t.Fatalf("Failed to create test rules file: %v", err)
+	}
+	defer os.Remove(".nexio.rules.yml")
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"logo.svg", true},
+		{"images/logo.svg", true},
+		{"assets/data.json", true},
+		{"src/assets.go", false},
+		{"main.go", false},
+	}
+	for _, test := range tests {
+		if result := IsBinaryPath(test.path); result != test.expected {
+			t.Errorf("Path '%s': expected binary=%v, got %v", test.path, test.expected, result)
+		}
+	}
+	if !IsBinaryFile("main.go", []byte("text"), []byte("\x00")) || IsBinaryFile("main.go", []byte("text")) {
+		t.Errorf("Expected files with a NUL byte to be binary")
 	}
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	serveCmd.Flags().BoolVar(&ServeAllowForce, "allow-force", false, "Accept forced pushes that are not a fast-forward")
+	serveCmd.Flags().StringVarP(&ServeAddr, "addr", "a", "127.0.0.1:8080", "Address to listen on, e.g. :8080 for all interfaces")
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+var (
+	ServeAddr       string
+	ServeAllowForce bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:     "serve",
+	Short:   "Serve the repository to remotes over HTTP",
+	Example: "nexio serve\nnexio serve --addr :9000",
+	Args:    cobra.NoArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting serve command: addr=%s, allow-force=%v", ServeAddr, ServeAllowForce)
+		runServeCommand(ServeAddr, ServeAllowForce)
+	},
+}
+
+func runServeCommand(addr string, allowForce bool) int {
+	if initialized := IsInitialized(); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           NewRemoteHandler(store, allowForce),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	BreakLine()
+	Info("Serving repository on " + Code(addr))
+	Text("Clone it with "+Code("nexio clone "+ServeUrl(addr)), "")
+	BreakLine()
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		Debug("Server failed: %v", err)
+		Fail(SERVE_RETURN_CODES[1802] + " " + err.Error())
+		return 1802
+	}
+	Info(SERVE_RETURN_CODES[1801])
+	return 1801
+}
+
+// ServeUrl is the URL clients use for a server listening on addr, a placeholder stands for the host
+// when it listens on all interfaces.
+func ServeUrl(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "http://<host>" + addr
+	}
+	return "http://" + addr
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"os"
 	"path/filepath"
 	"slices"
@@ -15,36 +14,74 @@ type LogFileEntry struct {
 	Id   string `json:"id"`
 	Op   string `json:"op"`
 	Path string `json:"path"`
+	// From is the previous path of a REN entry, and the source of the CPY entries FoldRenames shows
+	// for detected copies.
+	From string `json:"from,omitempty"`
+}
+
+// StagingOps maps the logged operations to the staging directories holding their files.
+var StagingOps = map[string]string{
+	"ADD": "added",
+	"MOD": "modified",
+	"REM": "removed",
+	"REN": "renamed",
+	// Conflicts keep the versions listed in ConflictVersions instead of a single file.
+	"CON": "conflicted",
 }
 
 var (
 	add = color.New(color.FgGreen).SprintFunc()
 	mod = color.New(color.FgYellow).SprintFunc()
 	rem = color.New(color.FgRed).SprintFunc()
+	ren = color.New(color.FgCyan).SprintFunc()
 )
 
+// logOpOrder is the order in which status and history list the operations.
+var logOpOrder = []string{"ADD", "MOD", "REN", "CPY", "REM"}
+
 func LogOperation(id string, op string, path string) {
 	Debug("Logging operation: id=%s, op=%s, path=%s", id, op, path)
+	appendLogEntry(LogFileEntry{Id: id, Op: op, Path: path})
+}
+
+// LogRename logs the move of a committed file, whose new content is staged under id.
+func LogRename(id string, from string, path string) {
+	Debug("Logging rename: id=%s, from=%s, path=%s", id, from, path)
+	appendLogEntry(LogFileEntry{Id: id, Op: "REN", Path: path, From: from})
+}
 
-	err := WithLock(dirs.StagingLogs, DefaultLockTimeout, func() error {
-		logs, err := os.ReadFile(dirs.StagingLogs)
+// RenameLookup finds the REN entry moving a file to path.
+func RenameLookup(path string) (isLogged bool, entry LogFileEntry) {
+	return findRename(func(entry LogFileEntry) bool { return entry.Path == path })
+}
+
+// RenameSourceLookup finds the REN entry moving a file away from path.
+func RenameSourceLookup(path string) (isLogged bool, entry LogFileEntry) {
+	return findRename(func(entry LogFileEntry) bool { return entry.From == path })
+}
+
+func findRename(match func(entry LogFileEntry) bool) (bool, LogFileEntry) {
+	for _, entry := range *GetStagingLogsContent() {
+		if entry.Op == "REN" && match(entry) {
+			Debug("Found rename: id=%s, from=%s, path=%s", entry.Id, entry.From, entry.Path)
+			return true, entry
+		}
+	}
+	return false, LogFileEntry{}
+}
+
+func appendLogEntry(entry LogFileEntry) {
+	err := store.WithLock(StagingLogsLock, func() error {
+		content, err := store.ReadStagingLogs()
 		if err != nil {
 			Debug("Failed to read staging logs")
 			return err
 		}
-		var content []LogFileEntry
-		if len(logs) > 0 {
-			if err = json.Unmarshal(logs, &content); err != nil {
-				Debug("Failed to unmarshal staging logs")
-				return err
-			}
+		content = append(content, entry)
+		if err := store.WriteStagingLogs(content); err != nil {
+			Debug("Failed to write staging logs")
+			return err
 		}
-		content = append(content, LogFileEntry{
-			Id:   id,
-			Op:   op,
-			Path: path,
-		})
-		WriteJson(dirs.StagingLogs, content)
 		Debug("Operation logged successfully")
 		return nil
 	})
@@ -56,23 +93,11 @@ func LogOperation(id string, op string, path string) {
 
 func LogEntryLookup(op string, path string) (isLogged bool, logId string, operation string) {
 	Debug("Looking up log entry: op=%s, path=%s", op, path)
-	logs, err := os.ReadFile(dirs.StagingLogs)
-	if err != nil {
-		Debug("Failed to read staging logs")
-		MustSucceed(err, "operation failed")
-	}
-	var content []LogFileEntry
-	if len(logs) > 0 {
-		if err = json.Unmarshal(logs, &content); err != nil {
-			Debug("Failed to unmarshal staging logs")
-			MustSucceed(err, "operation failed")
-		}
-		for _, entry := range content {
-			// Consider op "*" as a wildcard.
-			if op == "*" && entry.Path == path || entry.Op == op && entry.Path == path {
-				Debug("Found log entry: id=%s, op=%s", entry.Id, entry.Op)
-				return true, entry.Id, entry.Op
-			}
+	for _, entry := range *GetStagingLogsContent() {
+		// Consider op "*" as a wildcard.
+		if op == "*" && entry.Path == path || entry.Op == op && entry.Path == path {
+			Debug("Found log entry: id=%s, op=%s", entry.Id, entry.Op)
+			return true, entry.Id, entry.Op
 		}
 	}
 	Debug("No matching log entry found")
@@ -81,44 +106,23 @@ func LogEntryLookup(op string, path string) (isLogged bool, logId string, operat
 
 func IsStagingLogsEmpty() bool {
 	Debug("Checking if staging logs are empty")
-	logs, err := os.ReadFile(dirs.StagingLogs)
-	if err != nil {
-		Debug("Failed to read staging logs")
-		MustSucceed(err, "operation failed")
-	}
-	var content []LogFileEntry
-	if len(logs) > 0 {
-		if err = json.Unmarshal(logs, &content); err != nil {
-			Debug("Failed to unmarshal staging logs")
-			MustSucceed(err, "operation failed")
-		}
-		if len(content) == 0 {
-			Debug("Staging logs are empty")
-			return true
-		}
-		Debug("Staging logs are not empty")
-		return false
+	if len(*GetStagingLogsContent()) == 0 {
+		Debug("Staging logs are empty")
+		return true
 	}
-	Debug("Staging logs file is empty")
-	return true
+	Debug("Staging logs are not empty")
+	return false
 }
 
 func RemoveLogEntry(id string) {
 	Debug("Removing log entry: id=%s", id)
 
-	err := WithLock(dirs.StagingLogs, DefaultLockTimeout, func() error {
-		logs, err := os.ReadFile(dirs.StagingLogs)
+	err := store.WithLock(StagingLogsLock, func() error {
+		content, err := store.ReadStagingLogs()
 		if err != nil {
 			Debug("Failed to read staging logs")
 			return err
 		}
-		var content []LogFileEntry
-		if len(logs) > 0 {
-			if err = json.Unmarshal(logs, &content); err != nil {
-				Debug("Failed to unmarshal staging logs")
-				return err
-			}
-		}
 		for i, entry := range content {
 			if entry.Id == id {
 				Debug("Found and removing log entry: id=%s, op=%s", entry.Id, entry.Op)
@@ -126,7 +130,10 @@ func RemoveLogEntry(id string) {
 				break
 			}
 		}
-		WriteJson(dirs.StagingLogs, content)
+		if err := store.WriteStagingLogs(content); err != nil {
+			Debug("Failed to write staging logs")
+			return err
+		}
 		Debug("Log entry removed successfully")
 		return nil
 	})
@@ -139,8 +146,11 @@ func RemoveLogEntry(id string) {
 func TruncateLogs() {
 	Debug("Truncating staging logs")
 
-	err := WithLock(dirs.StagingLogs, DefaultLockTimeout, func() error {
-		WriteJson(dirs.StagingLogs, []LogFileEntry{})
+	err := store.WithLock(StagingLogsLock, func() error {
+		if err := store.WriteStagingLogs([]LogFileEntry{}); err != nil {
+			Debug("Failed to write staging logs")
+			return err
+		}
 		Debug("Staging logs truncated successfully")
 		return nil
 	})
@@ -152,44 +162,28 @@ func TruncateLogs() {
 
 func GetStagingLogsContent() (result *[]LogFileEntry) {
 	Debug("Getting staging logs content")
-	logs, err := os.ReadFile(dirs.StagingLogs)
+	content, err := store.ReadStagingLogs()
 	if err != nil {
 		Debug("Failed to read staging logs")
 		MustSucceed(err, "operation failed")
 	}
-	var content []LogFileEntry
-	if len(logs) > 0 {
-		if err = json.Unmarshal(logs, &content); err != nil {
-			Debug("Failed to unmarshal staging logs")
-			MustSucceed(err, "operation failed")
-		}
-	} else {
-		content = []LogFileEntry{}
-		Debug("Staging logs are empty")
-		return &content
-	}
 	Debug("Retrieved %d log entries", len(content))
 	return &content
 }
 
 func SortByOperationAndPath(content []LogFileEntry) (result *[]LogFileEntry) {
 	Debug("Sorting log entries by operation and path")
-	sort.Slice(content, func(i, j int) bool {
-		if content[i].Op == "ADD" && content[j].Op == "MOD" {
-			return true
-		}
-		if content[i].Op == "ADD" && content[j].Op == "REM" {
-			return true
+	rank := func(op string) int {
+		if index := slices.Index(logOpOrder, op); index != -1 {
+			return index
 		}
-		if content[i].Op == "MOD" && content[j].Op == "REM" {
-			return true
-		}
-		if content[i].Op == content[j].Op {
-			if content[i].Path < content[j].Path {
-				return true
-			}
+		return len(logOpOrder)
+	}
+	sort.Slice(content, func(i, j int) bool {
+		if rank(content[i].Op) != rank(content[j].Op) {
+			return rank(content[i].Op) < rank(content[j].Op)
 		}
-		return false
+		return content[i].Path < content[j].Path
 	})
 	Debug("Log entries sorted successfully")
 	return &content
@@ -200,16 +194,7 @@ func PrintLogs(content []LogFileEntry) {
 	sortedContent := SortByOperationAndPath(content)
 	log := []string{}
 	for _, logEntry := range *sortedContent {
-		switch logEntry.Op {
-		case "ADD":
-			log = append(log, add(" "+logEntry.Op+":")+" "+logEntry.Path)
-		case "MOD":
-			log = append(log, mod(" "+logEntry.Op+":")+" "+logEntry.Path)
-		case "REM":
-			log = append(log, rem(" "+logEntry.Op+":")+" "+logEntry.Path)
-		default:
-			log = append(log, logEntry.Op+" "+logEntry.Path)
-		}
+		log = append(log, formatLogEntry(logEntry))
 	}
 	Tree(log, false)
 	Debug("Log entries printed successfully")
@@ -224,16 +209,7 @@ func FormatLogs(content []LogFileEntry) string {
 	sortedContent := SortByOperationAndPath(content)
 	log := []string{}
 	for _, logEntry := range *sortedContent {
-		switch logEntry.Op {
-		case "ADD":
-			log = append(log, add(" "+logEntry.Op+":")+" "+logEntry.Path)
-		case "MOD":
-			log = append(log, mod(" "+logEntry.Op+":")+" "+logEntry.Path)
-		case "REM":
-			log = append(log, rem(" "+logEntry.Op+":")+" "+logEntry.Path)
-		default:
-			log = append(log, logEntry.Op+" "+logEntry.Path)
-		}
+		log = append(log, formatLogEntry(logEntry))
 	}
 
 	// Format as tree structure
@@ -249,15 +225,35 @@ func FormatLogs(content []LogFileEntry) string {
 	return result.String()
 }
 
+// formatLogEntry writes an entry the way status and history list it, renames and copies with
+// the path they come from.
+func formatLogEntry(entry LogFileEntry) string {
+	switch entry.Op {
+	case "ADD":
+		return add(" "+entry.Op+":") + " " + entry.Path
+	case "MOD":
+		return mod(" "+entry.Op+":") + " " + entry.Path
+	case "REM":
+		return rem(" "+entry.Op+":") + " " + entry.Path
+	case "REN":
+		return ren(" "+entry.Op+":") + " " + entry.From + " -> " + entry.Path
+	case "CPY":
+		return ren(" "+entry.Op+":") + " " + entry.From + " -> " + entry.Path
+	}
+	return entry.Op + " " + entry.Path
+}
+
+// CountOps counts the added, modified and removed files. Copies count as added files and
+// renames as modified ones.
 func CountOps(content []LogFileEntry) (add int, mod int, rem int) {
 	add = 0
 	mod = 0
 	rem = 0
 	for _, entry := range content {
-		if entry.Op == "ADD" {
+		if entry.Op == "ADD" || entry.Op == "CPY" {
 			add++
 		}
-		if entry.Op == "MOD" {
+		if entry.Op == "MOD" || entry.Op == "REN" {
 			mod++
 		}
 		if entry.Op == "REM" {
@@ -276,17 +272,7 @@ func ValidateStagingIntegrity() []string {
 
 	// Check if all logged files exist in staging
 	for _, entry := range *logs {
-		var stagingPath string
-		switch entry.Op {
-		case "ADD":
-			stagingPath = dirs.StagingAdded + entry.Id
-		case "MOD":
-			stagingPath = dirs.StagingModified + entry.Id
-		case "REM":
-			stagingPath = dirs.StagingRemoved + entry.Id
-		}
-
-		if _, err := os.Stat(stagingPath); os.IsNotExist(err) {
+		if !isStaged(store, entry) {
 			Debug("Found orphaned log entry: %s (path: %s)", entry.Id, entry.Path)
 			orphanedIds = append(orphanedIds, entry.Id)
 		}
@@ -353,19 +339,27 @@ func GetUntrackedFiles() []string {
 }
 
 func GetModifiedOrDeletedFiles() (modified []string, deleted []string) {
-	Debug("Getting modified or deleted files")
-	lastCommit := GetLastCommit()
-	if lastCommit.Id == "" {
+	return GetModifiedOrDeletedFilesSince(GetLastCommit().Id)
+}
+
+// GetModifiedOrDeletedFilesSince compares the working directory with the files of a commit, the one
+// checked out during a bisect rather than the head of the branch.
+func GetModifiedOrDeletedFilesSince(commitId string) (modified []string, deleted []string) {
+	Debug("Getting modified or deleted files since %s", commitId)
+	if commitId == "" {
 		return nil, nil
 	}
 
-	fileList := GetFileListContent(lastCommit.Id)
+	fileList := GetFileListContent(commitId)
 
 	for _, file := range *fileList {
-		// Skip if staged already
+		// Skip if staged already, or moved away by a staged rename
 		if IsFileStaged(file.Path) {
 			continue
 		}
+		if renamed, _ := RenameSourceLookup(file.Path); renamed {
+			continue
+		}
 
 		// Check if file exists in working directory
 		if !FileExists(file.Path) {
@@ -373,12 +367,50 @@ func GetModifiedOrDeletedFiles() (modified []string, deleted []string) {
 			continue
 		}
 
-		_, fileName := ParsePath(file.Path)
-
-		if isModified, _ := IsModified(file.Path, dirs.Commits+file.CommitId+"/"+file.Id+"/"+fileName); isModified {
+		if isModified, _ := IsModifiedFromObject(file.Path, file.CommitId, file.Id); isModified {
 			modified = append(modified, file.Path)
 		}
 	}
 
 	return modified, deleted
 }
+
+// GetIgnoredFiles lists the files matched by the rules that are neither staged nor committed.
+func GetIgnoredFiles() []string {
+	Debug("Getting ignored files")
+
+	ignored := []string{}
+	filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if strings.Contains(path, ".nexio") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !ShouldIgnore(path) || IsFileStaged(path) {
+			return nil
+		}
+		if isCommitted, _, _ := GetFileMetadata(path); isCommitted {
+			return nil
+		}
+		ignored = append(ignored, path)
+		return nil
+	})
+
+	Debug("Found %d ignored files.", len(ignored))
+	return ignored
+}
+
+// isStaged tells whether the file of a staging log entry is staged, the versions of a conflict
+// are staged as `<id>.<version>`.
+func isStaged(s Storage, entry LogFileEntry) bool {
+	if entry.Op == "CON" {
+		return slices.ContainsFunc(ConflictVersions, func(version string) bool {
+			return s.HasStagedFile(StagingOps["CON"], conflictVersionId(entry.Id, version))
+		})
+	}
+	return s.HasStagedFile(StagingOps[entry.Op], entry.Id)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 
 	"github.com/pterm/pterm"
@@ -28,29 +29,65 @@ func runStatusCommand() (returnCode int, stagingLogs []LogFileEntry) {
 		Fail(COMMON_RETURN_CODES[001])
 		return 001, nil
 	}
+
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		Fail(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
 	content := GetStagingLogsContent()
 	currentBranch := GetCurrentBranchName()
 	commitCount := CountCommits()
 	lastCommit := GetLastCommit()
+	summary := fmt.Sprintf(pterm.FgCyan.Sprint(" ")+"Branch: %s\n"+pterm.FgCyan.Sprint(" ")+"Commits: %d\n"+pterm.FgCyan.Sprint(" ")+"Last commit: %s", currentBranch, commitCount, TimeAgo(lastCommit.Timestamp))
+	if tracking := DescribeTracking(currentBranch); tracking != "" {
+		summary += "\n" + pterm.FgCyan.Sprint(" ") + "Upstream: " + tracking
+	}
+	checkedOut := lastCommit.Id
+	if bisect, err := store.ReadBisectState(); err == nil {
+		checkedOut = bisect.Current
+		summary += "\n" + pterm.FgCyan.Sprint(" ") + "Bisecting: " + StyledCommit(bisect.Current) + " checked out"
+	}
 	BreakLine()
-	Box(Bold("Status"), fmt.Sprintf(pterm.FgCyan.Sprint(" ")+"Branch: %s\n"+pterm.FgCyan.Sprint(" ")+"Commits: %d\n"+pterm.FgCyan.Sprint(" ")+"Last commit: %s", currentBranch, commitCount, TimeAgo(lastCommit.Timestamp)))
+	Box(Bold("Status"), summary)
 	BreakLine()
-	if len(*content) != 0 {
-		Debug("Found %d files staged for commit.", len(*content))
+	staged := slices.DeleteFunc(slices.Clone(*content), func(entry LogFileEntry) bool { return entry.Op == "CON" })
+	staged = FoldRenames(staged, DetectStagedRenames(staged))
+	if len(staged) != 0 {
+		Debug("Found %d files staged for commit.", len(staged))
 		BreakLine()
-		Info("Staged changes " + "(" + strconv.Itoa(len(*content)) + ")")
-		PrintLogs(*content)
+		Info("Staged changes " + "(" + strconv.Itoa(len(staged)) + ")")
+		PrintLogs(staged)
 	} else {
 		Debug("%s", STATUS_RETURN_CODES[501])
 	}
 
-	modified, deleted := GetModifiedOrDeletedFiles()
+	conflicts := GetConflicts()
+	if len(conflicts) != 0 {
+		Debug("Found %d unmerged paths.", len(conflicts))
+		BreakLine()
+		Info("Unmerged paths " + "(" + strconv.Itoa(len(conflicts)) + ")")
+		unmerged := []string{}
+		for _, conflict := range conflicts {
+			unmerged = append(unmerged, pterm.FgRed.Sprint(" CON: ")+conflict.Path+" ("+conflict.Describe()+")")
+		}
+		Tree(unmerged, false)
+		BreakLine()
+		Text("Use "+Code("nexio add <file>...")+" to mark resolution or "+Code("nexio mergetool")+" to resolve", "")
+	}
+
+	modified, deleted := GetModifiedOrDeletedFilesSince(checkedOut)
 	if len(modified) > 0 || len(deleted) > 0 {
 		Debug("Found %d tracked files that have been modified or deleted.", len(modified)+len(deleted))
 		BreakLine()
 		Info("Unstaged changes " + "(" + strconv.Itoa(len(modified)+len(deleted)) + ")")
 		for i, file := range modified {
 			modified[i] = pterm.FgYellow.Sprint(" MOD: ") + file
+			if change := DescribeModeChange(file); change != "" {
+				modified[i] += " (" + change + ")"
+			}
 		}
 		for i, file := range deleted {
 			deleted[i] = pterm.FgRed.Sprint(" REM: ") + file
@@ -1,33 +1,34 @@
 package main
 
 import (
-	"encoding/json"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 )
 
 type FileListEntry struct {
 	Id       string `json:"id"`
 	CommitId string `json:"commitId"`
 	Path     string `json:"path"`
+	// Mode holds the permissions and Type the kind of the file, see FileType. Both are empty in
+	// file lists written before they were recorded.
+	Mode os.FileMode `json:"mode,omitempty"`
+	Type string      `json:"type,omitempty"`
+}
+
+// IsDir tells whether the entry is a tracked empty directory.
+func (f FileListEntry) IsDir() bool {
+	return f.Type == "dir"
 }
 
 func IsFileStaged(filePath string) bool {
 	Debug("Checking if file is staged: %s", filePath)
-	logs, err := os.ReadFile(dirs.StagingLogs)
+	content, err := store.ReadStagingLogs()
 	if err != nil {
 		Debug("Failed to read staging logs")
 		MustSucceed(err, "operation failed")
 	}
-
-	if len(logs) == 0 {
-		Debug("No staging logs found")
-		return false
-	}
-	var content []LogFileEntry
-	if err = json.Unmarshal(logs, &content); err != nil {
-		Debug("Failed to unmarshal staging logs")
-		MustSucceed(err, "operation failed")
-	}
 	for _, entry := range content {
 		if entry.Path == filePath {
 			Debug("File is staged with operation: %s", entry.Op)
@@ -45,17 +46,11 @@ func GetFileMetadata(filePath string) (isCommitted bool, commitId string, fileId
 		Debug("No commits found")
 		return false, "", ""
 	}
-	fileList, err := os.ReadFile(dirs.Commits + latestCommitId + "/fileList.json")
+	content, err := store.ReadFileList(latestCommitId)
 	if err != nil {
 		Debug("Failed to read file list")
 		MustSucceed(err, "operation failed")
 	}
-
-	var content []FileListEntry
-	if err = json.Unmarshal(fileList, &content); err != nil {
-		Debug("Failed to unmarshal file list")
-		MustSucceed(err, "operation failed")
-	}
 	for _, file := range content {
 		if file.Path == filePath {
 			Debug("File found in commit: id=%s, commitId=%s", file.Id, file.CommitId)
@@ -74,3 +69,67 @@ func IsFileDeleted(filePath string) bool {
 	Debug("File deletion status: committed=%v, exists=%v, isDeleted=%v", committed, existsInWorkdir, isDeleted)
 	return isDeleted
 }
+
+// TrackedFiles returns the tracked file at path, or the tracked files under it if it is a directory:
+// files of the last commit that were not moved away, and files staged as added, modified or moved.
+func TrackedFiles(path string) []string {
+	Debug("Getting tracked files: %s", path)
+	path = filepath.Clean(path)
+	under := func(file string) bool {
+		return path == "." || file == path || strings.HasPrefix(file, path+"/")
+	}
+	tracked := []string{}
+	if lastCommit := GetLastCommit(); lastCommit.Id != "" {
+		for _, file := range *GetFileListContent(lastCommit.Id) {
+			if renamed, _ := RenameSourceLookup(file.Path); under(file.Path) && !renamed {
+				tracked = append(tracked, file.Path)
+			}
+		}
+	}
+	for _, entry := range *GetStagingLogsContent() {
+		if (entry.Op == "ADD" || entry.Op == "REN") && under(entry.Path) {
+			tracked = append(tracked, entry.Path)
+		}
+	}
+	slices.Sort(tracked)
+	return slices.Compact(tracked)
+}
+
+// HasUnstagedChanges reports whether a file of the working directory differs from its staged version,
+// or from the committed one if it is not staged. A deleted file has none.
+func HasUnstagedChanges(path string) bool {
+	if !FileExists(path) {
+		return false
+	}
+	modified := false
+	var err error
+	if staged, id, op := LogEntryLookup("*", path); staged {
+		if op == "REM" || op == "CON" {
+			return true
+		}
+		modified, err = IsModifiedFromStaged(path, StagingOps[op], id)
+	} else if committed, commitId, fileId := GetFileMetadata(path); committed {
+		modified, err = IsModifiedFromObject(path, commitId, fileId)
+	}
+	if err != nil {
+		Debug("Failed to compare %s: %v", path, err)
+		MustSucceed(err, "operation failed")
+	}
+	return modified
+}
+
+// DescribeModeChange tells how the mode of a file differs from its committed version, e.g.
+// `mode 100644 -> 100755`, empty if it does not.
+func DescribeModeChange(path string) string {
+	committed, commitId, fileId := GetFileMetadata(path)
+	info, err := os.Lstat(path)
+	if !committed || err != nil {
+		return ""
+	}
+	_, fileName := ParsePath(path)
+	_, mode, err := store.ReadObject(commitId, fileId, fileName)
+	if err != nil || !ModeChanged(mode, info.Mode()) {
+		return ""
+	}
+	return "mode " + gitFileMode(mode) + " -> " + gitFileMode(info.Mode())
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ObjectStore holds the commits: the committed file contents and the JSON documents describing each commit.
+type ObjectStore interface {
+	ListCommits() ([]string, error)
+	HasCommit(commitId string) bool
+	RemoveCommit(commitId string) error
+
+	ReadObject(commitId string, fileId string, fileName string) ([]byte, os.FileMode, error)
+	WriteObject(commitId string, fileId string, fileName string, data []byte, mode os.FileMode) error
+	ReadCommitMetadata(commitId string) (CommitMetadata, error)
+	WriteCommitMetadata(commitId string, metadata CommitMetadata) error
+	ReadCommitLogs(commitId string) ([]LogFileEntry, error)
+	WriteCommitLogs(commitId string, logs []LogFileEntry) error
+	ReadFileList(commitId string) ([]FileListEntry, error)
+	WriteFileList(commitId string, fileList []FileListEntry) error
+}
+
+// RefStore holds the branches, each of them a linked list of commits, the default and current branch names
+// and the remote-tracking refs.
+type RefStore interface {
+	ListBranches() ([]string, error)
+	// CreateBranch fails with an error satisfying os.IsExist if the branch already exists.
+	CreateBranch(branch string, commits []Commit) error
+	RemoveBranch(branch string) error
+	ReadBranchCommits(branch string) ([]Commit, error)
+	WriteBranchCommits(branch string, commits []Commit) error
+	ReadBranchesMetadata() (BranchMetadata, error)
+	WriteBranchesMetadata(metadata BranchMetadata) error
+
+	// Remote-tracking refs record the commits of a remote branch as last seen by fetch, push or pull.
+	// ListTrackedRemotes returns the remotes having some, even if they are no longer configured.
+	ListTrackedRemotes() ([]string, error)
+	ListRemoteRefs(remote string) ([]string, error)
+	ReadRemoteRef(remote string, branch string) ([]Commit, error)
+	WriteRemoteRef(remote string, branch string, commits []Commit) error
+	RemoveRemoteRef(remote string, branch string) error
+}
+
+// StagingStore holds the staging logs and a copy of every staged file, grouped by operation (`added`, `modified`, `removed`).
+type StagingStore interface {
+	ReadStagingLogs() ([]LogFileEntry, error)
+	WriteStagingLogs(logs []LogFileEntry) error
+	ReadStagedFile(op string, id string, fileName string) ([]byte, os.FileMode, error)
+	WriteStagedFile(op string, id string, fileName string, data []byte, mode os.FileMode) error
+	HasStagedFile(op string, id string) bool
+	RemoveStagedFile(op string, id string) error
+	ClearStagedFiles() error
+}
+
+type ConfigStore interface {
+	ReadConfig() (Config, error)
+	WriteConfig(config Config) error
+}
+
+// BisectStore holds the state of a bisect between invocations, it only exists while one is in progress.
+type BisectStore interface {
+	ReadBisectState() (BisectState, error)
+	WriteBisectState(state BisectState) error
+	RemoveBisectState() error
+}
+
+// CherryPickStore holds a cherry-pick stopped by conflicts, it only exists until it is continued or aborted.
+type CherryPickStore interface {
+	ReadCherryPickState() (CherryPickState, error)
+	WriteCherryPickState(state CherryPickState) error
+	RemoveCherryPickState() error
+}
+
+// RebaseStore holds the progress of a rebase, it exists from the start of a rebase until it finishes or is aborted.
+type RebaseStore interface {
+	ReadRebaseState() (RebaseState, error)
+	WriteRebaseState(state RebaseState) error
+	RemoveRebaseState() error
+}
+
+// DroppedCommitStore records when commits were removed from a branch, the grace period of gc starts then.
+type DroppedCommitStore interface {
+	ReadDroppedCommits() ([]DroppedCommit, error)
+	WriteDroppedCommits(dropped []DroppedCommit) error
+}
+
+// MaintenanceStore is what fsck, gc and unlock inspect beyond the documents: how committed files are
+// stored, the chunks of large files, the entries of the staging area and the lock files. Paths name
+// entries relative to the repository, e.g. `commits/<id>`, and are only used to report them.
+type MaintenanceStore interface {
+	// HasObject tells whether the content of a committed file is stored, whole or as a chunk manifest.
+	HasObject(commitId string, fileId string, fileName string) bool
+	// ObjectChunks returns the chunks of a committed file, chunked is false for a file stored whole.
+	ObjectChunks(commitId string, fileId string, fileName string) (chunks []string, chunked bool, err error)
+	ListChunks() ([]string, error)
+	HasChunk(id string) bool
+	RemoveChunk(id string) error
+	// ListStagedFiles returns the ids staged for op, `<id>.<version>` for the versions of a conflict.
+	ListStagedFiles(op string) ([]string, error)
+	// CommitTime returns when a commit was last written.
+	CommitTime(commitId string) (time.Time, error)
+	// Usage returns the bytes taken by the entry at path.
+	Usage(path string) int64
+	ListLocks() ([]LockInfo, error)
+	// RemoveLock removes a lock unless a process holds it, or anyway with force, and tells whether it did.
+	RemoveLock(path string, force bool) bool
+}
+
+// Storage is where a repository keeps its data. Reads of missing data return an error satisfying os.IsNotExist.
+type Storage interface {
+	ObjectStore
+	RefStore
+	StagingStore
+	ConfigStore
+	BisectStore
+	CherryPickStore
+	RebaseStore
+	DroppedCommitStore
+	MaintenanceStore
+
+	// Initialize creates an empty repository with the initial branch and no commits.
+	Initialize() error
+	Exists() bool
+	ReadFormatVersion() (int, error)
+	WriteFormatVersion(version int) error
+	// Backup copies the repository before a migration and returns where the copy was written.
+	Backup(name string) (string, error)
+
+	// WithLock runs fn while holding the named lock, guarding a read-modify-write of a single document.
+	WithLock(name string, fn func() error) error
+	// LockRepository takes the repository-wide lock and returns a function releasing it.
+	LockRepository(mode string) (release func(), err error)
+}
+
+// Lock names used with Storage.WithLock.
+const (
+	StagingLogsLock      = "staging/logs.json"
+	BranchesMetadataLock = "branches/metadata.json"
+	DroppedCommitsLock   = "dropped.json"
+)
+
+func BranchCommitsLock(branch string) string {
+	return "branches/" + branch + "/commits"
+}
+
+// store is the storage of the repository in the working directory. Maintenance commands (fsck,
+// gc, unlock) are given the storage to work on instead.
+var store Storage = NewFileStorage(dirs)
+
+// lockMutex locks the mutex in the given mode, retrying until the deadline.
+func lockMutex(mutex *sync.RWMutex, mode string, deadline time.Time) (unlock func(), err error) {
+	for {
+		var locked bool
+		if mode == SharedLock {
+			locked = mutex.TryRLock()
+		} else {
+			locked = mutex.TryLock()
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			Debug("Repository lock acquisition timeout")
+			return nil, errors.New("Repository lock acquisition timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return func() {
+		if mode == SharedLock {
+			mutex.RUnlock()
+		} else {
+			mutex.Unlock()
+		}
+	}, nil
+}
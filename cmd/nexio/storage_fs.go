@@ -0,0 +1,506 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStorage keeps the repository in a `.nexio` directory, see dirs.go for the layout.
+type FileStorage struct {
+	dirs Dirs
+	// mutex guards the repository within the process, the lock file guards it across processes.
+	mutex sync.RWMutex
+}
+
+func NewFileStorage(dirs Dirs) *FileStorage {
+	return &FileStorage{dirs: dirs}
+}
+
+// readJsonDocument reads a JSON document, an empty file leaves v untouched.
+func readJsonDocument(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJsonDocument(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	parent, _ := ParsePath(path)
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func listSubdirectories(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (s *FileStorage) Initialize() error {
+	Debug("Creating repository layout: %s", s.dirs.Root)
+	for _, dir := range []string{s.dirs.StagingAdded, s.dirs.StagingModified, s.dirs.StagingRemoved, s.dirs.StagingRenamed, s.dirs.StagingConflicted, s.dirs.Commits, s.dirs.Chunks, s.dirs.DefaultBranch, s.dirs.RemoteRefs, s.dirs.Backups} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	if err := s.WriteStagingLogs([]LogFileEntry{}); err != nil {
+		return err
+	}
+	return s.WriteBranchCommits(InitBranch, []Commit{})
+}
+
+func (s *FileStorage) Exists() bool {
+	_, err := os.Stat(s.dirs.Root)
+	return !os.IsNotExist(err)
+}
+
+func (s *FileStorage) ReadFormatVersion() (int, error) {
+	var format Format
+	if err := readJsonDocument(s.dirs.Format, &format); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return format.Version, nil
+}
+
+func (s *FileStorage) WriteFormatVersion(version int) error {
+	return writeJsonDocument(s.dirs.Format, Format{Version: version})
+}
+
+// Backup copies `.nexio` into `.nexio/backups/<name>/`, without the earlier backups.
+func (s *FileStorage) Backup(name string) (string, error) {
+	backup := s.dirs.Backups + name + "/"
+	if err := CopyDir(s.dirs.Root, backup, s.dirs.Backups); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+func (s *FileStorage) WithLock(name string, fn func() error) error {
+	return WithLock(s.dirs.Root+name, LockTimeout(), fn)
+}
+
+func (s *FileStorage) LockRepository(mode string) (release func(), err error) {
+	Debug("Attempting to acquire repository lock: mode=%s", mode)
+	deadline := time.Now().Add(LockTimeout())
+	unlock, err := lockMutex(&s.mutex, mode, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	path := s.dirs.Root + RepoLockName
+	for {
+		var lockFile *os.File
+		if mode == SharedLock {
+			lockFile, err = openSharedLockFile(path)
+		} else {
+			lockFile, err = openLockFile(path)
+		}
+		if err == nil {
+			if mode == ExclusiveLock || !flockSupported {
+				if err := writeLockOwner(lockFile, currentLockOwner()); err != nil {
+					Debug("Failed to write repository lock owner: %v", err)
+				}
+			}
+			Debug("Repository lock acquired: mode=%s", mode)
+			return func() {
+				if err := releaseRepoLockFile(path, lockFile); err != nil {
+					Debug("Warning: failed to release repository lock: %v", err)
+				}
+				unlock()
+				Debug("Repository lock released: mode=%s", mode)
+			}, nil
+		}
+		if !errors.Is(err, errLockHeld) {
+			Debug("Failed to open repository lock file: %v", err)
+			unlock()
+			return nil, err
+		}
+
+		if !flockSupported && stealStaleLock(path) {
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			Debug("Repository lock acquisition timeout")
+			unlock()
+			return nil, errors.New("Repository lock acquisition timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *FileStorage) ListCommits() ([]string, error) {
+	return listSubdirectories(s.dirs.Commits)
+}
+
+func (s *FileStorage) HasCommit(commitId string) bool {
+	return commitId != "" && FileExists(s.dirs.Commits+commitId)
+}
+
+func (s *FileStorage) RemoveCommit(commitId string) error {
+	return os.RemoveAll(s.dirs.Commits + commitId)
+}
+
+func (s *FileStorage) ReadObject(commitId string, fileId string, fileName string) ([]byte, os.FileMode, error) {
+	path := s.dirs.Commits + commitId + "/" + fileId + "/" + fileName
+	data, mode, err := ReadFileWithMode(path)
+	if os.IsNotExist(err) && FileExists(path+ChunkManifestSuffix) {
+		return readChunked(s.dirs.Chunks, path)
+	}
+	return data, mode, err
+}
+
+// WriteObject stores regular files from large-file-threshold on in chunks, see chunk_helper.go.
+func (s *FileStorage) WriteObject(commitId string, fileId string, fileName string, data []byte, mode os.FileMode) error {
+	path := s.dirs.Commits + commitId + "/" + fileId + "/" + fileName
+	if mode.IsRegular() {
+		// A missing config file leaves the default threshold.
+		config, _ := s.ReadConfig()
+		if int64(len(data)) >= LargeFileThreshold(config) {
+			Debug("Storing large file in chunks: %s", path)
+			return writeChunked(s.dirs.Chunks, path, data, mode)
+		}
+	}
+	return WriteFileWithMode(path, data, mode)
+}
+
+func (s *FileStorage) ReadCommitMetadata(commitId string) (CommitMetadata, error) {
+	var metadata CommitMetadata
+	err := readJsonDocument(s.dirs.Commits+commitId+"/metadata.json", &metadata)
+	return metadata, err
+}
+
+func (s *FileStorage) WriteCommitMetadata(commitId string, metadata CommitMetadata) error {
+	return writeJsonDocument(s.dirs.Commits+commitId+"/metadata.json", metadata)
+}
+
+func (s *FileStorage) ReadCommitLogs(commitId string) ([]LogFileEntry, error) {
+	logs := []LogFileEntry{}
+	err := readJsonDocument(s.dirs.Commits+commitId+"/logs.json", &logs)
+	return logs, err
+}
+
+func (s *FileStorage) WriteCommitLogs(commitId string, logs []LogFileEntry) error {
+	return writeJsonDocument(s.dirs.Commits+commitId+"/logs.json", append([]LogFileEntry{}, logs...))
+}
+
+func (s *FileStorage) ReadFileList(commitId string) ([]FileListEntry, error) {
+	fileList := []FileListEntry{}
+	err := readJsonDocument(s.dirs.Commits+commitId+"/fileList.json", &fileList)
+	return fileList, err
+}
+
+func (s *FileStorage) WriteFileList(commitId string, fileList []FileListEntry) error {
+	return writeJsonDocument(s.dirs.Commits+commitId+"/fileList.json", append([]FileListEntry{}, fileList...))
+}
+
+func (s *FileStorage) ListBranches() ([]string, error) {
+	return listSubdirectories(s.dirs.Branches)
+}
+
+func (s *FileStorage) CreateBranch(branch string, commits []Commit) error {
+	if err := os.Mkdir(s.dirs.Branches+branch, 0755); err != nil {
+		return err
+	}
+	return s.WriteBranchCommits(branch, commits)
+}
+
+func (s *FileStorage) RemoveBranch(branch string) error {
+	if _, err := os.Stat(s.dirs.Branches + branch); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.dirs.Branches + branch)
+}
+
+func (s *FileStorage) ReadBranchCommits(branch string) ([]Commit, error) {
+	commits := []Commit{}
+	err := readJsonDocument(s.dirs.Branches+branch+"/commits.json", &commits)
+	return commits, err
+}
+
+func (s *FileStorage) WriteBranchCommits(branch string, commits []Commit) error {
+	return writeJsonDocument(s.dirs.Branches+branch+"/commits.json", append([]Commit{}, commits...))
+}
+
+func (s *FileStorage) ReadBranchesMetadata() (BranchMetadata, error) {
+	var metadata BranchMetadata
+	err := readJsonDocument(s.dirs.BranchesMetadata, &metadata)
+	return metadata, err
+}
+
+func (s *FileStorage) WriteBranchesMetadata(metadata BranchMetadata) error {
+	return writeJsonDocument(s.dirs.BranchesMetadata, metadata)
+}
+
+func (s *FileStorage) ListTrackedRemotes() ([]string, error) {
+	remotes, err := listSubdirectories(s.dirs.RemoteRefs)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	return remotes, err
+}
+
+// ListRemoteRefs lists the tracked branches of a remote, nested directories hold branch names containing a slash.
+func (s *FileStorage) ListRemoteRefs(remote string) ([]string, error) {
+	root := s.dirs.RemoteRefs + remote + "/"
+	branches := []string{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			branches = append(branches, filepath.ToSlash(strings.TrimPrefix(path, filepath.Clean(root)+string(filepath.Separator))))
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return branches, nil
+	}
+	sort.Strings(branches)
+	return branches, err
+}
+
+func (s *FileStorage) ReadRemoteRef(remote string, branch string) ([]Commit, error) {
+	commits := []Commit{}
+	err := readJsonDocument(s.dirs.RemoteRefs+remote+"/"+branch, &commits)
+	return commits, err
+}
+
+func (s *FileStorage) WriteRemoteRef(remote string, branch string, commits []Commit) error {
+	return writeJsonDocument(s.dirs.RemoteRefs+remote+"/"+branch, append([]Commit{}, commits...))
+}
+
+func (s *FileStorage) RemoveRemoteRef(remote string, branch string) error {
+	return os.Remove(s.dirs.RemoteRefs + remote + "/" + branch)
+}
+
+func (s *FileStorage) ReadStagingLogs() ([]LogFileEntry, error) {
+	logs := []LogFileEntry{}
+	err := readJsonDocument(s.dirs.StagingLogs, &logs)
+	return logs, err
+}
+
+func (s *FileStorage) WriteStagingLogs(logs []LogFileEntry) error {
+	return writeJsonDocument(s.dirs.StagingLogs, append([]LogFileEntry{}, logs...))
+}
+
+func (s *FileStorage) ReadStagedFile(op string, id string, fileName string) ([]byte, os.FileMode, error) {
+	return ReadFileWithMode(s.dirs.Staging + op + "/" + id + "/" + fileName)
+}
+
+func (s *FileStorage) WriteStagedFile(op string, id string, fileName string, data []byte, mode os.FileMode) error {
+	return WriteFileWithMode(s.dirs.Staging+op+"/"+id+"/"+fileName, data, mode)
+}
+
+func (s *FileStorage) HasStagedFile(op string, id string) bool {
+	return FileExists(s.dirs.Staging + op + "/" + id)
+}
+
+func (s *FileStorage) RemoveStagedFile(op string, id string) error {
+	return os.RemoveAll(s.dirs.Staging + op + "/" + id)
+}
+
+func (s *FileStorage) ClearStagedFiles() error {
+	for _, dir := range []string{s.dirs.StagingAdded, s.dirs.StagingModified, s.dirs.StagingRemoved, s.dirs.StagingRenamed, s.dirs.StagingConflicted} {
+		if err := EmptyDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStorage) ReadConfig() (Config, error) {
+	var config Config
+	err := readJsonDocument(s.dirs.Config, &config)
+	return config, err
+}
+
+func (s *FileStorage) WriteConfig(config Config) error {
+	return writeJsonDocument(s.dirs.Config, config)
+}
+
+// bisectStatePath is not part of Dirs, the file only exists while a bisect is in progress.
+func (s *FileStorage) bisectStatePath() string {
+	return s.dirs.Root + "bisect.json"
+}
+
+func (s *FileStorage) ReadBisectState() (BisectState, error) {
+	var state BisectState
+	err := readJsonDocument(s.bisectStatePath(), &state)
+	return state, err
+}
+
+func (s *FileStorage) WriteBisectState(state BisectState) error {
+	return writeJsonDocument(s.bisectStatePath(), state)
+}
+
+func (s *FileStorage) RemoveBisectState() error {
+	if err := os.Remove(s.bisectStatePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// cherryPickStatePath is not part of Dirs either, the file only exists while a cherry-pick is stopped.
+func (s *FileStorage) cherryPickStatePath() string {
+	return s.dirs.Root + "cherry-pick.json"
+}
+
+func (s *FileStorage) ReadCherryPickState() (CherryPickState, error) {
+	var state CherryPickState
+	err := readJsonDocument(s.cherryPickStatePath(), &state)
+	return state, err
+}
+
+func (s *FileStorage) WriteCherryPickState(state CherryPickState) error {
+	return writeJsonDocument(s.cherryPickStatePath(), state)
+}
+
+func (s *FileStorage) RemoveCherryPickState() error {
+	if err := os.Remove(s.cherryPickStatePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// rebaseDir holds the state of a rebase in progress and the files opened in the editor.
+func (s *FileStorage) rebaseDir() string {
+	return s.dirs.Root + "rebase/"
+}
+
+func (s *FileStorage) ReadRebaseState() (RebaseState, error) {
+	var state RebaseState
+	err := readJsonDocument(s.rebaseDir()+"state.json", &state)
+	return state, err
+}
+
+func (s *FileStorage) WriteRebaseState(state RebaseState) error {
+	return writeJsonDocument(s.rebaseDir()+"state.json", state)
+}
+
+func (s *FileStorage) RemoveRebaseState() error {
+	return os.RemoveAll(s.rebaseDir())
+}
+
+// droppedCommitsPath is not part of Dirs, the file is created by the first branch dropping commits.
+func (s *FileStorage) droppedCommitsPath() string {
+	return s.dirs.Root + "dropped.json"
+}
+
+func (s *FileStorage) ReadDroppedCommits() ([]DroppedCommit, error) {
+	var dropped []DroppedCommit
+	err := readJsonDocument(s.droppedCommitsPath(), &dropped)
+	return dropped, err
+}
+
+func (s *FileStorage) WriteDroppedCommits(dropped []DroppedCommit) error {
+	return writeJsonDocument(s.droppedCommitsPath(), dropped)
+}
+
+func (s *FileStorage) objectPath(commitId string, fileId string, fileName string) string {
+	return s.dirs.Commits + commitId + "/" + fileId + "/" + fileName
+}
+
+func (s *FileStorage) HasObject(commitId string, fileId string, fileName string) bool {
+	path := s.objectPath(commitId, fileId, fileName)
+	return FileExists(path) || FileExists(path+ChunkManifestSuffix)
+}
+
+func (s *FileStorage) ObjectChunks(commitId string, fileId string, fileName string) ([]string, bool, error) {
+	path := s.objectPath(commitId, fileId, fileName)
+	if !FileExists(path + ChunkManifestSuffix) {
+		return nil, false, nil
+	}
+	manifest, _, err := readChunkManifest(path)
+	return manifest.Chunks, true, err
+}
+
+func (s *FileStorage) ListChunks() ([]string, error) {
+	entries, err := os.ReadDir(s.dirs.Chunks)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.Name())
+	}
+	return ids, nil
+}
+
+func (s *FileStorage) HasChunk(id string) bool {
+	return FileExists(s.dirs.Chunks + id)
+}
+
+func (s *FileStorage) RemoveChunk(id string) error {
+	return os.RemoveAll(s.dirs.Chunks + id)
+}
+
+func (s *FileStorage) ListStagedFiles(op string) ([]string, error) {
+	entries, err := os.ReadDir(s.dirs.Staging + op)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.Name())
+	}
+	return ids, nil
+}
+
+// CommitTime is the modification time of the commit directory.
+func (s *FileStorage) CommitTime(commitId string) (time.Time, error) {
+	info, err := os.Stat(s.dirs.Commits + commitId)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (s *FileStorage) Usage(path string) int64 {
+	return DirSize(s.dirs.Root + path)
+}
+
+func (s *FileStorage) ListLocks() ([]LockInfo, error) {
+	return ListLocks(s.dirs.Root)
+}
+
+func (s *FileStorage) RemoveLock(path string, force bool) bool {
+	if force {
+		return os.Remove(s.dirs.Root+path) == nil
+	}
+	return RemoveStaleLock(s.dirs.Root + path)
+}
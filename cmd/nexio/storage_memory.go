@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryFile struct {
+	name string
+	data []byte
+	mode os.FileMode
+}
+
+type memoryCommit struct {
+	metadata *CommitMetadata
+	logs     []LogFileEntry
+	fileList []FileListEntry
+	// Objects keyed by `<file-id>/<file-name>`.
+	objects map[string]memoryFile
+	written time.Time
+}
+
+// MemoryStorage keeps the repository in memory, it is used by tests and as a scratch repository.
+// Documents are copied on every read and write so callers never share state with the storage.
+type MemoryStorage struct {
+	mutex            sync.Mutex
+	repoMutex        sync.RWMutex
+	locks            map[string]*sync.Mutex
+	initialized      bool
+	formatVersion    int
+	config           *Config
+	bisectState      *BisectState
+	cherryPickState  *CherryPickState
+	rebaseState      *RebaseState
+	droppedCommits   []DroppedCommit
+	branchesMetadata *BranchMetadata
+	branches         map[string][]Commit
+	// Remote-tracking refs keyed by remote, then branch.
+	remoteRefs  map[string]map[string][]Commit
+	commits     map[string]*memoryCommit
+	stagingLogs []LogFileEntry
+	// Staged files keyed by `<op>/<id>`.
+	staged map[string]memoryFile
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		locks:      map[string]*sync.Mutex{},
+		branches:   map[string][]Commit{},
+		remoteRefs: map[string]map[string][]Commit{},
+		commits:    map[string]*memoryCommit{},
+		staged:     map[string]memoryFile{},
+	}
+}
+
+func notExist(name string) error {
+	return &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+}
+
+func (s *MemoryStorage) Initialize() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.initialized = true
+	s.stagingLogs = []LogFileEntry{}
+	s.branches[InitBranch] = []Commit{}
+	return nil
+}
+
+func (s *MemoryStorage) Exists() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.initialized
+}
+
+func (s *MemoryStorage) ReadFormatVersion() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.formatVersion, nil
+}
+
+func (s *MemoryStorage) WriteFormatVersion(version int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.formatVersion = version
+	return nil
+}
+
+// Backup does nothing, a memory repository does not outlive the process that would migrate it.
+func (s *MemoryStorage) Backup(name string) (string, error) {
+	return "", nil
+}
+
+func (s *MemoryStorage) WithLock(name string, fn func() error) error {
+	s.mutex.Lock()
+	lock, exists := s.locks[name]
+	if !exists {
+		lock = &sync.Mutex{}
+		s.locks[name] = lock
+	}
+	s.mutex.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+	return fn()
+}
+
+func (s *MemoryStorage) LockRepository(mode string) (release func(), err error) {
+	Debug("Attempting to acquire repository lock: mode=%s", mode)
+	return lockMutex(&s.repoMutex, mode, time.Now().Add(LockTimeout()))
+}
+
+func (s *MemoryStorage) ListCommits() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := []string{}
+	for id := range s.commits {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *MemoryStorage) HasCommit(commitId string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, exists := s.commits[commitId]
+	return exists
+}
+
+func (s *MemoryStorage) RemoveCommit(commitId string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.commits, commitId)
+	return nil
+}
+
+// commit returns the commit, creating it when create is set. The caller must hold the mutex.
+func (s *MemoryStorage) commit(commitId string, create bool) *memoryCommit {
+	commit, exists := s.commits[commitId]
+	if !exists && create {
+		commit = &memoryCommit{objects: map[string]memoryFile{}}
+		s.commits[commitId] = commit
+	}
+	if create {
+		commit.written = time.Now()
+	}
+	return commit
+}
+
+func (s *MemoryStorage) ReadObject(commitId string, fileId string, fileName string) ([]byte, os.FileMode, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if commit := s.commit(commitId, false); commit != nil {
+		if object, exists := commit.objects[fileId+"/"+fileName]; exists {
+			return bytes.Clone(object.data), object.mode, nil
+		}
+	}
+	return nil, 0, notExist("commits/" + commitId + "/" + fileId + "/" + fileName)
+}
+
+func (s *MemoryStorage) WriteObject(commitId string, fileId string, fileName string, data []byte, mode os.FileMode) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commit(commitId, true).objects[fileId+"/"+fileName] = memoryFile{name: fileName, data: bytes.Clone(data), mode: mode}
+	return nil
+}
+
+func (s *MemoryStorage) ReadCommitMetadata(commitId string) (CommitMetadata, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	commit := s.commit(commitId, false)
+	if commit == nil || commit.metadata == nil {
+		return CommitMetadata{}, notExist("commits/" + commitId + "/metadata.json")
+	}
+	return *commit.metadata, nil
+}
+
+func (s *MemoryStorage) WriteCommitMetadata(commitId string, metadata CommitMetadata) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commit(commitId, true).metadata = &metadata
+	return nil
+}
+
+func (s *MemoryStorage) ReadCommitLogs(commitId string) ([]LogFileEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	commit := s.commit(commitId, false)
+	if commit == nil || commit.logs == nil {
+		return nil, notExist("commits/" + commitId + "/logs.json")
+	}
+	return slices.Clone(commit.logs), nil
+}
+
+func (s *MemoryStorage) WriteCommitLogs(commitId string, logs []LogFileEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commit(commitId, true).logs = append([]LogFileEntry{}, logs...)
+	return nil
+}
+
+func (s *MemoryStorage) ReadFileList(commitId string) ([]FileListEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	commit := s.commit(commitId, false)
+	if commit == nil || commit.fileList == nil {
+		return nil, notExist("commits/" + commitId + "/fileList.json")
+	}
+	return slices.Clone(commit.fileList), nil
+}
+
+func (s *MemoryStorage) WriteFileList(commitId string, fileList []FileListEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commit(commitId, true).fileList = append([]FileListEntry{}, fileList...)
+	return nil
+}
+
+func (s *MemoryStorage) ListBranches() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	branches := []string{}
+	for branch := range s.branches {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+func (s *MemoryStorage) CreateBranch(branch string, commits []Commit) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.branches[branch]; exists {
+		return &fs.PathError{Op: "create", Path: "branches/" + branch, Err: fs.ErrExist}
+	}
+	s.branches[branch] = append([]Commit{}, commits...)
+	return nil
+}
+
+func (s *MemoryStorage) RemoveBranch(branch string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.branches[branch]; !exists {
+		return notExist("branches/" + branch)
+	}
+	delete(s.branches, branch)
+	return nil
+}
+
+func (s *MemoryStorage) ReadBranchCommits(branch string) ([]Commit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	commits, exists := s.branches[branch]
+	if !exists {
+		return nil, notExist("branches/" + branch + "/commits.json")
+	}
+	return append([]Commit{}, commits...), nil
+}
+
+func (s *MemoryStorage) WriteBranchCommits(branch string, commits []Commit) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.branches[branch] = append([]Commit{}, commits...)
+	return nil
+}
+
+func (s *MemoryStorage) ReadBranchesMetadata() (BranchMetadata, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.branchesMetadata == nil {
+		return BranchMetadata{}, notExist("branches/metadata.json")
+	}
+	return *s.branchesMetadata, nil
+}
+
+func (s *MemoryStorage) WriteBranchesMetadata(metadata BranchMetadata) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.branchesMetadata = &metadata
+	return nil
+}
+
+func (s *MemoryStorage) ListTrackedRemotes() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	remotes := []string{}
+	for remote, refs := range s.remoteRefs {
+		if len(refs) > 0 {
+			remotes = append(remotes, remote)
+		}
+	}
+	sort.Strings(remotes)
+	return remotes, nil
+}
+
+func (s *MemoryStorage) ListRemoteRefs(remote string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	branches := []string{}
+	for branch := range s.remoteRefs[remote] {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+func (s *MemoryStorage) ReadRemoteRef(remote string, branch string) ([]Commit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	commits, exists := s.remoteRefs[remote][branch]
+	if !exists {
+		return nil, notExist("refs/remotes/" + remote + "/" + branch)
+	}
+	return append([]Commit{}, commits...), nil
+}
+
+func (s *MemoryStorage) WriteRemoteRef(remote string, branch string, commits []Commit) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.remoteRefs[remote] == nil {
+		s.remoteRefs[remote] = map[string][]Commit{}
+	}
+	s.remoteRefs[remote][branch] = append([]Commit{}, commits...)
+	return nil
+}
+
+func (s *MemoryStorage) RemoveRemoteRef(remote string, branch string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.remoteRefs[remote][branch]; !exists {
+		return notExist("refs/remotes/" + remote + "/" + branch)
+	}
+	delete(s.remoteRefs[remote], branch)
+	return nil
+}
+
+func (s *MemoryStorage) ReadStagingLogs() ([]LogFileEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stagingLogs == nil {
+		return nil, notExist("staging/logs.json")
+	}
+	return slices.Clone(s.stagingLogs), nil
+}
+
+func (s *MemoryStorage) WriteStagingLogs(logs []LogFileEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stagingLogs = append([]LogFileEntry{}, logs...)
+	return nil
+}
+
+func (s *MemoryStorage) ReadStagedFile(op string, id string, fileName string) ([]byte, os.FileMode, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if file, exists := s.staged[op+"/"+id]; exists && file.name == fileName {
+		return bytes.Clone(file.data), file.mode, nil
+	}
+	return nil, 0, notExist("staging/" + op + "/" + id + "/" + fileName)
+}
+
+func (s *MemoryStorage) WriteStagedFile(op string, id string, fileName string, data []byte, mode os.FileMode) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.staged[op+"/"+id] = memoryFile{name: fileName, data: bytes.Clone(data), mode: mode}
+	return nil
+}
+
+func (s *MemoryStorage) HasStagedFile(op string, id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, exists := s.staged[op+"/"+id]
+	return exists
+}
+
+func (s *MemoryStorage) RemoveStagedFile(op string, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.staged, op+"/"+id)
+	return nil
+}
+
+func (s *MemoryStorage) ClearStagedFiles() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.staged = map[string]memoryFile{}
+	return nil
+}
+
+func (s *MemoryStorage) ReadConfig() (Config, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.config == nil {
+		return Config{}, notExist("config.json")
+	}
+	return *s.config, nil
+}
+
+func (s *MemoryStorage) WriteConfig(config Config) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.config = &config
+	return nil
+}
+
+func (s *MemoryStorage) ReadBisectState() (BisectState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.bisectState == nil {
+		return BisectState{}, notExist("bisect.json")
+	}
+	state := *s.bisectState
+	state.Good = slices.Clone(state.Good)
+	state.Skipped = slices.Clone(state.Skipped)
+	state.Log = slices.Clone(state.Log)
+	return state, nil
+}
+
+func (s *MemoryStorage) WriteBisectState(state BisectState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state.Good = slices.Clone(state.Good)
+	state.Skipped = slices.Clone(state.Skipped)
+	state.Log = slices.Clone(state.Log)
+	s.bisectState = &state
+	return nil
+}
+
+func (s *MemoryStorage) RemoveBisectState() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bisectState = nil
+	return nil
+}
+
+func (s *MemoryStorage) ReadCherryPickState() (CherryPickState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cherryPickState == nil {
+		return CherryPickState{}, notExist("cherry-pick.json")
+	}
+	return cloneCherryPickState(*s.cherryPickState), nil
+}
+
+func (s *MemoryStorage) WriteCherryPickState(state CherryPickState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state = cloneCherryPickState(state)
+	s.cherryPickState = &state
+	return nil
+}
+
+func (s *MemoryStorage) RemoveCherryPickState() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cherryPickState = nil
+	return nil
+}
+
+func (s *MemoryStorage) ReadRebaseState() (RebaseState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.rebaseState == nil {
+		return RebaseState{}, notExist("rebase/state.json")
+	}
+	return cloneRebaseState(*s.rebaseState), nil
+}
+
+func (s *MemoryStorage) WriteRebaseState(state RebaseState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state = cloneRebaseState(state)
+	s.rebaseState = &state
+	return nil
+}
+
+func (s *MemoryStorage) RemoveRebaseState() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rebaseState = nil
+	return nil
+}
+
+func (s *MemoryStorage) ReadDroppedCommits() ([]DroppedCommit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.droppedCommits == nil {
+		return nil, notExist("dropped.json")
+	}
+	return slices.Clone(s.droppedCommits), nil
+}
+
+func (s *MemoryStorage) WriteDroppedCommits(dropped []DroppedCommit) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.droppedCommits = append([]DroppedCommit{}, dropped...)
+	return nil
+}
+
+func cloneRebaseState(state RebaseState) RebaseState {
+	state.Original = slices.Clone(state.Original)
+	state.Todo = slices.Clone(state.Todo)
+	state.Paths = slices.Clone(state.Paths)
+	state.Conflicts = slices.Clone(state.Conflicts)
+	state.Commits = slices.Clone(state.Commits)
+	return state
+}
+
+func cloneCherryPickState(state CherryPickState) CherryPickState {
+	state.Remaining = slices.Clone(state.Remaining)
+	state.Paths = slices.Clone(state.Paths)
+	state.Conflicts = slices.Clone(state.Conflicts)
+	return state
+}
+
+func (s *MemoryStorage) HasObject(commitId string, fileId string, fileName string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if commit := s.commit(commitId, false); commit != nil {
+		_, exists := commit.objects[fileId+"/"+fileName]
+		return exists
+	}
+	return false
+}
+
+// ObjectChunks reports every file as stored whole, a memory repository does not chunk large files.
+func (s *MemoryStorage) ObjectChunks(commitId string, fileId string, fileName string) ([]string, bool, error) {
+	return nil, false, nil
+}
+
+func (s *MemoryStorage) ListChunks() ([]string, error) {
+	return []string{}, nil
+}
+
+func (s *MemoryStorage) HasChunk(id string) bool {
+	return false
+}
+
+func (s *MemoryStorage) RemoveChunk(id string) error {
+	return nil
+}
+
+func (s *MemoryStorage) ListStagedFiles(op string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := []string{}
+	for key := range s.staged {
+		if id, found := strings.CutPrefix(key, op+"/"); found {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *MemoryStorage) CommitTime(commitId string) (time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	commit := s.commit(commitId, false)
+	if commit == nil {
+		return time.Time{}, notExist("commits/" + commitId)
+	}
+	return commit.written, nil
+}
+
+// Usage counts the contents of the commits and staged files, documents are not counted.
+func (s *MemoryStorage) Usage(path string) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var size int64
+	if commitId, found := strings.CutPrefix(path, "commits/"); found {
+		if commit := s.commit(commitId, false); commit != nil {
+			for _, object := range commit.objects {
+				size += int64(len(object.data))
+			}
+		}
+	}
+	if staged, found := strings.CutPrefix(path, "staging/"); found {
+		size = int64(len(s.staged[staged].data))
+	}
+	return size
+}
+
+// ListLocks returns no locks, those of a memory repository do not outlive the process holding them.
+func (s *MemoryStorage) ListLocks() ([]LockInfo, error) {
+	return []LockInfo{}, nil
+}
+
+func (s *MemoryStorage) RemoveLock(path string, force bool) bool {
+	return false
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+// testStorage runs the same checks against every Storage implementation.
+func testStorage(t *testing.T, s Storage) {
+	if s.Exists() {
+		t.Fatalf("Expected storage to be empty before initialization")
+	}
+	if err := s.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize storage: %v", err)
+	}
+	if !s.Exists() {
+		t.Errorf("Expected storage to exist after initialization")
+	}
+
+	// Refs
+	branches, err := s.ListBranches()
+	if err != nil || !slices.Equal(branches, []string{InitBranch}) {
+		t.Errorf("Expected only the initial branch, got %v (%v)", branches, err)
+	}
+	if commits, err := s.ReadBranchCommits(InitBranch); err != nil || len(commits) != 0 {
+		t.Errorf("Expected no commits on the initial branch, got %v (%v)", commits, err)
+	}
+	commits := []Commit{{Id: "c1", Timestamp: GetTimestamp(), Next: "c2"}, {Id: "c2", Timestamp: GetTimestamp()}}
+	if err := s.WriteBranchCommits(InitBranch, commits); err != nil {
+		t.Fatalf("Failed to write branch commits: %v", err)
+	}
+	if err := s.CreateBranch("feature", commits[:1]); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if err := s.CreateBranch("feature", nil); !os.IsExist(err) {
+		t.Errorf("Expected creating an existing branch to fail with ErrExist, got %v", err)
+	}
+	if read, _ := s.ReadBranchCommits("feature"); len(read) != 1 || read[0] != commits[0] {
+		t.Errorf("Expected feature branch to have the first commit, got %v", read)
+	}
+	if err := s.RemoveBranch("feature"); err != nil {
+		t.Errorf("Failed to remove branch: %v", err)
+	}
+	if _, err := s.ReadBranchCommits("feature"); !os.IsNotExist(err) {
+		t.Errorf("Expected removed branch to be gone, got %v", err)
+	}
+	if _, err := s.ReadBranchesMetadata(); !os.IsNotExist(err) {
+		t.Errorf("Expected missing branches metadata to report ErrNotExist, got %v", err)
+	}
+	metadata := BranchMetadata{Default: InitBranch, Current: InitBranch}
+	s.WriteBranchesMetadata(metadata)
+	if read, _ := s.ReadBranchesMetadata(); read != metadata {
+		t.Errorf("Expected branches metadata %v, got %v", metadata, read)
+	}
+	if _, err := s.ReadRemoteRef("origin", "main"); !os.IsNotExist(err) {
+		t.Errorf("Expected missing remote-tracking ref to report ErrNotExist, got %v", err)
+	}
+	s.WriteRemoteRef("origin", "main", commits)
+	s.WriteRemoteRef("origin", "feature/login", commits[:1])
+	if refs, err := s.ListRemoteRefs("origin"); err != nil || !slices.Equal(refs, []string{"feature/login", "main"}) {
+		t.Errorf("Expected remote-tracking refs of origin, got %v (%v)", refs, err)
+	}
+	if read, _ := s.ReadRemoteRef("origin", "feature/login"); len(read) != 1 || read[0] != commits[0] {
+		t.Errorf("Expected remote-tracking ref to round-trip, got %v", read)
+	}
+	s.RemoveRemoteRef("origin", "feature/login")
+	if refs, _ := s.ListRemoteRefs("origin"); !slices.Equal(refs, []string{"main"}) {
+		t.Errorf("Expected only origin/main after removal, got %v", refs)
+	}
+	if refs, err := s.ListRemoteRefs("backup"); err != nil || len(refs) != 0 {
+		t.Errorf("Expected no refs for an unknown remote, got %v (%v)", refs, err)
+	}
+
+	// Objects
+	if err := s.WriteObject("c1", "f1", "file.txt", []byte("content"), 0640); err != nil {
+		t.Fatalf("Failed to write object: %v", err)
+	}
+	data, mode, err := s.ReadObject("c1", "f1", "file.txt")
+	if err != nil || string(data) != "content" || mode != 0640 {
+		t.Errorf("Expected object content with mode 0640, got %q %v (%v)", data, mode, err)
+	}
+	if _, _, err := s.ReadObject("c1", "f2", "file.txt"); !os.IsNotExist(err) {
+		t.Errorf("Expected missing object to report ErrNotExist, got %v", err)
+	}
+	fileList := []FileListEntry{{Id: "f1", CommitId: "c1", Path: "file.txt"}}
+	s.WriteFileList("c1", fileList)
+	s.WriteCommitMetadata("c1", CommitMetadata{Author: Author{Name: "Nexio"}, Message: "first"})
+	s.WriteCommitLogs("c1", []LogFileEntry{{Id: "f1", Op: "ADD", Path: "file.txt"}})
+	if read, _ := s.ReadFileList("c1"); !slices.Equal(read, fileList) {
+		t.Errorf("Expected file list %v, got %v", fileList, read)
+	}
+	if read, _ := s.ReadCommitMetadata("c1"); read.Message != "first" || read.Author.Name != "Nexio" {
+		t.Errorf("Expected commit metadata to round-trip, got %v", read)
+	}
+	if read, _ := s.ReadCommitLogs("c1"); len(read) != 1 || read[0].Op != "ADD" {
+		t.Errorf("Expected commit logs to round-trip, got %v", read)
+	}
+	if ids, _ := s.ListCommits(); !slices.Equal(ids, []string{"c1"}) || !s.HasCommit("c1") {
+		t.Errorf("Expected commit c1 to be listed, got %v", ids)
+	}
+	s.RemoveCommit("c1")
+	if s.HasCommit("c1") {
+		t.Errorf("Expected commit c1 to be removed")
+	}
+
+	// Staging
+	if logs, err := s.ReadStagingLogs(); err != nil || len(logs) != 0 {
+		t.Errorf("Expected empty staging logs, got %v (%v)", logs, err)
+	}
+	s.WriteStagingLogs([]LogFileEntry{{Id: "s1", Op: "ADD", Path: "file.txt"}})
+	if logs, _ := s.ReadStagingLogs(); len(logs) != 1 {
+		t.Errorf("Expected 1 staging log entry, got %d", len(logs))
+	}
+	s.WriteStagedFile("added", "s1", "file.txt", []byte("staged"), 0644)
+	s.WriteStagedFile("removed", "s2", "other.txt", []byte("removed"), 0644)
+	if data, _, err := s.ReadStagedFile("added", "s1", "file.txt"); err != nil || string(data) != "staged" {
+		t.Errorf("Expected staged content, got %q (%v)", data, err)
+	}
+	if !s.HasStagedFile("added", "s1") || s.HasStagedFile("modified", "s1") {
+		t.Errorf("Expected s1 to be staged as added only")
+	}
+	s.RemoveStagedFile("added", "s1")
+	if s.HasStagedFile("added", "s1") {
+		t.Errorf("Expected s1 to be removed from staging")
+	}
+	s.ClearStagedFiles()
+	if s.HasStagedFile("removed", "s2") {
+		t.Errorf("Expected staging to be cleared")
+	}
+
+	// Config, bisect, cherry-pick and rebase state and format
+	if _, err := s.ReadConfig(); !os.IsNotExist(err) {
+		t.Errorf("Expected missing config to report ErrNotExist, got %v", err)
+	}
+	s.WriteConfig(Config{Name: "Nexio", Email: "nexio@example.com"})
+	if config, _ := s.ReadConfig(); config.Name != "Nexio" || config.Email != "nexio@example.com" {
+		t.Errorf("Expected config to round-trip, got %v", config)
+	}
+	if _, err := s.ReadBisectState(); !os.IsNotExist(err) {
+		t.Errorf("Expected missing bisect state to report ErrNotExist, got %v", err)
+	}
+	s.WriteBisectState(BisectState{Branch: InitBranch, Good: []string{"c1"}, Bad: "c2"})
+	if state, _ := s.ReadBisectState(); state.Bad != "c2" || !slices.Equal(state.Good, []string{"c1"}) {
+		t.Errorf("Expected bisect state to round-trip, got %v", state)
+	}
+	if err := s.RemoveBisectState(); err != nil || s.RemoveBisectState() != nil {
+		t.Errorf("Expected removing the bisect state to succeed, also when it is gone: %v", err)
+	}
+	if _, err := s.ReadCherryPickState(); !os.IsNotExist(err) {
+		t.Errorf("Expected missing cherry-pick state to report ErrNotExist, got %v", err)
+	}
+	s.WriteCherryPickState(CherryPickState{Branch: InitBranch, Current: CherryPick{Id: "c2", Parent: "c1"}, Conflicts: []string{"file.txt"}})
+	if state, _ := s.ReadCherryPickState(); state.Current.Id != "c2" || !slices.Equal(state.Conflicts, []string{"file.txt"}) {
+		t.Errorf("Expected cherry-pick state to round-trip, got %v", state)
+	}
+	if err := s.RemoveCherryPickState(); err != nil || s.RemoveCherryPickState() != nil {
+		t.Errorf("Expected removing the cherry-pick state to succeed, also when it is gone: %v", err)
+	}
+	if _, err := s.ReadRebaseState(); !os.IsNotExist(err) {
+		t.Errorf("Expected missing rebase state to report ErrNotExist, got %v", err)
+	}
+	s.WriteRebaseState(RebaseState{Branch: "feature", Onto: "c2", Original: commits[:1], Todo: []RebaseStep{{Action: "pick", Id: "c3", Parent: "c1"}}})
+	if state, _ := s.ReadRebaseState(); state.Onto != "c2" || len(state.Original) != 1 || state.Todo[0].Id != "c3" {
+		t.Errorf("Expected rebase state to round-trip, got %v", state)
+	}
+	if err := s.RemoveRebaseState(); err != nil || s.RemoveRebaseState() != nil {
+		t.Errorf("Expected removing the rebase state to succeed, also when it is gone: %v", err)
+	}
+	s.WriteFormatVersion(CurrentFormatVersion())
+	if version, _ := s.ReadFormatVersion(); version != CurrentFormatVersion() {
+		t.Errorf("Expected format version %d, got %d", CurrentFormatVersion(), version)
+	}
+
+	// Locks
+	release, err := s.LockRepository(SharedLock)
+	if err != nil {
+		t.Fatalf("Failed to take shared repository lock: %v", err)
+	}
+	if second, err := s.LockRepository(SharedLock); err != nil {
+		t.Errorf("Expected shared locks to coexist: %v", err)
+	} else {
+		second()
+	}
+	release()
+	s.WriteStagingLogs([]LogFileEntry{})
+	done := make(chan bool)
+	for range 4 {
+		go func() {
+			s.WithLock(StagingLogsLock, func() error {
+				logs, err := s.ReadStagingLogs()
+				if err != nil {
+					return err
+				}
+				return s.WriteStagingLogs(append(logs, LogFileEntry{Id: GenRandHex(4), Op: "ADD"}))
+			})
+			done <- true
+		}()
+	}
+	for range 4 {
+		<-done
+	}
+	if logs, _ := s.ReadStagingLogs(); len(logs) != 4 {
+		t.Errorf("Expected named lock to serialize updates, got %d entries", len(logs))
+	}
+}
+
+// memoryRepository returns an initialized repository kept in memory, commands given it can be
+// tested in parallel.
+func memoryRepository(t *testing.T) *MemoryStorage {
+	t.Helper()
+	s := NewMemoryStorage()
+	if err := s.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize storage: %v", err)
+	}
+	s.WriteBranchesMetadata(BranchMetadata{Default: InitBranch, Current: InitBranch})
+	s.WriteConfig(Config{})
+	s.WriteFormatVersion(CurrentFormatVersion())
+	return s
+}
+
+// writeMemoryCommit commits a file on top of a branch of s and returns the commit id.
+func writeMemoryCommit(t *testing.T, s Storage, branch string, path string, content string) string {
+	t.Helper()
+	commitId, fileId := GenRandHex(20), GenRandHex(20)
+	_, fileName := ParsePath(path)
+	s.WriteObject(commitId, fileId, fileName, []byte(content), 0644)
+	s.WriteFileList(commitId, []FileListEntry{newFileListEntry(fileId, commitId, path, 0644)})
+	s.WriteCommitLogs(commitId, []LogFileEntry{{Id: fileId, Op: "ADD", Path: path}})
+	s.WriteCommitMetadata(commitId, CommitMetadata{Message: "add " + path})
+
+	commits, err := s.ReadBranchCommits(branch)
+	if err != nil {
+		t.Fatalf("Failed to read branch %s: %v", branch, err)
+	}
+	if len(commits) > 0 {
+		commits[len(commits)-1].Next = commitId
+	}
+	if err := s.WriteBranchCommits(branch, append(commits, Commit{Id: commitId, Timestamp: GetTimestamp()})); err != nil {
+		t.Fatalf("Failed to write branch %s: %v", branch, err)
+	}
+	return commitId
+}
+
+func Test_Storage_File(t *testing.T) {
+	t.Parallel()
+	testStorage(t, NewFileStorage(NewDirs(t.TempDir()+"/")))
+}
+
+func Test_Storage_Memory(t *testing.T) {
+	t.Parallel()
+	testStorage(t, NewMemoryStorage())
+}
+
+func Test_Storage_MemoryRepository(t *testing.T) {
+	os.RemoveAll(namespace)
+	os.MkdirAll(namespace, 0755)
+	original := store
+	store = NewMemoryStorage()
+	defer func() { store = original }()
+
+	runInitCommand()
+	file := namespace + "file.txt"
+	os.WriteFile(file, []byte("content"), 0644)
+	if result := runAddCommand(file, false); result.ReturnCode != 112 {
+		t.Errorf("Expected 112, got %d", result.ReturnCode)
+	}
+	returnCode, commitId := runCommitCommand("in memory")
+	if returnCode != 702 {
+		t.Fatalf("Expected 702, got %d", returnCode)
+	}
+	if runNewCommand("feature", "", "") != 206 {
+		t.Errorf("Expected branch to be created in memory")
+	}
+	if _, history := runHistoryCommand(); len(history) != 1 || history[0].Message != "in memory" {
+		t.Errorf("Expected history with the in-memory commit, got %v", history)
+	}
+	if data, _, err := store.ReadObject(commitId, (*GetFileListContent(commitId))[0].Id, "file.txt"); err != nil || string(data) != "content" {
+		t.Errorf("Expected committed object in memory, got %q (%v)", data, err)
+	}
+	if FileExists(dirs.Root) {
+		t.Errorf("Expected memory repository not to write %s", dirs.Root)
+	}
+
+	os.RemoveAll(namespace)
+}
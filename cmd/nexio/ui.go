@@ -127,6 +127,17 @@ func StyledCommit(commit string) string {
 	return style.Sprint(commit)
 }
 
+func StyledSeverity(severity string) string {
+	switch severity {
+	case SeverityError:
+		return pterm.FgRed.Sprint("[" + severity + "]")
+	case SeverityWarning:
+		return pterm.FgYellow.Sprint("[" + severity + "]")
+	default:
+		return pterm.FgCyan.Sprint("[" + severity + "]")
+	}
+}
+
 func Code(code string) string {
 	style := pterm.NewStyle(pterm.Reset, pterm.FgCyan)
 	return style.Sprint(code)
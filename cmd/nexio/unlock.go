@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	unlockCmd.Flags().BoolVarP(&ForceUnlock, "force", "f", false, "Also remove locks held by running processes")
+
+	rootCmd.AddCommand(unlockCmd)
+}
+
+var ForceUnlock bool
+
+var unlockCmd = &cobra.Command{
+	Use:     "unlock",
+	Short:   "List lock files and remove stale ones",
+	Example: "nexio unlock\nnexio unlock --force",
+	Args:    cobra.NoArgs,
+	Run: func(_ *cobra.Command, args []string) {
+		Debug("Starting unlock command: force=%v", ForceUnlock)
+		runUnlockCommand(store, ForceUnlock)
+	},
+}
+
+func runUnlockCommand(s Storage, force bool) (returnCode int, locks []LockInfo) {
+	if initialized := IsStorageInitialized(s); !initialized {
+		Fail(COMMON_RETURN_CODES[001])
+		return 001, nil
+	}
+
+	locks, err := s.ListLocks()
+	if err != nil {
+		Debug("Failed to list locks: %v", err)
+		MustSucceed(err, "operation failed")
+	}
+
+	BreakLine()
+	if len(locks) == 0 {
+		Debug("%s", UNLOCK_RETURN_CODES[1201])
+		Info(UNLOCK_RETURN_CODES[1201])
+		BreakLine()
+		return 1201, locks
+	}
+
+	lines := []string{}
+	held := 0
+	for _, lock := range locks {
+		status := StyledSeverity(SeverityWarning) + " stale"
+		if !lock.Stale {
+			status = StyledSeverity(SeverityError) + " held"
+			held++
+		}
+		owner := "unknown owner"
+		if lock.Owner != nil {
+			owner = fmt.Sprintf("PID %d on %s", lock.Owner.Pid, lock.Owner.Hostname)
+			if lock.Owner.Acquired != "" {
+				owner += ", acquired " + TimeAgo(lock.Owner.Acquired)
+			}
+		}
+		lines = append(lines, status+" "+lock.Path+" ("+owner+")")
+	}
+	Info("Lock files " + FormatFileCount(len(locks)))
+	Tree(lines, false)
+	BreakLine()
+
+	for _, lock := range locks {
+		if lock.Stale || force {
+			Debug("Removing lock: %s", lock.Path)
+			s.RemoveLock(lock.Path, force)
+		}
+	}
+
+	if force {
+		Success(UNLOCK_RETURN_CODES[1203])
+		BreakLine()
+		return 1203, locks
+	}
+	if held > 0 {
+		Warning(UNLOCK_RETURN_CODES[1204])
+		Text("Use "+Code("nexio unlock --force")+" if those processes are stuck", "")
+		BreakLine()
+		return 1204, locks
+	}
+	Success(UNLOCK_RETURN_CODES[1202])
+	BreakLine()
+	return 1202, locks
+}
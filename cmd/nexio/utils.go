@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -103,10 +104,69 @@ func ValidatePath(userPath string) error {
 	return nil
 }
 
+// FirstLine returns the subject of a commit message.
+func FirstLine(message string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return subject
+}
+
 func GetTimestamp() string {
 	return time.Now().Format(time.RFC3339)
 }
 
+// ParseDuration extends time.ParseDuration with day (`d`) and week (`w`) units, e.g. "14d" or "2w".
+func ParseDuration(value string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if number, found := strings.CutSuffix(value, suffix); found {
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 0 {
+				return 0, errors.New("invalid duration: " + value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// FormatBytes converts a size in bytes to a human-readable string, e.g. "1.5 MB".
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// ParseSize reads a size in bytes with an optional unit, e.g. "512KB" or "100MB". Units are powers
+// of 1024 like those of FormatBytes.
+func ParseSize(value string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40}, {"B", 1}}
+	number, unit := strings.ToUpper(strings.TrimSpace(value)), int64(1)
+	for _, u := range units {
+		if n, found := strings.CutSuffix(number, u.suffix); found {
+			number, unit = strings.TrimSpace(n), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid size: " + value)
+	}
+	return n * unit, nil
+}
+
 func FindIndex(arr []string, val string) int {
 	for i, v := range arr {
 		if v == val {
@@ -27,6 +27,14 @@ func runWorkdirCommand() (returnCode int, workdirContent []FileListEntry) {
 		color.Red(COMMON_RETURN_CODES[001])
 		return 001, nil
 	}
+
+	release, err := AcquireRepoLock(SharedLock)
+	if err != nil {
+		Debug("%s", COMMON_RETURN_CODES[005])
+		color.Red(COMMON_RETURN_CODES[005])
+		return 005, nil
+	}
+	defer release()
 	commitId := GetLastCommit().Id
 	if commitId == "" {
 		color.Cyan("No commits yet")